@@ -2,20 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	nethttp "net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	// Keep time for context timeout if needed, but attempted removes it. Sticking with attempted's context.Background()
 	"internal-dns/configs"
+	"internal-dns/internal/domain"
 	"internal-dns/internal/infrastructure/cache"
 	"internal-dns/internal/infrastructure/database"
+	"internal-dns/internal/infrastructure/ldap"
+	"internal-dns/internal/infrastructure/metrics"
 	"internal-dns/internal/infrastructure/transport/http"
 	"internal-dns/internal/service" // Keep usecase import for service interfaces
+	"internal-dns/internal/usecase"
 	"internal-dns/pkg/bloomfilter"
+	"internal-dns/pkg/oidc"
 	"internal-dns/pkg/token"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // @title Internal DNS Server API
@@ -37,6 +49,11 @@ import (
 // @in header
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
+
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key of a service account, for the record, zone file and change set endpoints.
 func main() {
 	// Load configuration
 	cfg, err := configs.LoadConfig()
@@ -69,6 +86,8 @@ func main() {
 	userRepo := database.NewUserPostgresRepository(dbPool)
 	dnsRecordRepo := database.NewDNSRecordPostgresRepository(dbPool)
 	auditLogRepo := database.NewAuditLogPostgresRepository(dbPool)
+	policyRuleRepo := database.NewPolicyRulePostgresRepository(dbPool)
+	recordHealthRepo := database.NewRecordHealthPostgresRepository(dbPool)
 
 	// --- Bloom Filter Population (on startup) ---
 	go func() {
@@ -87,18 +106,164 @@ func main() {
 	}()
 
 	// --- JWT ---
-	tokenGenerator := token.NewJWTGenerator(cfg.JWT_SECRET_KEY)
+	// Tokens are signed with keys stored in the database, so that all servers
+	// sign with the same key and rotate it together
+	if cfg.JWT_KEY_ENCRYPTION_KEY == "" {
+		log.Fatal("JWT_KEY_ENCRYPTION_KEY is required")
+	}
+	signingKeyRepo, err := database.NewSigningKeyPostgresRepository(dbPool, cfg.JWT_KEY_ENCRYPTION_KEY)
+	if err != nil {
+		log.Fatalf("failed to set up signing keys: %v", err)
+	}
+	signingKeyService, err := service.NewSigningKeyService(signingKeyRepo, cfg.JWT_SIGNING_ALGORITHM, cfg.JWT_KEY_ROTATION_INTERVAL)
+	if err != nil {
+		log.Fatalf("invalid JWT_SIGNING_ALGORITHM: %v", err)
+	}
+	if err := signingKeyService.Rotate(ctx); err != nil {
+		log.Fatalf("failed to load signing keys: %v", err)
+	}
+	if cfg.JWT_KEY_RELOAD_INTERVAL > 0 {
+		go service.RunSigningKeyRotator(ctx, signingKeyService, cfg.JWT_KEY_RELOAD_INTERVAL)
+	}
+	tokenGenerator := token.NewJWTGenerator(signingKeyService)
 
 	// --- Cache ---
 	dnsCache := cache.NewDNSRecordCache(redisClient)
+	sessionDenylist := cache.NewSessionDenylist(redisClient)
+
+	// --- Audit Outbox ---
+	// Audit entries are queued in the transaction of the change they describe
+	// and relayed to the audit log, so that no change is stored without one
+	auditOutbox := service.NewAuditOutboxService(database.NewAuditOutboxPostgresRepository(dbPool), auditLogRepo, metrics.NewAuditMetrics(prometheus.DefaultRegisterer))
+	tx := database.NewTransactor(dbPool)
+	relayCtx, stopRelay := context.WithCancel(ctx)
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		service.RunAuditOutboxRelay(relayCtx, auditOutbox, cfg.AUDIT_OUTBOX_RELAY_INTERVAL, cfg.AUDIT_OUTBOX_MAX_BACKOFF)
+	}()
 
 	// --- Services / Use Cases ---
-	authService := service.NewAuthService(userRepo, tokenGenerator, auditLogRepo)
-	userService := service.NewUserService(userRepo, auditLogRepo)
-	dnsRecordService := service.NewDNSRecordService(dnsRecordRepo, bf, dnsCache, auditLogRepo)
+	refreshTokenRepo := database.NewRefreshTokenPostgresRepository(dbPool)
+	userIdentityRepo := database.NewUserIdentityPostgresRepository(dbPool)
+	// Password logins try the configured backends in order
+	var authenticators []usecase.Authenticator
+	for _, backend := range cfg.AUTH_BACKENDS {
+		switch backend {
+		case service.AuthenticatorLocal:
+			authenticators = append(authenticators, service.NewLocalAuthenticator(userRepo))
+		case service.AuthenticatorLDAP:
+			directory := ldap.NewDirectory(ldap.Config{
+				URL:                cfg.LDAP_URL,
+				StartTLS:           cfg.LDAP_START_TLS,
+				BindDN:             cfg.LDAP_BIND_DN,
+				BindPassword:       cfg.LDAP_BIND_PASSWORD,
+				UserBaseDN:         cfg.LDAP_USER_BASE_DN,
+				UserFilter:         cfg.LDAP_USER_FILTER,
+				UsernameAttribute:  cfg.LDAP_USERNAME_ATTRIBUTE,
+				GroupBaseDN:        cfg.LDAP_GROUP_BASE_DN,
+				GroupFilter:        cfg.LDAP_GROUP_FILTER,
+				GroupNameAttribute: cfg.LDAP_GROUP_NAME_ATTRIBUTE,
+				Timeout:            cfg.LDAP_TIMEOUT,
+			})
+			roles := domain.RoleMapping{AdminGroups: cfg.LDAP_ADMIN_GROUPS, UserGroups: cfg.LDAP_USER_GROUPS}
+			authenticators = append(authenticators, service.NewLDAPAuthenticator(directory, roles, userRepo, userIdentityRepo, auditOutbox, tx))
+		default:
+			log.Fatalf("unknown authentication backend %q", backend)
+		}
+	}
+	mfaRepo, err := database.NewMFAPostgresRepository(dbPool, cfg.JWT_KEY_ENCRYPTION_KEY)
+	if err != nil {
+		log.Fatalf("failed to create MFA repository: %v", err)
+	}
+	mfaService := service.NewMFAService(mfaRepo, userRepo, auditOutbox, tx, cfg.MFA_ISSUER)
+	// Failed logins are throttled per username and per client IP
+	var loginThrottle cache.LoginThrottle
+	if cfg.LOGIN_THROTTLE_ENABLED {
+		loginThrottle = cache.NewLoginThrottle(redisClient)
+	}
+	loginThrottling := domain.LoginThrottling{
+		Username: domain.LoginThrottlePolicy{
+			FreeAttempts:     cfg.LOGIN_THROTTLE_USERNAME_FREE_ATTEMPTS,
+			BaseDelay:        cfg.LOGIN_THROTTLE_BASE_DELAY,
+			MaxDelay:         cfg.LOGIN_THROTTLE_MAX_DELAY,
+			LockoutThreshold: cfg.LOGIN_LOCKOUT_USERNAME_THRESHOLD,
+			LockoutDuration:  cfg.LOGIN_LOCKOUT_DURATION,
+		},
+		IP: domain.LoginThrottlePolicy{
+			FreeAttempts:     cfg.LOGIN_THROTTLE_IP_FREE_ATTEMPTS,
+			BaseDelay:        cfg.LOGIN_THROTTLE_BASE_DELAY,
+			MaxDelay:         cfg.LOGIN_THROTTLE_MAX_DELAY,
+			LockoutThreshold: cfg.LOGIN_LOCKOUT_IP_THRESHOLD,
+			LockoutDuration:  cfg.LOGIN_LOCKOUT_DURATION,
+		},
+	}
+	mfaChallenges := cache.NewMFAChallengeStore(redisClient)
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, sessionDenylist, tokenGenerator, auditOutbox, authenticators, mfaService, mfaChallenges, loginThrottle, loginThrottling, tx)
+	userService := service.NewUserService(userRepo, auditOutbox, tx)
+	apiKeyService := service.NewAPIKeyService(userRepo, database.NewAPIKeyPostgresRepository(dbPool), auditOutbox, tx)
+	// Single sign-on is only offered if an identity provider is configured
+	var ssoService usecase.SSOUseCase
+	if cfg.OIDC_ISSUER_URL != "" {
+		provider := oidc.NewProvider(oidc.Config{
+			IssuerURL:     cfg.OIDC_ISSUER_URL,
+			ClientID:      cfg.OIDC_CLIENT_ID,
+			ClientSecret:  cfg.OIDC_CLIENT_SECRET,
+			RedirectURL:   cfg.OIDC_REDIRECT_URL,
+			Scopes:        cfg.OIDC_SCOPES,
+			UsernameClaim: cfg.OIDC_USERNAME_CLAIM,
+			GroupsClaim:   cfg.OIDC_GROUPS_CLAIM,
+		}, &nethttp.Client{Timeout: 10 * time.Second})
+		roles := domain.RoleMapping{AdminGroups: cfg.OIDC_ADMIN_GROUPS, UserGroups: cfg.OIDC_USER_GROUPS}
+		ssoService = service.NewSSOService(provider, roles, cache.NewSSOLoginStore(redisClient), userRepo, userIdentityRepo, refreshTokenRepo, tokenGenerator, auditOutbox, mfaService, mfaChallenges, tx)
+	}
+	dnsRecordService := service.NewDNSRecordService(dnsRecordRepo, bf, dnsCache, auditOutbox, tx)
+	policyRuleService := service.NewPolicyRuleService(policyRuleRepo, auditOutbox, tx)
+	recordHealthService := service.NewRecordHealthService(dnsRecordRepo, recordHealthRepo, dnsCache, auditOutbox, tx)
+	trafficPolicyService := service.NewTrafficPolicyService(dnsRecordRepo, dnsCache, auditOutbox, tx)
+	zoneService := service.NewZoneService(dnsRecordRepo, bf, dnsCache, auditOutbox, tx)
+	bulkRecordService := service.NewBulkRecordService(dnsRecordRepo, bf, dnsCache, auditOutbox, tx)
+	changeSetService := service.NewChangeSetService(dnsRecordRepo, bf, dnsCache, auditOutbox, tx)
+	recordHistoryService := service.NewRecordHistoryService(dnsRecordRepo, userRepo, bf, dnsCache, auditOutbox, tx)
+	auditLogService := service.NewAuditLogService(auditLogRepo)
+	auditArchiveRepo := database.NewAuditArchivePostgresRepository(dbPool)
+	auditChainService := service.NewAuditChainService(auditLogRepo, database.NewAuditCheckpointPostgresRepository(dbPool), auditArchiveRepo, []byte(cfg.AUDIT_CHECKPOINT_KEY))
+	auditRetention, err := domain.ParseAuditRetention(cfg.AUDIT_RETENTION)
+	if err != nil {
+		log.Fatalf("invalid AUDIT_RETENTION: %v", err)
+	}
+	auditArchiveService := service.NewAuditArchiveService(auditArchiveRepo, auditRetention, cfg.AUDIT_ARCHIVE_DIR, []byte(cfg.AUDIT_CHECKPOINT_KEY))
+
+	// --- Audit Checkpoints ---
+	if cfg.AUDIT_CHECKPOINT_KEY != "" && cfg.AUDIT_CHECKPOINT_INTERVAL > 0 {
+		go service.RunAuditCheckpointer(ctx, auditChainService, cfg.AUDIT_CHECKPOINT_INTERVAL)
+	}
+
+	// --- Audit Retention ---
+	// The archiver also creates the monthly partitions of the audit log
+	if cfg.AUDIT_ARCHIVE_INTERVAL > 0 {
+		go service.RunAuditArchiver(ctx, auditArchiveService, cfg.AUDIT_ARCHIVE_INTERVAL)
+	}
+
+	// --- Refresh Token Purging ---
+	if cfg.REFRESH_TOKEN_PURGE_INTERVAL > 0 {
+		go service.RunRefreshTokenPurger(ctx, authService, cfg.REFRESH_TOKEN_PURGE_INTERVAL)
+	}
+
+	// --- Trash Purging ---
+	if cfg.TRASH_PURGE_INTERVAL > 0 {
+		go service.RunTrashPurger(ctx, dnsRecordService, cfg.TRASH_RETENTION, cfg.TRASH_PURGE_INTERVAL)
+	}
 
 	// Setup Echo HTTP server
 	e := echo.New()
+	// Client IPs throttle logins, so they are only taken from headers set
+	// by the configured proxies
+	ipExtractor, err := http.NewIPExtractor(cfg.TRUSTED_PROXIES)
+	if err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	e.IPExtractor = ipExtractor
 
 	// Middleware
 	e.Use(middleware.Logger())
@@ -106,16 +271,33 @@ func main() {
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
 		AllowMethods: []string{echo.POST, echo.GET, echo.PUT, echo.DELETE},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "X-API-Key"},
 	}))
 
 	// Register routes
-	http.RegisterRoutes(e, cfg, authService, userService, dnsRecordService, userRepo, tokenGenerator)
+	http.RegisterRoutes(e, cfg, authService, userService, dnsRecordService, policyRuleService, recordHealthService, trafficPolicyService, zoneService, bulkRecordService, changeSetService, recordHistoryService, auditLogService, auditChainService, auditArchiveService, signingKeyService, apiKeyService, ssoService, mfaService, userRepo, tokenGenerator, sessionDenylist)
 
 	// Start server
 	serverAddr := fmt.Sprintf(":%s", cfg.API_PORT)
-	log.Printf("Starting API server on %s", serverAddr)
-	if err := e.Start(serverAddr); err != nil {
-		e.Logger.Fatal(err)
+	go func() {
+		log.Printf("Starting API server on %s", serverAddr)
+		if err := e.Start(serverAddr); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// Wait for a termination signal and shut down gracefully
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down API server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("API server shutdown error: %v", err)
 	}
+	// Requests have finished queueing audit entries; flush them
+	stopRelay()
+	<-relayDone
 }
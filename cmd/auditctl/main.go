@@ -0,0 +1,109 @@
+// Command auditctl verifies and archives the audit log directly in the
+// database, so that neither depends on the API server. It reads the same environment
+// as the API server (DB_URL, AUDIT_CHECKPOINT_KEY, AUDIT_RETENTION,
+// AUDIT_ARCHIVE_DIR).
+//
+//	auditctl verify       exit status 1 if the chain is broken
+//	auditctl checkpoint   sign the newest entry
+//	auditctl archive      archive and remove expired entries
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"internal-dns/configs"
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/database"
+	"internal-dns/internal/service"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  auditctl verify\n  auditctl checkpoint\n  auditctl archive\n")
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		fatalf("failed to load config: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	dbPool, err := database.NewPostgresPool(ctx, cfg.DB_URL)
+	if err != nil {
+		fatalf("failed to connect to database: %v", err)
+	}
+	defer dbPool.Close()
+
+	archiveRepo := database.NewAuditArchivePostgresRepository(dbPool)
+	chain := service.NewAuditChainService(
+		database.NewAuditLogPostgresRepository(dbPool),
+		database.NewAuditCheckpointPostgresRepository(dbPool),
+		archiveRepo,
+		[]byte(cfg.AUDIT_CHECKPOINT_KEY),
+	)
+
+	switch flag.Arg(0) {
+	case "verify":
+		if cfg.AUDIT_CHECKPOINT_KEY == "" {
+			fmt.Fprintln(os.Stderr, "auditctl: AUDIT_CHECKPOINT_KEY is not set, checkpoints are not verified")
+		}
+		report, err := chain.VerifyChain(ctx)
+		if err != nil {
+			fatalf("verification failed: %v", err)
+		}
+		fmt.Printf("%d entries verified (IDs %d to %d), %d checkpoints, %d gaps of archived entries, %d unhashed entries before the chain\n",
+			report.Checked, report.FirstID, report.LastID, report.Checkpoints, report.Gaps, report.Unhashed)
+		if !report.Valid {
+			b := report.Broken
+			if b.CheckpointID != 0 {
+				fmt.Printf("BROKEN at entry %d (checkpoint %d): %s\n", b.LogID, b.CheckpointID, b.Reason)
+			} else {
+				fmt.Printf("BROKEN at entry %d: %s\n", b.LogID, b.Reason)
+			}
+			dbPool.Close()
+			os.Exit(1)
+		}
+		fmt.Println("OK")
+	case "checkpoint":
+		cp, err := chain.CreateCheckpoint(ctx)
+		if err != nil {
+			fatalf("failed to create checkpoint: %v", err)
+		}
+		fmt.Printf("checkpoint %d at entry %d: %s\n", cp.ID, cp.LogID, cp.Hash)
+	case "archive":
+		retention, err := domain.ParseAuditRetention(cfg.AUDIT_RETENTION)
+		if err != nil {
+			fatalf("invalid AUDIT_RETENTION: %v", err)
+		}
+		archiver := service.NewAuditArchiveService(archiveRepo, retention, cfg.AUDIT_ARCHIVE_DIR, []byte(cfg.AUDIT_CHECKPOINT_KEY))
+		run, err := archiver.Run(ctx)
+		for _, a := range run.Archives {
+			action := "archived"
+			if a.Dropped {
+				action = "archived and dropped"
+			}
+			fmt.Printf("%s: %d entries %s to %s\n", a.Partition, a.Entries, action, a.File)
+		}
+		if err != nil {
+			fatalf("archiving failed: %v", err)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "auditctl: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -2,14 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"internal-dns/configs"
 	"internal-dns/internal/infrastructure/cache"
 	"internal-dns/internal/infrastructure/database"
+	"internal-dns/internal/infrastructure/healthcheck"
+	"internal-dns/internal/infrastructure/metrics"
+	"internal-dns/internal/infrastructure/querylog"
+	"internal-dns/internal/infrastructure/rpz"
 	dnsTransport "internal-dns/internal/infrastructure/transport/dns"
 	"internal-dns/internal/service"
+	"internal-dns/internal/util"
 	"internal-dns/pkg/bloomfilter"
 )
 
@@ -41,22 +52,187 @@ func main() {
 	dnsRecordRepo := database.NewDNSRecordPostgresRepository(dbPool)
 	// dnsRecordRepo := database.NewDNSRecordInMemoryRepository()
 	auditLogRepo := database.NewAuditLogPostgresRepository(dbPool) // Added auditLogRepo
+	policyRuleRepo := database.NewPolicyRulePostgresRepository(dbPool)
+	recordHealthRepo := database.NewRecordHealthPostgresRepository(dbPool)
 
 	// Initialize Bloom Filter (needed for service, though not directly used by DNS server logic)
 	bf := bloomfilter.NewRedisBloomFilter(redisClient, "dns_domains_bloom", cfg.BLOOM_FILTER_SIZE, cfg.BLOOM_FILTER_HASHES)
 
 	// Initialize Cache
 	dnsCache := cache.NewDNSRecordCache(redisClient)
-	
+
 	// Initialize Service
-	dnsRecordService := service.NewDNSRecordService(dnsRecordRepo, bf, dnsCache, auditLogRepo) // Passed auditLogRepo
+	dnsRecordService := service.NewDNSRecordService(dnsRecordRepo, bf, dnsCache, auditLogRepo, database.NewTransactor(dbPool)) // Passed auditLogRepo
+
+	// Initialize metrics
+	metricsRegistry := metrics.NewRegistry()
+	dnsMetrics := metrics.NewDNSMetrics(metricsRegistry)
+
+	metricsAddr := fmt.Sprintf(":%s", cfg.DNS_METRICS_PORT)
+	metricsServer := metrics.NewServer(metricsAddr, metricsRegistry)
+	go func() {
+		log.Printf("Serving DNS metrics on %s/metrics", metricsAddr)
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start metrics server: %v", err)
+		}
+	}()
+
+	// Initialize query log
+	var queryLogger *querylog.Logger
+	if cfg.QUERY_LOG_ENABLED {
+		sinks, err := newQueryLogSinks(cfg)
+		if err != nil {
+			log.Fatalf("failed to initialize query log: %v", err)
+		}
+		queryLogger = querylog.New(querylog.Config{
+			SampleRate: cfg.QUERY_LOG_SAMPLE_RATE,
+			BufferSize: cfg.QUERY_LOG_BUFFER_SIZE,
+		}, dnsMetrics.QueryLogDropped, sinks...)
+	}
+
+	serverOpts := []dnsTransport.Option{
+		dnsTransport.WithMetrics(dnsMetrics),
+		dnsTransport.WithQueryLog(queryLogger),
+	}
+
+	// Initialize response rate limiting
+	if cfg.DNS_RRL_ENABLED {
+		whitelist, err := util.ParseNetworks(cfg.DNS_RRL_WHITELIST)
+		if err != nil {
+			log.Fatalf("invalid DNS_RRL_WHITELIST: %v", err)
+		}
+		serverOpts = append(serverOpts, dnsTransport.WithRateLimiter(dnsTransport.NewRateLimiter(dnsTransport.RateLimitConfig{
+			ResponsesPerSecond: cfg.DNS_RRL_RESPONSES_PER_SECOND,
+			ErrorsPerSecond:    cfg.DNS_RRL_ERRORS_PER_SECOND,
+			Burst:              cfg.DNS_RRL_BURST,
+			Slip:               cfg.DNS_RRL_SLIP,
+			IPv4PrefixLen:      cfg.DNS_RRL_IPV4_PREFIX,
+			IPv6PrefixLen:      cfg.DNS_RRL_IPV6_PREFIX,
+			Whitelist:          whitelist,
+			TTL:                cfg.DNS_RRL_TTL,
+		})))
+	}
+
+	// Initialize access control lists and recursion
+	acls, err := dnsTransport.NewACLStore(cfg.DNS_ACL_FILE)
+	if err != nil {
+		log.Fatalf("failed to load ACL file: %v", err)
+	}
+	serverOpts = append(serverOpts, dnsTransport.WithACL(acls))
+	if len(cfg.DNS_FORWARDERS) > 0 {
+		serverOpts = append(serverOpts, dnsTransport.WithForwarder(dnsTransport.NewForwarder(cfg.DNS_FORWARDERS, cfg.DNS_FORWARD_TIMEOUT)))
+	}
+
+	// Stops background reloaders (ACL file, policy rules, health checks) on shutdown
+	reloadCtx, stopReloaders := context.WithCancel(ctx)
+	defer stopReloaders()
+	go acls.Watch(reloadCtx, cfg.DNS_ACL_RELOAD_INTERVAL)
+
+	// Reload the ACL file immediately on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := acls.Reload(); err != nil {
+				log.Printf("Failed to reload ACL file, keeping previous ACL: %v", err)
+				continue
+			}
+			log.Println("Reloaded ACL file")
+		}
+	}()
+
+	// Initialize response policy; admin-managed rules take precedence over zone files
+	if cfg.POLICY_ENABLED {
+		sources := []rpz.Source{rpz.NewRepositorySource(policyRuleRepo)}
+		for _, path := range cfg.POLICY_ZONE_FILES {
+			sources = append(sources, rpz.NewZoneFileSource(path))
+		}
+		policyEngine := rpz.NewEngine(sources...)
+		if err := policyEngine.Refresh(ctx); err != nil {
+			log.Printf("Failed to load some policy rules: %v", err)
+		}
+		log.Printf("Loaded %d policy rules", policyEngine.Size())
+		serverOpts = append(serverOpts, dnsTransport.WithPolicy(policyEngine))
+		go policyEngine.Run(reloadCtx, cfg.POLICY_REFRESH_INTERVAL)
+	}
+
+	// Initialize health checks of records with failover addresses
+	if cfg.HEALTH_CHECKS_ENABLED {
+		checker := healthcheck.NewChecker(dnsRecordRepo, recordHealthRepo)
+		serverOpts = append(serverOpts, dnsTransport.WithHealth(checker))
+		go checker.Run(reloadCtx, cfg.HEALTH_CHECK_REFRESH_INTERVAL)
+	}
+
+	// Initialize query statistics
+	statsCtx, stopStats := context.WithCancel(ctx)
+	statsDone := make(chan struct{})
+	if cfg.QUERY_STATS_ENABLED {
+		queryStats := service.NewQueryStatsService(cache.NewQueryStatsStore(redisClient), dnsRecordRepo)
+		serverOpts = append(serverOpts, dnsTransport.WithQueryStats(queryStats))
+		go func() {
+			defer close(statsDone)
+			service.RunQueryStatsFlusher(statsCtx, queryStats, cfg.QUERY_STATS_REDIS_FLUSH_INTERVAL, cfg.QUERY_STATS_DB_FLUSH_INTERVAL)
+		}()
+	} else {
+		close(statsDone)
+	}
 
 	// Initialize and start DNS server
 	dnsServerAddr := fmt.Sprintf(":%s", cfg.DNS_PORT)
-	server := dnsTransport.NewServer(dnsServerAddr, dnsRecordService, dnsCache)
+	server := dnsTransport.NewServer(dnsServerAddr, dnsRecordService, dnsCache, serverOpts...)
+
+	go func() {
+		log.Printf("Starting DNS server on %s", dnsServerAddr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Fatalf("Failed to start DNS server: %v", err)
+		}
+	}()
+
+	// Wait for a termination signal and shut down gracefully
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down DNS server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("DNS server shutdown error: %v", err)
+	}
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Metrics server shutdown error: %v", err)
+	}
+	if err := queryLogger.Close(); err != nil {
+		log.Printf("Query log shutdown error: %v", err)
+	}
+	stopStats()
+	<-statsDone
+}
 
-	log.Printf("Starting DNS server on %s", dnsServerAddr)
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Failed to start DNS server: %v", err)
+// newQueryLogSinks creates the query log sinks listed in QUERY_LOG_SINKS.
+func newQueryLogSinks(cfg *configs.Config) ([]querylog.Sink, error) {
+	var sinks []querylog.Sink
+	for _, name := range cfg.QUERY_LOG_SINKS {
+		var sink querylog.Sink
+		var err error
+		switch name {
+		case "stdout":
+			sink = querylog.NewJSONSink(os.Stdout)
+		case "file":
+			sink, err = querylog.NewFileSink(cfg.QUERY_LOG_FILE)
+		case "dnstap":
+			if cfg.QUERY_LOG_DNSTAP_SOCKET != "" {
+				sink, err = querylog.DialDnstapSink("unix", cfg.QUERY_LOG_DNSTAP_SOCKET, cfg.QUERY_LOG_DNSTAP_IDENTITY, "")
+			} else {
+				sink, err = querylog.NewDnstapFileSink(cfg.QUERY_LOG_DNSTAP_FILE, cfg.QUERY_LOG_DNSTAP_IDENTITY, "")
+			}
+		default:
+			err = fmt.Errorf("unknown query log sink %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
 	}
+	return sinks, nil
 }
@@ -0,0 +1,191 @@
+// Command zonectl imports and exports BIND zone files through the API.
+//
+//	zonectl [flags] import <zone> <file>   (file "-" reads stdin)
+//	zonectl [flags] export <zone>
+//	zonectl [flags] export-mine
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+type issue struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+type change struct {
+	Line             int      `json:"line"`
+	Action           string   `json:"action"`
+	DomainName       string   `json:"domainName"`
+	Type             string   `json:"type"`
+	Value            string   `json:"value"`
+	AdditionalValues []string `json:"additionalValues"`
+	PreviousType     string   `json:"previousType"`
+	PreviousValue    string   `json:"previousValue"`
+}
+
+type importResult struct {
+	Zone     string   `json:"zone"`
+	DryRun   bool     `json:"dryRun"`
+	Applied  bool     `json:"applied"`
+	Changes  []change `json:"changes"`
+	Errors   []issue  `json:"errors"`
+	Warnings []issue  `json:"warnings"`
+}
+
+type client struct {
+	api   string
+	token string
+	http  *http.Client
+}
+
+func main() {
+	api := flag.String("api", envOr("DNS_API_URL", "http://localhost:8080/api/v1"), "API base URL (DNS_API_URL)")
+	token := flag.String("token", os.Getenv("DNS_API_TOKEN"), "bearer token (DNS_API_TOKEN)")
+	dryRun := flag.Bool("dry-run", false, "import: only show the diff")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  zonectl [flags] import <zone> <file|->\n  zonectl [flags] export <zone>\n  zonectl [flags] export-mine\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *token == "" {
+		fatalf("a token is required (-token or DNS_API_TOKEN)")
+	}
+	c := &client{api: *api, token: *token, http: &http.Client{Timeout: time.Minute}}
+
+	args := flag.Args()
+	switch {
+	case len(args) == 3 && args[0] == "import":
+		os.Exit(c.importZone(args[1], args[2], *dryRun))
+	case len(args) == 2 && args[0] == "export":
+		c.export("/zones/" + url.PathEscape(args[1]) + "/export")
+	case len(args) == 1 && args[0] == "export-mine":
+		c.export("/dns-records/zonefile")
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// importZone uploads a zone file and prints the diff, warnings and errors.
+// It returns the exit status: 1 if the file has errors.
+func (c *client) importZone(zone, path string, dryRun bool) int {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fatalf("failed to read zone file: %v", err)
+	}
+
+	endpoint := c.api + "/zones/" + url.PathEscape(zone) + "/import?dryRun=" + strconv.FormatBool(dryRun)
+	resp, body := c.do(http.MethodPost, endpoint, "text/plain", bytes.NewReader(content))
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnprocessableEntity {
+		fatalf("import failed: %s", apiError(resp, body))
+	}
+
+	var result importResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		fatalf("invalid response: %v", err)
+	}
+
+	counts := map[string]int{}
+	for _, ch := range result.Changes {
+		counts[ch.Action]++
+		switch ch.Action {
+		case "create":
+			fmt.Printf("+ %-40s %-5s %s\n", ch.DomainName, ch.Type, values(ch))
+		case "update":
+			fmt.Printf("~ %-40s %-5s %s (was %s %s)\n", ch.DomainName, ch.Type, values(ch), ch.PreviousType, ch.PreviousValue)
+		}
+	}
+	for _, w := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "%s:%d: warning: %s\n", path, w.Line, w.Message)
+	}
+	for _, e := range result.Errors {
+		fmt.Fprintf(os.Stderr, "%s:%d: error: %s\n", path, e.Line, e.Message)
+	}
+
+	summary := fmt.Sprintf("%d to create, %d to update, %d unchanged", counts["create"], counts["update"], counts["unchanged"])
+	switch {
+	case len(result.Errors) > 0:
+		fmt.Printf("%s; not applied: %d error(s)\n", summary, len(result.Errors))
+		return 1
+	case result.Applied:
+		fmt.Printf("%s; applied\n", summary)
+	default:
+		fmt.Printf("%s; dry run\n", summary)
+	}
+	return 0
+}
+
+func (c *client) export(path string) {
+	resp, body := c.do(http.MethodGet, c.api+path, "", nil)
+	if resp.StatusCode != http.StatusOK {
+		fatalf("export failed: %s", apiError(resp, body))
+	}
+	os.Stdout.Write(body)
+}
+
+func (c *client) do(method, endpoint, contentType string, body io.Reader) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		fatalf("invalid request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fatalf("failed to read response: %v", err)
+	}
+	return resp, data
+}
+
+func values(ch change) string {
+	s := ch.Value
+	for _, v := range ch.AdditionalValues {
+		s += "," + v
+	}
+	return s
+}
+
+func apiError(resp *http.Response, body []byte) string {
+	var e map[string]string
+	if json.Unmarshal(body, &e) == nil && e["error"] != "" {
+		return fmt.Sprintf("%s (%s)", e["error"], resp.Status)
+	}
+	return resp.Status
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "zonectl: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -1,5 +1,5 @@
-import type { LoginRequest, RegisterRequest, AuthResponse } from '../types';
-import { axiosPublic } from './axios';
+import type { LoginRequest, RegisterRequest, AuthResponse, TOTPProvisioning } from '../types';
+import { axiosPublic, axiosPrivate } from './axios';
 
 export const registerUser = async (data: RegisterRequest): Promise<void> => {
   await axiosPublic.post('/auth/register', data);
@@ -10,3 +10,16 @@ export const loginUser = async (data: LoginRequest): Promise<AuthResponse> => {
   return response.data;
 };
 
+export const enrollMFA = async (mfaToken: string): Promise<TOTPProvisioning> => {
+  const response = await axiosPublic.post<TOTPProvisioning>('/auth/mfa/enroll', { mfaToken });
+  return response.data;
+};
+
+export const verifyMFA = async (mfaToken: string, code: string): Promise<AuthResponse> => {
+  const response = await axiosPublic.post<AuthResponse>('/auth/mfa/verify', { mfaToken, code });
+  return response.data;
+};
+
+export const logoutUser = async (): Promise<void> => {
+  await axiosPrivate.post('/auth/logout');
+};
@@ -24,38 +24,44 @@ axiosPrivate.interceptors.request.use(
   (error) => Promise.reject(error)
 );
 
+// Refresh tokens can be used once, so concurrent requests that fail with 401
+// share a single refresh
+let refreshing: Promise<string> | null = null;
+
+const refreshAccessToken = (): Promise<string> => {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refreshToken');
+    refreshing = (refreshToken
+      ? axiosPublic.post('/auth/refresh', { refreshToken }).then(({ data }) => {
+          localStorage.setItem('accessToken', data.accessToken);
+          localStorage.setItem('refreshToken', data.refreshToken);
+          return data.accessToken as string;
+        })
+      : Promise.reject(new Error('No refresh token'))
+    ).finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+};
+
 axiosPrivate.interceptors.response.use(
   (response) => response,
   async (error) => {
     const originalRequest = error.config;
-    if (error.response.status === 401 && !originalRequest._retry) {
+    if (error.response?.status === 401 && !originalRequest._retry) {
       originalRequest._retry = true;
-      // const refreshToken = localStorage.getItem('refreshToken');
-      // if (refreshToken) {
-      //   try {
-      //     // This endpoint does not exist yet, but is planned for JWT refresh
-      //     // For now, we'll simulate a failure and logout
-      //     // const { data } = await axiosPublic.post('/auth/refresh', { refreshToken });
-      //     // localStorage.setItem('accessToken', data.accessToken);
-      //     // originalRequest.headers['Authorization'] = `Bearer ${data.accessToken}`;
-      //     // return axiosPrivate(originalRequest);
-          
-      //     // Placeholder for refresh logic: for now, just log out
-      //     console.error("Access token expired, refresh mechanism not implemented. Logging out.");
-      //     localStorage.removeItem('accessToken');
-      //     localStorage.removeItem('refreshToken');
-      //     window.location.href = '/login';
-      //     return Promise.reject(error);
-
-      //   } catch (refreshError) {
-      //     localStorage.removeItem('accessToken');
-      //     localStorage.removeItem('refreshToken');
-      //     window.location.href = '/login';
-      //     return Promise.reject(refreshError);
-      //   }
-      // }
+      try {
+        const accessToken = await refreshAccessToken();
+        originalRequest.headers['Authorization'] = `Bearer ${accessToken}`;
+        return axiosPrivate(originalRequest);
+      } catch (refreshError) {
+        localStorage.removeItem('accessToken');
+        localStorage.removeItem('refreshToken');
+        window.location.href = '/login';
+        return Promise.reject(refreshError);
+      }
     }
     return Promise.reject(error);
   }
 );
-
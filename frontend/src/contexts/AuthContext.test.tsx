@@ -20,11 +20,12 @@ jest.mock('jwt-decode', () => ({
 }));
 
 const TestComponent = () => {
-  const { user, login, logout, register } = useAuth();
+  const { user, login, verifyMFA, logout, register } = useAuth();
   return (
     <div>
       <div data-testid="user">{user ? user.Username : 'null'}</div>
       <button onClick={() => login('testuser', 'password')}>Login</button>
+      <button onClick={() => verifyMFA('mfa-token', '123456')}>Verify</button>
       <button onClick={() => logout()}>Logout</button>
       <button onClick={() => register('newuser', 'password')}>Register</button>
     </div>
@@ -58,6 +59,33 @@ describe('AuthContext', () => {
     expect(localStorage.getItem('refreshToken')).toBe('fake-refresh-token');
   });
 
+  test('login with a second factor gets the tokens at verifyMFA', async () => {
+    mockedAuthApi.loginUser.mockResolvedValue({ mfaRequired: true, mfaToken: 'mfa-token' });
+    mockedAuthApi.verifyMFA.mockResolvedValue({
+      accessToken: 'fake-access-token',
+      refreshToken: 'fake-refresh-token',
+    });
+
+    render(
+      <AuthProvider>
+        <TestComponent />
+      </AuthProvider>
+    );
+
+    await act(async () => {
+      screen.getByText('Login').click();
+    });
+    expect(screen.getByTestId('user')).toHaveTextContent('null');
+    expect(localStorage.getItem('accessToken')).toBeNull();
+
+    await act(async () => {
+      screen.getByText('Verify').click();
+    });
+    expect(mockedAuthApi.verifyMFA).toHaveBeenCalledWith('mfa-token', '123456');
+    expect(screen.getByTestId('user')).toHaveTextContent('testuser');
+    expect(localStorage.getItem('accessToken')).toBe('fake-access-token');
+  });
+
   test('logout clears user state and localStorage', async () => {
     // First, log in
     mockedAuthApi.loginUser.mockResolvedValue({
@@ -75,10 +103,12 @@ describe('AuthContext', () => {
     expect(screen.getByTestId('user')).toHaveTextContent('testuser');
 
     // Then, log out
+    mockedAuthApi.logoutUser.mockResolvedValue();
     await act(async () => {
       screen.getByText('Logout').click();
     });
 
+    expect(mockedAuthApi.logoutUser).toHaveBeenCalled();
     expect(screen.getByTestId('user')).toHaveTextContent('null');
     expect(localStorage.getItem('accessToken')).toBeNull();
     expect(localStorage.getItem('refreshToken')).toBeNull();
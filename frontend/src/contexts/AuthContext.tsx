@@ -1,5 +1,5 @@
 import React, { createContext, useState, useEffect, type ReactNode, useContext } from 'react';
-import type { AuthContextType, User, LoginRequest, RegisterRequest, AuthTokens } from '../types';
+import type { AuthContextType, User, LoginRequest, RegisterRequest, AuthTokens, AuthResponse, MFAChallenge, TOTPProvisioning } from '../types';
 import * as authApi from '../api/authApi';
 import { jwtDecode } from 'jwt-decode';
 
@@ -48,12 +48,32 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
     setLoading(false);
   }, [tokens]);
 
-  const login = async (username: string, password: string): Promise<void> => {
-    const loginData: LoginRequest = { Username: username, Password: password };
-    const response = await authApi.loginUser(loginData);
+  const startSession = (response: AuthResponse) => {
+    if (!response.accessToken || !response.refreshToken) {
+      throw new Error('The login did not return tokens');
+    }
     localStorage.setItem('accessToken', response.accessToken);
     localStorage.setItem('refreshToken', response.refreshToken);
-    setTokens(response);
+    setTokens({ accessToken: response.accessToken, refreshToken: response.refreshToken });
+  };
+
+  const login = async (username: string, password: string): Promise<MFAChallenge | null> => {
+    const loginData: LoginRequest = { Username: username, Password: password };
+    const response = await authApi.loginUser(loginData);
+    // Users with a second factor, and all admins, get tokens at verifyMFA
+    if (response.mfaRequired && response.mfaToken) {
+      return { mfaToken: response.mfaToken, enrollmentRequired: !!response.mfaEnrollmentRequired };
+    }
+    startSession(response);
+    return null;
+  };
+
+  const enrollMFA = (mfaToken: string): Promise<TOTPProvisioning> => authApi.enrollMFA(mfaToken);
+
+  const verifyMFA = async (mfaToken: string, code: string): Promise<string[]> => {
+    const response = await authApi.verifyMFA(mfaToken, code);
+    startSession(response);
+    return response.recoveryCodes ?? [];
   };
 
   const register = async (username: string, password: string): Promise<void> => {
@@ -62,13 +82,17 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
   };
 
   const logout = () => {
-    // setUser(null);
-    // setTokens(null);
-    // localStorage.removeItem('accessToken');
-    // localStorage.removeItem('refreshToken');
+    // Revoke the session on the server; the tokens are discarded either way
+    if (localStorage.getItem('accessToken')) {
+      Promise.resolve(authApi.logoutUser()).catch((error) => console.error('Failed to log out:', error));
+    }
+    setUser(null);
+    setTokens(null);
+    localStorage.removeItem('accessToken');
+    localStorage.removeItem('refreshToken');
   };
 
-  const value = { user, tokens, login, logout, register, loading };
+  const value = { user, tokens, login, enrollMFA, verifyMFA, logout, register, loading };
 
   return <AuthContext.Provider value={value}>{children}</AuthContext.Provider>;
 };
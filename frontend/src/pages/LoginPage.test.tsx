@@ -5,16 +5,22 @@ import { AuthProvider } from '../contexts/AuthContext';
 
 // Mock the useAuth hook
 const mockLogin = jest.fn();
+const mockEnrollMFA = jest.fn();
+const mockVerifyMFA = jest.fn();
 jest.mock('../hooks/useAuth', () => ({
   useAuth: () => ({
     login: mockLogin,
+    enrollMFA: mockEnrollMFA,
+    verifyMFA: mockVerifyMFA,
     user: null,
   }),
 }));
 
 describe('LoginPage', () => {
   beforeEach(() => {
-    mockLogin.mockClear();
+    mockLogin.mockReset();
+    mockEnrollMFA.mockReset();
+    mockVerifyMFA.mockReset();
   });
 
   const renderComponent = () =>
@@ -52,5 +58,42 @@ describe('LoginPage', () => {
       expect(mockLogin).toHaveBeenCalledWith('testuser', 'password123');
     });
   });
-});
 
+  const signIn = () => {
+    fireEvent.change(screen.getByLabelText(/username/i), { target: { value: 'admin' } });
+    fireEvent.change(screen.getByLabelText(/password/i), { target: { value: 'password123' } });
+    fireEvent.click(screen.getByRole('button', { name: /sign in/i }));
+  };
+
+  test('asks for the code of the second factor', async () => {
+    mockLogin.mockResolvedValue({ mfaToken: 'mfa-token', enrollmentRequired: false });
+    mockVerifyMFA.mockResolvedValue([]);
+    renderComponent();
+    signIn();
+
+    fireEvent.change(await screen.findByLabelText(/code/i), { target: { value: '123456' } });
+    fireEvent.click(screen.getByRole('button', { name: /verify/i }));
+
+    await waitFor(() => {
+      expect(mockVerifyMFA).toHaveBeenCalledWith('mfa-token', '123456');
+    });
+    expect(mockEnrollMFA).not.toHaveBeenCalled();
+  });
+
+  test('enrolls admins without a second factor and shows their recovery codes', async () => {
+    mockLogin.mockResolvedValue({ mfaToken: 'mfa-token', enrollmentRequired: true });
+    mockEnrollMFA.mockResolvedValue({ secret: 'JBSWY3DPEHPK3PXP', otpauthUri: 'otpauth://totp/internal-dns:admin' });
+    mockVerifyMFA.mockResolvedValue(['recovery-1', 'recovery-2']);
+    renderComponent();
+    signIn();
+
+    expect(await screen.findByText('JBSWY3DPEHPK3PXP')).toBeInTheDocument();
+    expect(mockEnrollMFA).toHaveBeenCalledWith('mfa-token');
+    fireEvent.change(screen.getByLabelText(/code/i), { target: { value: '123456' } });
+    fireEvent.click(screen.getByRole('button', { name: /verify/i }));
+
+    expect(await screen.findByText('recovery-1')).toBeInTheDocument();
+    expect(screen.getByText('recovery-2')).toBeInTheDocument();
+    expect(mockVerifyMFA).toHaveBeenCalledWith('mfa-token', '123456');
+  });
+});
@@ -3,13 +3,19 @@ import { useNavigate, Link } from 'react-router-dom';
 import { useAuth } from '../hooks/useAuth';
 import Input from '../components/common/Input';
 import Button from '../components/common/Button';
+import type { MFAChallenge, TOTPProvisioning } from '../types';
 
 const LoginPage: React.FC = () => {
   const [username, setUsername] = useState('');
   const [password, setPassword] = useState('');
   const [errors, setErrors] = useState<{ [key: string]: string }>({});
   const [serverError, setServerError] = useState('');
-  const { login } = useAuth();
+  // The second factor, asked for after the password
+  const [challenge, setChallenge] = useState<MFAChallenge | null>(null);
+  const [provisioning, setProvisioning] = useState<TOTPProvisioning | null>(null);
+  const [code, setCode] = useState('');
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+  const { login, enrollMFA, verifyMFA } = useAuth();
   const navigate = useNavigate();
 
   const validate = (): boolean => {
@@ -26,13 +32,107 @@ const LoginPage: React.FC = () => {
     if (!validate()) return;
 
     try {
-      await login(username, password);
-      navigate('/dashboard');
+      const mfaChallenge = await login(username, password);
+      if (!mfaChallenge) {
+        navigate('/dashboard');
+        return;
+      }
+      // Admins without a second factor enroll one before they get in
+      if (mfaChallenge.enrollmentRequired) {
+        setProvisioning(await enrollMFA(mfaChallenge.mfaToken));
+      }
+      setChallenge(mfaChallenge);
     } catch (error: any) {
       setServerError(error.response?.data?.message || 'Invalid username or password');
     }
   };
 
+  const handleVerify = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setServerError('');
+    if (!challenge) return;
+    if (!code) {
+      setErrors({ code: 'Code is required' });
+      return;
+    }
+    setErrors({});
+
+    try {
+      const codes = await verifyMFA(challenge.mfaToken, code);
+      if (codes.length > 0) {
+        setRecoveryCodes(codes);
+        return;
+      }
+      navigate('/dashboard');
+    } catch (error: any) {
+      setCode('');
+      setServerError(error.response?.data?.message || 'Invalid code');
+    }
+  };
+
+  if (recoveryCodes.length > 0) {
+    return (
+      <div className="flex items-center justify-center mt-10">
+        <div className="w-full max-w-md bg-white shadow-md rounded px-8 pt-6 pb-8 mb-4">
+          <h2 className="text-2xl font-bold text-center mb-6">Recovery Codes</h2>
+          <p className="text-gray-700 text-sm mb-4">
+            Keep these codes somewhere safe. Each of them logs you in once if you lose your authenticator app; they are
+            not shown again.
+          </p>
+          <ul className="font-mono text-center mb-6">
+            {recoveryCodes.map((recoveryCode) => (
+              <li key={recoveryCode}>{recoveryCode}</li>
+            ))}
+          </ul>
+          <Button type="button" onClick={() => navigate('/dashboard')}>
+            Continue
+          </Button>
+        </div>
+      </div>
+    );
+  }
+
+  if (challenge) {
+    return (
+      <div className="flex items-center justify-center mt-10">
+        <div className="w-full max-w-md">
+          <form onSubmit={handleVerify} className="bg-white shadow-md rounded px-8 pt-6 pb-8 mb-4">
+            <h2 className="text-2xl font-bold text-center mb-6">Two-Factor Authentication</h2>
+            {serverError && <p className="text-red-500 text-center mb-4">{serverError}</p>}
+            {provisioning ? (
+              <div className="text-gray-700 text-sm mb-4">
+                <p className="mb-2">
+                  Admins need two-factor authentication. Add this key to your authenticator app, then enter the code it
+                  shows:
+                </p>
+                <p className="font-mono break-all mb-2">{provisioning.secret}</p>
+                <a href={provisioning.otpauthUri} className="text-blue-500 hover:text-blue-800">
+                  Open in authenticator app
+                </a>
+              </div>
+            ) : (
+              <p className="text-gray-700 text-sm mb-4">
+                Enter the code from your authenticator app, or one of your recovery codes.
+              </p>
+            )}
+            <Input
+              label="Code"
+              id="code"
+              type="text"
+              autoComplete="one-time-code"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              error={errors.code}
+            />
+            <div className="flex items-center justify-between">
+              <Button type="submit">Verify</Button>
+            </div>
+          </form>
+        </div>
+      </div>
+    );
+  }
+
   return (
     <div className="flex items-center justify-center mt-10">
       <div className="w-full max-w-md">
@@ -71,4 +171,3 @@ const LoginPage: React.FC = () => {
 };
 
 export default LoginPage;
-
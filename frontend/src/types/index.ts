@@ -24,7 +24,13 @@ export interface AuthContextType {
     user: User | null;
     tokens: AuthTokens | null;
     loading: boolean;
-    login: (username: string, password: string) => Promise<void>;
+    // login resolves to the challenge of the second factor, if the user has
+    // to answer one with verifyMFA
+    login: (username: string, password: string) => Promise<MFAChallenge | null>;
+    enrollMFA: (mfaToken: string) => Promise<TOTPProvisioning>;
+    // verifyMFA resolves to the recovery codes of a login that enabled
+    // two-factor authentication, which are shown once
+    verifyMFA: (mfaToken: string, code: string) => Promise<string[]>;
     logout: () => void;
     register: (username: string, password: string) => Promise<void>;
 }
@@ -40,8 +46,22 @@ export interface LoginRequest {
 }
 
 export interface AuthResponse {
-    accessToken: string;
-    refreshToken: string;
+    accessToken?: string;
+    refreshToken?: string;
+    mfaRequired?: boolean;
+    mfaToken?: string;
+    mfaEnrollmentRequired?: boolean; // admins without a second factor enroll first
+    recoveryCodes?: string[];
+}
+
+export interface MFAChallenge {
+    mfaToken: string;
+    enrollmentRequired: boolean;
+}
+
+export interface TOTPProvisioning {
+    secret: string;
+    otpauthUri: string;
 }
 
 export interface DNSRecord {
@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// APIKeyScope is a permission granted to an API key.
+type APIKeyScope string
+
+const (
+	ScopeRecordsRead  APIKeyScope = "records:read"
+	ScopeRecordsWrite APIKeyScope = "records:write"
+)
+
+// apiKeyTokenPrefix marks API keys, so that leaked keys are easy to find.
+const apiKeyTokenPrefix = "idns_"
+
+var (
+	ErrInvalidAPIKey        = errors.New("invalid, expired or revoked API key")
+	ErrAPIKeyNotFound       = errors.New("API key not found")
+	ErrAPIKeyNameRequired   = errors.New("API key name is required")
+	ErrInvalidAPIKeyScope   = errors.New("invalid API key scope")
+	ErrInvalidAPIKeyExpiry  = errors.New("API key expiry must be in the future")
+	ErrInvalidNameSuffix    = errors.New("invalid name suffix")
+	ErrSuffixesRequireWrite = errors.New("name suffixes require the records:write scope")
+	ErrNotServiceAccount    = errors.New("user is not a service account")
+	ErrNameOutOfScope       = errors.New("domain name is outside the name suffixes of the API key")
+)
+
+// APIKey is a long-lived credential of a service account. A key reads
+// idns_<prefix>_<secret>; only a hash of the secret is stored, and the
+// prefix identifies the key.
+type APIKey struct {
+	ID         int64
+	UserID     int64
+	Name       string
+	Prefix     string
+	SecretHash string `json:"-"`
+	Scopes     []APIKeyScope
+
+	// Suffixes restricts the names that the key can write to names under
+	// one of them. Without suffixes the key can write every name of its
+	// service account.
+	Suffixes []string
+
+	ExpiresAt  *time.Time // nil if the key does not expire
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+	RotatedAt  *time.Time
+	RevokedAt  *time.Time
+}
+
+// NewAPIKey creates a key of a service account and returns it with the key
+// to hand out, which cannot be recovered later.
+func NewAPIKey(userID int64, name string, scopes []APIKeyScope, suffixes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", ErrAPIKeyNameRequired
+	}
+	if len(scopes) == 0 {
+		return nil, "", ErrInvalidAPIKeyScope
+	}
+	for _, scope := range scopes {
+		if scope != ScopeRecordsRead && scope != ScopeRecordsWrite {
+			return nil, "", ErrInvalidAPIKeyScope
+		}
+	}
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	normalized := make([]string, 0, len(suffixes))
+	for _, suffix := range suffixes {
+		suffix = strings.Trim(strings.ToLower(strings.TrimSpace(suffix)), ".")
+		if !domainNameRegex.MatchString(suffix) {
+			return nil, "", ErrInvalidNameSuffix
+		}
+		normalized = append(normalized, suffix)
+	}
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) > 0 && !slices.Contains(scopes, ScopeRecordsWrite) {
+		return nil, "", ErrSuffixesRequireWrite
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidAPIKeyExpiry
+	}
+
+	k := &APIKey{
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		Suffixes:  normalized,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	key, err := k.newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	return k, key, nil
+}
+
+// Rotate replaces the secret of the key and returns the new key. The old
+// key stops working at once.
+func (k *APIKey) Rotate() (string, error) {
+	key, err := k.newSecret()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	k.RotatedAt = &now
+	return key, nil
+}
+
+func (k *APIKey) newSecret() (string, error) {
+	b := make([]byte, 6+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	prefix := hex.EncodeToString(b[:6])
+	secret := base64.RawURLEncoding.EncodeToString(b[6:])
+	k.Prefix = prefix
+	k.SecretHash = hashAPIKeySecret(secret)
+	return apiKeyTokenPrefix + prefix + "_" + secret, nil
+}
+
+func hashAPIKeySecret(secret string) string {
+	// The secret is random, so a fast hash cannot be brute-forced
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseAPIKey splits a key into its prefix and secret.
+func ParseAPIKey(key string) (prefix, secret string, err error) {
+	rest, ok := strings.CutPrefix(key, apiKeyTokenPrefix)
+	if !ok {
+		return "", "", ErrInvalidAPIKey
+	}
+	prefix, secret, ok = strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", "", ErrInvalidAPIKey
+	}
+	return prefix, secret, nil
+}
+
+// Verify reports whether secret is the secret of the key and the key can be
+// used at now.
+func (k *APIKey) Verify(secret string, now time.Time) bool {
+	match := subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(k.SecretHash)) == 1
+	return match && k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// HasScope reports whether the key was granted scope. Writing implies
+// reading.
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	if scope == ScopeRecordsRead && slices.Contains(k.Scopes, ScopeRecordsWrite) {
+		return true
+	}
+	return slices.Contains(k.Scopes, scope)
+}
+
+// AllowsName reports whether the key can write the domain name name.
+func (k *APIKey) AllowsName(name string) bool {
+	if len(k.Suffixes) == 0 {
+		return true
+	}
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, suffix := range k.Suffixes {
+		if name == suffix || strings.HasSuffix(name, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+type apiKeyContextKey struct{}
+
+// ContextWithAPIKey returns a copy of ctx for a request authenticated with
+// key, so that the names it writes can be checked with CheckNameScope.
+func ContextWithAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// APIKeyFromContext returns the API key of the request of ctx, or nil if it
+// was not authenticated with one.
+func APIKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key
+}
+
+// CheckNameScope returns ErrNameOutOfScope if the request of ctx was
+// authenticated with an API key that cannot write one of names.
+func CheckNameScope(ctx context.Context, names ...string) error {
+	key := APIKeyFromContext(ctx)
+	if key == nil {
+		return nil
+	}
+	for _, name := range names {
+		if !key.AllowsName(name) {
+			return ErrNameOutOfScope
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	t.Run("Normalizes scopes and suffixes", func(t *testing.T) {
+		k, key, err := NewAPIKey(7, " terraform ", []APIKeyScope{ScopeRecordsWrite, ScopeRecordsRead, ScopeRecordsWrite}, []string{"Dev.Example.com.", "dev.example.com", "ci.example.com"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "terraform", k.Name)
+		assert.Equal(t, []APIKeyScope{ScopeRecordsRead, ScopeRecordsWrite}, k.Scopes)
+		assert.Equal(t, []string{"ci.example.com", "dev.example.com"}, k.Suffixes)
+		assert.True(t, strings.HasPrefix(key, "idns_"+k.Prefix+"_"))
+		assert.Len(t, k.SecretHash, 64)
+	})
+
+	past := time.Now().Add(-time.Minute)
+	invalid := []struct {
+		name     string
+		keyName  string
+		scopes   []APIKeyScope
+		suffixes []string
+		expires  *time.Time
+		wantErr  error
+	}{
+		{"Without name", "", []APIKeyScope{ScopeRecordsRead}, nil, nil, ErrAPIKeyNameRequired},
+		{"Without scopes", "ci", nil, nil, nil, ErrInvalidAPIKeyScope},
+		{"Unknown scope", "ci", []APIKeyScope{"users:write"}, nil, nil, ErrInvalidAPIKeyScope},
+		{"Invalid suffix", "ci", []APIKeyScope{ScopeRecordsWrite}, []string{"-bad-.com"}, nil, ErrInvalidNameSuffix},
+		{"Suffixes without write", "ci", []APIKeyScope{ScopeRecordsRead}, []string{"example.com"}, nil, ErrSuffixesRequireWrite},
+		{"Expired", "ci", []APIKeyScope{ScopeRecordsRead}, nil, &past, ErrInvalidAPIKeyExpiry},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := NewAPIKey(7, tt.keyName, tt.scopes, tt.suffixes, tt.expires)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestAPIKey_Verify(t *testing.T) {
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	k, key, err := NewAPIKey(7, "ci", []APIKeyScope{ScopeRecordsRead}, nil, &expires)
+	require.NoError(t, err)
+
+	prefix, secret, err := ParseAPIKey(key)
+	require.NoError(t, err)
+	assert.Equal(t, k.Prefix, prefix)
+	assert.True(t, k.Verify(secret, now))
+	assert.False(t, k.Verify(secret+"x", now))
+	assert.False(t, k.Verify(secret, expires), "expired")
+
+	// Rotating replaces the secret
+	rotated, err := k.Rotate()
+	require.NoError(t, err)
+	assert.NotNil(t, k.RotatedAt)
+	assert.False(t, k.Verify(secret, now))
+	_, secret, err = ParseAPIKey(rotated)
+	require.NoError(t, err)
+	assert.True(t, k.Verify(secret, now))
+
+	k.RevokedAt = &now
+	assert.False(t, k.Verify(secret, now), "revoked")
+
+	for _, malformed := range []string{"", "secret", "idns_", "idns_abc", "idns__secret", "key_abc_secret"} {
+		_, _, err := ParseAPIKey(malformed)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey, malformed)
+	}
+}
+
+func TestAPIKey_Scopes(t *testing.T) {
+	read := &APIKey{Scopes: []APIKeyScope{ScopeRecordsRead}}
+	assert.True(t, read.HasScope(ScopeRecordsRead))
+	assert.False(t, read.HasScope(ScopeRecordsWrite))
+
+	write := &APIKey{Scopes: []APIKeyScope{ScopeRecordsWrite}, Suffixes: []string{"dev.example.com"}}
+	assert.True(t, write.HasScope(ScopeRecordsRead), "writing implies reading")
+	assert.True(t, write.AllowsName("dev.example.com"))
+	assert.True(t, write.AllowsName("api.Dev.example.com."))
+	assert.False(t, write.AllowsName("prod.example.com"))
+	assert.False(t, write.AllowsName("mydev.example.com"))
+
+	ctx := context.Background()
+	assert.NoError(t, CheckNameScope(ctx, "prod.example.com"), "not authenticated with a key")
+	ctx = ContextWithAPIKey(ctx, write)
+	assert.Same(t, write, APIKeyFromContext(ctx))
+	assert.NoError(t, CheckNameScope(ctx, "a.dev.example.com"))
+	assert.ErrorIs(t, CheckNameScope(ctx, "a.dev.example.com", "prod.example.com"), ErrNameOutOfScope)
+}
@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidAuditRetention = errors.New("invalid audit retention policy")
+	ErrAuditArchiveBusy      = errors.New("the audit log is being archived by another process")
+)
+
+// AuditRetention is how long audit entries are kept, per action. Entries of
+// actions without their own period are kept for Default. A period of 0 keeps
+// entries forever.
+type AuditRetention struct {
+	Default time.Duration
+	Actions map[ActionType]time.Duration
+}
+
+// ParseAuditRetention parses a policy such as
+// "USER_LOGIN_FAILURE=30d,USER_LOGIN_SUCCESS=90d,*=730d", where "*" sets the
+// default. Periods are a number of days with a "d" suffix or a Go duration.
+// An empty policy keeps every entry forever.
+func ParseAuditRetention(s string) (AuditRetention, error) {
+	r := AuditRetention{Actions: make(map[ActionType]time.Duration)}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, period, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return AuditRetention{}, fmt.Errorf("%w: %q is not ACTION=PERIOD", ErrInvalidAuditRetention, item)
+		}
+		d, err := parseRetentionPeriod(strings.TrimSpace(period))
+		if err != nil {
+			return AuditRetention{}, fmt.Errorf("%w: %q: %v", ErrInvalidAuditRetention, item, err)
+		}
+		if name = strings.TrimSpace(name); name == "*" {
+			r.Default = d
+		} else {
+			r.Actions[ActionType(strings.ToUpper(name))] = d
+		}
+	}
+	return r, nil
+}
+
+func parseRetentionPeriod(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, errors.New("invalid number of days")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, errors.New("negative period")
+	}
+	return d, nil
+}
+
+// For returns the retention period of entries of action.
+func (r AuditRetention) For(action ActionType) time.Duration {
+	if d, ok := r.Actions[action]; ok {
+		return d
+	}
+	return r.Default
+}
+
+// Longest returns the period after which every entry has expired, or 0 if
+// some entries are kept forever.
+func (r AuditRetention) Longest() time.Duration {
+	longest := r.Default
+	for _, d := range r.Actions {
+		if d == 0 || longest == 0 {
+			return 0
+		}
+		longest = max(longest, d)
+	}
+	return longest
+}
+
+// Shortest returns the period after which the first entries expire, or 0 if
+// every entry is kept forever.
+func (r AuditRetention) Shortest() time.Duration {
+	shortest := r.Default
+	for _, d := range r.Actions {
+		if d > 0 && (shortest == 0 || d < shortest) {
+			shortest = d
+		}
+	}
+	return shortest
+}
+
+// ActionNames returns the actions with their own period, sorted.
+func (r AuditRetention) ActionNames() []ActionType {
+	names := make([]ActionType, 0, len(r.Actions))
+	for a := range r.Actions {
+		names = append(names, a)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// AuditPartition is one partition of the audit log, holding the entries
+// created in [From, To). From is zero for the partition of entries from
+// before partitioning, To is zero for the default partition that catches
+// entries without a partition of their month.
+type AuditPartition struct {
+	Name string
+	From time.Time
+	To   time.Time
+	Rows int64 // Estimated
+}
+
+// AuditArchive records entries that were removed from the audit log after
+// being written to File. Dropped archives hold a whole partition, which was
+// dropped; others hold the entries of a partition that had expired.
+type AuditArchive struct {
+	ID        int64
+	Partition string
+	File      string
+	Entries   int64
+	FirstID   int64
+	LastID    int64
+	Dropped   bool
+	CreatedAt time.Time
+}
+
+// AuditArchiveRun is the outcome of one run of the archiver.
+type AuditArchiveRun struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Archives   []*AuditArchive
+	Error      string
+}
+
+// AuditArchiveStatus describes the retention of the audit log.
+type AuditArchiveStatus struct {
+	Retention  AuditRetention
+	Partitions []*AuditPartition
+	Archives   []*AuditArchive // Newest first
+	LastRun    *AuditArchiveRun
+}
+
+// AuditChainGap is a run of consecutive entries that were archived, leaving
+// a gap in the hash chain: the entry after the gap follows on from ToHash,
+// the hash of the last archived entry, rather than from the entry before the
+// gap, whose hash is FromHash. Gaps are signed like checkpoints, so that
+// entries cannot be removed unnoticed by recording a gap for them.
+type AuditChainGap struct {
+	ID        int64
+	ArchiveID int64
+	FromHash  string
+	ToHash    string
+	Entries   int64
+	Signature string
+}
+
+// VerifySignature reports whether the gap was signed with key.
+func (g *AuditChainGap) VerifySignature(key []byte) bool {
+	return hmac.Equal([]byte(g.Signature), []byte(g.sign(key)))
+}
+
+func (g *AuditChainGap) sign(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(g.FromHash + "|" + g.ToHash + "|" + strconv.FormatInt(g.Entries, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AuditGapTracker collects the gaps that archived entries leave in the hash
+// chain. Entries must be added in ID order; unhashed entries are ignored.
+type AuditGapTracker struct {
+	gaps []*AuditChainGap
+}
+
+func (t *AuditGapTracker) Add(l *AuditLog) {
+	if l.Hash == "" {
+		return
+	}
+	if n := len(t.gaps); n > 0 && t.gaps[n-1].ToHash == l.PrevHash {
+		t.gaps[n-1].ToHash = l.Hash
+		t.gaps[n-1].Entries++
+		return
+	}
+	t.gaps = append(t.gaps, &AuditChainGap{FromHash: l.PrevHash, ToHash: l.Hash, Entries: 1})
+}
+
+// Gaps returns the gaps signed with key, or unsigned without a key.
+func (t *AuditGapTracker) Gaps(key []byte) []*AuditChainGap {
+	for _, g := range t.gaps {
+		if len(key) > 0 {
+			g.Signature = g.sign(key)
+		}
+	}
+	return t.gaps
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuditRetention(t *testing.T) {
+	const day = 24 * time.Hour
+
+	r, err := ParseAuditRetention("USER_LOGIN_FAILURE=30d, user_login_success=2160h,*=730d,IMPORT_ZONE=0")
+	require.NoError(t, err)
+	assert.Equal(t, 730*day, r.Default)
+	assert.Equal(t, 30*day, r.For(ActionUserLoginFailure))
+	assert.Equal(t, 90*day, r.For(ActionUserLoginSuccess))
+	assert.Equal(t, 730*day, r.For(ActionCreateDNSRecord))
+	assert.Equal(t, []ActionType{ActionImportZone, ActionUserLoginFailure, ActionUserLoginSuccess}, r.ActionNames())
+	assert.Equal(t, 30*day, r.Shortest())
+	assert.Zero(t, r.Longest(), "IMPORT_ZONE is kept forever")
+
+	r, err = ParseAuditRetention("")
+	require.NoError(t, err)
+	assert.Zero(t, r.Shortest())
+	assert.Zero(t, r.Longest())
+
+	for _, s := range []string{"USER_LOGIN_FAILURE", "=30d", "*=-1d", "*=30x", "*=-1h"} {
+		_, err := ParseAuditRetention(s)
+		assert.ErrorIs(t, err, ErrInvalidAuditRetention, s)
+	}
+}
+
+func TestAuditRetention_Longest(t *testing.T) {
+	r := AuditRetention{Default: time.Hour, Actions: map[ActionType]time.Duration{ActionImportZone: 2 * time.Hour}}
+	assert.Equal(t, 2*time.Hour, r.Longest())
+
+	// Actions without their own period are kept forever
+	r.Default = 0
+	assert.Zero(t, r.Longest())
+	assert.Equal(t, 2*time.Hour, r.Shortest())
+}
+
+func TestAuditGapTracker(t *testing.T) {
+	chain := func(hashes ...string) []*AuditLog {
+		logs := make([]*AuditLog, len(hashes))
+		prev := ""
+		for i, h := range hashes {
+			logs[i] = &AuditLog{ID: int64(i + 1), PrevHash: prev, Hash: h}
+			prev = h
+		}
+		return logs
+	}
+	logs := chain("a", "b", "c", "d", "e", "f")
+
+	// Entries b, c and e are archived; the unhashed entry is ignored
+	var tracker AuditGapTracker
+	tracker.Add(&AuditLog{ID: 0})
+	tracker.Add(logs[1])
+	tracker.Add(logs[2])
+	tracker.Add(logs[4])
+
+	gaps := tracker.Gaps([]byte("key"))
+	require.Len(t, gaps, 2)
+	assert.Equal(t, "a", gaps[0].FromHash)
+	assert.Equal(t, "c", gaps[0].ToHash)
+	assert.Equal(t, int64(2), gaps[0].Entries)
+	assert.Equal(t, "d", gaps[1].FromHash)
+	assert.Equal(t, "e", gaps[1].ToHash)
+
+	assert.True(t, gaps[0].VerifySignature([]byte("key")))
+	assert.False(t, gaps[0].VerifySignature([]byte("other")))
+	gaps[0].Entries = 1
+	assert.False(t, gaps[0].VerifySignature([]byte("key")))
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLog_ComputeHash(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	base := func() *AuditLog {
+		return &AuditLog{
+			ID:        1,
+			UserID:    1,
+			Action:    ActionCreateDNSRecord,
+			TargetID:  7,
+			NewValue:  json.RawMessage(`{"value":"10.0.0.1"}`),
+			Timestamp: at,
+			EventID:   "event",
+			Username:  "alice",
+		}
+	}
+	hash := base().ComputeHash("prev")
+	assert.Len(t, hash, 64)
+
+	// Fields that are not stored with the entry do not count
+	l := base()
+	l.ID, l.Username = 2, "bob"
+	l.Timestamp = at.In(time.FixedZone("CET", 3600))
+	assert.Equal(t, hash, l.ComputeHash("prev"))
+
+	changes := map[string]func(*AuditLog){
+		"user":      func(l *AuditLog) { l.UserID = 2 },
+		"action":    func(l *AuditLog) { l.Action = ActionDeleteDNSRecord },
+		"target":    func(l *AuditLog) { l.TargetID = 8 },
+		"old value": func(l *AuditLog) { l.OldValue = json.RawMessage(`{}`) },
+		"new value": func(l *AuditLog) { l.NewValue = json.RawMessage(`{"value":"10.0.0.2"}`) },
+		"change":    func(l *AuditLog) { l.ChangeID = "change" },
+		"timestamp": func(l *AuditLog) { l.Timestamp = at.Add(time.Microsecond) },
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			l := base()
+			change(l)
+			assert.NotEqual(t, hash, l.ComputeHash("prev"))
+		})
+	}
+	assert.NotEqual(t, hash, base().ComputeHash("other"))
+}
+
+func TestAuditCheckpoint_VerifySignature(t *testing.T) {
+	key := []byte("key")
+	cp := NewAuditCheckpoint(&AuditLog{ID: 5, Hash: "abc"}, key)
+
+	assert.True(t, cp.VerifySignature(key))
+	assert.False(t, cp.VerifySignature([]byte("other")))
+
+	cp.Hash = "abd"
+	assert.False(t, cp.VerifySignature(key))
+}
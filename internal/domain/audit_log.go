@@ -2,19 +2,53 @@ package domain
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 )
 
 type ActionType string
 
 const (
-	ActionCreateDNSRecord  ActionType = "CREATE_DNS_RECORD"
-	ActionUpdateDNSRecord  ActionType = "UPDATE_DNS_RECORD"
-	ActionDeleteDNSRecord  ActionType = "DELETE_DNS_RECORD"
-	ActionUserRegister     ActionType = "USER_REGISTER"
-	ActionUserLoginSuccess ActionType = "USER_LOGIN_SUCCESS"
-	ActionUserLoginFailure ActionType = "USER_LOGIN_FAILURE"
-	ActionUpdateUserStatus ActionType = "UPDATE_USER_STATUS"
+	ActionCreateDNSRecord     ActionType = "CREATE_DNS_RECORD"
+	ActionUpdateDNSRecord     ActionType = "UPDATE_DNS_RECORD"
+	ActionDeleteDNSRecord     ActionType = "DELETE_DNS_RECORD"
+	ActionUserRegister        ActionType = "USER_REGISTER"
+	ActionUserLoginSuccess    ActionType = "USER_LOGIN_SUCCESS"
+	ActionUserLoginFailure    ActionType = "USER_LOGIN_FAILURE"
+	ActionUpdateUserStatus    ActionType = "UPDATE_USER_STATUS"
+	ActionCreatePolicyRule    ActionType = "CREATE_POLICY_RULE"
+	ActionUpdatePolicyRule    ActionType = "UPDATE_POLICY_RULE"
+	ActionDeletePolicyRule    ActionType = "DELETE_POLICY_RULE"
+	ActionUpdateHealthCheck   ActionType = "UPDATE_HEALTH_CHECK"
+	ActionUpdateTrafficPolicy ActionType = "UPDATE_TRAFFIC_POLICY"
+	ActionImportZone          ActionType = "IMPORT_ZONE"
+	ActionImportDNSRecords    ActionType = "IMPORT_DNS_RECORDS"
+	ActionRollbackDNSRecord   ActionType = "ROLLBACK_DNS_RECORD"
+	ActionRestoreDNSRecord    ActionType = "RESTORE_DNS_RECORD"
+	ActionRefreshTokenReused  ActionType = "REFRESH_TOKEN_REUSED"
+	ActionUserLogout          ActionType = "USER_LOGOUT"
+	ActionRevokeSession       ActionType = "REVOKE_SESSION"
+	ActionRevokeAllSessions   ActionType = "REVOKE_ALL_SESSIONS"
+
+	// Service accounts and their API keys
+	ActionCreateServiceAccount ActionType = "CREATE_SERVICE_ACCOUNT"
+	ActionCreateAPIKey         ActionType = "CREATE_API_KEY"
+	ActionRotateAPIKey         ActionType = "ROTATE_API_KEY"
+	ActionRevokeAPIKey         ActionType = "REVOKE_API_KEY"
+
+	// Single sign-on
+	ActionLinkIdentity ActionType = "LINK_IDENTITY"
+	ActionSyncUserRole ActionType = "SYNC_USER_ROLE"
+
+	// Two-factor authentication
+	ActionEnableMFA               ActionType = "ENABLE_MFA"
+	ActionDisableMFA              ActionType = "DISABLE_MFA"
+	ActionRegenerateRecoveryCodes ActionType = "REGENERATE_RECOVERY_CODES"
+	ActionUseRecoveryCode         ActionType = "USE_RECOVERY_CODE"
+
+	// Login throttling
+	ActionLockOutLogin ActionType = "LOCK_OUT_LOGIN"
+	ActionUnlockLogin  ActionType = "UNLOCK_LOGIN"
 )
 
 type AuditLog struct {
@@ -25,6 +59,47 @@ type AuditLog struct {
 	OldValue  json.RawMessage
 	NewValue  json.RawMessage
 	Timestamp time.Time
+	ChangeID  string // Groups the entries written by one change set
+	EventID   string // Identifies the entry while it is queued, so that it is stored once
+	PrevHash  string // Hash of the entry before this one in the chain
+	Hash      string // See ComputeHash; empty for entries written before hashing
+	Username  string // Name of the acting user; only set when read
+}
+
+// AuditOutboxEntry is an audit log entry claimed from the outbox. ID
+// acknowledges it once it is stored.
+type AuditOutboxEntry struct {
+	ID  int64
+	Log *AuditLog
+}
+
+// Limits on the number of audit log entries returned per page.
+const (
+	DefaultAuditLogPageSize = 50
+	MaxAuditLogPageSize     = 500
+)
+
+var ErrInvalidAuditLogFilter = errors.New("invalid audit log filter")
+
+// AuditLogFilter selects audit log entries; unset fields match every entry.
+// Entries are returned newest first. BeforeID is the keyset cursor: only
+// entries with a lower ID are returned. From is inclusive, To exclusive.
+type AuditLogFilter struct {
+	UserID   *int64
+	Actions  []ActionType
+	TargetID *int64
+	ChangeID string
+	From     time.Time
+	To       time.Time
+	BeforeID int64
+	Limit    int
+}
+
+// AuditLogPage is one page of audit log entries. NextCursor is the BeforeID
+// of the next page, or 0 if this is the last one.
+type AuditLogPage struct {
+	Items      []*AuditLog
+	NextCursor int64
 }
 
 func NewAuditLog(userID int64, action ActionType, targetID int64, oldValue, newValue interface{}) (*AuditLog, error) {
@@ -52,4 +127,4 @@ func NewAuditLog(userID int64, action ActionType, targetID int64, oldValue, newV
 		OldValue: oldJSON,
 		NewValue: newJSON,
 	}, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+// ChangeOp is the kind of one operation of a change set.
+type ChangeOp string
+
+const (
+	ChangeCreate ChangeOp = "create"
+	ChangeUpdate ChangeOp = "update"
+	ChangeDelete ChangeOp = "delete"
+)
+
+// MaxChangeSetOperations limits the size of one change set.
+const MaxChangeSetOperations = 1000
+
+var (
+	ErrEmptyChangeSet    = errors.New("change set has no operations")
+	ErrChangeSetTooLarge = errors.New("change set has too many operations")
+	ErrChangeSetStale    = errors.New("records changed since the change set was previewed")
+)
+
+// RecordChange is one requested operation. RecordID is set for updates and
+// deletes; the record fields for creates and updates.
+type RecordChange struct {
+	Op         ChangeOp
+	RecordID   int64
+	DomainName string
+	Type       RecordType
+	Value      string
+}
+
+// ChangeSetItem is the computed outcome of one operation. Index is its
+// position in the request, starting at 0. Record is the state after the
+// change and Previous the state before; deletes have no Record and creates
+// no Previous.
+type ChangeSetItem struct {
+	Index    int
+	Op       ChangeOp
+	Record   *DNSRecord
+	Previous *DNSRecord
+	Error    string
+}
+
+// ChangeSet is the diff and validation result of a list of operations.
+// Fingerprint identifies the state of the records it was computed against;
+// ID is assigned once the change set is applied.
+type ChangeSet struct {
+	ID          string
+	Items       []ChangeSetItem
+	Valid       bool
+	Applied     bool
+	Fingerprint string
+}
+
+// NewChangeID returns a random UUID (version 4).
+func NewChangeID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
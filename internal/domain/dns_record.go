@@ -34,6 +34,30 @@ type DNSRecord struct {
 	Value      string
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
+
+	// Query statistics, aggregated by the DNS server. LastQueriedAt is nil if
+	// the record has never been queried.
+	QueryCount    int64
+	LastQueriedAt *time.Time
+
+	// AdditionalValues holds further addresses answered alongside Value for
+	// A records. With a HealthCheck, unhealthy addresses are left out.
+	AdditionalValues []string
+	HealthCheck      *HealthCheck
+
+	// TrafficPolicy, if set, selects the answers among its own targets.
+	TrafficPolicy *TrafficPolicy
+
+	// DeletedAt is set while the record is in the trash. It is not answered
+	// but keeps its name reserved until it is purged.
+	DeletedAt *time.Time
+}
+
+// QueryStat is an aggregated number of queries answered for a domain name.
+type QueryStat struct {
+	DomainName    string
+	Count         int64
+	LastQueriedAt time.Time
 }
 
 func NewDNSRecord(userID int64, domainName, value string, recordType RecordType) (*DNSRecord, error) {
@@ -65,3 +89,60 @@ func NewDNSRecord(userID int64, domainName, value string, recordType RecordType)
 	}, nil
 }
 
+// Addresses returns all addresses of the record: Value first, then the
+// additional values and the targets of its traffic policy.
+func (r *DNSRecord) Addresses() []string {
+	addrs := append([]string{r.Value}, r.AdditionalValues...)
+	if r.TrafficPolicy != nil {
+		seen := make(map[string]bool, len(addrs))
+		for _, a := range addrs {
+			seen[a] = true
+		}
+		for _, t := range r.TrafficPolicy.Targets {
+			if !seen[t.Value] {
+				seen[t.Value] = true
+				addrs = append(addrs, t.Value)
+			}
+		}
+	}
+	return addrs
+}
+
+// SetTrafficPolicy attaches a traffic policy to an A record. A nil policy
+// detaches it.
+func (r *DNSRecord) SetTrafficPolicy(policy *TrafficPolicy) error {
+	if r.Type != A && policy != nil {
+		return ErrTrafficPolicyRequiresA
+	}
+	r.TrafficPolicy = policy
+	return nil
+}
+
+// SetHealthCheck replaces the additional values and health check of an A
+// record. A nil check disables health checking.
+func (r *DNSRecord) SetHealthCheck(additionalValues []string, check *HealthCheck) error {
+	if r.Type != A && (len(additionalValues) > 0 || check != nil) {
+		return ErrHealthCheckRequiresA
+	}
+	if len(additionalValues) > MaxAdditionalValues {
+		return ErrInvalidRecordValue
+	}
+
+	seen := map[string]bool{r.Value: true}
+	values := make([]string, 0, len(additionalValues))
+	for _, v := range additionalValues {
+		v = strings.TrimSpace(v)
+		if !ipv4Regex.MatchString(v) {
+			return ErrInvalidRecordValue
+		}
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		values = append(values, v)
+	}
+
+	r.AdditionalValues = values
+	r.HealthCheck = check
+	return nil
+}
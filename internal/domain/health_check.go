@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// HealthCheckType is the protocol used to probe a record's targets.
+type HealthCheckType string
+
+const (
+	// HealthCheckTCP succeeds when a TCP connection can be opened.
+	HealthCheckTCP HealthCheckType = "tcp"
+	// HealthCheckHTTP succeeds when an HTTP GET returns the expected status.
+	HealthCheckHTTP HealthCheckType = "http"
+)
+
+// Health check defaults, applied to zero values.
+const (
+	DefaultHealthCheckInterval     = 10 // seconds
+	DefaultHealthCheckTimeout      = 2  // seconds
+	DefaultHealthyThreshold        = 2
+	DefaultUnhealthyThreshold      = 3
+	MaxAdditionalValues            = 16
+	defaultHealthCheckHTTPPort     = 80
+	defaultHealthCheckExpectedCode = 200
+)
+
+var (
+	ErrInvalidHealthCheck   = errors.New("invalid health check")
+	ErrHealthCheckRequiresA = errors.New("health checks and additional values are only supported for A records")
+)
+
+// HealthCheck describes how the DNS server probes the addresses of an A
+// record. It is stored with the record as JSON.
+type HealthCheck struct {
+	Type               HealthCheckType `json:"type"`
+	Port               int             `json:"port"`
+	Path               string          `json:"path,omitempty"`           // HTTP only
+	ExpectedStatus     int             `json:"expectedStatus,omitempty"` // HTTP only
+	IntervalSeconds    int             `json:"intervalSeconds"`
+	TimeoutSeconds     int             `json:"timeoutSeconds"`
+	HealthyThreshold   int             `json:"healthyThreshold"`   // consecutive successes to mark a target up
+	UnhealthyThreshold int             `json:"unhealthyThreshold"` // consecutive failures to mark a target down
+}
+
+func NewHealthCheck(checkType HealthCheckType, port int, path string, expectedStatus, intervalSeconds, timeoutSeconds, healthyThreshold, unhealthyThreshold int) (*HealthCheck, error) {
+	hc := &HealthCheck{
+		Type:               checkType,
+		Port:               port,
+		IntervalSeconds:    intervalSeconds,
+		TimeoutSeconds:     timeoutSeconds,
+		HealthyThreshold:   healthyThreshold,
+		UnhealthyThreshold: unhealthyThreshold,
+	}
+
+	switch checkType {
+	case HealthCheckTCP:
+		if port == 0 {
+			return nil, ErrInvalidHealthCheck
+		}
+	case HealthCheckHTTP:
+		if port == 0 {
+			hc.Port = defaultHealthCheckHTTPPort
+		}
+		hc.Path = strings.TrimSpace(path)
+		if hc.Path == "" {
+			hc.Path = "/"
+		}
+		if !strings.HasPrefix(hc.Path, "/") {
+			return nil, ErrInvalidHealthCheck
+		}
+		hc.ExpectedStatus = expectedStatus
+		if hc.ExpectedStatus == 0 {
+			hc.ExpectedStatus = defaultHealthCheckExpectedCode
+		}
+		if hc.ExpectedStatus < 100 || hc.ExpectedStatus > 599 {
+			return nil, ErrInvalidHealthCheck
+		}
+	default:
+		return nil, ErrInvalidHealthCheck
+	}
+
+	if hc.IntervalSeconds == 0 {
+		hc.IntervalSeconds = DefaultHealthCheckInterval
+	}
+	if hc.TimeoutSeconds == 0 {
+		hc.TimeoutSeconds = DefaultHealthCheckTimeout
+	}
+	if hc.HealthyThreshold == 0 {
+		hc.HealthyThreshold = DefaultHealthyThreshold
+	}
+	if hc.UnhealthyThreshold == 0 {
+		hc.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+	if hc.Port < 1 || hc.Port > 65535 || hc.IntervalSeconds < 1 || hc.TimeoutSeconds < 1 ||
+		hc.TimeoutSeconds > hc.IntervalSeconds || hc.HealthyThreshold < 1 || hc.UnhealthyThreshold < 1 {
+		return nil, ErrInvalidHealthCheck
+	}
+	return hc, nil
+}
+
+// Interval returns the time between two probes of a target.
+func (hc *HealthCheck) Interval() time.Duration {
+	return time.Duration(hc.IntervalSeconds) * time.Second
+}
+
+// Timeout returns how long a single probe may take.
+func (hc *HealthCheck) Timeout() time.Duration {
+	return time.Duration(hc.TimeoutSeconds) * time.Second
+}
+
+// TargetHealth is the current health of one address of a record.
+type TargetHealth struct {
+	RecordID             int64
+	Target               string
+	Healthy              bool
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	LastError            string
+	LastCheckedAt        time.Time
+	LastChangedAt        time.Time
+}
+
+// HealthEvent records a target changing between healthy and unhealthy.
+type HealthEvent struct {
+	ID        int64
+	RecordID  int64
+	Target    string
+	Healthy   bool
+	Error     string
+	CreatedAt time.Time
+}
+
+// RecordHealth is the health of all targets of a record and their recent
+// state changes, newest first.
+type RecordHealth struct {
+	Record  *DNSRecord
+	Targets []*TargetHealth
+	History []*HealthEvent
+}
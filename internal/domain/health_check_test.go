@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHealthCheck(t *testing.T) {
+	t.Run("HTTP defaults", func(t *testing.T) {
+		hc, err := NewHealthCheck(HealthCheckHTTP, 0, "", 0, 0, 0, 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 80, hc.Port)
+		assert.Equal(t, "/", hc.Path)
+		assert.Equal(t, 200, hc.ExpectedStatus)
+		assert.Equal(t, DefaultHealthCheckInterval, hc.IntervalSeconds)
+		assert.Equal(t, DefaultHealthCheckTimeout, hc.TimeoutSeconds)
+		assert.Equal(t, DefaultHealthyThreshold, hc.HealthyThreshold)
+		assert.Equal(t, DefaultUnhealthyThreshold, hc.UnhealthyThreshold)
+	})
+
+	t.Run("TCP ignores HTTP settings", func(t *testing.T) {
+		hc, err := NewHealthCheck(HealthCheckTCP, 5432, "/ignored", 204, 5, 1, 1, 1)
+		require.NoError(t, err)
+		assert.Empty(t, hc.Path)
+		assert.Zero(t, hc.ExpectedStatus)
+	})
+
+	invalid := []struct {
+		name string
+		fn   func() (*HealthCheck, error)
+	}{
+		{"Unknown type", func() (*HealthCheck, error) { return NewHealthCheck("icmp", 80, "", 0, 0, 0, 0, 0) }},
+		{"TCP without port", func() (*HealthCheck, error) { return NewHealthCheck(HealthCheckTCP, 0, "", 0, 0, 0, 0, 0) }},
+		{"Port out of range", func() (*HealthCheck, error) { return NewHealthCheck(HealthCheckTCP, 70000, "", 0, 0, 0, 0, 0) }},
+		{"Relative path", func() (*HealthCheck, error) { return NewHealthCheck(HealthCheckHTTP, 80, "health", 0, 0, 0, 0, 0) }},
+		{"Invalid status", func() (*HealthCheck, error) { return NewHealthCheck(HealthCheckHTTP, 80, "/", 42, 0, 0, 0, 0) }},
+		{"Timeout above interval", func() (*HealthCheck, error) { return NewHealthCheck(HealthCheckTCP, 80, "", 0, 5, 10, 0, 0) }},
+		{"Negative threshold", func() (*HealthCheck, error) { return NewHealthCheck(HealthCheckTCP, 80, "", 0, 0, 0, -1, 0) }},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.fn()
+			assert.ErrorIs(t, err, ErrInvalidHealthCheck)
+		})
+	}
+}
+
+func TestDNSRecord_SetHealthCheck(t *testing.T) {
+	hc, err := NewHealthCheck(HealthCheckTCP, 443, "", 0, 0, 0, 0, 0)
+	require.NoError(t, err)
+
+	record := &DNSRecord{Type: A, Value: "10.0.0.1"}
+	require.NoError(t, record.SetHealthCheck([]string{" 10.0.0.2 ", "10.0.0.1", "10.0.0.2"}, hc))
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, record.Addresses())
+	assert.Equal(t, hc, record.HealthCheck)
+
+	assert.ErrorIs(t, record.SetHealthCheck([]string{"10.0.0.256"}, hc), ErrInvalidRecordValue)
+
+	cname := &DNSRecord{Type: CNAME, Value: "svc.example.com"}
+	assert.ErrorIs(t, cname.SetHealthCheck(nil, hc), ErrHealthCheckRequiresA)
+	assert.NoError(t, cname.SetHealthCheck(nil, nil))
+}
@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"time"
+)
+
+// SSOLoginTTL is how long a user has to log in at the identity provider.
+const SSOLoginTTL = 10 * time.Minute
+
+var (
+	ErrIdentityNotFound   = errors.New("identity not found")
+	ErrInvalidSSOLogin    = errors.New("invalid or expired login attempt")
+	ErrSSOLoginFailed     = errors.New("identity provider login failed")
+	ErrMissingUsername    = errors.New("identity provider did not return a username")
+	ErrNoRoleForGroups    = errors.New("user is not in any group that is granted access")
+	ErrUserDisabled       = errors.New("user is disabled")
+	ErrUsernameUnlinkable = errors.New("username belongs to an existing user")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+)
+
+// UserIdentity links a user to an account at an external identity provider.
+// The account is identified by the provider's issuer and its subject.
+type UserIdentity struct {
+	ID        int64
+	UserID    int64
+	Issuer    string
+	Subject   string
+	CreatedAt time.Time
+}
+
+// NewUserIdentity creates the link of a user to the account subject of
+// issuer.
+func NewUserIdentity(userID int64, issuer, subject string) *UserIdentity {
+	return &UserIdentity{
+		UserID:    userID,
+		Issuer:    issuer,
+		Subject:   subject,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// NewExternalUser creates a user provisioned by an identity provider. It has
+// no password, so that it can only log in at the provider.
+func NewExternalUser(username string, role UserRole) (*User, error) {
+	if len(username) < 3 {
+		return nil, ErrUsernameTooShort
+	}
+	if role != RoleUser && role != RoleAdmin {
+		return nil, ErrInvalidRole
+	}
+	return &User{
+		Username:  username,
+		Role:      role,
+		IsEnabled: true,
+	}, nil
+}
+
+// DirectoryUser is a user of a directory such as LDAP, identified by the
+// directory's URL and the user's DN.
+type DirectoryUser struct {
+	Directory string
+	DN        string
+	Username  string
+	Groups    []string
+}
+
+// RoleMapping maps the groups of an identity provider to roles.
+type RoleMapping struct {
+	AdminGroups []string
+	// UserGroups are the groups granted RoleUser. If empty, every user of
+	// the provider is.
+	UserGroups []string
+}
+
+// Role returns the role granted to a member of groups. Admin groups take
+// precedence. It returns ErrNoRoleForGroups if none is granted.
+func (m RoleMapping) Role(groups []string) (UserRole, error) {
+	for _, g := range groups {
+		if slices.Contains(m.AdminGroups, g) {
+			return RoleAdmin, nil
+		}
+	}
+	if len(m.UserGroups) == 0 {
+		return RoleUser, nil
+	}
+	for _, g := range groups {
+		if slices.Contains(m.UserGroups, g) {
+			return RoleUser, nil
+		}
+	}
+	return "", ErrNoRoleForGroups
+}
+
+// SSOLogin is a login at an identity provider in progress. State identifies
+// it when the provider redirects the user back; the provider returns Nonce
+// in the ID token, and CodeVerifier proves that the authorization code is
+// redeemed by whoever started the login (PKCE).
+type SSOLogin struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	CreatedAt    time.Time
+}
+
+// NewSSOLogin starts a login with random state, nonce and code verifier.
+func NewSSOLogin() (*SSOLogin, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return &SSOLogin{
+		State:        values[0],
+		Nonce:        values[1],
+		CodeVerifier: values[2],
+		CreatedAt:    time.Now().UTC(),
+	}, nil
+}
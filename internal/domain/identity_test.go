@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleMapping_Role(t *testing.T) {
+	m := RoleMapping{AdminGroups: []string{"dns-admins"}, UserGroups: []string{"engineering", "ops"}}
+	tests := []struct {
+		name    string
+		mapping RoleMapping
+		groups  []string
+		want    UserRole
+		wantErr error
+	}{
+		{"Admin group", m, []string{"staff", "dns-admins"}, RoleAdmin, nil},
+		{"Admin group takes precedence", m, []string{"ops", "dns-admins"}, RoleAdmin, nil},
+		{"User group", m, []string{"staff", "ops"}, RoleUser, nil},
+		{"No mapped group", m, []string{"staff"}, "", ErrNoRoleForGroups},
+		{"No groups", m, nil, "", ErrNoRoleForGroups},
+		{"Everyone is a user without user groups", RoleMapping{AdminGroups: []string{"dns-admins"}}, nil, RoleUser, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, err := tt.mapping.Role(tt.groups)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, role)
+		})
+	}
+}
+
+func TestNewSSOLogin(t *testing.T) {
+	a, err := NewSSOLogin()
+	assert.NoError(t, err)
+	b, err := NewSSOLogin()
+	assert.NoError(t, err)
+
+	assert.Len(t, a.CodeVerifier, 43) // RFC 7636 requires 43 to 128 characters
+	assert.NotEqual(t, a.State, a.Nonce)
+	assert.NotEqual(t, a.State, b.State)
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrLoginThrottled is returned for logins refused after too many failed
+// ones. It is returned whether or not the account exists.
+var ErrLoginThrottled = errors.New("too many failed logins, try again later")
+
+// LoginThrottledError is an ErrLoginThrottled that tells when logins are
+// accepted again.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return ErrLoginThrottled.Error()
+}
+
+func (e *LoginThrottledError) Is(target error) bool {
+	return target == ErrLoginThrottled
+}
+
+// LoginThrottlePolicy decides how long logins are refused after failed
+// ones, e.g. of a username or from a client IP. The first FreeAttempts
+// failures in a row are not delayed; every further one refuses logins for
+// BaseDelay, doubled for each failure before it, up to MaxDelay. Reaching
+// LockoutThreshold failures locks logins out for LockoutDuration. Failures
+// are forgotten LockoutDuration after the last one.
+type LoginThrottlePolicy struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int // 0 never locks out
+	LockoutDuration  time.Duration
+}
+
+// LoginThrottling holds the policies for the usernames of logins and the
+// client IPs they come from.
+type LoginThrottling struct {
+	Username LoginThrottlePolicy
+	IP       LoginThrottlePolicy
+}
+
+// LockedOut reports whether failures failed logins in a row lock logins
+// out.
+func (p LoginThrottlePolicy) LockedOut(failures int64) bool {
+	return p.LockoutThreshold > 0 && failures >= int64(p.LockoutThreshold)
+}
+
+// Delay returns how long logins are refused after failures failed logins
+// in a row.
+func (p LoginThrottlePolicy) Delay(failures int64) time.Duration {
+	if p.LockedOut(failures) {
+		return p.LockoutDuration
+	}
+	if failures <= int64(p.FreeAttempts) {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := int64(p.FreeAttempts) + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
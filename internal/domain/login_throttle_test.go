@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottlePolicy_Delay(t *testing.T) {
+	policy := LoginThrottlePolicy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Second,
+		LockoutThreshold: 10,
+		LockoutDuration:  15 * time.Minute,
+	}
+
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{1, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 5 * time.Second},
+		{9, 5 * time.Second},
+		{10, 15 * time.Minute},
+		{25, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.failures), func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.Delay(tt.failures))
+			assert.Equal(t, tt.failures >= 10, policy.LockedOut(tt.failures))
+		})
+	}
+
+	// Without a threshold logins are only delayed
+	policy.LockoutThreshold = 0
+	assert.False(t, policy.LockedOut(100))
+	assert.Equal(t, 5*time.Second, policy.Delay(100))
+}
+
+func TestLoginThrottledError(t *testing.T) {
+	var err error = &LoginThrottledError{RetryAfter: time.Minute}
+	assert.ErrorIs(t, err, ErrLoginThrottled)
+	assert.Equal(t, ErrLoginThrottled.Error(), err.Error())
+
+	var throttled *LoginThrottledError
+	assert.True(t, errors.As(fmt.Errorf("login: %w", err), &throttled))
+	assert.Equal(t, time.Minute, throttled.RetryAfter)
+}
@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// MFAChallengeTTL is how long a user has to enter their code after
+	// their password was accepted.
+	MFAChallengeTTL = 5 * time.Minute
+	// MFAChallengeMaxAttempts is the number of wrong codes after which a
+	// challenge is discarded and the user has to log in again.
+	MFAChallengeMaxAttempts = 5
+	// RecoveryCodeCount is the number of recovery codes a user gets.
+	RecoveryCodeCount = 10
+)
+
+var (
+	ErrMFANotEnrolled      = errors.New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFARequired         = errors.New("two-factor authentication is required for admins")
+	ErrInvalidMFACode      = errors.New("invalid two-factor authentication code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired two-factor authentication challenge")
+)
+
+// TOTPEnrollment is the TOTP secret of a user. It is pending until the user
+// proves that their authenticator app has it by entering a code.
+type TOTPEnrollment struct {
+	UserID      int64
+	Secret      []byte
+	ConfirmedAt *time.Time
+	// LastUsedStep is the time step of the last accepted code, so that
+	// codes cannot be used twice.
+	LastUsedStep int64
+	CreatedAt    time.Time
+
+	RecoveryCodesLeft int // only set when read
+}
+
+// NewTOTPEnrollment starts the enrollment of a user with secret.
+func NewTOTPEnrollment(userID int64, secret []byte) *TOTPEnrollment {
+	return &TOTPEnrollment{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// Enabled reports whether the enrollment has been confirmed.
+func (e *TOTPEnrollment) Enabled() bool {
+	return e != nil && e.ConfirmedAt != nil
+}
+
+// MFAStatus describes the two-factor authentication of a user.
+type MFAStatus struct {
+	Enabled           bool
+	Required          bool // for admins
+	RecoveryCodesLeft int
+}
+
+// TOTPProvisioning is handed to a user to add their secret to an
+// authenticator app, by scanning URI as a QR code or typing in Secret.
+type TOTPProvisioning struct {
+	Secret string // base32
+	URI    string // otpauth://
+}
+
+// MFAChallenge is a login whose password was accepted and that waits for
+// the user's code. Token identifies it; EnrollmentRequired is set for
+// admins who have to enable two-factor authentication first.
+type MFAChallenge struct {
+	Token              string
+	UserID             int64
+	Username           string
+	Authenticator      string // that accepted the password, or oidc
+	EnrollmentRequired bool
+	Attempts           int
+	CreatedAt          time.Time
+}
+
+// NewMFAChallenge creates the challenge of a login of user with a random
+// token.
+func NewMFAChallenge(user *User, authenticator string, enrollmentRequired bool) (*MFAChallenge, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &MFAChallenge{
+		Token:              base64.RawURLEncoding.EncodeToString(b),
+		UserID:             user.ID,
+		Username:           user.Username,
+		Authenticator:      authenticator,
+		EnrollmentRequired: enrollmentRequired,
+		CreatedAt:          time.Now().UTC(),
+	}, nil
+}
+
+// ExpiresAt returns when the challenge expires.
+func (c *MFAChallenge) ExpiresAt() time.Time {
+	return c.CreatedAt.Add(MFAChallengeTTL)
+}
+
+// LoginResult is the outcome of a login step: either the tokens of a new
+// session, or the token of the challenge to answer with a code.
+type LoginResult struct {
+	AccessToken  string
+	RefreshToken string
+
+	MFAToken              string
+	MFAEnrollmentRequired bool
+
+	// RecoveryCodes are returned once when a login enabled two-factor
+	// authentication.
+	RecoveryCodes []string
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes returns RecoveryCodeCount random recovery codes of the
+// form xxxxx-xxxxx and their hashes, which are stored instead.
+func NewRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b)[:10])
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the hash of a recovery code, ignoring case,
+// dashes and spaces.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	// The codes are random, so a fast hash cannot be brute-forced
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
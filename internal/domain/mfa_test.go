@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+	require.Len(t, hashes, RecoveryCodeCount)
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		assert.Regexp(t, regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`), code)
+		assert.Equal(t, HashRecoveryCode(code), hashes[i])
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	// Codes are accepted however they are typed
+	hash := HashRecoveryCode("abcde-fghij")
+	assert.Equal(t, hash, HashRecoveryCode("ABCDE-FGHIJ"))
+	assert.Equal(t, hash, HashRecoveryCode("abcdefghij"))
+	assert.Equal(t, hash, HashRecoveryCode("abcde fghij"))
+	assert.NotEqual(t, hash, HashRecoveryCode("abcde-fghik"))
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"internal-dns/internal/util"
+)
+
+// PolicyMatch defines how a policy rule pattern is compared with a query name.
+type PolicyMatch string
+
+const (
+	// PolicyMatchExact matches the pattern itself only.
+	PolicyMatchExact PolicyMatch = "exact"
+	// PolicyMatchSuffix matches the pattern and every name below it.
+	PolicyMatchSuffix PolicyMatch = "suffix"
+	// PolicyMatchWildcard matches names below the pattern's parent, as in
+	// "*.example.com", but not the parent itself.
+	PolicyMatchWildcard PolicyMatch = "wildcard"
+)
+
+// PolicyAction is what the resolver does with a query matching a rule.
+type PolicyAction string
+
+const (
+	PolicyActionNXDomain PolicyAction = "NXDOMAIN"
+	PolicyActionNoData   PolicyAction = "NODATA"
+	PolicyActionRedirect PolicyAction = "REDIRECT"
+	// PolicyActionPassthru exempts matching names from all other rules.
+	PolicyActionPassthru PolicyAction = "PASSTHRU"
+)
+
+var (
+	ErrInvalidPolicyPattern  = errors.New("invalid policy rule pattern")
+	ErrInvalidPolicyMatch    = errors.New("invalid policy rule match type")
+	ErrInvalidPolicyAction   = errors.New("invalid policy rule action")
+	ErrInvalidPolicyRedirect = errors.New("redirect rules require a valid IP address")
+)
+
+// policyNameRegex validates policy rule names. Unlike record names, a single
+// label (e.g. a TLD) is allowed.
+var policyNameRegex = regexp.MustCompile(`^([a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?\.)*[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?$`)
+
+// PolicyRule is a response policy rule used to sinkhole or exempt domains.
+type PolicyRule struct {
+	ID         int64
+	Pattern    string // e.g. "bad.example.com" or "*.bad.example.com" for wildcard rules
+	Match      PolicyMatch
+	Action     PolicyAction
+	RedirectIP string // only for REDIRECT
+	Comment    string
+	Source     string // "database" or the zone file a rule was loaded from
+	CreatedBy  int64
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func NewPolicyRule(createdBy int64, pattern string, match PolicyMatch, action PolicyAction, redirectIP, comment string) (*PolicyRule, error) {
+	pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+	redirectIP = strings.TrimSpace(redirectIP)
+
+	name := pattern
+	switch match {
+	case PolicyMatchWildcard:
+		if !strings.HasPrefix(pattern, "*.") {
+			return nil, ErrInvalidPolicyPattern
+		}
+		name = strings.TrimPrefix(pattern, "*.")
+	case PolicyMatchExact, PolicyMatchSuffix:
+	default:
+		return nil, ErrInvalidPolicyMatch
+	}
+	// Policy rules may target any name, so only the label syntax is checked
+	if !policyNameRegex.MatchString(name) {
+		return nil, ErrInvalidPolicyPattern
+	}
+
+	switch action {
+	case PolicyActionNXDomain, PolicyActionNoData, PolicyActionPassthru:
+		redirectIP = ""
+	case PolicyActionRedirect:
+		ip, err := util.ParseIP(redirectIP)
+		if err != nil {
+			return nil, ErrInvalidPolicyRedirect
+		}
+		redirectIP = ip.String()
+	default:
+		return nil, ErrInvalidPolicyAction
+	}
+
+	return &PolicyRule{
+		Pattern:    pattern,
+		Match:      match,
+		Action:     action,
+		RedirectIP: redirectIP,
+		Comment:    strings.TrimSpace(comment),
+		CreatedBy:  createdBy,
+	}, nil
+}
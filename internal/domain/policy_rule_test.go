@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPolicyRule(t *testing.T) {
+	testCases := []struct {
+		name            string
+		pattern         string
+		match           PolicyMatch
+		action          PolicyAction
+		redirectIP      string
+		expectError     error
+		expectedPattern string
+	}{
+		{
+			name:            "Valid exact NXDOMAIN rule",
+			pattern:         "  Bad.Example.COM. ",
+			match:           PolicyMatchExact,
+			action:          PolicyActionNXDomain,
+			expectedPattern: "bad.example.com",
+		},
+		{
+			name:            "Valid suffix rule on a TLD",
+			pattern:         "zip",
+			match:           PolicyMatchSuffix,
+			action:          PolicyActionNoData,
+			expectedPattern: "zip",
+		},
+		{
+			name:            "Valid wildcard redirect rule",
+			pattern:         "*.ads.example.com",
+			match:           PolicyMatchWildcard,
+			action:          PolicyActionRedirect,
+			redirectIP:      "10.0.0.53",
+			expectedPattern: "*.ads.example.com",
+		},
+		{
+			name:        "Wildcard rule without wildcard label",
+			pattern:     "ads.example.com",
+			match:       PolicyMatchWildcard,
+			action:      PolicyActionNXDomain,
+			expectError: ErrInvalidPolicyPattern,
+		},
+		{
+			name:        "Invalid pattern",
+			pattern:     "bad..example.com",
+			match:       PolicyMatchExact,
+			action:      PolicyActionNXDomain,
+			expectError: ErrInvalidPolicyPattern,
+		},
+		{
+			name:        "Invalid match type",
+			pattern:     "bad.example.com",
+			match:       "regex",
+			action:      PolicyActionNXDomain,
+			expectError: ErrInvalidPolicyMatch,
+		},
+		{
+			name:        "Invalid action",
+			pattern:     "bad.example.com",
+			match:       PolicyMatchExact,
+			action:      "DROP",
+			expectError: ErrInvalidPolicyAction,
+		},
+		{
+			name:        "Redirect without IP",
+			pattern:     "bad.example.com",
+			match:       PolicyMatchExact,
+			action:      PolicyActionRedirect,
+			redirectIP:  "sinkhole",
+			expectError: ErrInvalidPolicyRedirect,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := NewPolicyRule(1, tc.pattern, tc.match, tc.action, tc.redirectIP, "")
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, rule)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPattern, rule.Pattern)
+			assert.Equal(t, tc.match, rule.Match)
+			assert.Equal(t, tc.action, rule.Action)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package domain
+
+import "errors"
+
+// ImportMode decides what happens to the valid rows of an import that also
+// has invalid ones.
+type ImportMode string
+
+const (
+	// ImportAtomic stores nothing if any row is invalid.
+	ImportAtomic ImportMode = "atomic"
+	// ImportBestEffort skips invalid rows and stores the others.
+	ImportBestEffort ImportMode = "best-effort"
+)
+
+// MaxImportRows limits the number of rows of one bulk import.
+const MaxImportRows = 10000
+
+var (
+	ErrInvalidImportMode = errors.New("invalid import mode")
+	ErrTooManyImportRows = errors.New("too many rows in import")
+)
+
+// RecordImportRow is one record of a bulk import. Row is its position in the
+// input, starting at 1. A nil AdditionalValues keeps those of an existing
+// record; an empty one clears them.
+type RecordImportRow struct {
+	Row              int
+	DomainName       string
+	Type             RecordType
+	Value            string
+	AdditionalValues []string
+}
+
+// RecordImportRowResult is the outcome of one row. Error is set for invalid
+// rows, which have no Action.
+type RecordImportRowResult struct {
+	Row        int
+	DomainName string
+	Action     ZoneChangeAction
+	Record     *DNSRecord
+	Error      string
+}
+
+// RecordImport is the outcome of a bulk import.
+type RecordImport struct {
+	Mode      ImportMode
+	Applied   bool
+	Created   int
+	Updated   int
+	Unchanged int
+	Failed    int
+	Rows      []RecordImportRowResult
+}
+
+// ParseImportMode parses an import mode, defaulting to ImportAtomic.
+func ParseImportMode(s string) (ImportMode, error) {
+	switch ImportMode(s) {
+	case "", ImportAtomic:
+		return ImportAtomic, nil
+	case ImportBestEffort:
+		return ImportBestEffort, nil
+	default:
+		return "", ErrInvalidImportMode
+	}
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrRecordVersionNotFound = errors.New("record version not found")
+	ErrRollbackToDeleted     = errors.New("cannot roll back to a version that deleted the record")
+)
+
+// RecordHistoryActions are the audit actions that change a DNS record.
+var RecordHistoryActions = []ActionType{
+	ActionCreateDNSRecord,
+	ActionUpdateDNSRecord,
+	ActionDeleteDNSRecord,
+	ActionUpdateHealthCheck,
+	ActionUpdateTrafficPolicy,
+	ActionRollbackDNSRecord,
+	ActionRestoreDNSRecord,
+}
+
+// RecordVersion is one state of a DNS record, reconstructed from the audit
+// log. Versions are numbered from 1, oldest first. Record is the state after
+// the change, and nil if the change deleted the record.
+type RecordVersion struct {
+	Version   int
+	Action    ActionType
+	UserID    int64 // Who made the change
+	Username  string
+	Timestamp time.Time
+	ChangeID  string
+	Record    *DNSRecord
+}
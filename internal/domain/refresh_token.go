@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// RefreshTokenTTL is how long a refresh token can be used.
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+// RefreshToken is the server-side record of an issued refresh token; its ID
+// is the token's "jti" claim. Every use rotates the token: it is marked as
+// used and replaced by a new one of the same family. A family starts at a
+// login, so that reuse of a rotated token revokes every token descended
+// from that login; the family is the user's session.
+type RefreshToken struct {
+	ID               string
+	FamilyID         string
+	UserID           int64
+	SessionCreatedAt time.Time // when the family started
+	CreatedAt        time.Time
+	ExpiresAt        time.Time
+	UsedAt           *time.Time
+	ReplacedBy       string // ID of the token that replaced this one
+	RevokedAt        *time.Time
+}
+
+// NewRefreshToken creates the record of the first refresh token of a new
+// family.
+func NewRefreshToken(userID int64) *RefreshToken {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	t := &RefreshToken{
+		ID:               NewChangeID(),
+		UserID:           userID,
+		SessionCreatedAt: now,
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(RefreshTokenTTL),
+	}
+	t.FamilyID = t.ID
+	return t
+}
+
+// Next creates the record of the token that replaces t.
+func (t *RefreshToken) Next() *RefreshToken {
+	next := NewRefreshToken(t.UserID)
+	next.FamilyID = t.FamilyID
+	next.SessionCreatedAt = t.SessionCreatedAt
+	return next
+}
+
+// Usable reports whether the token can be exchanged at now.
+func (t *RefreshToken) Usable(now time.Time) bool {
+	return t.UsedAt == nil && t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
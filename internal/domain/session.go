@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// AccessTokenTTL is how long an access token can be used.
+const AccessTokenTTL = time.Hour
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a login of a user and the refresh tokens issued since. Its ID
+// is the ID of the refresh token family, which access tokens carry as their
+// "sid" claim.
+type Session struct {
+	ID         string
+	UserID     int64
+	CreatedAt  time.Time // when the user logged in
+	LastUsedAt time.Time // when the tokens were last refreshed
+	ExpiresAt  time.Time // when the current refresh token expires
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Algorithms that tokens are signed with.
+const (
+	SigningAlgorithmEdDSA = "EdDSA"
+	SigningAlgorithmRS256 = "RS256"
+)
+
+// rsaKeyBits is the size of generated RSA keys.
+const rsaKeyBits = 2048
+
+var (
+	ErrInvalidSigningAlgorithm = errors.New("signing algorithm must be EdDSA or RS256")
+	ErrNoSigningKey            = errors.New("no signing key")
+	// ErrSigningKeyConflict is returned when another server added a signing
+	// key first.
+	ErrSigningKeyConflict = errors.New("signing key was added concurrently")
+)
+
+// SigningKey is a key pair that tokens are signed with. Its ID is the "kid"
+// header of the tokens. A key is published before it activates, so that
+// services that verify tokens know it before they see tokens signed with
+// it; it signs tokens until the next key activates, and verifies them until
+// they have expired.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	PrivateKey  crypto.Signer
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+}
+
+// ValidateSigningAlgorithm checks that tokens can be signed with algorithm.
+func ValidateSigningAlgorithm(algorithm string) error {
+	if algorithm != SigningAlgorithmEdDSA && algorithm != SigningAlgorithmRS256 {
+		return fmt.Errorf("%w: %q", ErrInvalidSigningAlgorithm, algorithm)
+	}
+	return nil
+}
+
+// NewSigningKey generates a new key pair for algorithm that activates at
+// activatesAt.
+func NewSigningKey(algorithm string, activatesAt time.Time) (*SigningKey, error) {
+	if err := ValidateSigningAlgorithm(algorithm); err != nil {
+		return nil, err
+	}
+
+	var signer crypto.Signer
+	var err error
+	if algorithm == SigningAlgorithmEdDSA {
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		signer, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		ID:          NewChangeID(),
+		Algorithm:   algorithm,
+		PrivateKey:  signer,
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+		ActivatesAt: activatesAt.UTC().Truncate(time.Microsecond),
+	}, nil
+}
+
+// PublicKey returns the key that verifies tokens signed with k.
+func (k *SigningKey) PublicKey() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// Traffic policy limits.
+const (
+	MaxTrafficTargets   = 16
+	MaxTrafficWeight    = 1000
+	MaxSubnetPreference = 64
+)
+
+var (
+	ErrInvalidTrafficPolicy   = errors.New("invalid traffic policy")
+	ErrTrafficPolicyRequiresA = errors.New("traffic policies are only supported for A records")
+)
+
+// TrafficTarget is a candidate address of a traffic policy. Targets are
+// picked with a probability proportional to their weight.
+type TrafficTarget struct {
+	Value  string `json:"value"`
+	Weight int    `json:"weight"`
+}
+
+// SubnetPreference restricts clients in a subnet to some of the targets, e.g.
+// to keep them in their own data centre.
+type SubnetPreference struct {
+	Subnet  string   `json:"subnet"`
+	Targets []string `json:"targets"`
+}
+
+// TrafficPolicy steers the answers of an A record between several targets.
+// It is stored with the record as JSON.
+type TrafficPolicy struct {
+	Targets           []TrafficTarget    `json:"targets"`
+	SubnetPreferences []SubnetPreference `json:"subnetPreferences,omitempty"`
+	MaxAnswers        int                `json:"maxAnswers,omitempty"` // 0 answers with every target
+}
+
+func NewTrafficPolicy(targets []TrafficTarget, preferences []SubnetPreference, maxAnswers int) (*TrafficPolicy, error) {
+	if len(targets) == 0 || len(targets) > MaxTrafficTargets || len(preferences) > MaxSubnetPreference {
+		return nil, ErrInvalidTrafficPolicy
+	}
+	if maxAnswers < 0 || maxAnswers > len(targets) {
+		return nil, ErrInvalidTrafficPolicy
+	}
+
+	policy := &TrafficPolicy{MaxAnswers: maxAnswers}
+	known := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		t.Value = strings.TrimSpace(t.Value)
+		if t.Weight == 0 {
+			t.Weight = 1
+		}
+		if !ipv4Regex.MatchString(t.Value) || known[t.Value] || t.Weight < 1 || t.Weight > MaxTrafficWeight {
+			return nil, ErrInvalidTrafficPolicy
+		}
+		known[t.Value] = true
+		policy.Targets = append(policy.Targets, t)
+	}
+
+	for _, p := range preferences {
+		_, subnet, err := net.ParseCIDR(strings.TrimSpace(p.Subnet))
+		if err != nil || len(p.Targets) == 0 {
+			return nil, ErrInvalidTrafficPolicy
+		}
+		pref := SubnetPreference{Subnet: subnet.String()}
+		for _, v := range p.Targets {
+			v = strings.TrimSpace(v)
+			if !known[v] {
+				return nil, ErrInvalidTrafficPolicy
+			}
+			pref.Targets = append(pref.Targets, v)
+		}
+		policy.SubnetPreferences = append(policy.SubnetPreferences, pref)
+	}
+	return policy, nil
+}
+
+// PreferenceFor returns the most specific subnet preference containing ip
+// and its prefix length, or nil if there is none.
+func (p *TrafficPolicy) PreferenceFor(ip net.IP) (*SubnetPreference, int) {
+	if ip == nil {
+		return nil, 0
+	}
+	var best *SubnetPreference
+	bestLen := -1
+	for i := range p.SubnetPreferences {
+		_, subnet, err := net.ParseCIDR(p.SubnetPreferences[i].Subnet)
+		if err != nil || !subnet.Contains(ip) {
+			continue
+		}
+		if ones, _ := subnet.Mask.Size(); ones > bestLen {
+			best, bestLen = &p.SubnetPreferences[i], ones
+		}
+	}
+	if best == nil {
+		return nil, 0
+	}
+	return best, bestLen
+}
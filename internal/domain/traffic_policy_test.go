@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTrafficPolicy(t *testing.T) {
+	targets := []TrafficTarget{{Value: " 10.0.0.1 ", Weight: 5}, {Value: "10.0.0.2"}}
+
+	t.Run("Valid", func(t *testing.T) {
+		policy, err := NewTrafficPolicy(targets, []SubnetPreference{{Subnet: "10.1.2.3/16", Targets: []string{"10.0.0.2"}}}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []TrafficTarget{{Value: "10.0.0.1", Weight: 5}, {Value: "10.0.0.2", Weight: 1}}, policy.Targets)
+		assert.Equal(t, "10.1.0.0/16", policy.SubnetPreferences[0].Subnet)
+	})
+
+	invalid := []struct {
+		name        string
+		targets     []TrafficTarget
+		preferences []SubnetPreference
+		maxAnswers  int
+	}{
+		{"No targets", nil, nil, 0},
+		{"Invalid address", []TrafficTarget{{Value: "svc.local"}}, nil, 0},
+		{"Duplicate target", []TrafficTarget{{Value: "10.0.0.1"}, {Value: "10.0.0.1"}}, nil, 0},
+		{"Weight too high", []TrafficTarget{{Value: "10.0.0.1", Weight: MaxTrafficWeight + 1}}, nil, 0},
+		{"Negative weight", []TrafficTarget{{Value: "10.0.0.1", Weight: -1}}, nil, 0},
+		{"Too many answers", targets, nil, 3},
+		{"Invalid subnet", targets, []SubnetPreference{{Subnet: "10.0.0.0", Targets: []string{"10.0.0.1"}}}, 0},
+		{"Unknown preferred target", targets, []SubnetPreference{{Subnet: "10.0.0.0/8", Targets: []string{"10.9.9.9"}}}, 0},
+		{"Empty preference", targets, []SubnetPreference{{Subnet: "10.0.0.0/8"}}, 0},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewTrafficPolicy(tc.targets, tc.preferences, tc.maxAnswers)
+			assert.ErrorIs(t, err, ErrInvalidTrafficPolicy)
+		})
+	}
+}
+
+func TestTrafficPolicy_PreferenceFor(t *testing.T) {
+	policy := &TrafficPolicy{SubnetPreferences: []SubnetPreference{
+		{Subnet: "10.0.0.0/8", Targets: []string{"a"}},
+		{Subnet: "10.1.0.0/16", Targets: []string{"b"}},
+		{Subnet: "2001:db8::/32", Targets: []string{"c"}},
+	}}
+
+	pref, prefixLen := policy.PreferenceFor(net.ParseIP("10.1.2.3"))
+	require.NotNil(t, pref)
+	assert.Equal(t, []string{"b"}, pref.Targets)
+	assert.Equal(t, 16, prefixLen)
+
+	pref, prefixLen = policy.PreferenceFor(net.ParseIP("2001:db8::1"))
+	require.NotNil(t, pref)
+	assert.Equal(t, 32, prefixLen)
+
+	pref, _ = policy.PreferenceFor(net.ParseIP("192.168.0.1"))
+	assert.Nil(t, pref)
+	pref, _ = policy.PreferenceFor(nil)
+	assert.Nil(t, pref)
+}
+
+func TestDNSRecord_TrafficPolicyAddresses(t *testing.T) {
+	record := &DNSRecord{Type: A, Value: "10.0.0.1", AdditionalValues: []string{"10.0.0.2"}}
+	require.NoError(t, record.SetTrafficPolicy(&TrafficPolicy{Targets: []TrafficTarget{{Value: "10.0.0.2"}, {Value: "10.0.0.3"}}}))
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, record.Addresses())
+
+	cname := &DNSRecord{Type: CNAME, Value: "svc.example.com"}
+	assert.ErrorIs(t, cname.SetTrafficPolicy(&TrafficPolicy{}), ErrTrafficPolicyRequiresA)
+}
@@ -23,6 +23,10 @@ type User struct {
 	IsEnabled    bool      `json:"is_enabled"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	// IsServiceAccount is set for the accounts of automation. They cannot
+	// log in and authenticate with API keys instead.
+	IsServiceAccount bool `json:"is_service_account"`
 }
 
 var (
@@ -56,8 +60,25 @@ func NewUser(username, password string, role UserRole) (*User, error) {
 	}, nil
 }
 
+// NewServiceAccount creates a service account. It has no password, so that
+// it can only authenticate with its API keys.
+func NewServiceAccount(username string) (*User, error) {
+	if len(username) < 3 {
+		return nil, ErrUsernameTooShort
+	}
+	return &User{
+		Username:         username,
+		Role:             RoleUser,
+		IsEnabled:        true,
+		IsServiceAccount: true,
+	}, nil
+}
+
 // ValidatePassword checks if the provided password matches the user's hashed password.
 func (u *User) ValidatePassword(password string) bool {
+	if u.IsServiceAccount {
+		return false
+	}
 	return util.CheckPasswordHash(password, u.PasswordHash) // Changed to use util
 }
 
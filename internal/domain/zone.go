@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+// ZoneChangeAction is what an import does to one record.
+type ZoneChangeAction string
+
+const (
+	ZoneChangeCreate    ZoneChangeAction = "create"
+	ZoneChangeUpdate    ZoneChangeAction = "update"
+	ZoneChangeUnchanged ZoneChangeAction = "unchanged"
+)
+
+var ErrInvalidZone = errors.New("invalid zone name")
+
+// ZoneIssue is an error or warning about one line of a zone file.
+type ZoneIssue struct {
+	Line    int
+	Message string
+}
+
+// ZoneChange is one entry of an import diff. Previous is the stored record
+// for updates and unchanged records.
+type ZoneChange struct {
+	Line     int
+	Action   ZoneChangeAction
+	Record   *DNSRecord
+	Previous *DNSRecord
+}
+
+// ZoneImport is the outcome of a zone file import. Nothing is applied while
+// there are errors or on a dry run.
+type ZoneImport struct {
+	Zone     string
+	DryRun   bool
+	Applied  bool
+	Changes  []ZoneChange
+	Errors   []ZoneIssue
+	Warnings []ZoneIssue
+}
+
+// NormalizeZone lowercases a zone name and strips the trailing dot.
+func NormalizeZone(zone string) (string, error) {
+	zone = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(zone)), ".")
+	if !policyNameRegex.MatchString(zone) {
+		return "", ErrInvalidZone
+	}
+	return zone, nil
+}
+
+// InZone reports whether name is zone itself or a name below it. Both must
+// be normalized.
+func InZone(name, zone string) bool {
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	loginFailuresKeyPrefix = "login_failures:"
+	loginBlockedKeyPrefix  = "login_blocked:"
+)
+
+// releaseLoginAttemptScript takes back a login attempt, unless its count has
+// expired meanwhile, so that no count without an expiry is left behind.
+var releaseLoginAttemptScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('DECR', KEYS[1])
+end
+return 0
+`)
+
+// LoginThrottle counts failed logins and refuses further ones, per key such
+// as a username or a client IP. The policy is up to the caller.
+type LoginThrottle interface {
+	// RecordAttempt counts a login of key as failed before it is checked,
+	// so that concurrent logins see it, and returns the number of failures
+	// in a row including it. They are forgotten window after the last one.
+	RecordAttempt(ctx context.Context, key string, window time.Duration) (int64, error)
+	// Release takes back a login counted by RecordAttempt that did not
+	// fail.
+	Release(ctx context.Context, key string) error
+	// Block refuses the logins of key for d, unless they are refused
+	// already. It reports whether it blocked them.
+	Block(ctx context.Context, key string, d time.Duration) (bool, error)
+	// Unblock accepts the logins of key again.
+	Unblock(ctx context.Context, key string) error
+	// RetryAfter returns how long the logins of any of keys are still
+	// refused, or 0 if none of them is blocked.
+	RetryAfter(ctx context.Context, keys ...string) (time.Duration, error)
+	// Reset forgets the failed logins of key and accepts its logins again.
+	Reset(ctx context.Context, key string) error
+}
+
+type loginThrottleRedis struct {
+	client *redis.Client
+}
+
+// NewLoginThrottle creates a new Redis-backed login throttle.
+func NewLoginThrottle(client *redis.Client) LoginThrottle {
+	return &loginThrottleRedis{client: client}
+}
+
+func (t *loginThrottleRedis) RecordAttempt(ctx context.Context, key string, window time.Duration) (int64, error) {
+	var failures *redis.IntCmd
+	_, err := t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		failures = pipe.Incr(ctx, loginFailuresKeyPrefix+key)
+		pipe.PExpire(ctx, loginFailuresKeyPrefix+key, window)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to record login attempt in redis: %w", err)
+	}
+	return failures.Val(), nil
+}
+
+func (t *loginThrottleRedis) Release(ctx context.Context, key string) error {
+	if err := releaseLoginAttemptScript.Run(ctx, t.client, []string{loginFailuresKeyPrefix + key}).Err(); err != nil {
+		return fmt.Errorf("failed to release login attempt in redis: %w", err)
+	}
+	return nil
+}
+
+func (t *loginThrottleRedis) Block(ctx context.Context, key string, d time.Duration) (bool, error) {
+	blocked, err := t.client.SetNX(ctx, loginBlockedKeyPrefix+key, 1, d).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to block logins in redis: %w", err)
+	}
+	return blocked, nil
+}
+
+func (t *loginThrottleRedis) Unblock(ctx context.Context, key string) error {
+	if err := t.client.Del(ctx, loginBlockedKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to unblock logins in redis: %w", err)
+	}
+	return nil
+}
+
+func (t *loginThrottleRedis) RetryAfter(ctx context.Context, keys ...string) (time.Duration, error) {
+	cmds := make([]*redis.DurationCmd, len(keys))
+	_, err := t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.PTTL(ctx, loginBlockedKeyPrefix+key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to check blocked logins in redis: %w", err)
+	}
+
+	// Missing keys have a negative TTL
+	var retryAfter time.Duration
+	for _, cmd := range cmds {
+		if ttl := cmd.Val(); ttl > retryAfter {
+			retryAfter = ttl
+		}
+	}
+	return retryAfter, nil
+}
+
+func (t *loginThrottleRedis) Reset(ctx context.Context, key string) error {
+	if err := t.client.Del(ctx, loginFailuresKeyPrefix+key, loginBlockedKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to reset failed logins in redis: %w", err)
+	}
+	return nil
+}
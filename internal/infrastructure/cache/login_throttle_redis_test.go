@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginThrottleRedis(t *testing.T) {
+	client := setupTestRedis(t)
+	throttle := NewLoginThrottle(client)
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		failures, err := throttle.RecordAttempt(ctx, "username:alice", 15*time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, want, failures)
+	}
+	ttl, err := client.TTL(ctx, loginFailuresKeyPrefix+"username:alice").Result()
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, ttl)
+
+	// Keys are counted separately
+	failures, err := throttle.RecordAttempt(ctx, "ip:192.0.2.1", 15*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), failures)
+
+	retryAfter, err := throttle.RetryAfter(ctx, "username:alice", "ip:192.0.2.1")
+	require.NoError(t, err)
+	assert.Zero(t, retryAfter)
+
+	// Released attempts are taken back, but expired counts are not revived
+	require.NoError(t, throttle.Release(ctx, "ip:192.0.2.1"))
+	failures, err = throttle.RecordAttempt(ctx, "ip:192.0.2.1", 15*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), failures)
+	require.NoError(t, throttle.Release(ctx, "ip:198.51.100.1"))
+	exists, err := client.Exists(ctx, loginFailuresKeyPrefix+"ip:198.51.100.1").Result()
+	require.NoError(t, err)
+	assert.Zero(t, exists)
+
+	// The longest block wins
+	blocked, err := throttle.Block(ctx, "username:alice", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, blocked)
+	blocked, err = throttle.Block(ctx, "ip:192.0.2.1", 2*time.Second)
+	require.NoError(t, err)
+	assert.True(t, blocked)
+	retryAfter, err = throttle.RetryAfter(ctx, "username:alice", "ip:192.0.2.1")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute.Seconds(), retryAfter.Seconds(), 1)
+	retryAfter, err = throttle.RetryAfter(ctx, "username:bob", "ip:192.0.2.1")
+	require.NoError(t, err)
+	assert.InDelta(t, 2, retryAfter.Seconds(), 1)
+
+	// Blocked logins are not blocked again
+	blocked, err = throttle.Block(ctx, "ip:192.0.2.1", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, blocked)
+	require.NoError(t, throttle.Unblock(ctx, "ip:192.0.2.1"))
+	retryAfter, err = throttle.RetryAfter(ctx, "ip:192.0.2.1")
+	require.NoError(t, err)
+	assert.Zero(t, retryAfter)
+
+	// Resetting forgets the failures and the block
+	require.NoError(t, throttle.Reset(ctx, "username:alice"))
+	retryAfter, err = throttle.RetryAfter(ctx, "username:alice")
+	require.NoError(t, err)
+	assert.Zero(t, retryAfter)
+	failures, err = throttle.RecordAttempt(ctx, "username:alice", 15*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), failures)
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"internal-dns/internal/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const mfaChallengeKeyPrefix = "mfa_challenge:"
+
+// MFAChallengeStore keeps the logins that wait for a two-factor
+// authentication code. They expire domain.MFAChallengeTTL after they were
+// created.
+type MFAChallengeStore interface {
+	// Save stores a challenge until it expires; expired challenges are not
+	// stored.
+	Save(ctx context.Context, challenge *domain.MFAChallenge) error
+	// Take returns and removes the challenge of token, so that concurrent
+	// requests cannot answer it more than once. It returns
+	// domain.ErrInvalidMFAChallenge if there is no such challenge.
+	Take(ctx context.Context, token string) (*domain.MFAChallenge, error)
+}
+
+type mfaChallengeRedis struct {
+	client *redis.Client
+}
+
+// NewMFAChallengeStore creates a new Redis-backed store of MFA challenges.
+func NewMFAChallengeStore(client *redis.Client) MFAChallengeStore {
+	return &mfaChallengeRedis{client: client}
+}
+
+func (s *mfaChallengeRedis) Save(ctx context.Context, challenge *domain.MFAChallenge) error {
+	ttl := time.Until(challenge.ExpiresAt())
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Set(ctx, mfaChallengeKeyPrefix+challenge.Token, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save MFA challenge in redis: %w", err)
+	}
+	return nil
+}
+
+func (s *mfaChallengeRedis) Take(ctx context.Context, token string) (*domain.MFAChallenge, error) {
+	if token == "" {
+		return nil, domain.ErrInvalidMFAChallenge
+	}
+	data, err := s.client.GetDel(ctx, mfaChallengeKeyPrefix+token).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, domain.ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to take MFA challenge from redis: %w", err)
+	}
+	challenge := &domain.MFAChallenge{}
+	if err := json.Unmarshal(data, challenge); err != nil {
+		return nil, fmt.Errorf("invalid MFA challenge in redis: %w", err)
+	}
+	return challenge, nil
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMFAChallengeRedis(t *testing.T) {
+	client := setupTestRedis(t)
+	store := NewMFAChallengeStore(client)
+	ctx := context.Background()
+
+	challenge, err := domain.NewMFAChallenge(&domain.User{ID: 7, Username: "alice"}, "local", false)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, challenge))
+
+	ttl, err := client.TTL(ctx, mfaChallengeKeyPrefix+challenge.Token).Result()
+	require.NoError(t, err)
+	assert.InDelta(t, domain.MFAChallengeTTL.Seconds(), ttl.Seconds(), 1)
+
+	taken, err := store.Take(ctx, challenge.Token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), taken.UserID)
+	assert.Equal(t, "local", taken.Authenticator)
+
+	// A challenge is answered once
+	_, err = store.Take(ctx, challenge.Token)
+	assert.ErrorIs(t, err, domain.ErrInvalidMFAChallenge)
+	_, err = store.Take(ctx, "")
+	assert.ErrorIs(t, err, domain.ErrInvalidMFAChallenge)
+
+	// Saving a challenge again keeps its expiry
+	taken.Attempts++
+	taken.CreatedAt = time.Now().Add(-domain.MFAChallengeTTL + time.Minute)
+	require.NoError(t, store.Save(ctx, taken))
+	ttl, err = client.TTL(ctx, mfaChallengeKeyPrefix+challenge.Token).Result()
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute.Seconds(), ttl.Seconds(), 1)
+
+	// Expired challenges are not saved
+	taken, err = store.Take(ctx, challenge.Token)
+	require.NoError(t, err)
+	taken.CreatedAt = time.Now().Add(-domain.MFAChallengeTTL)
+	require.NoError(t, store.Save(ctx, taken))
+	_, err = store.Take(ctx, challenge.Token)
+	assert.ErrorIs(t, err, domain.ErrInvalidMFAChallenge)
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"internal-dns/internal/domain"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	queryStatsCountKey = "dns_stats:count"
+	queryStatsLastKey  = "dns_stats:last"
+)
+
+// addQueryStatsScript increments the query count of every name and keeps the
+// most recent last-queried timestamp. ARGV holds (name, count, unix) triples.
+var addQueryStatsScript = redis.NewScript(`
+for i = 1, #ARGV, 3 do
+	local name = ARGV[i]
+	redis.call('HINCRBY', KEYS[1], name, ARGV[i + 1])
+	local last = tonumber(redis.call('HGET', KEYS[2], name) or '0')
+	if tonumber(ARGV[i + 2]) > last then
+		redis.call('HSET', KEYS[2], name, ARGV[i + 2])
+	end
+end
+return 1
+`)
+
+// drainQueryStatsScript returns and removes all pending statistics atomically,
+// so that concurrent DNS servers never flush the same counts twice.
+var drainQueryStatsScript = redis.NewScript(`
+local counts = redis.call('HGETALL', KEYS[1])
+local last = redis.call('HGETALL', KEYS[2])
+redis.call('DEL', KEYS[1], KEYS[2])
+return {counts, last}
+`)
+
+// QueryStatsStore accumulates per-name query statistics shared by all DNS
+// servers until they are flushed to the database.
+type QueryStatsStore interface {
+	Add(ctx context.Context, stats []domain.QueryStat) error
+	Drain(ctx context.Context) ([]domain.QueryStat, error)
+}
+
+type queryStatsRedis struct {
+	client *redis.Client
+}
+
+// NewQueryStatsStore creates a new Redis-backed query statistics store.
+func NewQueryStatsStore(client *redis.Client) QueryStatsStore {
+	return &queryStatsRedis{client: client}
+}
+
+func (s *queryStatsRedis) Add(ctx context.Context, stats []domain.QueryStat) error {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(stats)*3)
+	for _, stat := range stats {
+		args = append(args, stat.DomainName, stat.Count, stat.LastQueriedAt.Unix())
+	}
+	keys := []string{queryStatsCountKey, queryStatsLastKey}
+	if err := addQueryStatsScript.Run(ctx, s.client, keys, args...).Err(); err != nil {
+		return fmt.Errorf("failed to add query stats to redis: %w", err)
+	}
+	return nil
+}
+
+func (s *queryStatsRedis) Drain(ctx context.Context) ([]domain.QueryStat, error) {
+	keys := []string{queryStatsCountKey, queryStatsLastKey}
+	res, err := drainQueryStatsScript.Run(ctx, s.client, keys).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to drain query stats from redis: %w", err)
+	}
+	if len(res) != 2 {
+		return nil, fmt.Errorf("unexpected query stats reply of length %d", len(res))
+	}
+
+	counts, _ := res[0].([]interface{})
+	last, _ := res[1].([]interface{})
+
+	lastByName := make(map[string]int64, len(last)/2)
+	for i := 0; i+1 < len(last); i += 2 {
+		name, _ := last[i].(string)
+		unix, _ := strconv.ParseInt(fmt.Sprint(last[i+1]), 10, 64)
+		lastByName[name] = unix
+	}
+
+	stats := make([]domain.QueryStat, 0, len(counts)/2)
+	for i := 0; i+1 < len(counts); i += 2 {
+		name, _ := counts[i].(string)
+		count, err := strconv.ParseInt(fmt.Sprint(counts[i+1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query count for %s: %w", name, err)
+		}
+		stats = append(stats, domain.QueryStat{
+			DomainName:    name,
+			Count:         count,
+			LastQueriedAt: time.Unix(lastByName[name], 0).UTC(),
+		})
+	}
+	return stats, nil
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"internal-dns/internal/domain"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryStatsRedis(t *testing.T) {
+	client := setupTestRedis(t)
+	store := NewQueryStatsStore(client)
+	ctx := context.Background()
+
+	t1 := time.Unix(1700000000, 0).UTC()
+	t2 := t1.Add(time.Minute)
+
+	t.Run("Drain empty", func(t *testing.T) {
+		stats, err := store.Drain(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, stats)
+	})
+
+	t.Run("Add aggregates counts and keeps latest timestamp", func(t *testing.T) {
+		require.NoError(t, store.Add(ctx, []domain.QueryStat{
+			{DomainName: "a.local", Count: 2, LastQueriedAt: t2},
+			{DomainName: "b.local", Count: 1, LastQueriedAt: t1},
+		}))
+		require.NoError(t, store.Add(ctx, []domain.QueryStat{
+			{DomainName: "a.local", Count: 3, LastQueriedAt: t1},
+		}))
+
+		stats, err := store.Drain(ctx)
+		require.NoError(t, err)
+		sort.Slice(stats, func(i, j int) bool { return stats[i].DomainName < stats[j].DomainName })
+
+		assert.Equal(t, []domain.QueryStat{
+			{DomainName: "a.local", Count: 5, LastQueriedAt: t2},
+			{DomainName: "b.local", Count: 1, LastQueriedAt: t1},
+		}, stats)
+	})
+
+	t.Run("Drain removes pending stats", func(t *testing.T) {
+		stats, err := store.Drain(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, stats)
+	})
+}
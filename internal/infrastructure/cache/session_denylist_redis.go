@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"internal-dns/internal/domain"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	sessionDenylistKeyPrefix = "session_denylist:"
+	userDenylistKeyPrefix    = "user_denylist:"
+)
+
+// SessionDenylist rejects access tokens before they expire. Entries only
+// need to outlive the access tokens they reject, so they expire after
+// domain.AccessTokenTTL.
+type SessionDenylist interface {
+	// RevokeSession rejects the access tokens of a session.
+	RevokeSession(ctx context.Context, sessionID string) error
+	// RevokeUser rejects the access tokens of a user issued up to at.
+	RevokeUser(ctx context.Context, userID int64, at time.Time) error
+	// IsRevoked reports whether an access token of a session and user,
+	// issued at issuedAt, was revoked.
+	IsRevoked(ctx context.Context, sessionID string, userID int64, issuedAt time.Time) (bool, error)
+}
+
+type sessionDenylistRedis struct {
+	client *redis.Client
+}
+
+// NewSessionDenylist creates a new Redis-backed session denylist.
+func NewSessionDenylist(client *redis.Client) SessionDenylist {
+	return &sessionDenylistRedis{client: client}
+}
+
+func (d *sessionDenylistRedis) RevokeSession(ctx context.Context, sessionID string) error {
+	if err := d.client.Set(ctx, sessionDenylistKeyPrefix+sessionID, 1, domain.AccessTokenTTL).Err(); err != nil {
+		return fmt.Errorf("failed to revoke session in redis: %w", err)
+	}
+	return nil
+}
+
+// RevokeUser stores the time of the revocation. Token issue times are whole
+// seconds, so tokens issued in the same second as the revocation are
+// rejected as well.
+func (d *sessionDenylistRedis) RevokeUser(ctx context.Context, userID int64, at time.Time) error {
+	key := userDenylistKeyPrefix + strconv.FormatInt(userID, 10)
+	if err := d.client.Set(ctx, key, at.Unix(), domain.AccessTokenTTL).Err(); err != nil {
+		return fmt.Errorf("failed to revoke user sessions in redis: %w", err)
+	}
+	return nil
+}
+
+func (d *sessionDenylistRedis) IsRevoked(ctx context.Context, sessionID string, userID int64, issuedAt time.Time) (bool, error) {
+	keys := []string{userDenylistKeyPrefix + strconv.FormatInt(userID, 10)}
+	if sessionID != "" {
+		keys = append(keys, sessionDenylistKeyPrefix+sessionID)
+	}
+	values, err := d.client.MGet(ctx, keys...).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, fmt.Errorf("failed to check session denylist in redis: %w", err)
+	}
+
+	if len(values) > 1 && values[1] != nil {
+		return true, nil
+	}
+	if revokedAt, ok := values[0].(string); ok {
+		at, err := strconv.ParseInt(revokedAt, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid user denylist entry: %w", err)
+		}
+		return issuedAt.Unix() <= at, nil
+	}
+	return false, nil
+}
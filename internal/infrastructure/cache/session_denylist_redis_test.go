@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionDenylistRedis(t *testing.T) {
+	client := setupTestRedis(t)
+	denylist := NewSessionDenylist(client)
+	ctx := context.Background()
+	issuedAt := time.Unix(1700000000, 0)
+
+	t.Run("Nothing revoked", func(t *testing.T) {
+		revoked, err := denylist.IsRevoked(ctx, "a", 1, issuedAt)
+		require.NoError(t, err)
+		assert.False(t, revoked)
+
+		// Tokens issued before sessions were tracked have no session
+		revoked, err = denylist.IsRevoked(ctx, "", 1, issuedAt)
+		require.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("Revoke session", func(t *testing.T) {
+		require.NoError(t, denylist.RevokeSession(ctx, "a"))
+
+		revoked, err := denylist.IsRevoked(ctx, "a", 1, issuedAt)
+		require.NoError(t, err)
+		assert.True(t, revoked)
+
+		revoked, err = denylist.IsRevoked(ctx, "b", 1, issuedAt)
+		require.NoError(t, err)
+		assert.False(t, revoked)
+
+		ttl, err := client.TTL(ctx, sessionDenylistKeyPrefix+"a").Result()
+		require.NoError(t, err)
+		assert.Equal(t, time.Hour, ttl)
+	})
+
+	t.Run("Revoke user", func(t *testing.T) {
+		require.NoError(t, denylist.RevokeUser(ctx, 2, issuedAt.Add(500*time.Millisecond)))
+
+		for _, tc := range []struct {
+			sessionID string
+			issuedAt  time.Time
+			revoked   bool
+		}{
+			{"b", issuedAt.Add(-time.Minute), true},
+			{"", issuedAt.Add(-time.Minute), true},
+			{"b", issuedAt, true},
+			{"b", issuedAt.Add(time.Second), false},
+		} {
+			revoked, err := denylist.IsRevoked(ctx, tc.sessionID, 2, tc.issuedAt)
+			require.NoError(t, err)
+			assert.Equal(t, tc.revoked, revoked, "issued at %v", tc.issuedAt)
+		}
+
+		// Other users are not affected
+		revoked, err := denylist.IsRevoked(ctx, "b", 3, issuedAt)
+		require.NoError(t, err)
+		assert.False(t, revoked)
+	})
+}
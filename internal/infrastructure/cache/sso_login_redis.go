@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"internal-dns/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const ssoLoginKeyPrefix = "sso_login:"
+
+// SSOLoginStore keeps logins at an identity provider while the user is away
+// at the provider. They expire after domain.SSOLoginTTL.
+type SSOLoginStore interface {
+	Save(ctx context.Context, login *domain.SSOLogin) error
+	// Take returns and removes the login of state, so that it can complete
+	// once. It returns domain.ErrInvalidSSOLogin if there is no such login.
+	Take(ctx context.Context, state string) (*domain.SSOLogin, error)
+}
+
+type ssoLoginRedis struct {
+	client *redis.Client
+}
+
+// NewSSOLoginStore creates a new Redis-backed store of SSO logins.
+func NewSSOLoginStore(client *redis.Client) SSOLoginStore {
+	return &ssoLoginRedis{client: client}
+}
+
+func (s *ssoLoginRedis) Save(ctx context.Context, login *domain.SSOLogin) error {
+	data, err := json.Marshal(login)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Set(ctx, ssoLoginKeyPrefix+login.State, data, domain.SSOLoginTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save SSO login in redis: %w", err)
+	}
+	return nil
+}
+
+func (s *ssoLoginRedis) Take(ctx context.Context, state string) (*domain.SSOLogin, error) {
+	data, err := s.client.GetDel(ctx, ssoLoginKeyPrefix+state).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, domain.ErrInvalidSSOLogin
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to take SSO login from redis: %w", err)
+	}
+	login := &domain.SSOLogin{}
+	if err := json.Unmarshal(data, login); err != nil {
+		return nil, fmt.Errorf("invalid SSO login in redis: %w", err)
+	}
+	return login, nil
+}
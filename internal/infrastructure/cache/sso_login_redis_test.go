@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"internal-dns/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSOLoginRedis(t *testing.T) {
+	client := setupTestRedis(t)
+	store := NewSSOLoginStore(client)
+	ctx := context.Background()
+
+	login, err := domain.NewSSOLogin()
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, login))
+
+	ttl, err := client.TTL(ctx, ssoLoginKeyPrefix+login.State).Result()
+	require.NoError(t, err)
+	assert.Equal(t, domain.SSOLoginTTL, ttl)
+
+	taken, err := store.Take(ctx, login.State)
+	require.NoError(t, err)
+	assert.Equal(t, login.Nonce, taken.Nonce)
+	assert.Equal(t, login.CodeVerifier, taken.CodeVerifier)
+
+	// A login completes once
+	_, err = store.Take(ctx, login.State)
+	assert.ErrorIs(t, err, domain.ErrInvalidSSOLogin)
+	_, err = store.Take(ctx, "unknown")
+	assert.ErrorIs(t, err, domain.ErrInvalidSSOLogin)
+}
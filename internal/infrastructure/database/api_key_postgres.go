@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+type apiKeyPostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAPIKeyPostgresRepository(db *pgxpool.Pool) repository.APIKeyRepository {
+	return &apiKeyPostgresRepository{db: db}
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, secret_hash, scopes, suffixes, expires_at, last_used_at, created_at, rotated_at, revoked_at`
+
+func scanAPIKey(row pgx.Row) (*domain.APIKey, error) {
+	k := &domain.APIKey{}
+	var scopes []string
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.SecretHash, &scopes, &k.Suffixes,
+		&k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt, &k.RotatedAt, &k.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range scopes {
+		k.Scopes = append(k.Scopes, domain.APIKeyScope(s))
+	}
+	return k, nil
+}
+
+func (r *apiKeyPostgresRepository) Create(ctx context.Context, k *domain.APIKey) error {
+	scopes := make([]string, len(k.Scopes))
+	for i, s := range k.Scopes {
+		scopes[i] = string(s)
+	}
+	suffixes := k.Suffixes
+	if suffixes == nil {
+		suffixes = []string{}
+	}
+	query := `INSERT INTO api_keys (user_id, name, prefix, secret_hash, scopes, suffixes, expires_at, created_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+              RETURNING id`
+	return conn(ctx, r.db).QueryRow(ctx, query, k.UserID, k.Name, k.Prefix, k.SecretHash, scopes, suffixes, k.ExpiresAt, k.CreatedAt).Scan(&k.ID)
+}
+
+func (r *apiKeyPostgresRepository) FindByID(ctx context.Context, id int64) (*domain.APIKey, error) {
+	return scanAPIKey(conn(ctx, r.db).QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id))
+}
+
+func (r *apiKeyPostgresRepository) FindByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	return scanAPIKey(conn(ctx, r.db).QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE prefix = $1`, prefix))
+}
+
+func (r *apiKeyPostgresRepository) ListByUserID(ctx context.Context, userID int64) ([]*domain.APIKey, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*domain.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *apiKeyPostgresRepository) Rotate(ctx context.Context, k *domain.APIKey) error {
+	query := `UPDATE api_keys SET prefix = $2, secret_hash = $3, rotated_at = $4
+              WHERE id = $1 AND revoked_at IS NULL`
+	tag, err := conn(ctx, r.db).Exec(ctx, query, k.ID, k.Prefix, k.SecretHash, k.RotatedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *apiKeyPostgresRepository) Revoke(ctx context.Context, id int64, at time.Time) error {
+	tag, err := conn(ctx, r.db).Exec(ctx, `UPDATE api_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL`, id, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *apiKeyPostgresRepository) Touch(ctx context.Context, id int64, at time.Time) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, at)
+	return err
+}
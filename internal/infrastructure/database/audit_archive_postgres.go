@@ -0,0 +1,306 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+type auditArchivePostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditArchivePostgresRepository(db *pgxpool.Pool) repository.AuditArchiveRepository {
+	return &auditArchivePostgresRepository{db: db}
+}
+
+// auditArchiveLockID is the advisory lock held while entries are archived.
+const auditArchiveLockID = 0x61726368
+
+const auditDefaultPartition = "audit_logs_default"
+
+func auditPartitionName(month time.Time) string {
+	return "audit_logs_" + month.UTC().Format("2006_01")
+}
+
+func (r *auditArchivePostgresRepository) EnsurePartition(ctx context.Context, month time.Time) error {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	name := pgx.Identifier{auditPartitionName(from)}.Sanitize()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Attaching a partition locks the default partition anyway; locking it
+	// first keeps other servers from creating the same partition
+	if _, err := tx.Exec(ctx, "LOCK TABLE "+auditDefaultPartition+" IN ACCESS EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", auditPartitionName(from)).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, "CREATE TABLE "+name+" (LIKE audit_logs INCLUDING DEFAULTS INCLUDING CONSTRAINTS)"); err != nil {
+		return err
+	}
+	moved := "FROM " + auditDefaultPartition + " WHERE created_at >= $1 AND created_at < $2"
+	if _, err := tx.Exec(ctx, "INSERT INTO "+name+" SELECT * "+moved, from, to); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "DELETE "+moved, from, to); err != nil {
+		return err
+	}
+	// Partition bounds cannot be parameters
+	attach := fmt.Sprintf("ALTER TABLE audit_logs ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')",
+		name, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	if _, err := tx.Exec(ctx, attach); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// auditPartitionBound matches a bound in the text of a partition bound
+// expression, e.g. FOR VALUES FROM (MINVALUE) TO ('2024-06-01 00:00:00+00').
+var auditPartitionBound = regexp.MustCompile(`(FROM|TO) \('([^']+)'\)`)
+
+func (r *auditArchivePostgresRepository) ListPartitions(ctx context.Context) ([]*domain.AuditPartition, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Bounds are printed in the session time zone
+	if _, err := tx.Exec(ctx, "SET LOCAL TimeZone TO 'UTC'"); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT c.relname, pg_get_expr(c.relpartbound, c.oid), GREATEST(c.reltuples, 0)::BIGINT
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'audit_logs'::regclass
+		ORDER BY c.relname
+	`
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partitions []*domain.AuditPartition
+	for rows.Next() {
+		p := &domain.AuditPartition{}
+		var bound string
+		if err := rows.Scan(&p.Name, &bound, &p.Rows); err != nil {
+			return nil, err
+		}
+		for _, m := range auditPartitionBound.FindAllStringSubmatch(bound, -1) {
+			t, err := time.Parse("2006-01-02 15:04:05.999999-07", m[2])
+			if err != nil {
+				return nil, fmt.Errorf("partition %s: %w", p.Name, err)
+			}
+			if m[1] == "FROM" {
+				p.From = t
+			} else {
+				p.To = t
+			}
+		}
+		partitions = append(partitions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// The legacy partition first, the default partition last
+	sort.SliceStable(partitions, func(i, j int) bool {
+		a, b := partitions[i], partitions[j]
+		if a.To.IsZero() || b.To.IsZero() {
+			return b.To.IsZero() && !a.To.IsZero()
+		}
+		return a.From.Before(b.From)
+	})
+	return partitions, nil
+}
+
+// Archive runs in a repeatable read transaction when removing expired
+// entries, so that it deletes exactly the entries it archived. Dropping a
+// partition locks it against inserts before reading it instead.
+func (r *auditArchivePostgresRepository) Archive(ctx context.Context, archive *domain.AuditArchive, retention domain.AuditRetention, w repository.AuditArchiveWriter) error {
+	opts := pgx.TxOptions{IsoLevel: pgx.RepeatableRead}
+	if archive.Dropped {
+		opts.IsoLevel = pgx.ReadCommitted
+	}
+	tx, err := r.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1)", auditArchiveLockID).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return domain.ErrAuditArchiveBusy
+	}
+
+	partition := pgx.Identifier{archive.Partition}.Sanitize()
+	where := "TRUE"
+	var args []any
+	if archive.Dropped {
+		if _, err := tx.Exec(ctx, "LOCK TABLE "+partition+" IN EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+	} else {
+		where, args = auditExpiredCondition(retention, archive.CreatedAt)
+	}
+
+	query := `
+		SELECT ` + auditLogColumns + `
+		FROM ` + partition + ` a
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE ` + where + `
+		ORDER BY a.id
+	`
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	archive.Entries = 0
+	err = forEachAuditLog(rows, func(log *domain.AuditLog) error {
+		if archive.Entries == 0 {
+			archive.FirstID = log.ID
+		}
+		archive.Entries++
+		archive.LastID = log.ID
+		return w.Write(log)
+	})
+	if err != nil {
+		return err
+	}
+	gaps, err := w.Close()
+	if err != nil {
+		return err
+	}
+	if archive.Entries == 0 && !archive.Dropped {
+		return nil
+	}
+
+	if archive.Dropped {
+		_, err = tx.Exec(ctx, "DROP TABLE "+partition)
+	} else {
+		_, err = tx.Exec(ctx, "DELETE FROM "+partition+" a WHERE "+where, args...)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Checkpoints of removed entries can no longer be verified
+	if archive.Entries > 0 {
+		_, err = tx.Exec(ctx, `
+			DELETE FROM audit_checkpoints c
+			WHERE c.log_id BETWEEN $1 AND $2 AND NOT EXISTS (SELECT 1 FROM audit_logs a WHERE a.id = c.log_id)`,
+			archive.FirstID, archive.LastID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO audit_archives (partition_name, file, entries, first_id, last_id, dropped, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		archive.Partition, archive.File, archive.Entries, archive.FirstID, archive.LastID, archive.Dropped, archive.CreatedAt,
+	).Scan(&archive.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(gaps) > 0 {
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"audit_chain_gaps"},
+			[]string{"archive_id", "from_hash", "to_hash", "entries", "signature"},
+			pgx.CopyFromSlice(len(gaps), func(i int) ([]any, error) {
+				g := gaps[i]
+				g.ArchiveID = archive.ID
+				return []any{g.ArchiveID, g.FromHash, g.ToHash, g.Entries, g.Signature}, nil
+			}))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// auditExpiredCondition returns the condition that selects entries that
+// expired under retention at now, with its arguments.
+func auditExpiredCondition(retention domain.AuditRetention, now time.Time) (string, []any) {
+	cutoff := func(d time.Duration) any {
+		if d == 0 {
+			return nil
+		}
+		return now.Add(-d)
+	}
+
+	var args []any
+	cond := "a.created_at < CASE a.action"
+	for _, action := range retention.ActionNames() {
+		args = append(args, string(action), cutoff(retention.Actions[action]))
+		cond += fmt.Sprintf(" WHEN $%d THEN $%d::TIMESTAMPTZ", len(args)-1, len(args))
+	}
+	args = append(args, cutoff(retention.Default))
+	cond += fmt.Sprintf(" ELSE $%d::TIMESTAMPTZ END", len(args))
+	return cond, args
+}
+
+func (r *auditArchivePostgresRepository) ListArchives(ctx context.Context, limit int) ([]*domain.AuditArchive, error) {
+	query := `SELECT id, partition_name, file, entries, first_id, last_id, dropped, created_at
+              FROM audit_archives ORDER BY id DESC LIMIT $1`
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var archives []*domain.AuditArchive
+	for rows.Next() {
+		a := &domain.AuditArchive{}
+		if err := rows.Scan(&a.ID, &a.Partition, &a.File, &a.Entries, &a.FirstID, &a.LastID, &a.Dropped, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		archives = append(archives, a)
+	}
+	return archives, rows.Err()
+}
+
+func (r *auditArchivePostgresRepository) ListGaps(ctx context.Context) ([]*domain.AuditChainGap, error) {
+	query := `SELECT id, archive_id, from_hash, to_hash, entries, signature FROM audit_chain_gaps ORDER BY id`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var gaps []*domain.AuditChainGap
+	for rows.Next() {
+		g := &domain.AuditChainGap{}
+		if err := rows.Scan(&g.ID, &g.ArchiveID, &g.FromHash, &g.ToHash, &g.Entries, &g.Signature); err != nil {
+			return nil, err
+		}
+		gaps = append(gaps, g)
+	}
+	return gaps, rows.Err()
+}
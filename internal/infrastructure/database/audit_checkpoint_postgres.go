@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+type auditCheckpointPostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditCheckpointPostgresRepository(db *pgxpool.Pool) repository.AuditCheckpointRepository {
+	return &auditCheckpointPostgresRepository{db: db}
+}
+
+func (r *auditCheckpointPostgresRepository) Create(ctx context.Context, cp *domain.AuditCheckpoint) error {
+	query := `INSERT INTO audit_checkpoints (log_id, hash, signature, created_at)
+              VALUES ($1, $2, $3, $4)
+              RETURNING id`
+	return r.db.QueryRow(ctx, query, cp.LogID, cp.Hash, cp.Signature, cp.CreatedAt).Scan(&cp.ID)
+}
+
+func (r *auditCheckpointPostgresRepository) Latest(ctx context.Context) (*domain.AuditCheckpoint, error) {
+	query := `SELECT id, log_id, hash, signature, created_at FROM audit_checkpoints ORDER BY log_id DESC, id DESC LIMIT 1`
+	cp := &domain.AuditCheckpoint{}
+	err := r.db.QueryRow(ctx, query).Scan(&cp.ID, &cp.LogID, &cp.Hash, &cp.Signature, &cp.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func (r *auditCheckpointPostgresRepository) List(ctx context.Context) ([]*domain.AuditCheckpoint, error) {
+	query := `SELECT id, log_id, hash, signature, created_at FROM audit_checkpoints ORDER BY log_id, id`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []*domain.AuditCheckpoint
+	for rows.Next() {
+		cp := &domain.AuditCheckpoint{}
+		if err := rows.Scan(&cp.ID, &cp.LogID, &cp.Hash, &cp.Signature, &cp.CreatedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
@@ -2,9 +2,15 @@ package database
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"internal-dns/internal/domain"
 	"internal-dns/internal/repository"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -16,11 +22,229 @@ func NewAuditLogPostgresRepository(db *pgxpool.Pool) repository.AuditLogReposito
 	return &auditLogPostgresRepository{db: db}
 }
 
+// auditChainLockID is the advisory lock that serialises appending to the
+// audit log hash chain.
+const auditChainLockID = 0x61756469
+
+// insertAuditLogQuery stores entries without an actor (user 0) with a NULL
+// user.
+const insertAuditLogQuery = `
+	INSERT INTO audit_logs (user_id, action, target_id, old_value, new_value, change_id, event_id, created_at, prev_hash, hash)
+	VALUES (NULLIF($1, 0), $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10)
+	RETURNING id
+`
+
 func (r *auditLogPostgresRepository) Create(ctx context.Context, log *domain.AuditLog) error {
+	return r.CreateBatch(ctx, []*domain.AuditLog{log})
+}
+
+// CreateBatch appends logs to the hash chain. Appends are serialised by a
+// transaction-level advisory lock, so that every entry is chained to the one
+// committed before it. Entries keep the time they were queued.
+func (r *auditLogPostgresRepository) CreateBatch(ctx context.Context, logs []*domain.AuditLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLockID); err != nil {
+		return err
+	}
+
+	// Skip entries that were stored before, e.g. when an acknowledgement was lost
+	var eventIDs []string
+	for _, log := range logs {
+		if log.EventID != "" {
+			eventIDs = append(eventIDs, log.EventID)
+		}
+	}
+	stored := make(map[string]bool)
+	if len(eventIDs) > 0 {
+		rows, err := tx.Query(ctx, "SELECT event_id FROM audit_logs WHERE event_id = ANY($1)", eventIDs)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			stored[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	var prevHash string
+	err = tx.QueryRow(ctx, "SELECT COALESCE(hash, '') FROM audit_logs ORDER BY id DESC LIMIT 1").Scan(&prevHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	var queued []*domain.AuditLog
+	for _, log := range logs {
+		if log.EventID != "" && stored[log.EventID] {
+			continue
+		}
+		stored[log.EventID] = true
+
+		if log.Timestamp.IsZero() {
+			log.Timestamp = time.Now()
+		}
+		log.Timestamp = log.Timestamp.UTC().Truncate(time.Microsecond)
+		log.PrevHash = prevHash
+		log.Hash = log.ComputeHash(prevHash)
+		prevHash = log.Hash
+
+		batch.Queue(insertAuditLogQuery, log.UserID, log.Action, log.TargetID, log.OldValue, log.NewValue,
+			log.ChangeID, log.EventID, log.Timestamp, log.PrevHash, log.Hash)
+		queued = append(queued, log)
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	for _, log := range queued {
+		if err := results.QueryRow().Scan(&log.ID); err != nil {
+			results.Close()
+			return err
+		}
+	}
+	if err := results.Close(); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+const auditLogColumns = `a.id, COALESCE(a.user_id, 0), COALESCE(u.username, ''), a.action, COALESCE(a.target_id, 0),
+                          a.old_value, a.new_value, a.created_at, COALESCE(a.change_id, ''),
+                          COALESCE(a.event_id, ''), COALESCE(a.prev_hash, ''), COALESCE(a.hash, '')`
+
+func (r *auditLogPostgresRepository) FindByTarget(ctx context.Context, targetID int64, actions []domain.ActionType) ([]*domain.AuditLog, error) {
 	query := `
-		INSERT INTO audit_logs (user_id, action, target_id, old_value, new_value)
-		VALUES ($1, $2, $3, $4, $5)
+		SELECT ` + auditLogColumns + `
+		FROM audit_logs a
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE a.target_id = $1 AND a.action = ANY($2)
+		ORDER BY a.id
 	`
-	_, err := r.db.Exec(ctx, query, log.UserID, log.Action, log.TargetID, log.OldValue, log.NewValue)
-	return err
+	rows, err := conn(ctx, r.db).Query(ctx, query, targetID, actionNames(actions))
+	if err != nil {
+		return nil, err
+	}
+	return scanAuditLogs(rows)
+}
+
+// Find builds its WHERE clause from the set filter fields only, so that each
+// combination can use the matching index.
+func (r *auditLogPostgresRepository) Find(ctx context.Context, filter domain.AuditLogFilter) ([]*domain.AuditLog, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.UserID != nil {
+		add("a.user_id = $%d", *filter.UserID)
+	}
+	if len(filter.Actions) > 0 {
+		add("a.action = ANY($%d)", actionNames(filter.Actions))
+	}
+	if filter.TargetID != nil {
+		add("a.target_id = $%d", *filter.TargetID)
+	}
+	if filter.ChangeID != "" {
+		add("a.change_id = $%d", filter.ChangeID)
+	}
+	if !filter.From.IsZero() {
+		add("a.created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("a.created_at < $%d", filter.To)
+	}
+	if filter.BeforeID > 0 {
+		add("a.id < $%d", filter.BeforeID)
+	}
+
+	query := `SELECT ` + auditLogColumns + ` FROM audit_logs a LEFT JOIN users u ON u.id = a.user_id`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY a.id DESC LIMIT $%d", len(args))
+
+	rows, err := conn(ctx, r.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanAuditLogs(rows)
+}
+
+func (r *auditLogPostgresRepository) FindAfter(ctx context.Context, afterID int64, limit int) ([]*domain.AuditLog, error) {
+	query := `
+		SELECT ` + auditLogColumns + `
+		FROM audit_logs a
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE a.id > $1
+		ORDER BY a.id
+		LIMIT $2
+	`
+	rows, err := conn(ctx, r.db).Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanAuditLogs(rows)
+}
+
+func actionNames(actions []domain.ActionType) []string {
+	names := make([]string, len(actions))
+	for i, a := range actions {
+		names[i] = string(a)
+	}
+	return names
+}
+
+func scanAuditLogs(rows pgx.Rows) ([]*domain.AuditLog, error) {
+	var logs []*domain.AuditLog
+	err := forEachAuditLog(rows, func(log *domain.AuditLog) error {
+		logs = append(logs, log)
+		return nil
+	})
+	return logs, err
+}
+
+// forEachAuditLog calls fn with each entry of rows, which select
+// auditLogColumns, and closes them.
+func forEachAuditLog(rows pgx.Rows, fn func(*domain.AuditLog) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		log := &domain.AuditLog{}
+		var oldValue, newValue *string
+		err := rows.Scan(&log.ID, &log.UserID, &log.Username, &log.Action, &log.TargetID,
+			&oldValue, &newValue, &log.Timestamp, &log.ChangeID, &log.EventID, &log.PrevHash, &log.Hash)
+		if err != nil {
+			return err
+		}
+		if oldValue != nil {
+			log.OldValue = json.RawMessage(*oldValue)
+		}
+		if newValue != nil {
+			log.NewValue = json.RawMessage(*newValue)
+		}
+		if err := fn(log); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
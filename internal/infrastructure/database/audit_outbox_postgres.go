@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type auditOutboxPostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewAuditOutboxPostgresRepository creates a new audit outbox in the
+// audit_outbox table.
+func NewAuditOutboxPostgresRepository(db *pgxpool.Pool) repository.AuditOutboxRepository {
+	return &auditOutboxPostgresRepository{db: db}
+}
+
+func (r *auditOutboxPostgresRepository) Push(ctx context.Context, log *domain.AuditLog) error {
+	data, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+	if _, err := conn(ctx, r.db).Exec(ctx, `INSERT INTO audit_outbox (log) VALUES ($1)`, data); err != nil {
+		return fmt.Errorf("failed to queue audit log: %w", err)
+	}
+	return nil
+}
+
+// Claim skips the entries that other relays are claiming at the same time.
+func (r *auditOutboxPostgresRepository) Claim(ctx context.Context, consumer string, count int, minIdle time.Duration) ([]domain.AuditOutboxEntry, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE audit_outbox SET claimed_by = $1, claimed_at = NOW()
+			WHERE id IN (
+				SELECT id FROM audit_outbox
+				WHERE claimed_by IS NULL OR claimed_by = $1 OR claimed_at < NOW() - make_interval(secs => $3)
+				ORDER BY id
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, log
+		)
+		SELECT id, log FROM claimed ORDER BY id
+	`
+	rows, err := conn(ctx, r.db).Query(ctx, query, consumer, count, minIdle.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []domain.AuditOutboxEntry
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		log := &domain.AuditLog{}
+		if err := json.Unmarshal(data, log); err != nil {
+			return nil, fmt.Errorf("invalid audit log %d in outbox: %w", id, err)
+		}
+		entries = append(entries, domain.AuditOutboxEntry{ID: id, Log: log})
+	}
+	return entries, rows.Err()
+}
+
+func (r *auditOutboxPostgresRepository) Ack(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM audit_outbox WHERE id = ANY($1)`, ids); err != nil {
+		return fmt.Errorf("failed to acknowledge audit logs: %w", err)
+	}
+	return nil
+}
+
+func (r *auditOutboxPostgresRepository) Len(ctx context.Context) (int64, error) {
+	var n int64
+	err := conn(ctx, r.db).QueryRow(ctx, `SELECT COUNT(*) FROM audit_outbox`).Scan(&n)
+	return n, err
+}
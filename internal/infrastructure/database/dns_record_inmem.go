@@ -68,6 +68,16 @@ func (r *dnsRepoInMemory) FindByDomainName(ctx context.Context, domainName strin
 	return nil, repository.ErrDNSRecordNotFound
 }
 
+func (r *dnsRepoInMemory) FindByDomainNames(ctx context.Context, domainNames []string) ([]*domain.DNSRecord, error) {
+	var records []*domain.DNSRecord
+	for _, name := range domainNames {
+		if val, ok := r.hm[name]; ok {
+			records = append(records, val)
+		}
+	}
+	return records, nil
+}
+
 func (r *dnsRepoInMemory) FindByUserID(ctx context.Context, userID int64, page, pageSize int) ([]*domain.DNSRecord, error) {
 	return nil, repository.ErrDNSRecordNotFound
 }
@@ -87,3 +97,39 @@ func (r *dnsRepoInMemory) CountByUserID(ctx context.Context, userID int64) (int,
 func (r *dnsRepoInMemory) GetAllDomainNames(ctx context.Context) ([]string, error) {
 	return []string{}, nil
 }
+
+func (r *dnsRepoInMemory) AddQueryStats(ctx context.Context, stats []domain.QueryStat) error {
+	return nil
+}
+
+func (r *dnsRepoInMemory) FindUnqueriedSince(ctx context.Context, since time.Time) ([]*domain.DNSRecord, error) {
+	return nil, nil
+}
+
+func (r *dnsRepoInMemory) FindWithHealthChecks(ctx context.Context) ([]*domain.DNSRecord, error) {
+	return nil, nil
+}
+
+func (r *dnsRepoInMemory) FindByZone(ctx context.Context, zone string) ([]*domain.DNSRecord, error) {
+	return nil, nil
+}
+
+func (r *dnsRepoInMemory) ApplyBatch(ctx context.Context, creates, updates []*domain.DNSRecord, deletes []int64) error {
+	return nil
+}
+
+func (r *dnsRepoInMemory) Restore(ctx context.Context, record *domain.DNSRecord) error {
+	return nil
+}
+
+func (r *dnsRepoInMemory) FindDeletedByID(ctx context.Context, id int64) (*domain.DNSRecord, error) {
+	return nil, repository.ErrDNSRecordNotFound
+}
+
+func (r *dnsRepoInMemory) FindDeletedByUserID(ctx context.Context, userID int64) ([]*domain.DNSRecord, error) {
+	return nil, nil
+}
+
+func (r *dnsRepoInMemory) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -20,12 +21,24 @@ func NewDNSRecordPostgresRepository(db *pgxpool.Pool) repository.DNSRecordReposi
 	return &dnsRecordPostgresRepository{db: db}
 }
 
+// querier is implemented by both the pool and transactions, so that single
+// writes and batches share their queries.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
 func (r *dnsRecordPostgresRepository) Create(ctx context.Context, record *domain.DNSRecord) error {
-	query := `INSERT INTO dns_records (user_id, domain_name, type, value)
-              VALUES ($1, $2, $3, $4)
+	return createRecord(ctx, conn(ctx, r.db), record)
+}
+
+func createRecord(ctx context.Context, q querier, record *domain.DNSRecord) error {
+	query := `INSERT INTO dns_records (user_id, domain_name, type, value, additional_values, health_check, traffic_policy)
+              VALUES ($1, $2, $3, $4, COALESCE($5, '{}'::TEXT[]), $6, $7)
               RETURNING id, created_at, updated_at`
 
-	err := r.db.QueryRow(ctx, query, record.UserID, record.DomainName, record.Type, record.Value).
+	err := q.QueryRow(ctx, query, record.UserID, record.DomainName, record.Type, record.Value,
+		record.AdditionalValues, record.HealthCheck, record.TrafficPolicy).
 		Scan(&record.ID, &record.CreatedAt, &record.UpdatedAt)
 
 	if err != nil {
@@ -38,13 +51,52 @@ func (r *dnsRecordPostgresRepository) Create(ctx context.Context, record *domain
 	return nil
 }
 
+// Restore brings back a deleted record with the given state under its ID: a
+// record in the trash is taken out of it, a purged one is inserted again with
+// its previous creation time, so that it keeps its history.
+func (r *dnsRecordPostgresRepository) Restore(ctx context.Context, record *domain.DNSRecord) error {
+	query := `INSERT INTO dns_records (id, user_id, domain_name, type, value, additional_values, health_check, traffic_policy, created_at)
+              VALUES ($1, $2, $3, $4, $5, COALESCE($6, '{}'::TEXT[]), $7, $8, COALESCE($9, NOW()))
+              ON CONFLICT (id) DO UPDATE
+              SET domain_name = EXCLUDED.domain_name, type = EXCLUDED.type, value = EXCLUDED.value,
+                  additional_values = EXCLUDED.additional_values, health_check = EXCLUDED.health_check,
+                  traffic_policy = EXCLUDED.traffic_policy, deleted_at = NULL, updated_at = NOW()
+              WHERE dns_records.deleted_at IS NOT NULL AND dns_records.user_id = EXCLUDED.user_id
+              RETURNING created_at, updated_at`
+
+	var createdAt *time.Time
+	if !record.CreatedAt.IsZero() {
+		createdAt = &record.CreatedAt
+	}
+	err := conn(ctx, r.db).QueryRow(ctx, query, record.ID, record.UserID, record.DomainName, record.Type, record.Value,
+		record.AdditionalValues, record.HealthCheck, record.TrafficPolicy, createdAt).
+		Scan(&record.CreatedAt, &record.UpdatedAt)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) { // The record exists and is not deleted
+			return repository.ErrDNSRecordNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repository.ErrDuplicateDomainName
+		}
+		return err
+	}
+	record.DeletedAt = nil
+	return nil
+}
+
 func (r *dnsRecordPostgresRepository) FindByID(ctx context.Context, id int64) (*domain.DNSRecord, error) {
-	query := `SELECT id, user_id, domain_name, type, value, created_at, updated_at
-              FROM dns_records WHERE id = $1`
+	query := `SELECT r.id, r.user_id, r.domain_name, r.type, r.value, r.created_at, r.updated_at,
+                     r.additional_values, r.health_check, r.traffic_policy, COALESCE(s.query_count, 0), s.last_queried_at, r.deleted_at
+              FROM dns_records r
+              LEFT JOIN dns_record_stats s ON s.record_id = r.id
+              WHERE r.id = $1 AND r.deleted_at IS NULL`
 	record := &domain.DNSRecord{}
-	err := r.db.QueryRow(ctx, query, id).Scan(
+	err := conn(ctx, r.db).QueryRow(ctx, query, id).Scan(
 		&record.ID, &record.UserID, &record.DomainName, &record.Type,
 		&record.Value, &record.CreatedAt, &record.UpdatedAt,
+		&record.AdditionalValues, &record.HealthCheck, &record.TrafficPolicy, &record.QueryCount, &record.LastQueriedAt, &record.DeletedAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -56,12 +108,13 @@ func (r *dnsRecordPostgresRepository) FindByID(ctx context.Context, id int64) (*
 }
 
 func (r *dnsRecordPostgresRepository) FindByDomainName(ctx context.Context, domainName string) (*domain.DNSRecord, error) {
-	query := `SELECT id, user_id, domain_name, type, value, created_at, updated_at
-              FROM dns_records WHERE domain_name = $1`
+	query := `SELECT id, user_id, domain_name, type, value, created_at, updated_at, additional_values, health_check, traffic_policy
+              FROM dns_records WHERE domain_name = $1 AND deleted_at IS NULL`
 	record := &domain.DNSRecord{}
-	err := r.db.QueryRow(ctx, query, domainName).Scan(
+	err := conn(ctx, r.db).QueryRow(ctx, query, domainName).Scan(
 		&record.ID, &record.UserID, &record.DomainName, &record.Type,
 		&record.Value, &record.CreatedAt, &record.UpdatedAt,
+		&record.AdditionalValues, &record.HealthCheck, &record.TrafficPolicy,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -72,17 +125,158 @@ func (r *dnsRecordPostgresRepository) FindByDomainName(ctx context.Context, doma
 	return record, nil
 }
 
+// FindByDomainNames returns the records with any of the given names,
+// including deleted records that still reserve their name. Names without a
+// record are left out.
+func (r *dnsRecordPostgresRepository) FindByDomainNames(ctx context.Context, domainNames []string) ([]*domain.DNSRecord, error) {
+	query := `SELECT r.id, r.user_id, r.domain_name, r.type, r.value, r.created_at, r.updated_at,
+                     r.additional_values, r.health_check, r.traffic_policy, COALESCE(s.query_count, 0), s.last_queried_at, r.deleted_at
+              FROM dns_records r
+              LEFT JOIN dns_record_stats s ON s.record_id = r.id
+              WHERE r.domain_name = ANY($1)`
+	rows, err := conn(ctx, r.db).Query(ctx, query, domainNames)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecordsWithStats(rows)
+}
+
 func (r *dnsRecordPostgresRepository) FindByUserID(ctx context.Context, userID int64, page, pageSize int) ([]*domain.DNSRecord, error) {
-	query := `SELECT id, user_id, domain_name, type, value, created_at, updated_at
-              FROM dns_records
-              WHERE user_id = $1
-              ORDER BY created_at DESC
+	query := `SELECT r.id, r.user_id, r.domain_name, r.type, r.value, r.created_at, r.updated_at,
+                     r.additional_values, r.health_check, r.traffic_policy, COALESCE(s.query_count, 0), s.last_queried_at, r.deleted_at
+              FROM dns_records r
+              LEFT JOIN dns_record_stats s ON s.record_id = r.id
+              WHERE r.user_id = $1 AND r.deleted_at IS NULL
+              ORDER BY r.created_at DESC
               LIMIT $2 OFFSET $3`
 	offset := (page - 1) * pageSize
-	rows, err := r.db.Query(ctx, query, userID, pageSize, offset)
+	rows, err := conn(ctx, r.db).Query(ctx, query, userID, pageSize, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecordsWithStats(rows)
+}
+
+// FindUnqueriedSince returns records created before since that have not been
+// queried since then, oldest activity first.
+func (r *dnsRecordPostgresRepository) FindUnqueriedSince(ctx context.Context, since time.Time) ([]*domain.DNSRecord, error) {
+	query := `SELECT r.id, r.user_id, r.domain_name, r.type, r.value, r.created_at, r.updated_at,
+                     r.additional_values, r.health_check, r.traffic_policy, COALESCE(s.query_count, 0), s.last_queried_at, r.deleted_at
+              FROM dns_records r
+              LEFT JOIN dns_record_stats s ON s.record_id = r.id
+              WHERE r.created_at < $1 AND r.deleted_at IS NULL
+                AND (s.last_queried_at IS NULL OR s.last_queried_at < $1)
+              ORDER BY s.last_queried_at ASC NULLS FIRST, r.id ASC`
+	rows, err := conn(ctx, r.db).Query(ctx, query, since)
 	if err != nil {
 		return nil, err
 	}
+	return scanRecordsWithStats(rows)
+}
+
+// AddQueryStats adds aggregated query counts to the records with the given
+// domain names. Names without a matching record are ignored.
+func (r *dnsRecordPostgresRepository) AddQueryStats(ctx context.Context, stats []domain.QueryStat) error {
+	query := `INSERT INTO dns_record_stats (record_id, query_count, last_queried_at)
+              SELECT id, $2, $3 FROM dns_records WHERE domain_name = $1 AND deleted_at IS NULL
+              ON CONFLICT (record_id) DO UPDATE
+              SET query_count = dns_record_stats.query_count + EXCLUDED.query_count,
+                  last_queried_at = GREATEST(dns_record_stats.last_queried_at, EXCLUDED.last_queried_at)`
+
+	batch := &pgx.Batch{}
+	for _, stat := range stats {
+		batch.Queue(query, stat.DomainName, stat.Count, stat.LastQueriedAt)
+	}
+	return conn(ctx, r.db).SendBatch(ctx, batch).Close()
+}
+
+// FindWithHealthChecks returns all records that have a health check.
+func (r *dnsRecordPostgresRepository) FindWithHealthChecks(ctx context.Context) ([]*domain.DNSRecord, error) {
+	query := `SELECT r.id, r.user_id, r.domain_name, r.type, r.value, r.created_at, r.updated_at,
+                     r.additional_values, r.health_check, r.traffic_policy, COALESCE(s.query_count, 0), s.last_queried_at, r.deleted_at
+              FROM dns_records r
+              LEFT JOIN dns_record_stats s ON s.record_id = r.id
+              WHERE r.health_check IS NOT NULL AND r.deleted_at IS NULL
+              ORDER BY r.id`
+	rows, err := conn(ctx, r.db).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecordsWithStats(rows)
+}
+
+// FindByZone returns the records named zone or any name below it.
+func (r *dnsRecordPostgresRepository) FindByZone(ctx context.Context, zone string) ([]*domain.DNSRecord, error) {
+	query := `SELECT r.id, r.user_id, r.domain_name, r.type, r.value, r.created_at, r.updated_at,
+                     r.additional_values, r.health_check, r.traffic_policy, COALESCE(s.query_count, 0), s.last_queried_at, r.deleted_at
+              FROM dns_records r
+              LEFT JOIN dns_record_stats s ON s.record_id = r.id
+              WHERE (r.domain_name = $1 OR right(r.domain_name, length($1) + 1) = '.' || $1)
+                AND r.deleted_at IS NULL
+              ORDER BY r.domain_name`
+	rows, err := conn(ctx, r.db).Query(ctx, query, zone)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecordsWithStats(rows)
+}
+
+// ApplyBatch creates, updates and deletes records in one transaction. Either
+// every change is stored or none is.
+func (r *dnsRecordPostgresRepository) ApplyBatch(ctx context.Context, creates, updates []*domain.DNSRecord, deletes []int64) error {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Names are checked for uniqueness at commit, so records can swap names
+	if _, err := tx.Exec(ctx, `SET CONSTRAINTS dns_records_domain_name_key DEFERRED`); err != nil {
+		return err
+	}
+	// Deletes go first so that a batch can reuse a name it frees
+	for _, id := range deletes {
+		if err := deleteRecord(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	if len(deletes) > 0 {
+		// Records whose name is taken over by the batch cannot keep it
+		// reserved in the trash, so they are purged right away
+		names := make([]string, 0, len(creates)+len(updates))
+		for _, record := range creates {
+			names = append(names, record.DomainName)
+		}
+		for _, record := range updates {
+			names = append(names, record.DomainName)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM dns_records WHERE id = ANY($1) AND domain_name = ANY($2)`, deletes, names); err != nil {
+			return err
+		}
+	}
+	for _, record := range updates {
+		if err := updateRecord(ctx, tx, record); err != nil {
+			return err
+		}
+	}
+	for _, record := range creates {
+		if err := createRecord(ctx, tx, record); err != nil {
+			return err
+		}
+	}
+	// Check the names now: within an outer transaction, committing only
+	// releases a savepoint
+	if _, err := tx.Exec(ctx, `SET CONSTRAINTS dns_records_domain_name_key IMMEDIATE`); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repository.ErrDuplicateDomainName
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func scanRecordsWithStats(rows pgx.Rows) ([]*domain.DNSRecord, error) {
 	defer rows.Close()
 
 	var records []*domain.DNSRecord
@@ -91,28 +285,36 @@ func (r *dnsRecordPostgresRepository) FindByUserID(ctx context.Context, userID i
 		err := rows.Scan(
 			&record.ID, &record.UserID, &record.DomainName, &record.Type,
 			&record.Value, &record.CreatedAt, &record.UpdatedAt,
+			&record.AdditionalValues, &record.HealthCheck, &record.TrafficPolicy, &record.QueryCount, &record.LastQueriedAt, &record.DeletedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 		records = append(records, record)
 	}
-	return records, nil
+	return records, rows.Err()
 }
 
 func (r *dnsRecordPostgresRepository) CountByUserID(ctx context.Context, userID int64) (int, error) {
-	query := `SELECT COUNT(*) FROM dns_records WHERE user_id = $1`
+	query := `SELECT COUNT(*) FROM dns_records WHERE user_id = $1 AND deleted_at IS NULL`
 	var count int
-	err := r.db.QueryRow(ctx, query, userID).Scan(&count)
+	err := conn(ctx, r.db).QueryRow(ctx, query, userID).Scan(&count)
 	return count, err
 }
 
 func (r *dnsRecordPostgresRepository) Update(ctx context.Context, record *domain.DNSRecord) error {
+	return updateRecord(ctx, conn(ctx, r.db), record)
+}
+
+func updateRecord(ctx context.Context, q querier, record *domain.DNSRecord) error {
 	query := `UPDATE dns_records
-              SET domain_name = $1, type = $2, value = $3, updated_at = NOW()
-              WHERE id = $4
+              SET domain_name = $1, type = $2, value = $3,
+                  additional_values = COALESCE($4, '{}'::TEXT[]), health_check = $5, traffic_policy = $6,
+                  updated_at = NOW()
+              WHERE id = $7 AND deleted_at IS NULL
               RETURNING updated_at`
-	err := r.db.QueryRow(ctx, query, record.DomainName, record.Type, record.Value, record.ID).Scan(&record.UpdatedAt)
+	err := q.QueryRow(ctx, query, record.DomainName, record.Type, record.Value,
+		record.AdditionalValues, record.HealthCheck, record.TrafficPolicy, record.ID).Scan(&record.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return repository.ErrDNSRecordNotFound
@@ -127,8 +329,14 @@ func (r *dnsRecordPostgresRepository) Update(ctx context.Context, record *domain
 }
 
 func (r *dnsRecordPostgresRepository) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM dns_records WHERE id = $1`
-	cmdTag, err := r.db.Exec(ctx, query, id)
+	return deleteRecord(ctx, conn(ctx, r.db), id)
+}
+
+// deleteRecord moves a record to the trash. It keeps its name until it is
+// purged.
+func deleteRecord(ctx context.Context, q querier, id int64) error {
+	query := `UPDATE dns_records SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+	cmdTag, err := q.Exec(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -138,9 +346,59 @@ func (r *dnsRecordPostgresRepository) Delete(ctx context.Context, id int64) erro
 	return nil
 }
 
+// FindDeletedByID returns a record in the trash.
+func (r *dnsRecordPostgresRepository) FindDeletedByID(ctx context.Context, id int64) (*domain.DNSRecord, error) {
+	query := `SELECT r.id, r.user_id, r.domain_name, r.type, r.value, r.created_at, r.updated_at,
+                     r.additional_values, r.health_check, r.traffic_policy, COALESCE(s.query_count, 0), s.last_queried_at, r.deleted_at
+              FROM dns_records r
+              LEFT JOIN dns_record_stats s ON s.record_id = r.id
+              WHERE r.id = $1 AND r.deleted_at IS NOT NULL`
+	rows, err := conn(ctx, r.db).Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	records, err := scanRecordsWithStats(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, repository.ErrDNSRecordNotFound
+	}
+	return records[0], nil
+}
+
+// FindDeletedByUserID returns the records of a user in the trash, most
+// recently deleted first.
+func (r *dnsRecordPostgresRepository) FindDeletedByUserID(ctx context.Context, userID int64) ([]*domain.DNSRecord, error) {
+	query := `SELECT r.id, r.user_id, r.domain_name, r.type, r.value, r.created_at, r.updated_at,
+                     r.additional_values, r.health_check, r.traffic_policy, COALESCE(s.query_count, 0), s.last_queried_at, r.deleted_at
+              FROM dns_records r
+              LEFT JOIN dns_record_stats s ON s.record_id = r.id
+              WHERE r.user_id = $1 AND r.deleted_at IS NOT NULL
+              ORDER BY r.deleted_at DESC, r.id DESC`
+	rows, err := conn(ctx, r.db).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecordsWithStats(rows)
+}
+
+// PurgeDeleted removes records deleted before the given time for good,
+// releasing their names. It returns the number of purged records.
+func (r *dnsRecordPostgresRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM dns_records WHERE deleted_at < $1`
+	cmdTag, err := conn(ctx, r.db).Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return cmdTag.RowsAffected(), nil
+}
+
+// GetAllDomainNames returns the names of all records, including deleted ones
+// that still reserve their name.
 func (r *dnsRecordPostgresRepository) GetAllDomainNames(ctx context.Context) ([]string, error) {
 	query := `SELECT domain_name FROM dns_records`
-	rows, err := r.db.Query(ctx, query)
+	rows, err := conn(ctx, r.db).Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-dns/internal/domain"
+)
+
+// fakeTx is a transaction that records the queries run on it. The methods
+// it does not override panic.
+type fakeTx struct {
+	pgx.Tx
+	queries []string
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tx.queries = append(tx.queries, sql)
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (tx *fakeTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	tx.queries = append(tx.queries, sql)
+	return fakeRow{}
+}
+
+type fakeRow struct{}
+
+func (fakeRow) Scan(dest ...any) error {
+	for _, d := range dest {
+		switch d := d.(type) {
+		case *int64:
+			*d = 1
+		case *time.Time:
+			*d = time.Now()
+		}
+	}
+	return nil
+}
+
+func TestDNSRecordPostgresRepository_WritesInTransaction(t *testing.T) {
+	// Without a pool, writes that do not use the transaction of the context
+	// panic
+	repo := NewDNSRecordPostgresRepository(nil)
+	tx := &fakeTx{}
+	ctx := context.WithValue(context.Background(), txKey{}, pgx.Tx(tx))
+	record := &domain.DNSRecord{UserID: 1, DomainName: "www.example.com", Type: domain.A, Value: "192.0.2.1"}
+
+	require.NoError(t, repo.Create(ctx, record))
+	assert.Equal(t, int64(1), record.ID)
+	require.NoError(t, repo.Update(ctx, record))
+	require.NoError(t, repo.Delete(ctx, record.ID))
+	assert.Len(t, tx.queries, 3)
+}
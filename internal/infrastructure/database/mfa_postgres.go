@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+type mfaPostgresRepository struct {
+	db   *pgxpool.Pool
+	aead cipher.AEAD
+}
+
+// NewMFAPostgresRepository creates a repository that stores TOTP secrets
+// encrypted with AES-GCM under the SHA-256 hash of encryptionKey.
+func NewMFAPostgresRepository(db *pgxpool.Pool, encryptionKey string) (repository.MFARepository, error) {
+	aead, err := newAEAD(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return &mfaPostgresRepository{db: db, aead: aead}, nil
+}
+
+func (r *mfaPostgresRepository) Find(ctx context.Context, userID int64) (*domain.TOTPEnrollment, error) {
+	query := `SELECT secret, confirmed_at, last_used_step, created_at,
+                     (SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL)
+              FROM user_totp WHERE user_id = $1`
+	enrollment := &domain.TOTPEnrollment{UserID: userID}
+	var sealed []byte
+	err := conn(ctx, r.db).QueryRow(ctx, query, userID).Scan(&sealed, &enrollment.ConfirmedAt, &enrollment.LastUsedStep, &enrollment.CreatedAt, &enrollment.RecoveryCodesLeft)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Secret, err = r.open(userID, sealed); err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (r *mfaPostgresRepository) SavePending(ctx context.Context, enrollment *domain.TOTPEnrollment) error {
+	sealed, err := r.seal(enrollment.UserID, enrollment.Secret)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO user_totp (user_id, secret, created_at)
+              VALUES ($1, $2, $3)
+              ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at, last_used_step = 0
+              WHERE user_totp.confirmed_at IS NULL`
+	tag, err := conn(ctx, r.db).Exec(ctx, query, enrollment.UserID, sealed, enrollment.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+func (r *mfaPostgresRepository) Confirm(ctx context.Context, userID, step int64, recoveryCodeHashes []string) error {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE user_totp SET confirmed_at = NOW(), last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NULL`, userID, step)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrMFANotEnrolled
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *mfaPostgresRepository) UseStep(ctx context.Context, userID, step int64) error {
+	query := `UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`
+	tag, err := conn(ctx, r.db).Exec(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (r *mfaPostgresRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	query := `UPDATE mfa_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	tag, err := conn(ctx, r.db).Exec(ctx, query, userID, codeHash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (r *mfaPostgresRepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *mfaPostgresRepository) Delete(ctx context.Context, userID int64) error {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// seal encrypts a TOTP secret, bound to the user's ID, and prepends the
+// nonce.
+func (r *mfaPostgresRepository) seal(userID int64, secret []byte) ([]byte, error) {
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return r.aead.Seal(nonce, nonce, secret, []byte(strconv.FormatInt(userID, 10))), nil
+}
+
+func (r *mfaPostgresRepository) open(userID int64, sealed []byte) ([]byte, error) {
+	if len(sealed) < r.aead.NonceSize() {
+		return nil, errors.New("encrypted TOTP secret is too short")
+	}
+	nonce, ciphertext := sealed[:r.aead.NonceSize()], sealed[r.aead.NonceSize():]
+	secret, err := r.aead.Open(nil, nonce, ciphertext, []byte(strconv.FormatInt(userID, 10)))
+	if err != nil {
+		return nil, errors.New("failed to decrypt TOTP secret with JWT_KEY_ENCRYPTION_KEY")
+	}
+	return secret, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+// policyRuleSource is the Source of rules stored in the database.
+const policyRuleSource = "database"
+
+type policyRulePostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPolicyRulePostgresRepository(db *pgxpool.Pool) repository.PolicyRuleRepository {
+	return &policyRulePostgresRepository{db: db}
+}
+
+func (r *policyRulePostgresRepository) Create(ctx context.Context, rule *domain.PolicyRule) error {
+	query := `INSERT INTO policy_rules (pattern, match, action, redirect_ip, comment, created_by)
+              VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))
+              RETURNING id, created_at, updated_at`
+
+	err := conn(ctx, r.db).QueryRow(ctx, query, rule.Pattern, rule.Match, rule.Action, rule.RedirectIP, rule.Comment, rule.CreatedBy).
+		Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return repository.ErrDuplicatePolicyRule
+		}
+		return err
+	}
+	rule.Source = policyRuleSource
+	return nil
+}
+
+func (r *policyRulePostgresRepository) FindByID(ctx context.Context, id int64) (*domain.PolicyRule, error) {
+	query := `SELECT id, pattern, match, action, redirect_ip, comment, COALESCE(created_by, 0), created_at, updated_at
+              FROM policy_rules WHERE id = $1`
+	rule, err := scanPolicyRule(conn(ctx, r.db).QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.ErrPolicyRuleNotFound
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *policyRulePostgresRepository) FindAll(ctx context.Context) ([]*domain.PolicyRule, error) {
+	query := `SELECT id, pattern, match, action, redirect_ip, comment, COALESCE(created_by, 0), created_at, updated_at
+              FROM policy_rules ORDER BY pattern, match`
+	rows, err := conn(ctx, r.db).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*domain.PolicyRule
+	for rows.Next() {
+		rule, err := scanPolicyRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *policyRulePostgresRepository) Update(ctx context.Context, rule *domain.PolicyRule) error {
+	query := `UPDATE policy_rules
+              SET pattern = $1, match = $2, action = $3, redirect_ip = $4, comment = $5
+              WHERE id = $6
+              RETURNING updated_at`
+	err := conn(ctx, r.db).QueryRow(ctx, query, rule.Pattern, rule.Match, rule.Action, rule.RedirectIP, rule.Comment, rule.ID).
+		Scan(&rule.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repository.ErrPolicyRuleNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repository.ErrDuplicatePolicyRule
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *policyRulePostgresRepository) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM policy_rules WHERE id = $1`
+	cmdTag, err := conn(ctx, r.db).Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return repository.ErrPolicyRuleNotFound
+	}
+	return nil
+}
+
+func scanPolicyRule(row pgx.Row) (*domain.PolicyRule, error) {
+	rule := &domain.PolicyRule{Source: policyRuleSource}
+	err := row.Scan(
+		&rule.ID, &rule.Pattern, &rule.Match, &rule.Action, &rule.RedirectIP,
+		&rule.Comment, &rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+type recordHealthPostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRecordHealthPostgresRepository(db *pgxpool.Pool) repository.RecordHealthRepository {
+	return &recordHealthPostgresRepository{db: db}
+}
+
+// SaveStatus upserts the current health of a target. Statuses of records
+// deleted in the meantime are ignored.
+func (r *recordHealthPostgresRepository) SaveStatus(ctx context.Context, status *domain.TargetHealth) error {
+	query := `INSERT INTO dns_record_health (record_id, target, healthy, consecutive_failures, consecutive_successes,
+                                             last_error, last_checked_at, last_changed_at)
+              SELECT id, $2, $3, $4, $5, $6, $7, $8 FROM dns_records WHERE id = $1
+              ON CONFLICT (record_id, target) DO UPDATE
+              SET healthy = EXCLUDED.healthy,
+                  consecutive_failures = EXCLUDED.consecutive_failures,
+                  consecutive_successes = EXCLUDED.consecutive_successes,
+                  last_error = EXCLUDED.last_error,
+                  last_checked_at = EXCLUDED.last_checked_at,
+                  last_changed_at = EXCLUDED.last_changed_at`
+	_, err := conn(ctx, r.db).Exec(ctx, query, status.RecordID, status.Target, status.Healthy, status.ConsecutiveFailures,
+		status.ConsecutiveSuccesses, status.LastError, status.LastCheckedAt, status.LastChangedAt)
+	return err
+}
+
+func (r *recordHealthPostgresRepository) AddEvent(ctx context.Context, event *domain.HealthEvent) error {
+	query := `INSERT INTO dns_record_health_events (record_id, target, healthy, error, created_at)
+              SELECT id, $2, $3, $4, $5 FROM dns_records WHERE id = $1
+              RETURNING id`
+	err := conn(ctx, r.db).QueryRow(ctx, query, event.RecordID, event.Target, event.Healthy, event.Error, event.CreatedAt).Scan(&event.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil // Record deleted
+	}
+	return err
+}
+
+func (r *recordHealthPostgresRepository) FindStatusByRecordID(ctx context.Context, recordID int64) ([]*domain.TargetHealth, error) {
+	query := `SELECT record_id, target, healthy, consecutive_failures, consecutive_successes,
+                     last_error, last_checked_at, last_changed_at
+              FROM dns_record_health WHERE record_id = $1
+              ORDER BY target`
+	rows, err := conn(ctx, r.db).Query(ctx, query, recordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []*domain.TargetHealth
+	for rows.Next() {
+		s := &domain.TargetHealth{}
+		if err := rows.Scan(&s.RecordID, &s.Target, &s.Healthy, &s.ConsecutiveFailures, &s.ConsecutiveSuccesses,
+			&s.LastError, &s.LastCheckedAt, &s.LastChangedAt); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, rows.Err()
+}
+
+func (r *recordHealthPostgresRepository) FindEventsByRecordID(ctx context.Context, recordID int64, limit int) ([]*domain.HealthEvent, error) {
+	query := `SELECT id, record_id, target, healthy, error, created_at
+              FROM dns_record_health_events WHERE record_id = $1
+              ORDER BY created_at DESC, id DESC
+              LIMIT $2`
+	rows, err := conn(ctx, r.db).Query(ctx, query, recordID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*domain.HealthEvent
+	for rows.Next() {
+		e := &domain.HealthEvent{}
+		if err := rows.Scan(&e.ID, &e.RecordID, &e.Target, &e.Healthy, &e.Error, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *recordHealthPostgresRepository) DeleteStatusByRecordID(ctx context.Context, recordID int64) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM dns_record_health WHERE record_id = $1`, recordID)
+	return err
+}
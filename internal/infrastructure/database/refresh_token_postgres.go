@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+type refreshTokenPostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRefreshTokenPostgresRepository(db *pgxpool.Pool) repository.RefreshTokenRepository {
+	return &refreshTokenPostgresRepository{db: db}
+}
+
+const insertRefreshTokenQuery = `
+	INSERT INTO refresh_tokens (id, family_id, user_id, session_created_at, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+func (r *refreshTokenPostgresRepository) Create(ctx context.Context, t *domain.RefreshToken) error {
+	_, err := conn(ctx, r.db).Exec(ctx, insertRefreshTokenQuery, t.ID, t.FamilyID, t.UserID, t.SessionCreatedAt, t.CreatedAt, t.ExpiresAt)
+	return err
+}
+
+func (r *refreshTokenPostgresRepository) FindByID(ctx context.Context, id string) (*domain.RefreshToken, error) {
+	query := `SELECT id, family_id, user_id, session_created_at, created_at, expires_at, used_at, COALESCE(replaced_by, ''), revoked_at
+              FROM refresh_tokens WHERE id = $1`
+	t := &domain.RefreshToken{}
+	err := conn(ctx, r.db).QueryRow(ctx, query, id).Scan(&t.ID, &t.FamilyID, &t.UserID, &t.SessionCreatedAt, &t.CreatedAt, &t.ExpiresAt,
+		&t.UsedAt, &t.ReplacedBy, &t.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *refreshTokenPostgresRepository) Rotate(ctx context.Context, id string, next *domain.RefreshToken) error {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Only one of several concurrent uses of a token marks it
+	tag, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET used_at = $2, replaced_by = $3
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`,
+		id, next.CreatedAt, next.ID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrRefreshTokenReused
+	}
+	if _, err := tx.Exec(ctx, insertRefreshTokenQuery, next.ID, next.FamilyID, next.UserID, next.SessionCreatedAt, next.CreatedAt, next.ExpiresAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *refreshTokenPostgresRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+	_, err := conn(ctx, r.db).Exec(ctx, query, familyID)
+	return err
+}
+
+func (r *refreshTokenPostgresRepository) RevokeUser(ctx context.Context, userID int64) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := conn(ctx, r.db).Exec(ctx, query, userID)
+	return err
+}
+
+// ListSessions returns a session for every family with a usable token. A
+// family has one at most; it was created by the last refresh.
+func (r *refreshTokenPostgresRepository) ListSessions(ctx context.Context, userID int64) ([]*domain.Session, error) {
+	query := `
+		SELECT family_id, user_id, session_created_at, created_at, expires_at
+		FROM refresh_tokens
+		WHERE user_id = $1 AND used_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+	rows, err := conn(ctx, r.db).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*domain.Session
+	for rows.Next() {
+		s := &domain.Session{}
+		if err := rows.Scan(&s.ID, &s.UserID, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *refreshTokenPostgresRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM refresh_tokens WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
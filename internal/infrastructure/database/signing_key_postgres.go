@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+type signingKeyPostgresRepository struct {
+	db   *pgxpool.Pool
+	aead cipher.AEAD
+}
+
+// NewSigningKeyPostgresRepository creates a repository that stores private
+// keys encrypted with AES-GCM under the SHA-256 hash of encryptionKey.
+func NewSigningKeyPostgresRepository(db *pgxpool.Pool, encryptionKey string) (repository.SigningKeyRepository, error) {
+	aead, err := newAEAD(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return &signingKeyPostgresRepository{db: db, aead: aead}, nil
+}
+
+// newAEAD returns AES-GCM under the SHA-256 hash of encryptionKey.
+func newAEAD(encryptionKey string) (cipher.AEAD, error) {
+	if encryptionKey == "" {
+		return nil, errors.New("encryption key is empty")
+	}
+	key := sha256.Sum256([]byte(encryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// signingKeyLockID is the advisory lock held while a signing key is added.
+const signingKeyLockID = 0x6b657973
+
+func (r *signingKeyPostgresRepository) Add(ctx context.Context, key *domain.SigningKey, latestID string) error {
+	sealed, err := r.seal(key)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", signingKeyLockID); err != nil {
+		return err
+	}
+	var latest string
+	err = tx.QueryRow(ctx, `SELECT id FROM signing_keys ORDER BY activates_at DESC, id DESC LIMIT 1`).Scan(&latest)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if latest != latestID {
+		return domain.ErrSigningKeyConflict
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO signing_keys (id, algorithm, private_key, created_at, activates_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		key.ID, key.Algorithm, sealed, key.CreatedAt, key.ActivatesAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *signingKeyPostgresRepository) List(ctx context.Context) ([]*domain.SigningKey, error) {
+	query := `SELECT id, algorithm, private_key, created_at, activates_at FROM signing_keys ORDER BY activates_at, id`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*domain.SigningKey
+	for rows.Next() {
+		key := &domain.SigningKey{}
+		var sealed []byte
+		if err := rows.Scan(&key.ID, &key.Algorithm, &sealed, &key.CreatedAt, &key.ActivatesAt); err != nil {
+			return nil, err
+		}
+		if key.PrivateKey, err = r.open(key.ID, sealed); err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", key.ID, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *signingKeyPostgresRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM signing_keys WHERE id = $1`, id)
+	return err
+}
+
+// seal encrypts the PKCS #8 encoding of the private key, bound to the key's
+// ID, and prepends the nonce.
+func (r *signingKeyPostgresRepository) seal(key *domain.SigningKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return r.aead.Seal(nonce, nonce, der, []byte(key.ID)), nil
+}
+
+func (r *signingKeyPostgresRepository) open(id string, sealed []byte) (crypto.Signer, error) {
+	if len(sealed) < r.aead.NonceSize() {
+		return nil, errors.New("encrypted private key is too short")
+	}
+	nonce, ciphertext := sealed[:r.aead.NonceSize()], sealed[r.aead.NonceSize():]
+	der, err := r.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, errors.New("failed to decrypt private key with JWT_KEY_ENCRYPTION_KEY")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
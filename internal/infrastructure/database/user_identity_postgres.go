@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+type userIdentityPostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUserIdentityPostgresRepository(db *pgxpool.Pool) repository.UserIdentityRepository {
+	return &userIdentityPostgresRepository{db: db}
+}
+
+func (r *userIdentityPostgresRepository) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	query := `INSERT INTO user_identities (user_id, issuer, subject, created_at)
+              VALUES ($1, $2, $3, $4)
+              RETURNING id`
+	return conn(ctx, r.db).QueryRow(ctx, query, identity.UserID, identity.Issuer, identity.Subject, identity.CreatedAt).Scan(&identity.ID)
+}
+
+func (r *userIdentityPostgresRepository) FindBySubject(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error) {
+	query := `SELECT id, user_id, issuer, subject, created_at FROM user_identities WHERE issuer = $1 AND subject = $2`
+	identity := &domain.UserIdentity{}
+	err := conn(ctx, r.db).QueryRow(ctx, query, issuer, subject).Scan(&identity.ID, &identity.UserID, &identity.Issuer, &identity.Subject, &identity.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
@@ -19,10 +19,10 @@ func NewUserPostgresRepository(db *pgxpool.Pool) repository.UserRepository {
 }
 
 func (r *userPostgresRepository) Create(ctx context.Context, user *domain.User) error {
-	query := `INSERT INTO users (username, password_hash, role, is_enabled) 
-              VALUES ($1, $2, $3, $4) 
+	query := `INSERT INTO users (username, password_hash, role, is_enabled, is_service_account) 
+              VALUES ($1, $2, $3, $4, $5) 
               RETURNING id, created_at, updated_at`
-	err := r.db.QueryRow(ctx, query, user.Username, user.PasswordHash, user.Role, user.IsEnabled).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := conn(ctx, r.db).QueryRow(ctx, query, user.Username, user.PasswordHash, user.Role, user.IsEnabled, user.IsServiceAccount).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		// A more robust implementation would check for specific constraint violations
 		return err
@@ -31,10 +31,10 @@ func (r *userPostgresRepository) Create(ctx context.Context, user *domain.User)
 }
 
 func (r *userPostgresRepository) FindByUsername(ctx context.Context, username string) (*domain.User, error) {
-	query := `SELECT id, username, password_hash, role, is_enabled, created_at, updated_at 
+	query := `SELECT id, username, password_hash, role, is_enabled, created_at, updated_at, is_service_account 
               FROM users WHERE username = $1`
 	user := &domain.User{}
-	err := r.db.QueryRow(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.IsEnabled, &user.CreatedAt, &user.UpdatedAt)
+	err := conn(ctx, r.db).QueryRow(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.IsEnabled, &user.CreatedAt, &user.UpdatedAt, &user.IsServiceAccount)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, repository.ErrUserNotFound
@@ -45,10 +45,10 @@ func (r *userPostgresRepository) FindByUsername(ctx context.Context, username st
 }
 
 func (r *userPostgresRepository) FindByID(ctx context.Context, id int64) (*domain.User, error) {
-	query := `SELECT id, username, password_hash, role, is_enabled, created_at, updated_at 
+	query := `SELECT id, username, password_hash, role, is_enabled, created_at, updated_at, is_service_account 
               FROM users WHERE id = $1`
 	user := &domain.User{}
-	err := r.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.IsEnabled, &user.CreatedAt, &user.UpdatedAt)
+	err := conn(ctx, r.db).QueryRow(ctx, query, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.IsEnabled, &user.CreatedAt, &user.UpdatedAt, &user.IsServiceAccount)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, repository.ErrUserNotFound
@@ -59,9 +59,9 @@ func (r *userPostgresRepository) FindByID(ctx context.Context, id int64) (*domai
 }
 
 func (r *userPostgresRepository) FindAll(ctx context.Context) ([]*domain.User, error) {
-	query := `SELECT id, username, password_hash, role, is_enabled, created_at, updated_at 
+	query := `SELECT id, username, password_hash, role, is_enabled, created_at, updated_at, is_service_account 
               FROM users ORDER BY id ASC`
-	rows, err := r.db.Query(ctx, query)
+	rows, err := conn(ctx, r.db).Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +70,7 @@ func (r *userPostgresRepository) FindAll(ctx context.Context) ([]*domain.User, e
 	var users []*domain.User
 	for rows.Next() {
 		user := &domain.User{}
-		err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.IsEnabled, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.IsEnabled, &user.CreatedAt, &user.UpdatedAt, &user.IsServiceAccount)
 		if err != nil {
 			return nil, err
 		}
@@ -89,7 +89,7 @@ func (r *userPostgresRepository) Update(ctx context.Context, user *domain.User)
               SET username = $1, role = $2, is_enabled = $3, updated_at = NOW() 
               WHERE id = $4
               RETURNING updated_at`
-	err := r.db.QueryRow(ctx, query, user.Username, user.Role, user.IsEnabled, user.ID).Scan(&user.UpdatedAt)
+	err := conn(ctx, r.db).QueryRow(ctx, query, user.Username, user.Role, user.IsEnabled, user.ID).Scan(&user.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return repository.ErrUserNotFound
@@ -0,0 +1,224 @@
+package healthcheck
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+// RecordSource lists the records to check.
+type RecordSource interface {
+	FindWithHealthChecks(ctx context.Context) ([]*domain.DNSRecord, error)
+}
+
+type targetKey struct {
+	recordID int64
+	addr     string
+}
+
+type target struct {
+	host   string // record name, sent as the HTTP Host header
+	check  domain.HealthCheck
+	cancel context.CancelFunc
+	health domain.TargetHealth
+}
+
+// Checker probes every address of health-checked records and tracks whether
+// each one is up. The record list is refreshed periodically; each target is
+// then probed on its own schedule.
+type Checker struct {
+	records RecordSource
+	status  repository.RecordHealthRepository
+
+	mu      sync.RWMutex
+	targets map[targetKey]*target
+}
+
+// NewChecker creates a checker. Health state and changes are written to
+// status, if it is not nil, so that they can be inspected through the API.
+func NewChecker(records RecordSource, status repository.RecordHealthRepository) *Checker {
+	return &Checker{
+		records: records,
+		status:  status,
+		targets: make(map[targetKey]*target),
+	}
+}
+
+// Run refreshes the list of records every refreshInterval and probes their
+// targets until ctx is cancelled.
+func (c *Checker) Run(ctx context.Context, refreshInterval time.Duration) {
+	c.sync(ctx)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			c.mu.Lock()
+			for key, t := range c.targets {
+				t.cancel()
+				delete(c.targets, key)
+			}
+			c.mu.Unlock()
+			return
+		case <-ticker.C:
+			c.sync(ctx)
+		}
+	}
+}
+
+// Healthy returns the healthy addresses of record. Targets that have not
+// been checked yet count as healthy, as do all addresses of records without
+// a check.
+func (c *Checker) Healthy(record *domain.DNSRecord) []string {
+	addrs := record.Addresses()
+	if c == nil || record.HealthCheck == nil {
+		return addrs
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	healthy := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if t, ok := c.targets[targetKey{record.ID, addr}]; ok && !t.health.Healthy {
+			continue
+		}
+		healthy = append(healthy, addr)
+	}
+	return healthy
+}
+
+// sync starts checking new targets and stops checking removed ones.
+func (c *Checker) sync(ctx context.Context) {
+	records, err := c.records.FindWithHealthChecks(ctx)
+	if err != nil {
+		log.Printf("Failed to load health-checked records, keeping current checks: %v", err)
+		return
+	}
+
+	type desiredTarget struct {
+		host  string
+		check domain.HealthCheck
+	}
+	desired := make(map[targetKey]desiredTarget)
+	for _, record := range records {
+		if record.HealthCheck == nil {
+			continue
+		}
+		for _, addr := range record.Addresses() {
+			desired[targetKey{record.ID, addr}] = desiredTarget{host: record.DomainName, check: *record.HealthCheck}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, t := range c.targets {
+		if d, ok := desired[key]; !ok || d.check != t.check || d.host != t.host {
+			t.cancel()
+			delete(c.targets, key)
+		}
+	}
+
+	now := time.Now()
+	for key, d := range desired {
+		if _, ok := c.targets[key]; ok {
+			continue
+		}
+		targetCtx, cancel := context.WithCancel(ctx)
+		t := &target{
+			host:   d.host,
+			check:  d.check,
+			cancel: cancel,
+			health: domain.TargetHealth{RecordID: key.recordID, Target: key.addr, Healthy: true, LastChangedAt: now},
+		}
+		c.targets[key] = t
+		go c.watch(targetCtx, key, t)
+	}
+}
+
+func (c *Checker) watch(ctx context.Context, key targetKey, t *target) {
+	ticker := time.NewTicker(t.check.Interval())
+	defer ticker.Stop()
+	for {
+		c.observe(key, t, probe(ctx, key.addr, t.host, t.check), time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// observe applies the result of a probe to the target's state, marking it
+// down or up once the configured number of consecutive results is reached.
+func (c *Checker) observe(key targetKey, t *target, probeErr error, now time.Time) {
+	c.mu.Lock()
+	if c.targets[key] != t {
+		// Removed or replaced while the probe was running
+		c.mu.Unlock()
+		return
+	}
+
+	h := &t.health
+	h.LastCheckedAt = now
+	changed := false
+	if probeErr == nil {
+		h.ConsecutiveSuccesses++
+		h.ConsecutiveFailures = 0
+		h.LastError = ""
+		if !h.Healthy && h.ConsecutiveSuccesses >= t.check.HealthyThreshold {
+			h.Healthy, changed = true, true
+		}
+	} else {
+		h.ConsecutiveFailures++
+		h.ConsecutiveSuccesses = 0
+		h.LastError = probeErr.Error()
+		if h.Healthy && h.ConsecutiveFailures >= t.check.UnhealthyThreshold {
+			h.Healthy, changed = false, true
+		}
+	}
+	if changed {
+		h.LastChangedAt = now
+	}
+	status := *h
+	c.mu.Unlock()
+
+	switch {
+	case changed && status.Healthy:
+		log.Printf("Target %s of %s (record %d) is healthy again", key.addr, t.host, key.recordID)
+	case changed:
+		log.Printf("Target %s of %s (record %d) is unhealthy: %s", key.addr, t.host, key.recordID, status.LastError)
+	}
+	c.persist(&status, changed)
+}
+
+func (c *Checker) persist(status *domain.TargetHealth, changed bool) {
+	if c.status == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.status.SaveStatus(ctx, status); err != nil {
+		log.Printf("Failed to save health of %s (record %d): %v", status.Target, status.RecordID, err)
+	}
+	if !changed {
+		return
+	}
+	event := &domain.HealthEvent{
+		RecordID:  status.RecordID,
+		Target:    status.Target,
+		Healthy:   status.Healthy,
+		Error:     status.LastError,
+		CreatedAt: status.LastChangedAt,
+	}
+	if err := c.status.AddEvent(ctx, event); err != nil {
+		log.Printf("Failed to record health change of %s (record %d): %v", status.Target, status.RecordID, err)
+	}
+}
@@ -0,0 +1,196 @@
+package healthcheck
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticSource []*domain.DNSRecord
+
+func (s staticSource) FindWithHealthChecks(ctx context.Context) ([]*domain.DNSRecord, error) {
+	return s, nil
+}
+
+// memoryStatus records what the checker persists.
+type memoryStatus struct {
+	mu       sync.Mutex
+	statuses map[string]domain.TargetHealth
+	events   []domain.HealthEvent
+}
+
+func (m *memoryStatus) SaveStatus(ctx context.Context, status *domain.TargetHealth) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.statuses == nil {
+		m.statuses = make(map[string]domain.TargetHealth)
+	}
+	m.statuses[status.Target] = *status
+	return nil
+}
+
+func (m *memoryStatus) AddEvent(ctx context.Context, event *domain.HealthEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *memoryStatus) FindStatusByRecordID(ctx context.Context, recordID int64) ([]*domain.TargetHealth, error) {
+	return nil, nil
+}
+
+func (m *memoryStatus) FindEventsByRecordID(ctx context.Context, recordID int64, limit int) ([]*domain.HealthEvent, error) {
+	return nil, nil
+}
+
+func (m *memoryStatus) DeleteStatusByRecordID(ctx context.Context, recordID int64) error {
+	return nil
+}
+
+func (m *memoryStatus) eventCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.events)
+}
+
+func listenerPort(t *testing.T, addr net.Addr) int {
+	_, port, err := net.SplitHostPort(addr.String())
+	require.NoError(t, err)
+	p, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return p
+}
+
+func TestProbe(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("TCP", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		check := domain.HealthCheck{Type: domain.HealthCheckTCP, Port: listenerPort(t, ln.Addr()), TimeoutSeconds: 1}
+
+		assert.NoError(t, probe(ctx, "127.0.0.1", "svc.local", check))
+
+		ln.Close()
+		assert.Error(t, probe(ctx, "127.0.0.1", "svc.local", check))
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		var gotHost string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotHost = r.Host
+			switch r.URL.Path {
+			case "/healthz":
+				w.WriteHeader(http.StatusOK)
+			case "/moved":
+				http.Redirect(w, r, "/healthz", http.StatusFound)
+			default:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer srv.Close()
+		port := listenerPort(t, srv.Listener.Addr())
+
+		check := domain.HealthCheck{Type: domain.HealthCheckHTTP, Port: port, Path: "/healthz", ExpectedStatus: 200, TimeoutSeconds: 1}
+		assert.NoError(t, probe(ctx, "127.0.0.1", "svc.local", check))
+		assert.Equal(t, "svc.local", gotHost)
+
+		check.Path = "/down"
+		assert.Error(t, probe(ctx, "127.0.0.1", "svc.local", check))
+
+		// Redirects are not followed
+		check.Path = "/moved"
+		assert.Error(t, probe(ctx, "127.0.0.1", "svc.local", check))
+		check.ExpectedStatus = http.StatusFound
+		assert.NoError(t, probe(ctx, "127.0.0.1", "svc.local", check))
+	})
+}
+
+func TestChecker_Thresholds(t *testing.T) {
+	status := &memoryStatus{}
+	c := NewChecker(staticSource{}, status)
+	key := targetKey{recordID: 1, addr: "10.0.0.1"}
+	tg := &target{
+		host:   "svc.local",
+		check:  domain.HealthCheck{HealthyThreshold: 2, UnhealthyThreshold: 3},
+		cancel: func() {},
+		health: domain.TargetHealth{RecordID: 1, Target: "10.0.0.1", Healthy: true},
+	}
+	c.targets[key] = tg
+	now := time.Now()
+
+	c.observe(key, tg, assert.AnError, now)
+	c.observe(key, tg, assert.AnError, now)
+	assert.True(t, tg.health.Healthy)
+	c.observe(key, tg, assert.AnError, now)
+	assert.False(t, tg.health.Healthy)
+	assert.Equal(t, assert.AnError.Error(), tg.health.LastError)
+
+	c.observe(key, tg, nil, now)
+	assert.False(t, tg.health.Healthy)
+	c.observe(key, tg, nil, now)
+	assert.True(t, tg.health.Healthy)
+
+	require.Len(t, status.events, 2)
+	assert.False(t, status.events[0].Healthy)
+	assert.True(t, status.events[1].Healthy)
+	assert.Equal(t, 2, status.statuses["10.0.0.1"].ConsecutiveSuccesses)
+}
+
+func TestChecker_Failover(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// 127.0.0.2 has nothing listening on the port
+	record := &domain.DNSRecord{ID: 1, DomainName: "svc.local", Type: domain.A, Value: "127.0.0.1"}
+	check, err := domain.NewHealthCheck(domain.HealthCheckTCP, listenerPort(t, ln.Addr()), "", 0, 1, 1, 1, 1)
+	require.NoError(t, err)
+	require.NoError(t, record.SetHealthCheck([]string{"127.0.0.2"}, check))
+
+	status := &memoryStatus{}
+	c := NewChecker(staticSource{record}, status)
+	// Unchecked targets are answered
+	assert.Equal(t, []string{"127.0.0.1", "127.0.0.2"}, c.Healthy(record))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx, time.Hour)
+
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"127.0.0.1"}, c.Healthy(record))
+	}, 3*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 1, status.eventCount())
+
+	// With every target down, none is healthy
+	ln.Close()
+	require.Eventually(t, func() bool {
+		return status.eventCount() == 2
+	}, 3*time.Second, 20*time.Millisecond)
+	assert.Empty(t, c.Healthy(record))
+
+	// Records without a check are answered as stored
+	plain := &domain.DNSRecord{ID: 2, Type: domain.A, Value: "10.0.0.1", AdditionalValues: []string{"10.0.0.2"}}
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, c.Healthy(plain))
+	var nilChecker *Checker
+	assert.Equal(t, []string{"127.0.0.1", "127.0.0.2"}, nilChecker.Healthy(record))
+}
@@ -0,0 +1,55 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"internal-dns/internal/domain"
+)
+
+// httpClient does not follow redirects, so that a redirect is judged by its
+// own status code.
+var httpClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// probe checks a single address, returning nil if it is up.
+func probe(ctx context.Context, addr, host string, check domain.HealthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout())
+	defer cancel()
+
+	hostPort := net.JoinHostPort(addr, strconv.Itoa(check.Port))
+	switch check.Type {
+	case domain.HealthCheckTCP:
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", hostPort)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case domain.HealthCheckHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+hostPort+check.Path, nil)
+		if err != nil {
+			return err
+		}
+		req.Host = host
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode != check.ExpectedStatus {
+			return fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported health check type %q", check.Type)
+}
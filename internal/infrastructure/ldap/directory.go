@@ -0,0 +1,176 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"internal-dns/internal/domain"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// Config configures the connection to an LDAP directory and how its users
+// and groups are found.
+type Config struct {
+	URL      string // ldap:// or ldaps://
+	StartTLS bool
+	// BindDN and BindPassword authenticate the searches; an empty BindDN
+	// searches anonymously.
+	BindDN       string
+	BindPassword string
+	UserBaseDN   string
+	// UserFilter finds a user by name; %s is replaced with the escaped
+	// username, e.g. "(uid=%s)".
+	UserFilter        string
+	UsernameAttribute string
+	GroupBaseDN       string
+	// GroupFilter finds the groups of a user; %s is replaced with the
+	// escaped DN of the user, e.g. "(member=%s)".
+	GroupFilter        string
+	GroupNameAttribute string
+	Timeout            time.Duration
+}
+
+// Directory authenticates the users of an LDAP directory.
+type Directory interface {
+	// Authenticate finds the user username and binds as them with
+	// password. It returns domain.ErrInvalidCredentials if there is no such
+	// user or the password is wrong.
+	Authenticate(ctx context.Context, username, password string) (*domain.DirectoryUser, error)
+}
+
+type directory struct {
+	cfg Config
+}
+
+// NewDirectory creates a client of the directory cfg.URL. Every
+// authentication uses a new connection.
+func NewDirectory(cfg Config) Directory {
+	return &directory{cfg: cfg}
+}
+
+func (d *directory) Authenticate(ctx context.Context, username, password string) (*domain.DirectoryUser, error) {
+	// An empty password would be an unauthenticated bind, which servers
+	// accept for any DN
+	if username == "" || password == "" {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := d.bindService(conn); err != nil {
+		return nil, err
+	}
+	entry, err := d.findUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return nil, domain.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to bind as %s: %w", entry.DN, err)
+	}
+
+	user := &domain.DirectoryUser{
+		Directory: d.cfg.URL,
+		DN:        entry.DN,
+		Username:  entry.GetAttributeValue(d.cfg.UsernameAttribute),
+	}
+	if user.Username == "" {
+		user.Username = username
+	}
+
+	// Groups are searched with the permissions of the service account
+	if d.cfg.GroupBaseDN != "" {
+		if err := d.bindService(conn); err != nil {
+			return nil, err
+		}
+		user.Groups, err = d.findGroups(conn, entry.DN)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+func (d *directory) dial(ctx context.Context) (*goldap.Conn, error) {
+	u, err := url.Parse(d.cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+	tlsConfig := &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+
+	dialer := &net.Dialer{Timeout: d.cfg.Timeout}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+	conn, err := goldap.DialURL(d.cfg.URL, goldap.DialWithDialer(dialer), goldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP directory: %w", err)
+	}
+	if d.cfg.Timeout > 0 {
+		conn.SetTimeout(d.cfg.Timeout)
+	}
+	if d.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to start TLS with LDAP directory: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (d *directory) bindService(conn *goldap.Conn) error {
+	if d.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(d.cfg.BindDN, d.cfg.BindPassword); err != nil {
+		return fmt.Errorf("failed to bind as %s: %w", d.cfg.BindDN, err)
+	}
+	return nil
+}
+
+func (d *directory) findUser(conn *goldap.Conn, username string) (*goldap.Entry, error) {
+	filter := strings.ReplaceAll(d.cfg.UserFilter, "%s", goldap.EscapeFilter(username))
+	result, err := conn.Search(goldap.NewSearchRequest(
+		d.cfg.UserBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 0, int(d.cfg.Timeout.Seconds()), false,
+		filter, []string{d.cfg.UsernameAttribute}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP user: %w", err)
+	}
+	// Ambiguous names are refused rather than guessed
+	if len(result.Entries) != 1 {
+		return nil, domain.ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+func (d *directory) findGroups(conn *goldap.Conn, userDN string) ([]string, error) {
+	filter := strings.ReplaceAll(d.cfg.GroupFilter, "%s", goldap.EscapeFilter(userDN))
+	result, err := conn.Search(goldap.NewSearchRequest(
+		d.cfg.GroupBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 0, int(d.cfg.Timeout.Seconds()), false,
+		filter, []string{d.cfg.GroupNameAttribute}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP groups: %w", err)
+	}
+	var groups []string
+	for _, entry := range result.Entries {
+		if name := entry.GetAttributeValue(d.cfg.GroupNameAttribute); name != "" {
+			groups = append(groups, name)
+		}
+	}
+	return groups, nil
+}
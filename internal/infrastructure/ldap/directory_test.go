@@ -0,0 +1,78 @@
+package ldap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectory_Authenticate(t *testing.T) {
+	ctx := context.Background()
+	stub := newLDAPStub(t)
+	stub.add("cn=search,dc=example,dc=com", "search-secret", map[string][]string{"cn": {"search"}})
+	stub.add("uid=alice,ou=people,dc=example,dc=com", "alice-secret", map[string][]string{"objectClass": {"person"}, "uid": {"alice"}})
+	stub.add("uid=bob,ou=people,dc=example,dc=com", "bob-secret", map[string][]string{"objectClass": {"person"}, "uid": {"bob"}})
+	stub.add("cn=dns-admins,ou=groups,dc=example,dc=com", "", map[string][]string{"cn": {"dns-admins"}, "member": {"uid=alice,ou=people,dc=example,dc=com"}})
+	stub.add("cn=staff,ou=groups,dc=example,dc=com", "", map[string][]string{"cn": {"staff"}, "member": {"uid=alice,ou=people,dc=example,dc=com", "uid=bob,ou=people,dc=example,dc=com"}})
+
+	cfg := Config{
+		URL:                stub.URL(),
+		BindDN:             "cn=search,dc=example,dc=com",
+		BindPassword:       "search-secret",
+		UserBaseDN:         "ou=people,dc=example,dc=com",
+		UserFilter:         "(&(objectClass=person)(uid=%s))",
+		UsernameAttribute:  "uid",
+		GroupBaseDN:        "ou=groups,dc=example,dc=com",
+		GroupFilter:        "(member=%s)",
+		GroupNameAttribute: "cn",
+		Timeout:            5 * time.Second,
+	}
+	dir := NewDirectory(cfg)
+
+	t.Run("Valid credentials", func(t *testing.T) {
+		user, err := dir.Authenticate(ctx, "alice", "alice-secret")
+		require.NoError(t, err)
+		assert.Equal(t, stub.URL(), user.Directory)
+		assert.Equal(t, "uid=alice,ou=people,dc=example,dc=com", user.DN)
+		assert.Equal(t, "alice", user.Username)
+		assert.ElementsMatch(t, []string{"dns-admins", "staff"}, user.Groups)
+
+		user, err = dir.Authenticate(ctx, "bob", "bob-secret")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"staff"}, user.Groups)
+	})
+
+	t.Run("Invalid credentials", func(t *testing.T) {
+		for _, creds := range [][2]string{
+			{"alice", "wrong"},
+			{"alice", ""},
+			{"alice", "bob-secret"},
+			{"carol", "alice-secret"},
+			// Filter syntax in usernames is escaped
+			{"*", "alice-secret"},
+			{"alice)(uid=*", "alice-secret"},
+		} {
+			_, err := dir.Authenticate(ctx, creds[0], creds[1])
+			assert.ErrorIs(t, err, domain.ErrInvalidCredentials, creds[0])
+		}
+	})
+
+	t.Run("Directory errors are not invalid credentials", func(t *testing.T) {
+		wrongBind := cfg
+		wrongBind.BindPassword = "wrong"
+		_, err := NewDirectory(wrongBind).Authenticate(ctx, "alice", "alice-secret")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, domain.ErrInvalidCredentials)
+
+		unreachable := cfg
+		unreachable.URL = "ldap://127.0.0.1:1"
+		_, err = NewDirectory(unreachable).Authenticate(ctx, "alice", "alice-secret")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, domain.ErrInvalidCredentials)
+	})
+}
@@ -0,0 +1,208 @@
+package ldap
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// LDAP protocol operations and result codes (RFC 4511)
+const (
+	opBindRequest      = 0
+	opBindResponse     = 1
+	opUnbindRequest    = 2
+	opSearchRequest    = 3
+	opSearchResultItem = 4
+	opSearchResultDone = 5
+
+	resultSuccess            = 0
+	resultOperationsError    = 1
+	resultInvalidCredentials = 49
+)
+
+// ldapStub is an in-process LDAP server that supports simple binds and
+// searches with and, or, not, equality and presence filters.
+type ldapStub struct {
+	listener net.Listener
+
+	mu        sync.Mutex
+	entries   map[string]map[string][]string // attributes by DN
+	passwords map[string]string              // by DN
+}
+
+func newLDAPStub(t *testing.T) *ldapStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ldapStub{listener: listener, entries: make(map[string]map[string][]string), passwords: make(map[string]string)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *ldapStub) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// add adds an entry; entries with a password can bind.
+func (s *ldapStub) add(dn, password string, attributes map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[dn] = attributes
+	if password != "" {
+		s.passwords[dn] = password
+	}
+}
+
+func (s *ldapStub) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value
+		op := packet.Children[1]
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case opBindRequest:
+			responses = append(responses, s.bind(op))
+		case opSearchRequest:
+			responses = s.search(op)
+		case opUnbindRequest:
+			return
+		default:
+			responses = append(responses, result(opSearchResultDone, resultOperationsError))
+		}
+		for _, response := range responses {
+			envelope := ber.NewSequence("LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+			envelope.AppendChild(response)
+			if _, err := conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *ldapStub) bind(op *ber.Packet) *ber.Packet {
+	dn := op.Children[1].Value.(string)
+	password := op.Children[2].Data.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if want, ok := s.passwords[dn]; !ok || password == "" || password != want {
+		return result(opBindResponse, resultInvalidCredentials)
+	}
+	return result(opBindResponse, resultSuccess)
+}
+
+func (s *ldapStub) search(op *ber.Packet) []*ber.Packet {
+	base := strings.ToLower(op.Children[0].Value.(string))
+	filter := op.Children[6]
+	var requested []string
+	for _, attr := range op.Children[7].Children {
+		requested = append(requested, attr.Value.(string))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var responses []*ber.Packet
+	for dn, attributes := range s.entries {
+		lower := strings.ToLower(dn)
+		if lower != base && !strings.HasSuffix(lower, ","+base) {
+			continue
+		}
+		if !matches(filter, attributes) {
+			continue
+		}
+
+		entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, opSearchResultItem, nil, "Search Result Entry")
+		entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+		list := ber.NewSequence("Attributes")
+		for _, name := range requested {
+			values, ok := attribute(attributes, name)
+			if !ok {
+				continue
+			}
+			attr := ber.NewSequence("Attribute")
+			attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, v := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+			}
+			attr.AppendChild(set)
+			list.AppendChild(attr)
+		}
+		entry.AppendChild(list)
+		responses = append(responses, entry)
+	}
+	return append(responses, result(opSearchResultDone, resultSuccess))
+}
+
+// matches evaluates an LDAP filter against the attributes of an entry.
+func matches(filter *ber.Packet, attributes map[string][]string) bool {
+	switch filter.Tag {
+	case 0: // and
+		for _, f := range filter.Children {
+			if !matches(f, attributes) {
+				return false
+			}
+		}
+		return true
+	case 1: // or
+		for _, f := range filter.Children {
+			if matches(f, attributes) {
+				return true
+			}
+		}
+		return false
+	case 2: // not
+		return !matches(filter.Children[0], attributes)
+	case 3: // equalityMatch
+		values, _ := attribute(attributes, filter.Children[0].Data.String())
+		want := filter.Children[1].Data.String()
+		for _, v := range values {
+			if strings.EqualFold(v, want) {
+				return true
+			}
+		}
+		return false
+	case 7: // present
+		_, ok := attribute(attributes, filter.Data.String())
+		return ok
+	}
+	return false
+}
+
+func attribute(attributes map[string][]string, name string) ([]string, bool) {
+	for k, v := range attributes {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func result(op ber.Tag, code int64) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, op, nil, "Result")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return p
+}
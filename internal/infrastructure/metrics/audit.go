@@ -0,0 +1,52 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Audit outbox stages used as the "stage" label of AuditMetrics.Failures.
+const (
+	AuditStageEnqueue = "enqueue"
+	AuditStageClaim   = "claim"
+	AuditStageDeliver = "deliver"
+	AuditStageAck     = "ack"
+)
+
+// AuditMetrics holds the Prometheus collectors of the audit log outbox.
+type AuditMetrics struct {
+	// Enqueued counts audit log entries queued in the outbox.
+	Enqueued prometheus.Counter
+	// Delivered counts audit log entries moved from the outbox to the database.
+	Delivered prometheus.Counter
+	// Failures counts failed outbox operations by stage (enqueue, claim, deliver, ack).
+	Failures *prometheus.CounterVec
+	// Backlog is the number of entries waiting in the outbox.
+	Backlog prometheus.Gauge
+}
+
+// NewAuditMetrics creates the audit outbox collectors and registers them with reg.
+func NewAuditMetrics(reg prometheus.Registerer) *AuditMetrics {
+	m := &AuditMetrics{
+		Enqueued: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "audit",
+			Name:      "outbox_enqueued_total",
+			Help:      "Total number of audit log entries queued in the outbox.",
+		}),
+		Delivered: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "audit",
+			Name:      "outbox_delivered_total",
+			Help:      "Total number of audit log entries stored from the outbox.",
+		}),
+		Failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "audit",
+			Name:      "outbox_failures_total",
+			Help:      "Total number of failed audit outbox operations, by stage.",
+		}, []string{"stage"}),
+		Backlog: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "audit",
+			Name:      "outbox_backlog",
+			Help:      "Number of audit log entries waiting in the outbox.",
+		}),
+	}
+
+	reg.MustRegister(m.Enqueued, m.Delivered, m.Failures, m.Backlog)
+	return m
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dns"
+
+// Cache lookup results used as the "result" label of CacheLookups.
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+// Backends used as the "backend" label of BackendErrors.
+const (
+	BackendCache     = "cache"
+	BackendDatabase  = "database"
+	BackendForwarder = "forwarder"
+)
+
+// Resolution results used as the "result" label of RequestDuration.
+const (
+	ResultSuccess  = "success"
+	ResultNXDomain = "nxdomain"
+	ResultFailure  = "failure"
+)
+
+// DNSMetrics holds the Prometheus collectors exported by the DNS server.
+type DNSMetrics struct {
+	// Queries counts answered questions by query type, response code and transport.
+	Queries *prometheus.CounterVec
+	// QueryDuration observes the time spent answering a question by query type and transport.
+	QueryDuration *prometheus.HistogramVec
+	// RequestDuration observes the time spent on a whole request by outcome, so that
+	// resolution failures can be alerted on independently of the query mix.
+	RequestDuration *prometheus.HistogramVec
+	// CacheLookups counts record cache lookups by result (hit, miss, error).
+	CacheLookups *prometheus.CounterVec
+	// BackendErrors counts errors returned by the cache or database backends.
+	BackendErrors *prometheus.CounterVec
+	// QueryLogDropped counts query log entries discarded because the log buffer was full.
+	QueryLogDropped prometheus.Counter
+	// RateLimited counts responses withheld by response rate limiting, by action (drop, slip).
+	RateLimited *prometheus.CounterVec
+	// ACLDenied counts requests refused by an access control list, by list (query, recursion, transfer, update).
+	ACLDenied *prometheus.CounterVec
+	// PolicyHits counts queries matching a response policy rule, by action and rule source.
+	PolicyHits *prometheus.CounterVec
+}
+
+// NewDNSMetrics creates the DNS server collectors and registers them with reg.
+func NewDNSMetrics(reg prometheus.Registerer) *DNSMetrics {
+	m := &DNSMetrics{
+		Queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "queries_total",
+			Help:      "Total number of DNS questions answered.",
+		}, []string{"qtype", "rcode", "transport"}),
+		QueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "query_duration_seconds",
+			Help:      "Time spent answering a DNS question.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 16),
+		}, []string{"qtype", "transport"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time spent handling a DNS request, by outcome.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 16),
+		}, []string{"transport", "result"}),
+		CacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_lookups_total",
+			Help:      "Total number of record cache lookups, by result.",
+		}, []string{"result"}),
+		BackendErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "backend_errors_total",
+			Help:      "Total number of errors returned by storage backends.",
+		}, []string{"backend"}),
+		QueryLogDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "query_log_dropped_total",
+			Help:      "Total number of query log entries dropped because the buffer was full.",
+		}),
+		RateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rrl_responses_total",
+			Help:      "Total number of responses dropped or truncated by response rate limiting.",
+		}, []string{"action"}),
+		ACLDenied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "acl_denied_total",
+			Help:      "Total number of requests refused by an access control list.",
+		}, []string{"acl"}),
+		PolicyHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "policy_hits_total",
+			Help:      "Total number of queries matching a response policy rule.",
+		}, []string{"action", "source"}),
+	}
+
+	reg.MustRegister(m.Queries, m.QueryDuration, m.RequestDuration, m.CacheLookups, m.BackendErrors, m.QueryLogDropped, m.RateLimited, m.ACLDenied, m.PolicyHits)
+	return m
+}
+
+// NewRegistry creates a registry pre-populated with the Go runtime and process collectors.
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg
+}
+
+// NewServer creates an HTTP server exposing the metrics gathered by g on /metrics.
+func NewServer(addr string, g prometheus.Gatherer) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(g, promhttp.HandlerOpts{}))
+	return &http.Server{Addr: addr, Handler: mux}
+}
@@ -0,0 +1,313 @@
+package querylog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// dnstapContentType is the Frame Streams content type of dnstap payloads.
+const dnstapContentType = "protobuf:dnstap.Dnstap"
+
+// Frame Streams control frame types.
+const (
+	fstrmControlAccept uint32 = 0x01
+	fstrmControlStart  uint32 = 0x02
+	fstrmControlStop   uint32 = 0x03
+	fstrmControlReady  uint32 = 0x04
+	fstrmControlFinish uint32 = 0x05
+
+	fstrmFieldContentType uint32 = 0x01
+)
+
+// dnstap protobuf enum values (see dnstap.proto).
+const (
+	dnstapTypeMessage = 1
+
+	dnstapMessageClientQuery    = 5
+	dnstapMessageClientResponse = 6
+
+	dnstapSocketFamilyINET  = 1
+	dnstapSocketFamilyINET6 = 2
+
+	dnstapSocketProtocolUDP = 1
+	dnstapSocketProtocolTCP = 2
+)
+
+// handshakeTimeout bounds how long the bidirectional Frame Streams handshake may take.
+const handshakeTimeout = 5 * time.Second
+
+// dnstapSink encodes entries as dnstap CLIENT_QUERY/CLIENT_RESPONSE messages
+// in a Frame Streams container.
+type dnstapSink struct {
+	mu       sync.Mutex
+	buf      *bufio.Writer
+	conn     net.Conn // set for bidirectional (socket) streams
+	closer   io.Closer
+	identity []byte
+	version  []byte
+}
+
+// NewDnstapSink creates a sink writing a unidirectional Frame Streams dnstap
+// stream to w, as used for dnstap files.
+func NewDnstapSink(w io.Writer, identity, version string) (Sink, error) {
+	return newUnidirectionalDnstapSink(w, nil, identity, version)
+}
+
+// NewDnstapFileSink creates a dnstap sink writing to a new file at path.
+func NewDnstapFileSink(path, identity, version string) (Sink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := newUnidirectionalDnstapSink(f, f, identity, version)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return sink, nil
+}
+
+func newUnidirectionalDnstapSink(w io.Writer, closer io.Closer, identity, version string) (*dnstapSink, error) {
+	s := &dnstapSink{buf: bufio.NewWriter(w), closer: closer, identity: []byte(identity), version: []byte(version)}
+	if err := s.writeControl(fstrmControlStart, dnstapContentType); err != nil {
+		return nil, err
+	}
+	if err := s.buf.Flush(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DialDnstapSink connects to a dnstap collector listening on a unix or TCP
+// socket and performs the bidirectional Frame Streams handshake.
+func DialDnstapSink(network, addr, identity, version string) (Sink, error) {
+	conn, err := net.DialTimeout(network, addr, handshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	s := &dnstapSink{buf: bufio.NewWriter(conn), conn: conn, identity: []byte(identity), version: []byte(version)}
+	if err := s.writeControl(fstrmControlReady, dnstapContentType); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := s.buf.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := readControl(conn, fstrmControlAccept); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("dnstap handshake: %w", err)
+	}
+	if err := s.writeControl(fstrmControlStart, dnstapContentType); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := s.buf.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return s, nil
+}
+
+func (s *dnstapSink) Write(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.Query != nil {
+		if err := s.writeData(encodeDnstap(e, dnstapMessageClientQuery, s.identity, s.version)); err != nil {
+			return err
+		}
+	}
+	if e.Response != nil {
+		if err := s.writeData(encodeDnstap(e, dnstapMessageClientResponse, s.identity, s.version)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *dnstapSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Flush()
+}
+
+func (s *dnstapSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.writeControl(fstrmControlStop, "")
+	if err == nil {
+		err = s.buf.Flush()
+	}
+	if s.conn != nil {
+		if err == nil {
+			_ = s.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+			err = readControl(s.conn, fstrmControlFinish)
+		}
+		return errors.Join(err, s.conn.Close())
+	}
+	if s.closer != nil {
+		return errors.Join(err, s.closer.Close())
+	}
+	return err
+}
+
+func (s *dnstapSink) writeData(payload []byte) error {
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], uint32(len(payload)))
+	if _, err := s.buf.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := s.buf.Write(payload)
+	return err
+}
+
+// writeControl writes an escaped control frame, optionally carrying a content type field.
+func (s *dnstapSink) writeControl(controlType uint32, contentType string) error {
+	frame := binary.BigEndian.AppendUint32(nil, controlType)
+	if contentType != "" {
+		frame = binary.BigEndian.AppendUint32(frame, fstrmFieldContentType)
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(contentType)))
+		frame = append(frame, contentType...)
+	}
+
+	hdr := binary.BigEndian.AppendUint32(nil, 0) // escape sequence
+	hdr = binary.BigEndian.AppendUint32(hdr, uint32(len(frame)))
+	if _, err := s.buf.Write(hdr); err != nil {
+		return err
+	}
+	_, err := s.buf.Write(frame)
+	return err
+}
+
+// readControl reads one control frame from r and checks its type.
+func readControl(r io.Reader, want uint32) error {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return err
+	}
+	if binary.BigEndian.Uint32(hdr[:4]) != 0 {
+		return errors.New("expected control frame")
+	}
+	frame := make([]byte, binary.BigEndian.Uint32(hdr[4:]))
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return err
+	}
+	if len(frame) < 4 {
+		return errors.New("short control frame")
+	}
+	if got := binary.BigEndian.Uint32(frame[:4]); got != want {
+		return fmt.Errorf("unexpected control frame type %d, want %d", got, want)
+	}
+	return nil
+}
+
+// encodeDnstap encodes an entry as a dnstap.Dnstap protobuf message of the given message type.
+func encodeDnstap(e *Entry, messageType int, identity, version []byte) []byte {
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(messageType))
+
+	clientIP, clientPort := splitAddr(e.RemoteAddr)
+	serverIP, serverPort := splitAddr(e.LocalAddr)
+
+	family := dnstapSocketFamilyINET
+	if clientIP != nil && clientIP.To4() == nil {
+		family = dnstapSocketFamilyINET6
+	}
+	msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(family))
+
+	protocol := dnstapSocketProtocolUDP
+	if e.Transport == "tcp" {
+		protocol = dnstapSocketProtocolTCP
+	}
+	msg = protowire.AppendTag(msg, 3, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(protocol))
+
+	if clientIP != nil {
+		msg = protowire.AppendTag(msg, 4, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, ipBytes(clientIP))
+		msg = protowire.AppendTag(msg, 6, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(clientPort))
+	}
+	if serverIP != nil {
+		msg = protowire.AppendTag(msg, 5, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, ipBytes(serverIP))
+		msg = protowire.AppendTag(msg, 7, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(serverPort))
+	}
+
+	msg = protowire.AppendTag(msg, 8, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(e.Time.Unix()))
+	msg = protowire.AppendTag(msg, 9, protowire.Fixed32Type)
+	msg = protowire.AppendFixed32(msg, uint32(e.Time.Nanosecond()))
+
+	if e.Query != nil {
+		if packed, err := e.Query.Pack(); err == nil {
+			msg = protowire.AppendTag(msg, 10, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, packed)
+		}
+	}
+
+	if messageType == dnstapMessageClientResponse {
+		responseTime := e.Time.Add(time.Duration(e.LatencyMS * float64(time.Millisecond)))
+		msg = protowire.AppendTag(msg, 12, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(responseTime.Unix()))
+		msg = protowire.AppendTag(msg, 13, protowire.Fixed32Type)
+		msg = protowire.AppendFixed32(msg, uint32(responseTime.Nanosecond()))
+
+		if e.Response != nil {
+			if packed, err := e.Response.Pack(); err == nil {
+				msg = protowire.AppendTag(msg, 14, protowire.BytesType)
+				msg = protowire.AppendBytes(msg, packed)
+			}
+		}
+	}
+
+	var out []byte
+	if len(identity) > 0 {
+		out = protowire.AppendTag(out, 1, protowire.BytesType)
+		out = protowire.AppendBytes(out, identity)
+	}
+	if len(version) > 0 {
+		out = protowire.AppendTag(out, 2, protowire.BytesType)
+		out = protowire.AppendBytes(out, version)
+	}
+	out = protowire.AppendTag(out, 14, protowire.BytesType)
+	out = protowire.AppendBytes(out, msg)
+	out = protowire.AppendTag(out, 15, protowire.VarintType)
+	out = protowire.AppendVarint(out, dnstapTypeMessage)
+	return out
+}
+
+func splitAddr(addr net.Addr) (net.IP, int) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP, a.Port
+	case *net.TCPAddr:
+		return a.IP, a.Port
+	}
+	return nil, 0
+}
+
+func ipBytes(ip net.IP) []byte {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip.To16()
+}
@@ -0,0 +1,59 @@
+package querylog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// jsonSink writes entries as newline-delimited JSON.
+type jsonSink struct {
+	mu     sync.Mutex
+	buf    *bufio.Writer
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+// NewJSONSink creates a sink writing newline-delimited JSON entries to w.
+// Closing the sink flushes pending output but does not close w.
+func NewJSONSink(w io.Writer) Sink {
+	buf := bufio.NewWriter(w)
+	return &jsonSink{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// NewFileSink creates a sink appending newline-delimited JSON entries to the
+// file at path, creating it if necessary.
+func NewFileSink(path string) (Sink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(f)
+	return &jsonSink{buf: buf, enc: json.NewEncoder(buf), closer: f}, nil
+}
+
+func (s *jsonSink) Write(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(e)
+}
+
+func (s *jsonSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Flush()
+}
+
+func (s *jsonSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
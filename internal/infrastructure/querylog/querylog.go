@@ -0,0 +1,173 @@
+package querylog
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Entry is a single structured query log record.
+type Entry struct {
+	Time        time.Time `json:"time"`
+	Client      string    `json:"client"`
+	ClientPort  int       `json:"clientPort"`
+	Transport   string    `json:"transport"`
+	QName       string    `json:"qname"`
+	QType       string    `json:"qtype"`
+	RCode       string    `json:"rcode"`
+	LatencyMS   float64   `json:"latencyMs"`
+	CacheStatus string    `json:"cacheStatus,omitempty"`
+	Answers     int       `json:"answers"`
+
+	// PolicyAction and PolicyRule are set when a response policy rule matched.
+	PolicyAction string `json:"policyAction,omitempty"`
+	PolicyRule   string `json:"policyRule,omitempty"`
+
+	// The raw messages and socket addresses are only needed by sinks that
+	// re-encode the exchange (e.g. dnstap) and are not part of the JSON form.
+	Query      *dns.Msg `json:"-"`
+	Response   *dns.Msg `json:"-"`
+	LocalAddr  net.Addr `json:"-"`
+	RemoteAddr net.Addr `json:"-"`
+}
+
+// Sink receives query log entries from a Logger.
+type Sink interface {
+	Write(e *Entry) error
+	Close() error
+}
+
+// flusher is implemented by sinks that buffer output. The Logger flushes them
+// whenever its queue drains so entries are not held back under light load.
+type flusher interface {
+	Flush() error
+}
+
+// Config controls sampling and buffering of a Logger.
+type Config struct {
+	// SampleRate is the fraction of queries logged, between 0 and 1.
+	SampleRate float64
+	// BufferSize is the number of entries queued before new entries are dropped.
+	BufferSize int
+}
+
+// Logger samples query log entries and hands them to its sinks on a
+// background goroutine so that logging never blocks query handling.
+type Logger struct {
+	cfg     Config
+	sinks   []Sink
+	entries chan *Entry
+	dropped prometheus.Counter
+	done    chan struct{}
+
+	mu     sync.Mutex
+	rnd    *rand.Rand
+	closed bool
+}
+
+// New creates a Logger and starts its writer goroutine. dropped, if not nil,
+// is incremented for every entry discarded because the buffer was full.
+func New(cfg Config, dropped prometheus.Counter, sinks ...Sink) *Logger {
+	if cfg.BufferSize < 1 {
+		cfg.BufferSize = 1024
+	}
+	l := &Logger{
+		cfg:     cfg,
+		sinks:   sinks,
+		entries: make(chan *Entry, cfg.BufferSize),
+		dropped: dropped,
+		done:    make(chan struct{}),
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	go l.run()
+	return l
+}
+
+// Log queues an entry for writing, subject to sampling. It never blocks; if
+// the buffer is full the entry is dropped. Log is a no-op on a nil Logger.
+func (l *Logger) Log(e *Entry) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed || !l.sampled() {
+		return
+	}
+
+	select {
+	case l.entries <- e:
+	default:
+		if l.dropped != nil {
+			l.dropped.Inc()
+		}
+	}
+}
+
+// sampled reports whether the next entry should be logged. Callers must hold l.mu.
+func (l *Logger) sampled() bool {
+	if l.cfg.SampleRate >= 1 {
+		return true
+	}
+	if l.cfg.SampleRate <= 0 {
+		return false
+	}
+	return l.rnd.Float64() < l.cfg.SampleRate
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+	for e := range l.entries {
+		for _, sink := range l.sinks {
+			if err := sink.Write(e); err != nil {
+				log.Printf("query log sink write failed: %v", err)
+			}
+		}
+		if len(l.entries) == 0 {
+			l.flush()
+		}
+	}
+}
+
+func (l *Logger) flush() {
+	for _, sink := range l.sinks {
+		if f, ok := sink.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				log.Printf("query log sink flush failed: %v", err)
+			}
+		}
+	}
+}
+
+// Close stops accepting entries, writes everything still buffered and closes
+// the sinks.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.entries)
+	l.mu.Unlock()
+
+	<-l.done
+
+	var firstErr error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,228 @@
+package querylog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// memorySink records entries in memory. If block is set, Write waits on it.
+type memorySink struct {
+	mu      sync.Mutex
+	entries []*Entry
+	block   chan struct{}
+	closed  bool
+}
+
+func (s *memorySink) Write(e *Entry) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func testEntry(qname string) *Entry {
+	query := new(dns.Msg)
+	query.SetQuestion(dns.Fqdn(qname), dns.TypeA)
+	response := new(dns.Msg)
+	response.SetReply(query)
+
+	return &Entry{
+		Time:        time.Unix(1700000000, 500),
+		Client:      "10.0.0.7",
+		ClientPort:  40000,
+		Transport:   "udp",
+		QName:       qname,
+		QType:       "A",
+		RCode:       "NOERROR",
+		LatencyMS:   1.5,
+		CacheStatus: "hit",
+		Query:       query,
+		Response:    response,
+		LocalAddr:   &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 53},
+		RemoteAddr:  &net.UDPAddr{IP: net.ParseIP("10.0.0.7"), Port: 40000},
+	}
+}
+
+func TestLogger_Sampling(t *testing.T) {
+	t.Run("Rate 1 logs everything", func(t *testing.T) {
+		sink := &memorySink{}
+		l := New(Config{SampleRate: 1, BufferSize: 100}, nil, sink)
+		for i := 0; i < 50; i++ {
+			l.Log(testEntry("a.local"))
+		}
+		require.NoError(t, l.Close())
+		assert.Len(t, sink.entries, 50)
+		assert.True(t, sink.closed)
+	})
+
+	t.Run("Rate 0 logs nothing", func(t *testing.T) {
+		sink := &memorySink{}
+		l := New(Config{SampleRate: 0, BufferSize: 100}, nil, sink)
+		for i := 0; i < 50; i++ {
+			l.Log(testEntry("a.local"))
+		}
+		require.NoError(t, l.Close())
+		assert.Empty(t, sink.entries)
+	})
+
+	t.Run("Fractional rate logs a subset", func(t *testing.T) {
+		sink := &memorySink{}
+		l := New(Config{SampleRate: 0.5, BufferSize: 2000}, nil, sink)
+		for i := 0; i < 1000; i++ {
+			l.Log(testEntry("a.local"))
+		}
+		require.NoError(t, l.Close())
+		assert.InDelta(t, 500, len(sink.entries), 150)
+	})
+}
+
+func TestLogger_DropsWhenBufferFull(t *testing.T) {
+	sink := &memorySink{block: make(chan struct{})}
+	dropped := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"})
+	l := New(Config{SampleRate: 1, BufferSize: 2}, dropped, sink)
+
+	// The first entry is taken by the writer goroutine and blocks in the sink,
+	// the next two fill the buffer and the rest are dropped.
+	l.Log(testEntry("a.local"))
+	require.Eventually(t, func() bool { return len(l.entries) == 0 }, time.Second, time.Millisecond)
+	for i := 0; i < 5; i++ {
+		l.Log(testEntry("a.local"))
+	}
+	assert.Equal(t, 3.0, testutil.ToFloat64(dropped))
+
+	close(sink.block)
+	require.NoError(t, l.Close())
+	assert.Len(t, sink.entries, 3)
+
+	// Entries logged after Close are ignored.
+	l.Log(testEntry("a.local"))
+	assert.Len(t, sink.entries, 3)
+}
+
+func TestLogger_NilIsNoop(t *testing.T) {
+	var l *Logger
+	l.Log(testEntry("a.local"))
+	assert.NoError(t, l.Close())
+}
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONSink(&buf)
+
+	require.NoError(t, sink.Write(testEntry("a.local")))
+	require.NoError(t, sink.Write(testEntry("b.local")))
+	require.NoError(t, sink.Close())
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[1], &got))
+	assert.Equal(t, "b.local", got["qname"])
+	assert.Equal(t, "10.0.0.7", got["client"])
+	assert.Equal(t, "A", got["qtype"])
+	assert.Equal(t, "NOERROR", got["rcode"])
+	assert.Equal(t, "hit", got["cacheStatus"])
+	assert.Equal(t, 1.5, got["latencyMs"])
+	assert.NotContains(t, got, "Query")
+}
+
+func TestDnstapSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink, err := NewDnstapSink(&buf, "dns-1", "test")
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Write(testEntry("a.local")))
+	require.NoError(t, sink.Close())
+
+	r := bytes.NewReader(buf.Bytes())
+	require.NoError(t, readControl(r, fstrmControlStart))
+
+	var messageTypes []uint64
+	for {
+		var hdr [4]byte
+		_, err := io.ReadFull(r, hdr[:])
+		require.NoError(t, err)
+		length := binary.BigEndian.Uint32(hdr[:])
+		if length == 0 {
+			// Escape sequence: the only control frame left is STOP.
+			var control [8]byte
+			_, err := io.ReadFull(r, control[:])
+			require.NoError(t, err)
+			assert.Equal(t, fstrmControlStop, binary.BigEndian.Uint32(control[4:]))
+			assert.Zero(t, r.Len())
+			break
+		}
+		payload := make([]byte, length)
+		_, err = io.ReadFull(r, payload)
+		require.NoError(t, err)
+
+		fields := decodeFields(t, payload)
+		assert.Equal(t, []byte("dns-1"), fields[1])
+		message := decodeFields(t, fields[14].([]byte))
+		messageTypes = append(messageTypes, message[1].(uint64))
+		assert.Equal(t, net.ParseIP("10.0.0.7").To4(), net.IP(message[4].([]byte)))
+		assert.Equal(t, uint64(40000), message[6])
+		assert.Equal(t, uint64(1700000000), message[8])
+
+		var query dns.Msg
+		require.NoError(t, query.Unpack(message[10].([]byte)))
+		assert.Equal(t, "a.local.", query.Question[0].Name)
+	}
+
+	assert.Equal(t, []uint64{dnstapMessageClientQuery, dnstapMessageClientResponse}, messageTypes)
+}
+
+// decodeFields decodes a flat protobuf message into a map of field number to value.
+func decodeFields(t *testing.T, b []byte) map[protowire.Number]interface{} {
+	t.Helper()
+	fields := make(map[protowire.Number]interface{})
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			require.GreaterOrEqual(t, n, 0)
+			fields[num] = v
+			b = b[n:]
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			require.GreaterOrEqual(t, n, 0)
+			fields[num] = v
+			b = b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			require.GreaterOrEqual(t, n, 0)
+			fields[num] = v
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+	}
+	return fields
+}
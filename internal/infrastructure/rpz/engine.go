@@ -0,0 +1,203 @@
+// Package rpz implements a response policy engine in the style of DNS RPZ:
+// rules loaded from the database or from RPZ zone files rewrite answers for
+// matching query names before normal resolution.
+package rpz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+// Source provides policy rules to an Engine.
+type Source interface {
+	// Name identifies the source in logs.
+	Name() string
+	Rules(ctx context.Context) ([]*domain.PolicyRule, error)
+}
+
+type repositorySource struct {
+	repo repository.PolicyRuleRepository
+}
+
+// NewRepositorySource creates a source serving the admin-managed rules stored in the database.
+func NewRepositorySource(repo repository.PolicyRuleRepository) Source {
+	return &repositorySource{repo: repo}
+}
+
+func (s *repositorySource) Name() string { return "database" }
+
+func (s *repositorySource) Rules(ctx context.Context) ([]*domain.PolicyRule, error) {
+	return s.repo.FindAll(ctx)
+}
+
+// ruleSet is an immutable index of rules by match type. A trigger has one
+// rule, or one redirect rule per address family.
+type ruleSet struct {
+	exact    map[string][]*domain.PolicyRule
+	suffix   map[string][]*domain.PolicyRule
+	wildcard map[string][]*domain.PolicyRule // keyed by the parent of "*."
+	size     int
+}
+
+func newRuleSet() *ruleSet {
+	return &ruleSet{
+		exact:    make(map[string][]*domain.PolicyRule),
+		suffix:   make(map[string][]*domain.PolicyRule),
+		wildcard: make(map[string][]*domain.PolicyRule),
+	}
+}
+
+// add indexes rule unless an earlier rule already uses the same trigger. A
+// redirect rule is still added next to a redirect rule of the other address
+// family.
+func (rs *ruleSet) add(rule *domain.PolicyRule) {
+	var index map[string][]*domain.PolicyRule
+	key := rule.Pattern
+	switch rule.Match {
+	case domain.PolicyMatchExact:
+		index = rs.exact
+	case domain.PolicyMatchSuffix:
+		index = rs.suffix
+	case domain.PolicyMatchWildcard:
+		index = rs.wildcard
+		key = strings.TrimPrefix(key, "*.")
+	default:
+		return
+	}
+	for _, existing := range index[key] {
+		if existing.Action != domain.PolicyActionRedirect || rule.Action != domain.PolicyActionRedirect ||
+			redirectsToIPv4(existing) == redirectsToIPv4(rule) {
+			return
+		}
+	}
+	index[key] = append(index[key], rule)
+	rs.size++
+}
+
+// redirectsToIPv4 reports whether a redirect rule answers with an IPv4
+// address.
+func redirectsToIPv4(rule *domain.PolicyRule) bool {
+	ip := net.ParseIP(rule.RedirectIP)
+	return ip != nil && ip.To4() != nil
+}
+
+// lookup returns the rules of the most specific trigger matching name.
+// Exact rules win over wildcard and suffix rules, which are tried from the
+// closest enclosing name up.
+func (rs *ruleSet) lookup(name string) []*domain.PolicyRule {
+	if rules, ok := rs.exact[name]; ok {
+		return rules
+	}
+	for candidate := name; ; {
+		if candidate != name {
+			if rules, ok := rs.wildcard[candidate]; ok {
+				return rules
+			}
+		}
+		if rules, ok := rs.suffix[candidate]; ok {
+			return rules
+		}
+		i := strings.IndexByte(candidate, '.')
+		if i < 0 {
+			return nil
+		}
+		candidate = candidate[i+1:]
+	}
+}
+
+// Engine evaluates query names against the rules of its sources. Rules are
+// reloaded with Refresh; lookups always see a consistent snapshot.
+type Engine struct {
+	sources []Source
+	current atomic.Pointer[ruleSet]
+
+	mu       sync.Mutex
+	lastGood map[string][]*domain.PolicyRule
+}
+
+// NewEngine creates an engine over sources. Earlier sources take precedence
+// when several define a rule for the same trigger.
+func NewEngine(sources ...Source) *Engine {
+	e := &Engine{sources: sources, lastGood: make(map[string][]*domain.PolicyRule)}
+	e.current.Store(newRuleSet())
+	return e
+}
+
+// Refresh reloads the rules of every source. A source that fails keeps the
+// rules it last provided; the errors are returned joined.
+func (e *Engine) Refresh(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []error
+	rs := newRuleSet()
+	for _, src := range e.sources {
+		rules, err := src.Rules(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", src.Name(), err))
+			rules = e.lastGood[src.Name()]
+		} else {
+			e.lastGood[src.Name()] = rules
+		}
+		for _, rule := range rules {
+			rs.add(rule)
+		}
+	}
+	e.current.Store(rs)
+	return errors.Join(errs...)
+}
+
+// Run refreshes the rules every interval until ctx is cancelled.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Refresh(ctx); err != nil {
+				log.Printf("Failed to refresh policy rules: %v", err)
+			}
+		}
+	}
+}
+
+// Evaluate returns the rule matching a normalized query name, or nil. Of
+// the redirect rules of a trigger, the one of the address family of qtype is
+// returned. It is safe to call on a nil Engine.
+func (e *Engine) Evaluate(name string, qtype uint16) *domain.PolicyRule {
+	if e == nil {
+		return nil
+	}
+	rules := e.current.Load().lookup(name)
+	if len(rules) == 0 {
+		return nil
+	}
+	for _, rule := range rules {
+		if rule.Action != domain.PolicyActionRedirect {
+			break
+		}
+		if (qtype == dns.TypeA && redirectsToIPv4(rule)) || (qtype == dns.TypeAAAA && !redirectsToIPv4(rule)) {
+			return rule
+		}
+	}
+	return rules[0]
+}
+
+// Size returns the number of rules currently loaded.
+func (e *Engine) Size() int {
+	return e.current.Load().size
+}
@@ -0,0 +1,179 @@
+package rpz
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-dns/internal/domain"
+)
+
+type staticSource struct {
+	name  string
+	rules []*domain.PolicyRule
+	err   error
+}
+
+func (s *staticSource) Name() string { return s.name }
+
+func (s *staticSource) Rules(context.Context) ([]*domain.PolicyRule, error) {
+	return s.rules, s.err
+}
+
+func rule(t *testing.T, pattern string, match domain.PolicyMatch, action domain.PolicyAction) *domain.PolicyRule {
+	t.Helper()
+	redirect := ""
+	if action == domain.PolicyActionRedirect {
+		redirect = "10.0.0.53"
+	}
+	r, err := domain.NewPolicyRule(1, pattern, match, action, redirect, "")
+	require.NoError(t, err)
+	return r
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	src := &staticSource{name: "test", rules: []*domain.PolicyRule{
+		rule(t, "bad.com", domain.PolicyMatchSuffix, domain.PolicyActionNXDomain),
+		rule(t, "ok.bad.com", domain.PolicyMatchExact, domain.PolicyActionPassthru),
+		rule(t, "*.ads.example.com", domain.PolicyMatchWildcard, domain.PolicyActionRedirect),
+		rule(t, "tracker.example.com", domain.PolicyMatchExact, domain.PolicyActionNoData),
+	}}
+	e := NewEngine(src)
+	require.NoError(t, e.Refresh(context.Background()))
+	assert.Equal(t, 4, e.Size())
+
+	tests := []struct {
+		name   string
+		action domain.PolicyAction // empty for no match
+	}{
+		{"bad.com", domain.PolicyActionNXDomain},
+		{"www.bad.com", domain.PolicyActionNXDomain},
+		{"ok.bad.com", domain.PolicyActionPassthru},     // exact beats suffix
+		{"sub.ok.bad.com", domain.PolicyActionNXDomain}, // exact does not cover children
+		{"x.ads.example.com", domain.PolicyActionRedirect},
+		{"a.b.ads.example.com", domain.PolicyActionRedirect},
+		{"ads.example.com", ""}, // wildcard does not cover its parent
+		{"tracker.example.com", domain.PolicyActionNoData},
+		{"example.com", ""},
+		{"notbad.com", ""},
+	}
+	for _, tt := range tests {
+		got := e.Evaluate(tt.name, dns.TypeA)
+		if tt.action == "" {
+			assert.Nil(t, got, tt.name)
+			continue
+		}
+		require.NotNil(t, got, tt.name)
+		assert.Equal(t, tt.action, got.Action, tt.name)
+	}
+}
+
+func TestEngine_RefreshKeepsRulesOfFailingSource(t *testing.T) {
+	src := &staticSource{name: "db", rules: []*domain.PolicyRule{
+		rule(t, "bad.com", domain.PolicyMatchExact, domain.PolicyActionNXDomain),
+	}}
+	e := NewEngine(src)
+	require.NoError(t, e.Refresh(context.Background()))
+
+	src.rules, src.err = nil, errors.New("db down")
+	assert.Error(t, e.Refresh(context.Background()))
+	assert.NotNil(t, e.Evaluate("bad.com", dns.TypeA))
+}
+
+func TestEngine_NilIsNoop(t *testing.T) {
+	var e *Engine
+	assert.Nil(t, e.Evaluate("bad.com", dns.TypeA))
+}
+
+func TestLoadZoneFile(t *testing.T) {
+	zone := `$TTL 60
+$ORIGIN rpz.local.
+@               SOA   ns.rpz.local. admin.rpz.local. 1 3600 600 86400 60
+@               NS    ns.rpz.local.
+bad.com         CNAME .
+*.bad.com       CNAME .
+nodata.org      CNAME *.
+good.bad.com    CNAME rpz-passthru.
+sinkhole.net    A     10.0.0.53
+sinkhole.net    A     10.0.0.54
+v6.sinkhole.net AAAA  fd00::53
+32.1.0.0.10.rpz-ip CNAME .
+rewrite.com     CNAME elsewhere.example.
+`
+	path := filepath.Join(t.TempDir(), "block.rpz")
+	require.NoError(t, os.WriteFile(path, []byte(zone), 0o644))
+
+	rules, err := LoadZoneFile(path)
+	require.NoError(t, err)
+
+	got := make(map[string]*domain.PolicyRule)
+	for _, r := range rules {
+		got[string(r.Match)+" "+r.Pattern] = r
+		assert.Equal(t, path, r.Source)
+	}
+	require.Len(t, got, 6)
+	assert.Equal(t, domain.PolicyActionNXDomain, got["exact bad.com"].Action)
+	assert.Equal(t, domain.PolicyActionNXDomain, got["wildcard *.bad.com"].Action)
+	assert.Equal(t, domain.PolicyActionNoData, got["exact nodata.org"].Action)
+	assert.Equal(t, domain.PolicyActionPassthru, got["exact good.bad.com"].Action)
+	assert.Equal(t, domain.PolicyActionRedirect, got["exact sinkhole.net"].Action)
+	assert.Equal(t, "10.0.0.53", got["exact sinkhole.net"].RedirectIP)
+	assert.Equal(t, "fd00::53", got["exact v6.sinkhole.net"].RedirectIP)
+}
+
+func TestLoadZoneFile_RedirectToIPv4AndIPv6(t *testing.T) {
+	zone := `$TTL 60
+$ORIGIN rpz.local.
+@            SOA   ns.rpz.local. admin.rpz.local. 1 3600 600 86400 60
+sinkhole.net A     10.0.0.53
+sinkhole.net AAAA  fd00::53
+sinkhole.net A     10.0.0.54
+`
+	path := filepath.Join(t.TempDir(), "block.rpz")
+	require.NoError(t, os.WriteFile(path, []byte(zone), 0o644))
+
+	rules, err := LoadZoneFile(path)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "10.0.0.53", rules[0].RedirectIP)
+	assert.Equal(t, "fd00::53", rules[1].RedirectIP)
+
+	// Both are served, each for its query type
+	e := NewEngine(&staticSource{name: path, rules: rules})
+	require.NoError(t, e.Refresh(context.Background()))
+	assert.Equal(t, 2, e.Size())
+	assert.Equal(t, "10.0.0.53", e.Evaluate("sinkhole.net", dns.TypeA).RedirectIP)
+	assert.Equal(t, "fd00::53", e.Evaluate("sinkhole.net", dns.TypeAAAA).RedirectIP)
+	assert.Equal(t, "10.0.0.53", e.Evaluate("sinkhole.net", dns.TypeMX).RedirectIP)
+}
+
+func TestEngine_RedirectRulesPerAddressFamily(t *testing.T) {
+	v6, err := domain.NewPolicyRule(1, "bad.com", domain.PolicyMatchExact, domain.PolicyActionRedirect, "fd00::53", "")
+	require.NoError(t, err)
+	e := NewEngine(&staticSource{name: "test", rules: []*domain.PolicyRule{
+		rule(t, "bad.com", domain.PolicyMatchExact, domain.PolicyActionNXDomain),
+		v6,
+		rule(t, "ads.com", domain.PolicyMatchExact, domain.PolicyActionRedirect),
+	}})
+	require.NoError(t, e.Refresh(context.Background()))
+
+	// Only redirect rules share a trigger
+	assert.Equal(t, 2, e.Size())
+	assert.Equal(t, domain.PolicyActionNXDomain, e.Evaluate("bad.com", dns.TypeAAAA).Action)
+	// A trigger without an IPv6 redirect answers AAAA queries with its IPv4 rule
+	assert.Equal(t, "10.0.0.53", e.Evaluate("ads.com", dns.TypeAAAA).RedirectIP)
+}
+
+func TestLoadZoneFile_RequiresSOA(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "block.rpz")
+	require.NoError(t, os.WriteFile(path, []byte("$ORIGIN rpz.local.\nbad.com 60 CNAME .\n"), 0o644))
+
+	_, err := LoadZoneFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package rpz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"internal-dns/internal/domain"
+)
+
+type zoneFileSource struct {
+	path string
+}
+
+// NewZoneFileSource creates a source serving the rules of an RPZ zone file.
+// The file is read again on every refresh.
+func NewZoneFileSource(path string) Source {
+	return &zoneFileSource{path: path}
+}
+
+func (s *zoneFileSource) Name() string { return s.path }
+
+func (s *zoneFileSource) Rules(ctx context.Context) ([]*domain.PolicyRule, error) {
+	return LoadZoneFile(s.path)
+}
+
+// LoadZoneFile parses an RPZ zone file. The zone apex is taken from the SOA
+// record, which must come first. Supported triggers are query names (exact
+// and "*." wildcard owners); supported actions are CNAME . (NXDOMAIN),
+// CNAME *. (NODATA), CNAME rpz-passthru. (PASSTHRU) and A/AAAA local data
+// (REDIRECT). Other entries are skipped with a log message.
+func LoadZoneFile(path string) ([]*domain.PolicyRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zp := dns.NewZoneParser(f, "", path)
+	var origin string
+	var rules []*domain.PolicyRule
+	seen := make(map[string]bool)
+
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		owner := strings.ToLower(rr.Header().Name)
+		if soa, isSOA := rr.(*dns.SOA); isSOA && origin == "" {
+			origin = strings.ToLower(soa.Hdr.Name)
+			continue
+		}
+		if origin == "" {
+			return nil, errors.New("RPZ zone file must start with an SOA record")
+		}
+		if owner == origin {
+			continue // apex NS and other zone data
+		}
+		if !dns.IsSubDomain(origin, owner) {
+			return nil, fmt.Errorf("record %s is outside the zone %s", owner, origin)
+		}
+
+		trigger := strings.TrimSuffix(strings.TrimSuffix(owner, origin), ".")
+		if strings.Contains(trigger, ".rpz-") {
+			log.Printf("%s: skipping unsupported RPZ trigger %s", path, owner)
+			continue
+		}
+		match := domain.PolicyMatchExact
+		if strings.HasPrefix(trigger, "*.") {
+			match = domain.PolicyMatchWildcard
+		}
+
+		action, redirectIP, supported := zoneRecordAction(rr)
+		if !supported {
+			log.Printf("%s: skipping unsupported RPZ action %s", path, strings.TrimSpace(rr.String()))
+			continue
+		}
+		// A trigger can carry several local data records; the first one of
+		// each type wins
+		key := string(match) + " " + trigger + " " + dns.TypeToString[rr.Header().Rrtype]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		rule, err := domain.NewPolicyRule(0, trigger, match, action, redirectIP, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", owner, err)
+		}
+		rule.Source = path
+		rules = append(rules, rule)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	if origin == "" {
+		return nil, errors.New("RPZ zone file must start with an SOA record")
+	}
+	return rules, nil
+}
+
+func zoneRecordAction(rr dns.RR) (domain.PolicyAction, string, bool) {
+	switch v := rr.(type) {
+	case *dns.CNAME:
+		switch strings.ToLower(v.Target) {
+		case ".":
+			return domain.PolicyActionNXDomain, "", true
+		case "*.":
+			return domain.PolicyActionNoData, "", true
+		case "rpz-passthru.":
+			return domain.PolicyActionPassthru, "", true
+		}
+	case *dns.A:
+		return domain.PolicyActionRedirect, v.A.String(), true
+	case *dns.AAAA:
+		return domain.PolicyActionRedirect, v.AAAA.String(), true
+	}
+	return "", "", false
+}
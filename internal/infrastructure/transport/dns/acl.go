@@ -0,0 +1,245 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"internal-dns/internal/util"
+)
+
+// ACL names, used as the "acl" label of the denied metric.
+const (
+	ACLQuery     = "query"
+	ACLRecursion = "recursion"
+	ACLTransfer  = "transfer"
+	ACLUpdate    = "update"
+)
+
+// ACLConfig is the file representation of the access control lists. Each
+// list holds CIDRs, bare addresses, group names, "any" or "none"; entries
+// prefixed with "!" deny. Lists are evaluated in order and the first match
+// wins; a client matching nothing is denied.
+type ACLConfig struct {
+	Groups         map[string][]string `json:"groups"`
+	AllowQuery     []string            `json:"allowQuery"`
+	AllowRecursion []string            `json:"allowRecursion"`
+	AllowTransfer  []string            `json:"allowTransfer"`
+	AllowUpdate    []string            `json:"allowUpdate"`
+}
+
+// DefaultACLConfig answers queries from anyone, offers recursion to
+// localhost only and refuses transfers and updates.
+func DefaultACLConfig() ACLConfig {
+	return ACLConfig{
+		AllowQuery:     []string{"any"},
+		AllowRecursion: []string{"127.0.0.0/8", "::1"},
+		AllowTransfer:  []string{"none"},
+		AllowUpdate:    []string{"none"},
+	}
+}
+
+type aclEntry struct {
+	network *net.IPNet
+	deny    bool
+}
+
+type addressMatchList []aclEntry
+
+func (l addressMatchList) allows(ip net.IP) bool {
+	for _, e := range l {
+		if e.network.Contains(ip) {
+			return !e.deny
+		}
+	}
+	return false
+}
+
+// ACL is a compiled, immutable set of access control lists.
+type ACL struct {
+	lists map[string]addressMatchList
+}
+
+// NewACL compiles cfg, resolving group references.
+func NewACL(cfg ACLConfig) (*ACL, error) {
+	acl := &ACL{lists: make(map[string]addressMatchList)}
+	for name, entries := range map[string][]string{
+		ACLQuery:     cfg.AllowQuery,
+		ACLRecursion: cfg.AllowRecursion,
+		ACLTransfer:  cfg.AllowTransfer,
+		ACLUpdate:    cfg.AllowUpdate,
+	} {
+		list, err := compileMatchList(entries, cfg.Groups, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		acl.lists[name] = list
+	}
+	return acl, nil
+}
+
+func compileMatchList(entries []string, groups map[string][]string, seen map[string]bool) (addressMatchList, error) {
+	var list addressMatchList
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		deny := strings.HasPrefix(entry, "!")
+		entry = strings.TrimPrefix(entry, "!")
+
+		switch {
+		case entry == "any":
+			list = append(list,
+				aclEntry{network: &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}, deny: deny},
+				aclEntry{network: &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}, deny: deny},
+			)
+		case entry == "none":
+			// Matches nothing
+		case isGroup(groups, entry):
+			if seen[entry] {
+				return nil, fmt.Errorf("group %q references itself", entry)
+			}
+			nested := map[string]bool{entry: true}
+			for k := range seen {
+				nested[k] = true
+			}
+			members, err := compileMatchList(groups[entry], groups, nested)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range members {
+				// A negated group denies all its members
+				list = append(list, aclEntry{network: m.network, deny: m.deny != deny})
+			}
+		default:
+			network, err := util.ParseNetwork(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%w (not a known group either)", err)
+			}
+			list = append(list, aclEntry{network: network, deny: deny})
+		}
+	}
+	return list, nil
+}
+
+func isGroup(groups map[string][]string, name string) bool {
+	_, ok := groups[name]
+	return ok
+}
+
+// Allows reports whether ip may use the function guarded by the named list.
+func (a *ACL) Allows(name string, ip net.IP) bool {
+	if a == nil {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	return a.lists[name].allows(ip)
+}
+
+// ACLStore holds the current ACL and reloads it from a JSON file, so that
+// changes take effect without restarting the server.
+type ACLStore struct {
+	path    string
+	current atomic.Pointer[ACL]
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// NewACLStore creates a store loaded from path. With an empty path the
+// default ACL is used and Reload is a no-op.
+func NewACLStore(path string) (*ACLStore, error) {
+	s := &ACLStore{path: path}
+	if path == "" {
+		acl, err := NewACL(DefaultACLConfig())
+		if err != nil {
+			return nil, err
+		}
+		s.current.Store(acl)
+		return s, nil
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ACL returns the ACL currently in force.
+func (s *ACLStore) ACL() *ACL {
+	if s == nil {
+		return nil
+	}
+	return s.current.Load()
+}
+
+// Reload reads the ACL file again. On error the current ACL stays in force.
+func (s *ACLStore) Reload() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var cfg ACLConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse ACL file %s: %w", s.path, err)
+	}
+	acl, err := NewACL(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid ACL file %s: %w", s.path, err)
+	}
+
+	s.current.Store(acl)
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// Watch reloads the ACL file whenever its modification time changes, checking
+// every interval until ctx is cancelled.
+func (s *ACLStore) Watch(ctx context.Context, interval time.Duration) {
+	if s.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.path)
+			if err != nil {
+				log.Printf("Failed to stat ACL file %s: %v", s.path, err)
+				continue
+			}
+			s.mu.Lock()
+			changed := !info.ModTime().Equal(s.modTime)
+			s.mu.Unlock()
+			if !changed {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				log.Printf("Failed to reload ACL file, keeping previous ACL: %v", err)
+				continue
+			}
+			log.Printf("Reloaded ACL file %s", s.path)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package dns
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/infrastructure/metrics"
+	"internal-dns/internal/repository"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACL_Allows(t *testing.T) {
+	acl, err := NewACL(ACLConfig{
+		Groups: map[string][]string{
+			"office": {"!10.1.99.0/24", "10.1.0.0/16"},
+			"ops":    {"office", "192.168.5.5"},
+		},
+		AllowQuery:     []string{"!10.1.2.3", "office", "127.0.0.1"},
+		AllowRecursion: []string{"ops"},
+		AllowTransfer:  []string{"none"},
+		AllowUpdate:    []string{"any"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		list  string
+		ip    string
+		allow bool
+	}{
+		{ACLQuery, "10.1.0.1", true},
+		{ACLQuery, "10.1.2.3", false},  // explicitly denied before the group
+		{ACLQuery, "10.1.99.1", false}, // denied inside the group
+		{ACLQuery, "127.0.0.1", true},
+		{ACLQuery, "172.16.0.1", false}, // no match
+		{ACLRecursion, "192.168.5.5", true},
+		{ACLRecursion, "10.1.0.1", true}, // nested group
+		{ACLTransfer, "127.0.0.1", false},
+		{ACLUpdate, "2001:db8::1", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allow, acl.Allows(tt.list, net.ParseIP(tt.ip)), "%s %s", tt.list, tt.ip)
+	}
+}
+
+func TestNewACL_Errors(t *testing.T) {
+	_, err := NewACL(ACLConfig{AllowQuery: []string{"unknown-group"}})
+	assert.Error(t, err)
+
+	_, err = NewACL(ACLConfig{
+		Groups:     map[string][]string{"a": {"b"}, "b": {"a"}},
+		AllowQuery: []string{"a"},
+	})
+	assert.Error(t, err)
+}
+
+func TestACLStore_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acl.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"allowQuery": ["10.0.0.0/8"]}`), 0o644))
+
+	store, err := NewACLStore(path)
+	require.NoError(t, err)
+	assert.True(t, store.ACL().Allows(ACLQuery, net.ParseIP("10.0.0.1")))
+	assert.False(t, store.ACL().Allows(ACLQuery, net.ParseIP("192.168.0.1")))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"allowQuery": ["192.168.0.0/16"]}`), 0o644))
+	require.NoError(t, store.Reload())
+	assert.False(t, store.ACL().Allows(ACLQuery, net.ParseIP("10.0.0.1")))
+	assert.True(t, store.ACL().Allows(ACLQuery, net.ParseIP("192.168.0.1")))
+
+	// An invalid file keeps the previous ACL in force
+	require.NoError(t, os.WriteFile(path, []byte(`{"allowQuery": ["bogus"]}`), 0o644))
+	assert.Error(t, store.Reload())
+	assert.True(t, store.ACL().Allows(ACLQuery, net.ParseIP("192.168.0.1")))
+}
+
+func TestACLStore_Default(t *testing.T) {
+	store, err := NewACLStore("")
+	require.NoError(t, err)
+	acl := store.ACL()
+	assert.True(t, acl.Allows(ACLQuery, net.ParseIP("203.0.113.1")))
+	assert.True(t, acl.Allows(ACLRecursion, net.ParseIP("127.0.0.1")))
+	assert.False(t, acl.Allows(ACLRecursion, net.ParseIP("203.0.113.1")))
+	assert.False(t, acl.Allows(ACLTransfer, net.ParseIP("127.0.0.1")))
+	assert.False(t, acl.Allows(ACLUpdate, net.ParseIP("127.0.0.1")))
+}
+
+// startUpstream runs a resolver on a local UDP port that answers every A query with 9.9.9.9.
+func startUpstream(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: pc, NotifyStartedFunc: func() { close(started) }, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("9.9.9.9"),
+		})
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = srv.ActivateAndServe() }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("upstream did not start")
+	}
+	return pc.LocalAddr().String()
+}
+
+func TestServer_ACL(t *testing.T) {
+	aRecord := &domain.DNSRecord{DomainName: "test-a.local.", Type: domain.A, Value: "1.2.3.4"}
+	acl, err := NewACL(ACLConfig{
+		AllowQuery:     []string{"127.0.0.0/8"},
+		AllowRecursion: []string{"none"},
+		AllowTransfer:  []string{"127.0.0.1"},
+		AllowUpdate:    []string{"none"},
+	})
+	require.NoError(t, err)
+	store := &ACLStore{}
+	store.current.Store(acl)
+
+	newServer := func(opts ...Option) (*Server, *MockDNSRecordUseCase, *MockDNSRecordCache, *metrics.DNSMetrics) {
+		mockUC := new(MockDNSRecordUseCase)
+		mockCache := new(MockDNSRecordCache)
+		m := metrics.NewDNSMetrics(prometheus.NewRegistry())
+		opts = append(opts, WithMetrics(m), WithACL(store))
+		return NewServer(":53535", mockUC, mockCache, opts...), mockUC, mockCache, m
+	}
+
+	t.Run("Query Allowed", func(t *testing.T) {
+		server, _, mockCache, _ := newServer()
+		mockCache.On("Get", mock.Anything, "test-a.local.").Return(aRecord, nil).Once()
+
+		req := new(dns.Msg)
+		req.SetQuestion("test-a.local.", dns.TypeA)
+		w := &mockResponseWriter{}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeSuccess, w.msg.Rcode)
+		assert.Len(t, w.msg.Answer, 1)
+	})
+
+	t.Run("Query Refused", func(t *testing.T) {
+		server, mockUC, mockCache, m := newServer()
+
+		req := new(dns.Msg)
+		req.SetQuestion("test-a.local.", dns.TypeA)
+		w := &mockResponseWriter{remoteIP: "192.0.2.1"}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeRefused, w.msg.Rcode)
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.ACLDenied.WithLabelValues(ACLQuery)))
+		mockCache.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+		mockUC.AssertNotCalled(t, "ResolveDomain", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Transfer Allowed But Not Implemented", func(t *testing.T) {
+		server, _, _, _ := newServer()
+
+		req := new(dns.Msg)
+		req.SetAxfr("local.")
+		w := &mockResponseWriter{tcp: true}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeNotImplemented, w.msg.Rcode)
+	})
+
+	t.Run("Transfer Refused", func(t *testing.T) {
+		server, _, _, m := newServer()
+
+		req := new(dns.Msg)
+		req.SetAxfr("local.")
+		w := &mockResponseWriter{tcp: true, remoteIP: "127.0.0.2"}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeRefused, w.msg.Rcode)
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.ACLDenied.WithLabelValues(ACLTransfer)))
+	})
+
+	t.Run("Update Refused", func(t *testing.T) {
+		server, _, _, m := newServer()
+
+		req := new(dns.Msg)
+		req.SetUpdate("local.")
+		w := &mockResponseWriter{}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeRefused, w.msg.Rcode)
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.ACLDenied.WithLabelValues(ACLUpdate)))
+	})
+
+	t.Run("Recursion Refused", func(t *testing.T) {
+		server, mockUC, mockCache, m := newServer(WithForwarder(NewForwarder([]string{startUpstream(t)}, time.Second)))
+		mockCache.On("Get", mock.Anything, "example.com.").Return(nil, cache.ErrCacheMiss).Once()
+		mockUC.On("ResolveDomain", mock.Anything, "example.com.").Return(nil, repository.ErrDNSRecordNotFound).Once()
+
+		req := new(dns.Msg)
+		req.SetQuestion("example.com.", dns.TypeA)
+		w := &mockResponseWriter{}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeRefused, w.msg.Rcode)
+		assert.False(t, w.msg.RecursionAvailable)
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.ACLDenied.WithLabelValues(ACLRecursion)))
+	})
+}
+
+func TestServer_Forwarding(t *testing.T) {
+	mockUC := new(MockDNSRecordUseCase)
+	mockCache := new(MockDNSRecordCache)
+	server := NewServer(":53535", mockUC, mockCache, WithForwarder(NewForwarder([]string{startUpstream(t)}, time.Second)))
+
+	mockCache.On("Get", mock.Anything, "example.com.").Return(nil, cache.ErrCacheMiss).Twice()
+	mockUC.On("ResolveDomain", mock.Anything, "example.com.").Return(nil, repository.ErrDNSRecordNotFound).Twice()
+
+	t.Run("Forwards Unknown Names", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion("example.com.", dns.TypeA)
+		w := &mockResponseWriter{}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, req.Id, w.msg.Id)
+		assert.Equal(t, dns.RcodeSuccess, w.msg.Rcode)
+		assert.True(t, w.msg.RecursionAvailable)
+		require.Len(t, w.msg.Answer, 1)
+		assert.Equal(t, "9.9.9.9", w.msg.Answer[0].(*dns.A).A.String())
+	})
+
+	t.Run("No Recursion Desired", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion("example.com.", dns.TypeA)
+		req.RecursionDesired = false
+		w := &mockResponseWriter{}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeNameError, w.msg.Rcode)
+	})
+}
@@ -0,0 +1,62 @@
+package dns
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// clientInfo describes the client a request is answered for.
+type clientInfo struct {
+	// ip is the address answers are tailored to: the subnet from EDNS Client
+	// Subnet (RFC 7871) if the request carries one, the client address otherwise.
+	ip    net.IP
+	ecs   *dns.EDNS0_SUBNET
+	scope uint8 // longest prefix an answer depended on
+}
+
+func newClientInfo(r *dns.Msg, client net.IP) *clientInfo {
+	ci := &clientInfo{ip: client}
+	opt := r.IsEdns0()
+	if opt == nil {
+		return ci
+	}
+	for _, o := range opt.Option {
+		if ecs, ok := o.(*dns.EDNS0_SUBNET); ok && ecs.Address != nil {
+			ci.ip = ecs.Address
+			ci.ecs = ecs
+			break
+		}
+	}
+	return ci
+}
+
+// sourcePrefix returns the prefix length of the client subnet sent by the
+// resolver, or 0 without EDNS Client Subnet.
+func (ci *clientInfo) sourcePrefix() int {
+	if ci.ecs == nil {
+		return 0
+	}
+	return int(ci.ecs.SourceNetmask)
+}
+
+func (ci *clientInfo) widenScope(prefixLen int) {
+	if prefixLen > int(ci.scope) {
+		ci.scope = uint8(prefixLen)
+	}
+}
+
+// echoSubnet adds the client subnet option to the response with the scope of
+// the answer, so that resolvers cache tailored answers per subnet only.
+func (ci *clientInfo) echoSubnet(r, msg *dns.Msg) {
+	if ci.ecs == nil {
+		return
+	}
+	opt := r.IsEdns0()
+	msg.SetEdns0(opt.UDPSize(), opt.Do())
+
+	ecs := *ci.ecs
+	ecs.SourceScope = ci.scope
+	resp := msg.IsEdns0()
+	resp.Option = append(resp.Option, &ecs)
+}
@@ -0,0 +1,47 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Forwarder resolves names the server has no record for by forwarding the
+// query to upstream resolvers, trying them in order.
+type Forwarder struct {
+	upstreams []string
+	timeout   time.Duration
+}
+
+// NewForwarder creates a forwarder. Upstreams without a port use port 53.
+func NewForwarder(upstreams []string, timeout time.Duration) *Forwarder {
+	f := &Forwarder{timeout: timeout}
+	for _, u := range upstreams {
+		if _, _, err := net.SplitHostPort(u); err != nil {
+			u = net.JoinHostPort(u, "53")
+		}
+		f.upstreams = append(f.upstreams, u)
+	}
+	return f
+}
+
+// Exchange forwards r over the given transport and returns the first upstream answer.
+func (f *Forwarder) Exchange(r *dns.Msg, transport string) (*dns.Msg, error) {
+	if len(f.upstreams) == 0 {
+		return nil, errors.New("no upstream resolvers configured")
+	}
+
+	client := &dns.Client{Net: transport, Timeout: f.timeout}
+	var errs []error
+	for _, upstream := range f.upstreams {
+		resp, _, err := client.Exchange(r, upstream)
+		if err == nil {
+			return resp, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", upstream, err))
+	}
+	return nil, errors.Join(errs...)
+}
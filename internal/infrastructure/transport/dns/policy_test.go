@@ -0,0 +1,138 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/metrics"
+	"internal-dns/internal/infrastructure/querylog"
+	"internal-dns/internal/infrastructure/rpz"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type staticPolicySource []*domain.PolicyRule
+
+func (s staticPolicySource) Name() string { return "test" }
+
+func (s staticPolicySource) Rules(context.Context) ([]*domain.PolicyRule, error) {
+	return s, nil
+}
+
+func newPolicyEngine(t *testing.T) *rpz.Engine {
+	t.Helper()
+	var rules staticPolicySource
+	for _, r := range []struct {
+		pattern  string
+		match    domain.PolicyMatch
+		action   domain.PolicyAction
+		redirect string
+	}{
+		{"bad.com", domain.PolicyMatchSuffix, domain.PolicyActionNXDomain, ""},
+		{"test-a.bad.com", domain.PolicyMatchExact, domain.PolicyActionPassthru, ""},
+		{"nodata.com", domain.PolicyMatchExact, domain.PolicyActionNoData, ""},
+		{"*.sinkhole.com", domain.PolicyMatchWildcard, domain.PolicyActionRedirect, "10.0.0.53"},
+	} {
+		rule, err := domain.NewPolicyRule(1, r.pattern, r.match, r.action, r.redirect, "")
+		require.NoError(t, err)
+		rule.Source = "test"
+		rules = append(rules, rule)
+	}
+	e := rpz.NewEngine(rules)
+	require.NoError(t, e.Refresh(context.Background()))
+	return e
+}
+
+func TestServer_Policy(t *testing.T) {
+	engine := newPolicyEngine(t)
+
+	newServer := func(opts ...Option) (*Server, *MockDNSRecordUseCase, *MockDNSRecordCache, *metrics.DNSMetrics) {
+		mockUC := new(MockDNSRecordUseCase)
+		mockCache := new(MockDNSRecordCache)
+		m := metrics.NewDNSMetrics(prometheus.NewRegistry())
+		opts = append(opts, WithMetrics(m), WithPolicy(engine))
+		return NewServer(":53535", mockUC, mockCache, opts...), mockUC, mockCache, m
+	}
+
+	t.Run("NXDOMAIN Before Resolution", func(t *testing.T) {
+		server, mockUC, mockCache, m := newServer()
+
+		req := new(dns.Msg)
+		req.SetQuestion("www.bad.com.", dns.TypeA)
+		w := &mockResponseWriter{}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeNameError, w.msg.Rcode)
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.PolicyHits.WithLabelValues("NXDOMAIN", "test")))
+		mockCache.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+		mockUC.AssertNotCalled(t, "ResolveDomain", mock.Anything, mock.Anything)
+	})
+
+	t.Run("NODATA", func(t *testing.T) {
+		server, _, _, _ := newServer()
+
+		req := new(dns.Msg)
+		req.SetQuestion("nodata.com.", dns.TypeA)
+		w := &mockResponseWriter{}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeSuccess, w.msg.Rcode)
+		assert.Empty(t, w.msg.Answer)
+	})
+
+	t.Run("Redirect", func(t *testing.T) {
+		server, _, _, _ := newServer()
+
+		req := new(dns.Msg)
+		req.SetQuestion("x.sinkhole.com.", dns.TypeA)
+		w := &mockResponseWriter{}
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeSuccess, w.msg.Rcode)
+		require.Len(t, w.msg.Answer, 1)
+		assert.Equal(t, "10.0.0.53", w.msg.Answer[0].(*dns.A).A.String())
+
+		// An IPv4 redirect has no AAAA data
+		req.SetQuestion("x.sinkhole.com.", dns.TypeAAAA)
+		w = &mockResponseWriter{}
+		server.handleRequest(w, req)
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeSuccess, w.msg.Rcode)
+		assert.Empty(t, w.msg.Answer)
+	})
+
+	t.Run("Passthru Resolves Normally And Is Logged", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := querylog.New(querylog.Config{SampleRate: 1, BufferSize: 10}, nil, querylog.NewJSONSink(&buf))
+		server, _, mockCache, m := newServer(WithQueryLog(logger))
+		record := &domain.DNSRecord{DomainName: "test-a.bad.com.", Type: domain.A, Value: "1.2.3.4"}
+		mockCache.On("Get", mock.Anything, "test-a.bad.com.").Return(record, nil).Once()
+
+		req := new(dns.Msg)
+		req.SetQuestion("test-a.bad.com.", dns.TypeA)
+		w := &mockResponseWriter{}
+		server.handleRequest(w, req)
+		require.NoError(t, logger.Close())
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeSuccess, w.msg.Rcode)
+		require.Len(t, w.msg.Answer, 1)
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.PolicyHits.WithLabelValues("PASSTHRU", "test")))
+
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, "PASSTHRU", entry["policyAction"])
+		assert.Equal(t, "test-a.bad.com", entry["policyRule"])
+	})
+}
@@ -0,0 +1,175 @@
+package dns
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitAction is the outcome of a response rate limiting check.
+type RateLimitAction int
+
+const (
+	// RateLimitAllow sends the response unchanged.
+	RateLimitAllow RateLimitAction = iota
+	// RateLimitDrop sends nothing.
+	RateLimitDrop
+	// RateLimitSlip sends an empty truncated (TC=1) response instead, so that
+	// legitimate clients behind a limited prefix can retry over TCP.
+	RateLimitSlip
+)
+
+// String returns the label used for the action in metrics.
+func (a RateLimitAction) String() string {
+	switch a {
+	case RateLimitDrop:
+		return "drop"
+	case RateLimitSlip:
+		return "slip"
+	default:
+		return "allow"
+	}
+}
+
+// RateLimitConfig configures response rate limiting.
+type RateLimitConfig struct {
+	// ResponsesPerSecond limits identical positive responses sent to one client prefix.
+	ResponsesPerSecond float64
+	// ErrorsPerSecond limits NXDOMAIN and error responses sent to one client
+	// prefix. They are accounted together regardless of the query name, so
+	// random-subdomain floods share a single budget.
+	ErrorsPerSecond float64
+	// Burst is the number of responses allowed above the rate.
+	Burst int
+	// Slip sends every Nth limited response as a truncated response instead
+	// of dropping it. 0 drops every limited response, 1 slips every one.
+	Slip int
+	// IPv4PrefixLen and IPv6PrefixLen group clients into networks.
+	IPv4PrefixLen int
+	IPv6PrefixLen int
+	// Whitelist lists networks that are never limited.
+	Whitelist []*net.IPNet
+	// TTL is how long an idle bucket is kept.
+	TTL time.Duration
+}
+
+type rateLimitBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+	limited  int
+}
+
+// RateLimiter implements RFC-style DNS response rate limiting (RRL). Buckets
+// are keyed on the client network and the response, so a flood of identical
+// answers towards one (possibly spoofed) prefix is cut down without
+// affecting other clients or other names.
+type RateLimiter struct {
+	cfg RateLimitConfig
+
+	mu        sync.Mutex
+	buckets   map[string]*rateLimitBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a response rate limiter.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	if cfg.IPv4PrefixLen <= 0 || cfg.IPv4PrefixLen > 32 {
+		cfg.IPv4PrefixLen = 24
+	}
+	if cfg.IPv6PrefixLen <= 0 || cfg.IPv6PrefixLen > 128 {
+		cfg.IPv6PrefixLen = 56
+	}
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	return &RateLimiter{
+		cfg:       cfg,
+		buckets:   make(map[string]*rateLimitBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Check accounts a response about to be sent to client and decides what to do with it.
+func (l *RateLimiter) Check(client net.IP, resp *dns.Msg) RateLimitAction {
+	return l.check(client, resp, time.Now())
+}
+
+func (l *RateLimiter) check(client net.IP, resp *dns.Msg, now time.Time) RateLimitAction {
+	if client == nil || l.whitelisted(client) {
+		return RateLimitAllow
+	}
+
+	key, limit := l.key(client, resp)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > l.cfg.TTL {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateLimitBucket{limiter: rate.NewLimiter(limit, l.cfg.Burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	if b.limiter.AllowN(now, 1) {
+		return RateLimitAllow
+	}
+
+	b.limited++
+	if l.cfg.Slip > 0 && b.limited%l.cfg.Slip == 0 {
+		return RateLimitSlip
+	}
+	return RateLimitDrop
+}
+
+// key builds the bucket key of a response: the client network, the response
+// category and, for positive answers, the question.
+func (l *RateLimiter) key(client net.IP, resp *dns.Msg) (string, rate.Limit) {
+	var network string
+	if v4 := client.To4(); v4 != nil {
+		network = v4.Mask(net.CIDRMask(l.cfg.IPv4PrefixLen, 32)).String()
+	} else {
+		network = client.Mask(net.CIDRMask(l.cfg.IPv6PrefixLen, 128)).String()
+	}
+
+	if resp.Rcode != dns.RcodeSuccess {
+		return network + "|error", rate.Limit(l.cfg.ErrorsPerSecond)
+	}
+
+	var question string
+	if len(resp.Question) > 0 {
+		q := resp.Question[0]
+		question = strings.ToLower(q.Name) + "/" + dns.TypeToString[q.Qtype]
+	}
+	return network + "|answer|" + question, rate.Limit(l.cfg.ResponsesPerSecond)
+}
+
+func (l *RateLimiter) whitelisted(ip net.IP) bool {
+	for _, network := range l.cfg.Whitelist {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// sweep removes idle buckets. Callers must hold l.mu.
+func (l *RateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > l.cfg.TTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,139 @@
+package dns
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/metrics"
+	"internal-dns/internal/util"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testResponse(name string, rcode int) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(dns.Fqdn(name), dns.TypeA)
+	resp := new(dns.Msg)
+	resp.SetRcode(req, rcode)
+	return resp
+}
+
+func TestRateLimiter_Check(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cfg := RateLimitConfig{ResponsesPerSecond: 1, ErrorsPerSecond: 1, Burst: 2}
+
+	t.Run("Limits identical responses to a prefix", func(t *testing.T) {
+		l := NewRateLimiter(cfg)
+		resp := testResponse("a.local", dns.RcodeSuccess)
+
+		assert.Equal(t, RateLimitAllow, l.check(net.ParseIP("10.0.0.1"), resp, now))
+		// Same /24, same response: shares the bucket
+		assert.Equal(t, RateLimitAllow, l.check(net.ParseIP("10.0.0.2"), resp, now))
+		assert.Equal(t, RateLimitDrop, l.check(net.ParseIP("10.0.0.3"), resp, now))
+
+		// Other prefixes and other names have their own buckets
+		assert.Equal(t, RateLimitAllow, l.check(net.ParseIP("10.0.1.1"), resp, now))
+		assert.Equal(t, RateLimitAllow, l.check(net.ParseIP("10.0.0.1"), testResponse("b.local", dns.RcodeSuccess), now))
+
+		// Tokens are refilled over time
+		assert.Equal(t, RateLimitAllow, l.check(net.ParseIP("10.0.0.1"), resp, now.Add(time.Second)))
+	})
+
+	t.Run("Errors share one bucket per prefix", func(t *testing.T) {
+		l := NewRateLimiter(cfg)
+		client := net.ParseIP("2001:db8::1")
+
+		assert.Equal(t, RateLimitAllow, l.check(client, testResponse("x1.local", dns.RcodeNameError), now))
+		assert.Equal(t, RateLimitAllow, l.check(client, testResponse("x2.local", dns.RcodeNameError), now))
+		assert.Equal(t, RateLimitDrop, l.check(net.ParseIP("2001:db8::2"), testResponse("x3.local", dns.RcodeServerFailure), now))
+	})
+
+	t.Run("Slip truncates every Nth limited response", func(t *testing.T) {
+		slipCfg := cfg
+		slipCfg.Slip = 2
+		l := NewRateLimiter(slipCfg)
+		client := net.ParseIP("10.0.0.1")
+		resp := testResponse("a.local", dns.RcodeSuccess)
+
+		l.check(client, resp, now)
+		l.check(client, resp, now)
+		assert.Equal(t, RateLimitDrop, l.check(client, resp, now))
+		assert.Equal(t, RateLimitSlip, l.check(client, resp, now))
+		assert.Equal(t, RateLimitDrop, l.check(client, resp, now))
+		assert.Equal(t, RateLimitSlip, l.check(client, resp, now))
+	})
+
+	t.Run("Whitelisted networks are never limited", func(t *testing.T) {
+		whitelist, err := util.ParseNetworks([]string{"192.168.0.0/16", "10.0.0.5"})
+		require.NoError(t, err)
+		wlCfg := cfg
+		wlCfg.Whitelist = whitelist
+		l := NewRateLimiter(wlCfg)
+		resp := testResponse("a.local", dns.RcodeSuccess)
+
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, RateLimitAllow, l.check(net.ParseIP("192.168.1.1"), resp, now))
+			assert.Equal(t, RateLimitAllow, l.check(net.ParseIP("10.0.0.5"), resp, now))
+		}
+	})
+
+	t.Run("Idle buckets are removed", func(t *testing.T) {
+		ttlCfg := cfg
+		ttlCfg.TTL = time.Minute
+		l := NewRateLimiter(ttlCfg)
+		l.lastSweep = now
+		l.check(net.ParseIP("10.0.0.1"), testResponse("a.local", dns.RcodeSuccess), now)
+		require.Len(t, l.buckets, 1)
+
+		l.check(net.ParseIP("10.0.1.1"), testResponse("a.local", dns.RcodeSuccess), now.Add(2*time.Minute))
+		assert.Len(t, l.buckets, 1)
+	})
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	aRecord := &domain.DNSRecord{DomainName: "test-a.local.", Type: domain.A, Value: "1.2.3.4"}
+
+	mockUC := new(MockDNSRecordUseCase)
+	mockCache := new(MockDNSRecordCache)
+	m := metrics.NewDNSMetrics(prometheus.NewRegistry())
+	limiter := NewRateLimiter(RateLimitConfig{ResponsesPerSecond: 0.001, ErrorsPerSecond: 0.001, Burst: 1, Slip: 2})
+	server := NewServer(":53535", mockUC, mockCache, WithMetrics(m), WithRateLimiter(limiter))
+
+	mockCache.On("Get", mock.Anything, "test-a.local.").Return(aRecord, nil)
+
+	req := new(dns.Msg)
+	req.SetQuestion("test-a.local.", dns.TypeA)
+
+	w := &mockResponseWriter{}
+	server.handleRequest(w, req)
+	require.NotNil(t, w.msg)
+	assert.Len(t, w.msg.Answer, 1)
+
+	// Over the limit: the first limited response is dropped...
+	w = &mockResponseWriter{}
+	server.handleRequest(w, req)
+	assert.Nil(t, w.msg)
+
+	// ...and the second slips through truncated and empty
+	w = &mockResponseWriter{}
+	server.handleRequest(w, req)
+	require.NotNil(t, w.msg)
+	assert.True(t, w.msg.Truncated)
+	assert.Empty(t, w.msg.Answer)
+
+	// TCP responses are not limited
+	w = &mockResponseWriter{tcp: true}
+	server.handleRequest(w, req)
+	require.NotNil(t, w.msg)
+	assert.Len(t, w.msg.Answer, 1)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.RateLimited.WithLabelValues("drop")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.RateLimited.WithLabelValues("slip")))
+}
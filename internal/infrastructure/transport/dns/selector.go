@@ -0,0 +1,121 @@
+package dns
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+
+	"internal-dns/internal/domain"
+)
+
+// Selector picks the answers of A records among their healthy addresses.
+type Selector struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewSelector creates a selector. The same seed yields the same sequence of
+// selections, which makes answers reproducible in tests.
+func NewSelector(seed int64) *Selector {
+	return &Selector{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Answers returns the addresses that an A record is answered with, given
+// its healthy ones: those, or the targets that its traffic policy selects
+// among them, as Select. It also returns the scope of the answer for EDNS
+// Client Subnet.
+func (s *Selector) Answers(record *domain.DNSRecord, healthy []string, client net.IP) ([]string, int) {
+	if record.TrafficPolicy != nil {
+		return s.Select(record.TrafficPolicy, healthy, client)
+	}
+	healthySet := stringSet(healthy)
+	return failOpen(record.Addresses(), func(addr string) bool { return healthySet[addr] }), 0
+}
+
+// Select orders the targets of a traffic policy by weighted random choice,
+// restricted to the client's preferred targets and to available (healthy)
+// addresses where possible, and truncated to the policy's answer limit. It
+// also returns the prefix length of the matched subnet preference, which is
+// the scope of the answer for EDNS Client Subnet.
+func (s *Selector) Select(policy *domain.TrafficPolicy, available []string, client net.IP) ([]string, int) {
+	availableSet := stringSet(available)
+	candidates := failOpen(policy.Targets, func(t domain.TrafficTarget) bool { return availableSet[t.Value] })
+
+	pref, scope := policy.PreferenceFor(client)
+	if pref != nil {
+		preferredSet := make(map[string]bool, len(pref.Targets))
+		for _, v := range pref.Targets {
+			preferredSet[v] = true
+		}
+		if preferred := filterTargets(candidates, func(t domain.TrafficTarget) bool { return preferredSet[t.Value] }); len(preferred) > 0 {
+			candidates = preferred
+		}
+	}
+
+	n := len(candidates)
+	if policy.MaxAnswers > 0 && policy.MaxAnswers < n {
+		n = policy.MaxAnswers
+	}
+	return s.weightedSample(candidates, n), scope
+}
+
+// weightedSample draws n targets without replacement, each with a
+// probability proportional to its weight.
+func (s *Selector) weightedSample(targets []domain.TrafficTarget, n int) []string {
+	remaining := append([]domain.TrafficTarget(nil), targets...)
+	total := 0
+	for _, t := range remaining {
+		total += t.Weight
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	picked := make([]string, 0, n)
+	for len(picked) < n && total > 0 {
+		r := s.rng.Intn(total)
+		for i, t := range remaining {
+			if r < t.Weight {
+				picked = append(picked, t.Value)
+				total -= t.Weight
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			r -= t.Weight
+		}
+	}
+	return picked
+}
+
+// failOpen returns the candidates that are healthy, or all of them if none
+// is: answering with unhealthy targets beats answering with nothing.
+func failOpen[T any](candidates []T, healthy func(T) bool) []T {
+	var kept []T
+	for _, c := range candidates {
+		if healthy(c) {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == 0 {
+		return candidates
+	}
+	return kept
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func filterTargets(targets []domain.TrafficTarget, keep func(domain.TrafficTarget) bool) []domain.TrafficTarget {
+	var kept []domain.TrafficTarget
+	for _, t := range targets {
+		if keep(t) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,162 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"internal-dns/internal/domain"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testTrafficPolicy(t *testing.T, maxAnswers int) *domain.TrafficPolicy {
+	policy, err := domain.NewTrafficPolicy(
+		[]domain.TrafficTarget{
+			{Value: "10.0.0.1", Weight: 3},
+			{Value: "10.0.0.2", Weight: 1},
+			{Value: "10.1.0.1", Weight: 1},
+		},
+		[]domain.SubnetPreference{
+			{Subnet: "192.168.0.0/16", Targets: []string{"10.1.0.1"}},
+			{Subnet: "192.168.7.0/24", Targets: []string{"10.0.0.2"}},
+		},
+		maxAnswers,
+	)
+	require.NoError(t, err)
+	return policy
+}
+
+var allTargets = []string{"10.0.0.1", "10.0.0.2", "10.1.0.1"}
+
+func TestSelector_Weights(t *testing.T) {
+	policy := testTrafficPolicy(t, 1)
+	sel := NewSelector(42)
+
+	counts := map[string]int{}
+	for i := 0; i < 5000; i++ {
+		answers, scope := sel.Select(policy, allTargets, net.ParseIP("172.16.0.1"))
+		require.Len(t, answers, 1)
+		assert.Zero(t, scope)
+		counts[answers[0]]++
+	}
+	// Expected 3000 / 1000 / 1000
+	assert.InDelta(t, 3000, counts["10.0.0.1"], 150)
+	assert.InDelta(t, 1000, counts["10.0.0.2"], 100)
+	assert.InDelta(t, 1000, counts["10.1.0.1"], 100)
+}
+
+func TestSelector_Deterministic(t *testing.T) {
+	policy := testTrafficPolicy(t, 0)
+	a, b := NewSelector(7), NewSelector(7)
+	for i := 0; i < 20; i++ {
+		answersA, _ := a.Select(policy, allTargets, nil)
+		answersB, _ := b.Select(policy, allTargets, nil)
+		require.Equal(t, answersA, answersB)
+		assert.ElementsMatch(t, allTargets, answersA)
+	}
+}
+
+func TestSelector_MaxAnswers(t *testing.T) {
+	policy := testTrafficPolicy(t, 2)
+	sel := NewSelector(1)
+	for i := 0; i < 50; i++ {
+		answers, _ := sel.Select(policy, allTargets, nil)
+		require.Len(t, answers, 2)
+		assert.NotEqual(t, answers[0], answers[1])
+	}
+}
+
+func TestSelector_SubnetPreferences(t *testing.T) {
+	policy := testTrafficPolicy(t, 0)
+	sel := NewSelector(1)
+
+	answers, scope := sel.Select(policy, allTargets, net.ParseIP("192.168.1.1"))
+	assert.Equal(t, []string{"10.1.0.1"}, answers)
+	assert.Equal(t, 16, scope)
+
+	// The most specific subnet wins
+	answers, scope = sel.Select(policy, allTargets, net.ParseIP("192.168.7.9"))
+	assert.Equal(t, []string{"10.0.0.2"}, answers)
+	assert.Equal(t, 24, scope)
+
+	// Unavailable preferred targets fall back to the others
+	answers, _ = sel.Select(policy, []string{"10.0.0.1", "10.0.0.2"}, net.ParseIP("192.168.1.1"))
+	assert.ElementsMatch(t, []string{"10.0.0.1", "10.0.0.2"}, answers)
+}
+
+func TestSelector_Availability(t *testing.T) {
+	policy := testTrafficPolicy(t, 0)
+	sel := NewSelector(1)
+
+	answers, _ := sel.Select(policy, []string{"10.0.0.2"}, nil)
+	assert.Equal(t, []string{"10.0.0.2"}, answers)
+
+	// With no target available, all are answered
+	answers, _ = sel.Select(policy, nil, nil)
+	assert.ElementsMatch(t, allTargets, answers)
+
+	// So are the addresses of records without a policy
+	record := &domain.DNSRecord{Type: domain.A, Value: "10.0.0.1", AdditionalValues: []string{"10.0.0.2"}}
+	answers, _ = sel.Answers(record, []string{"10.0.0.2"}, nil)
+	assert.Equal(t, []string{"10.0.0.2"}, answers)
+	answers, _ = sel.Answers(record, nil, nil)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, answers)
+}
+
+func TestServer_TrafficPolicy(t *testing.T) {
+	record := &domain.DNSRecord{DomainName: "svc.local.", Type: domain.A, Value: "10.0.0.1", TrafficPolicy: testTrafficPolicy(t, 1)}
+
+	mockUC := new(MockDNSRecordUseCase)
+	mockCache := new(MockDNSRecordCache)
+	mockCache.On("Get", mock.Anything, "svc.local.").Return(record, nil)
+	server := NewServer(":53535", mockUC, mockCache, WithSelector(NewSelector(1)))
+
+	t.Run("Client address", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion("svc.local.", dns.TypeA)
+		w := &mockResponseWriter{remoteIP: "192.168.3.4"}
+		server.handleRequest(w, req)
+
+		require.Len(t, w.msg.Answer, 1)
+		assert.Equal(t, "10.1.0.1", w.msg.Answer[0].(*dns.A).A.String())
+		assert.Nil(t, w.msg.IsEdns0())
+	})
+
+	t.Run("EDNS Client Subnet", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion("svc.local.", dns.TypeA)
+		req.SetEdns0(1232, false)
+		req.IsEdns0().Option = append(req.IsEdns0().Option, &dns.EDNS0_SUBNET{
+			Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("192.168.7.0").To4(),
+		})
+		w := &mockResponseWriter{remoteIP: "127.0.0.1"}
+		server.handleRequest(w, req)
+
+		require.Len(t, w.msg.Answer, 1)
+		assert.Equal(t, "10.0.0.2", w.msg.Answer[0].(*dns.A).A.String())
+
+		opt := w.msg.IsEdns0()
+		require.NotNil(t, opt)
+		require.Len(t, opt.Option, 1)
+		ecs := opt.Option[0].(*dns.EDNS0_SUBNET)
+		assert.Equal(t, uint8(24), ecs.SourceScope)
+	})
+
+	t.Run("Subnet without preference", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion("svc.local.", dns.TypeA)
+		req.SetEdns0(1232, false)
+		req.IsEdns0().Option = append(req.IsEdns0().Option, &dns.EDNS0_SUBNET{
+			Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 20, Address: net.ParseIP("172.16.0.0").To4(),
+		})
+		w := &mockResponseWriter{}
+		server.handleRequest(w, req)
+
+		require.Len(t, w.msg.Answer, 1)
+		// Other subnets get other answers, so the answer is scoped to the source subnet
+		assert.Equal(t, uint8(20), w.msg.IsEdns0().Option[0].(*dns.EDNS0_SUBNET).SourceScope)
+	})
+}
@@ -6,122 +6,486 @@ import (
 	"fmt"
 	"internal-dns/internal/domain"
 	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/infrastructure/metrics"
+	"internal-dns/internal/infrastructure/querylog"
+	"internal-dns/internal/infrastructure/rpz"
+	"internal-dns/internal/repository"
 	"internal-dns/internal/usecase"
 	"log"
 	"net"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+var errRecordTypeMismatch = errors.New("record type does not match query type")
+
 // Server is a DNS server implementation.
 type Server struct {
-	uc    usecase.DNSRecordUseCase
-	cache cache.DNSRecordCache
-	addr  string
+	uc        usecase.DNSRecordUseCase
+	cache     cache.DNSRecordCache
+	addr      string
+	metrics   *metrics.DNSMetrics
+	queryLog  *querylog.Logger
+	stats     usecase.QueryStatsUseCase
+	limiter   *RateLimiter
+	acls      *ACLStore
+	forwarder *Forwarder
+	policy    *rpz.Engine
+	health    HealthFilter
+	selector  *Selector
+	servers   []*dns.Server
+}
+
+// HealthFilter reports the healthy addresses of a record.
+type HealthFilter interface {
+	Healthy(record *domain.DNSRecord) []string
+}
+
+// Option configures optional Server dependencies.
+type Option func(*Server)
+
+// WithMetrics sets the collectors the server reports to.
+func WithMetrics(m *metrics.DNSMetrics) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
+}
+
+// WithQueryLog sets the logger that receives one entry per request.
+func WithQueryLog(l *querylog.Logger) Option {
+	return func(s *Server) {
+		s.queryLog = l
+	}
+}
+
+// WithQueryStats sets the collector that counts queries for existing records.
+func WithQueryStats(stats usecase.QueryStatsUseCase) Option {
+	return func(s *Server) {
+		s.stats = stats
+	}
+}
+
+// WithRateLimiter enables response rate limiting of UDP responses.
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(s *Server) {
+		s.limiter = l
+	}
+}
+
+// WithACL sets the access control lists enforced on every request. Without
+// it all clients may query and recurse.
+func WithACL(acls *ACLStore) Option {
+	return func(s *Server) {
+		s.acls = acls
+	}
+}
+
+// WithForwarder enables recursion for names the server has no record for.
+func WithForwarder(f *Forwarder) Option {
+	return func(s *Server) {
+		s.forwarder = f
+	}
+}
+
+// WithPolicy sets the response policy evaluated before normal resolution.
+func WithPolicy(e *rpz.Engine) Option {
+	return func(s *Server) {
+		s.policy = e
+	}
+}
+
+// WithHealth leaves unhealthy addresses out of A record answers.
+func WithHealth(h HealthFilter) Option {
+	return func(s *Server) {
+		s.health = h
+	}
+}
+
+// WithSelector sets the selector used for records with a traffic policy.
+// By default a selector seeded from the current time is used.
+func WithSelector(sel *Selector) Option {
+	return func(s *Server) {
+		s.selector = sel
+	}
 }
 
 // NewServer creates a new DNS server.
-func NewServer(addr string, uc usecase.DNSRecordUseCase, cache cache.DNSRecordCache) *Server {
-	return &Server{
+func NewServer(addr string, uc usecase.DNSRecordUseCase, cache cache.DNSRecordCache, opts ...Option) *Server {
+	s := &Server{
 		uc:    uc,
 		cache: cache,
 		addr:  addr,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.metrics == nil {
+		// Report to a throwaway registry so handlers never need nil checks.
+		s.metrics = metrics.NewDNSMetrics(prometheus.NewRegistry())
+	}
+	if s.selector == nil {
+		s.selector = NewSelector(time.Now().UnixNano())
+	}
+
+	handler := dns.HandlerFunc(s.handleRequest)
+	s.servers = []*dns.Server{
+		{Addr: addr, Net: "udp", Handler: handler},
+		{Addr: addr, Net: "tcp", Handler: handler},
+	}
+	return s
 }
 
-// ListenAndServe starts the DNS server.
+// ListenAndServe starts the DNS server on both UDP and TCP and blocks until
+// one of the listeners fails.
 func (s *Server) ListenAndServe() error {
-	dns.HandleFunc(".", s.handleRequest)
-	server := &dns.Server{Addr: s.addr, Net: "udp"}
-	log.Printf("DNS server listening on %s", s.addr)
-	return server.ListenAndServe()
+	errCh := make(chan error, len(s.servers))
+	for _, srv := range s.servers {
+		go func(srv *dns.Server) {
+			log.Printf("DNS server listening on %s/%s", s.addr, srv.Net)
+			errCh <- srv.ListenAndServe()
+		}(srv)
+	}
+	return <-errCh
+}
+
+// Shutdown stops all listeners started by ListenAndServe.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, srv := range s.servers {
+		if err := srv.ShutdownContext(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (s *Server) handleRequest(w dns.ResponseWriter, r *dns.Msg) {
+	start := time.Now()
+	transport := transportOf(w)
+	client := clientIP(w)
+	acl := s.acls.ACL()
+
 	msg := new(dns.Msg)
 	msg.SetReply(r)
 	msg.Authoritative = true
 
 	ctx := context.Background()
 
-	for _, q := range r.Question {
-		// Normalize domain name: lowercase and ensure it's fully qualified.
-		domainName := strings.ToLower(dns.Fqdn(q.Name))
-		log.Printf("Received query for %s type %s", domainName, dns.TypeToString[q.Qtype])
-
-		record, err := s.resolve(ctx, domainName)
-		if err != nil {
-			if !errors.Is(err, cache.ErrCacheMiss) {
-				log.Printf("Error resolving domain %s: %v", domainName, err)
+	var cacheStatus string
+	var policy *domain.PolicyRule
+	if denied := deniedACL(acl, client, r); denied != "" {
+		s.metrics.ACLDenied.WithLabelValues(denied).Inc()
+		msg.Authoritative = false
+		msg.SetRcode(r, dns.RcodeRefused)
+	} else if r.Opcode != dns.OpcodeQuery || isTransfer(r) {
+		msg.SetRcode(r, dns.RcodeNotImplemented)
+	} else if policy = s.matchPolicy(r, client); policy != nil && policy.Action != domain.PolicyActionPassthru {
+		applyPolicy(r, msg, policy)
+	} else {
+		ci := newClientInfo(r, client)
+		for _, q := range r.Question {
+			qStart := time.Now()
+			var rcode int
+			rcode, cacheStatus = s.answer(ctx, q, msg, ci)
+			s.metrics.QueryDuration.WithLabelValues(dns.TypeToString[q.Qtype], transport).Observe(time.Since(qStart).Seconds())
+
+			if rcode != dns.RcodeSuccess {
+				msg.Answer = nil
+				msg.SetRcode(r, rcode)
+				break
 			}
-			msg.SetRcode(r, dns.RcodeNameError) // NXDOMAIN
-			_ = w.WriteMsg(msg)
-			return
 		}
+		ci.echoSubnet(r, msg)
 
-		rr, err := s.buildRR(q, record)
-		if err != nil {
-			log.Printf("Error building resource record for %s: %v", domainName, err)
-			msg.SetRcode(r, dns.RcodeServerFailure)
-			_ = w.WriteMsg(msg)
+		recursionAllowed := s.forwarder != nil && acl.Allows(ACLRecursion, client)
+		msg.RecursionAvailable = recursionAllowed
+		if msg.Rcode == dns.RcodeNameError && r.RecursionDesired && s.forwarder != nil {
+			msg = s.recurse(r, msg, transport, recursionAllowed)
+		}
+	}
+	for _, q := range r.Question {
+		s.metrics.Queries.WithLabelValues(dns.TypeToString[q.Qtype], dns.RcodeToString[msg.Rcode], transport).Inc()
+	}
+
+	// Only UDP responses can be reflected towards a spoofed source
+	if s.limiter != nil && transport == "udp" {
+		action := s.limiter.Check(client, msg)
+		if action != RateLimitAllow {
+			s.metrics.RateLimited.WithLabelValues(action.String()).Inc()
+		}
+		switch action {
+		case RateLimitDrop:
 			return
+		case RateLimitSlip:
+			msg = new(dns.Msg)
+			msg.SetReply(r)
+			msg.Truncated = true
 		}
-		msg.Answer = append(msg.Answer, rr)
 	}
 
 	_ = w.WriteMsg(msg)
+	elapsed := time.Since(start)
+	s.metrics.RequestDuration.WithLabelValues(transport, resultOf(msg.Rcode)).Observe(elapsed.Seconds())
+	s.logQuery(w, r, msg, transport, cacheStatus, policy, start, elapsed)
+}
+
+// matchPolicy evaluates the response policy for the query name, recording hits.
+func (s *Server) matchPolicy(r *dns.Msg, client net.IP) *domain.PolicyRule {
+	if s.policy == nil || len(r.Question) == 0 {
+		return nil
+	}
+	name := normalizeName(r.Question[0].Name)
+	rule := s.policy.Evaluate(name, r.Question[0].Qtype)
+	if rule == nil {
+		return nil
+	}
+	s.metrics.PolicyHits.WithLabelValues(string(rule.Action), rule.Source).Inc()
+	log.Printf("Policy %s for %s from %s (rule %s %s, source %s)", rule.Action, name, client, rule.Match, rule.Pattern, rule.Source)
+	return rule
 }
 
-func (s *Server) resolve(ctx context.Context, domainName string) (*domain.DNSRecord, error) {
+// applyPolicy rewrites msg according to a matching policy rule.
+func applyPolicy(r, msg *dns.Msg, rule *domain.PolicyRule) {
+	switch rule.Action {
+	case domain.PolicyActionNXDomain:
+		msg.SetRcode(r, dns.RcodeNameError)
+	case domain.PolicyActionRedirect:
+		ip := net.ParseIP(rule.RedirectIP)
+		for _, q := range r.Question {
+			hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 300}
+			switch {
+			case q.Qtype == dns.TypeA && ip.To4() != nil:
+				msg.Answer = append(msg.Answer, &dns.A{Hdr: hdr, A: ip.To4()})
+			case q.Qtype == dns.TypeAAAA && ip.To4() == nil:
+				msg.Answer = append(msg.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip})
+			}
+			// Other query types get NODATA
+		}
+	}
+	// NODATA: NOERROR without answers
+}
+
+// recurse forwards a request for a name the server has no record for, if the
+// client is allowed recursion. Otherwise the request is refused.
+func (s *Server) recurse(r, msg *dns.Msg, transport string, allowed bool) *dns.Msg {
+	if !allowed {
+		s.metrics.ACLDenied.WithLabelValues(ACLRecursion).Inc()
+		msg.Authoritative = false
+		msg.SetRcode(r, dns.RcodeRefused)
+		return msg
+	}
+
+	resp, err := s.forwarder.Exchange(r, transport)
+	if err != nil {
+		log.Printf("Error forwarding query: %v", err)
+		s.metrics.BackendErrors.WithLabelValues(metrics.BackendForwarder).Inc()
+		msg.Authoritative = false
+		msg.SetRcode(r, dns.RcodeServerFailure)
+		return msg
+	}
+	resp.Id = r.Id
+	resp.RecursionAvailable = true
+	return resp
+}
+
+// deniedACL returns the name of the ACL that denies the request, or "" if it is allowed.
+func deniedACL(acl *ACL, client net.IP, r *dns.Msg) string {
+	name := ACLQuery
+	switch {
+	case r.Opcode == dns.OpcodeUpdate:
+		name = ACLUpdate
+	case isTransfer(r):
+		name = ACLTransfer
+	}
+	if acl.Allows(name, client) {
+		return ""
+	}
+	return name
+}
+
+func isTransfer(r *dns.Msg) bool {
+	for _, q := range r.Question {
+		if q.Qtype == dns.TypeAXFR || q.Qtype == dns.TypeIXFR {
+			return true
+		}
+	}
+	return false
+}
+
+// answer resolves a single question, appending to msg.Answer on success. It
+// returns the response code for the question and the record cache status.
+func (s *Server) answer(ctx context.Context, q dns.Question, msg *dns.Msg, ci *clientInfo) (int, string) {
+	// Normalize domain name: lowercase and ensure it's fully qualified.
+	domainName := strings.ToLower(dns.Fqdn(q.Name))
+
+	record, cacheStatus, err := s.resolve(ctx, domainName)
+	if err != nil {
+		if errors.Is(err, repository.ErrDNSRecordNotFound) {
+			return dns.RcodeNameError, cacheStatus
+		}
+		log.Printf("Error resolving domain %s: %v", domainName, err)
+		return dns.RcodeServerFailure, cacheStatus
+	}
+	if s.stats != nil {
+		s.stats.Record(domainName, time.Now())
+	}
+
+	rrs, err := s.buildRRs(q, record, ci)
+	if err != nil {
+		if errors.Is(err, errRecordTypeMismatch) {
+			return dns.RcodeSuccess, cacheStatus // NODATA
+		}
+		log.Printf("Error building resource record for %s: %v", domainName, err)
+		return dns.RcodeServerFailure, cacheStatus
+	}
+	msg.Answer = append(msg.Answer, rrs...)
+	return dns.RcodeSuccess, cacheStatus
+}
+
+// resolve looks a record up in the cache, falling back to the database. It
+// also returns how the cache was involved (one of the metrics.Cache* values).
+func (s *Server) resolve(ctx context.Context, domainName string) (*domain.DNSRecord, string, error) {
 	// 1. Check cache
 	cachedRecord, err := s.cache.Get(ctx, domainName)
 	if err == nil {
-		log.Printf("Cache hit for domain: %s", domainName)
-		return cachedRecord, nil
+		s.metrics.CacheLookups.WithLabelValues(metrics.CacheHit).Inc()
+		return cachedRecord, metrics.CacheHit, nil
 	}
+	cacheStatus := metrics.CacheMiss
 	if !errors.Is(err, cache.ErrCacheMiss) {
 		log.Printf("Cache error for domain %s: %v", domainName, err)
+		cacheStatus = metrics.CacheError
+		s.metrics.BackendErrors.WithLabelValues(metrics.BackendCache).Inc()
 		// Fall through to DB if cache fails
 	}
-
-	log.Printf("Cache miss for domain: %s", domainName)
+	s.metrics.CacheLookups.WithLabelValues(cacheStatus).Inc()
 
 	// 2. Check database via use case
 	dbRecord, err := s.uc.ResolveDomain(ctx, domainName)
 	if err != nil {
-		return nil, err // Propagate repository.ErrDNSRecordNotFound
+		if !errors.Is(err, repository.ErrDNSRecordNotFound) {
+			s.metrics.BackendErrors.WithLabelValues(metrics.BackendDatabase).Inc()
+		}
+		return nil, cacheStatus, err // Propagate repository.ErrDNSRecordNotFound
 	}
 
 	// 3. Set cache
 	if err := s.cache.Set(ctx, dbRecord); err != nil {
 		log.Printf("Failed to cache record for %s: %v", domainName, err)
+		s.metrics.BackendErrors.WithLabelValues(metrics.BackendCache).Inc()
+	}
+
+	return dbRecord, cacheStatus, nil
+}
+
+func (s *Server) logQuery(w dns.ResponseWriter, r, resp *dns.Msg, transport, cacheStatus string, policy *domain.PolicyRule, start time.Time, elapsed time.Duration) {
+	if s.queryLog == nil {
+		return
 	}
 
-	return dbRecord, nil
+	entry := &querylog.Entry{
+		Time:        start,
+		Transport:   transport,
+		RCode:       dns.RcodeToString[resp.Rcode],
+		LatencyMS:   float64(elapsed) / float64(time.Millisecond),
+		CacheStatus: cacheStatus,
+		Answers:     len(resp.Answer),
+		Query:       r,
+		Response:    resp,
+		LocalAddr:   w.LocalAddr(),
+		RemoteAddr:  w.RemoteAddr(),
+	}
+	if host, port, err := net.SplitHostPort(w.RemoteAddr().String()); err == nil {
+		entry.Client = host
+		entry.ClientPort, _ = strconv.Atoi(port)
+	}
+	if policy != nil {
+		entry.PolicyAction = string(policy.Action)
+		entry.PolicyRule = policy.Pattern
+	}
+	if len(r.Question) > 0 {
+		entry.QName = normalizeName(r.Question[0].Name)
+		entry.QType = dns.TypeToString[r.Question[0].Qtype]
+	}
+	s.queryLog.Log(entry)
 }
 
-func (s *Server) buildRR(q dns.Question, record *domain.DNSRecord) (dns.RR, error) {
+// buildRRs builds the answers for a record. A records are answered with
+// every available address, or with the targets their traffic policy selects.
+func (s *Server) buildRRs(q dns.Question, record *domain.DNSRecord, ci *clientInfo) ([]dns.RR, error) {
 	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 300}
 
 	switch domain.RecordType(record.Type) {
 	case domain.A:
 		if q.Qtype != dns.TypeA {
-			return nil, fmt.Errorf("record type mismatch: expected A, got %s", record.Type)
+			return nil, fmt.Errorf("%w: expected A, got %s", errRecordTypeMismatch, dns.TypeToString[q.Qtype])
 		}
-		ip := net.ParseIP(record.Value)
-		if ip == nil || ip.To4() == nil {
-			return nil, fmt.Errorf("invalid IPv4 address in record value: %s", record.Value)
+		healthy := record.Addresses()
+		if s.health != nil {
+			healthy = s.health.Healthy(record)
 		}
-		return &dns.A{Hdr: hdr, A: ip.To4()}, nil
+		addrs, scope := s.selector.Answers(record, healthy, ci.ip)
+		if record.TrafficPolicy != nil {
+			if scope == 0 && len(record.TrafficPolicy.SubnetPreferences) > 0 {
+				// Other subnets may get other answers
+				scope = ci.sourcePrefix()
+			}
+			ci.widenScope(scope)
+		}
+		rrs := make([]dns.RR, 0, len(addrs))
+		for _, addr := range addrs {
+			ip := net.ParseIP(addr)
+			if ip == nil || ip.To4() == nil {
+				return nil, fmt.Errorf("invalid IPv4 address in record value: %s", addr)
+			}
+			rrs = append(rrs, &dns.A{Hdr: hdr, A: ip.To4()})
+		}
+		return rrs, nil
 
 	case domain.CNAME:
 		if q.Qtype != dns.TypeCNAME {
-			return nil, fmt.Errorf("record type mismatch: expected CNAME, got %s", record.Type)
+			return nil, fmt.Errorf("%w: expected CNAME, got %s", errRecordTypeMismatch, dns.TypeToString[q.Qtype])
 		}
-		return &dns.CNAME{Hdr: hdr, Target: dns.Fqdn(record.Value)}, nil
+		return []dns.RR{&dns.CNAME{Hdr: hdr, Target: dns.Fqdn(record.Value)}}, nil
 	}
 
 	return nil, fmt.Errorf("unsupported record type: %s", record.Type)
 }
 
+// normalizeName lowercases a query name and strips the trailing root label so
+// that it matches the form policy patterns are stored under.
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+func transportOf(w dns.ResponseWriter) string {
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+		return "tcp"
+	}
+	return "udp"
+}
+
+func clientIP(w dns.ResponseWriter) net.IP {
+	switch addr := w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.TCPAddr:
+		return addr.IP
+	}
+	return nil
+}
+
+func resultOf(rcode int) string {
+	switch rcode {
+	case dns.RcodeSuccess:
+		return metrics.ResultSuccess
+	case dns.RcodeNameError:
+		return metrics.ResultNXDomain
+	default:
+		return metrics.ResultFailure
+	}
+}
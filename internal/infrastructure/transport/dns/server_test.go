@@ -1,15 +1,22 @@
 package dns
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"internal-dns/internal/domain"
 	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/infrastructure/metrics"
+	"internal-dns/internal/infrastructure/querylog"
 	"internal-dns/internal/repository"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -41,6 +48,18 @@ func (m *MockDNSRecordUseCase) UpdateRecord(context.Context, int64, int64, strin
 func (m *MockDNSRecordUseCase) DeleteRecord(context.Context, int64, int64) error {
 	return errors.New("not implemented")
 }
+func (m *MockDNSRecordUseCase) ListUnusedRecords(context.Context, time.Duration) ([]*domain.DNSRecord, error) {
+	return nil, errors.New("not implemented")
+}
+func (m *MockDNSRecordUseCase) ListDeletedRecords(context.Context, int64) ([]*domain.DNSRecord, error) {
+	return nil, errors.New("not implemented")
+}
+func (m *MockDNSRecordUseCase) RestoreRecord(context.Context, int64, int64) (*domain.DNSRecord, error) {
+	return nil, errors.New("not implemented")
+}
+func (m *MockDNSRecordUseCase) PurgeDeletedRecords(context.Context, time.Duration) (int64, error) {
+	return 0, errors.New("not implemented")
+}
 
 type MockDNSRecordCache struct {
 	mock.Mock
@@ -135,6 +154,188 @@ func TestServer_handleRequest(t *testing.T) {
 		mockCache.AssertExpectations(t)
 		mockUC.AssertExpectations(t)
 	})
+
+	t.Run("Type Mismatch Returns NODATA", func(t *testing.T) {
+		mockUC := new(MockDNSRecordUseCase)
+		mockCache := new(MockDNSRecordCache)
+		server := NewServer(":53535", mockUC, mockCache)
+
+		mockCache.On("Get", mock.Anything, "test-a.local.").Return(aRecord, nil).Once()
+
+		req := new(dns.Msg)
+		req.SetQuestion("test-a.local.", dns.TypeAAAA)
+		w := &mockResponseWriter{}
+
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeSuccess, w.msg.Rcode)
+		assert.Empty(t, w.msg.Answer)
+	})
+
+	t.Run("Database Error Fails", func(t *testing.T) {
+		mockUC := new(MockDNSRecordUseCase)
+		mockCache := new(MockDNSRecordCache)
+		reg := prometheus.NewRegistry()
+		m := metrics.NewDNSMetrics(reg)
+		server := NewServer(":53535", mockUC, mockCache, WithMetrics(m))
+
+		mockCache.On("Get", mock.Anything, "test-a.local.").Return(nil, cache.ErrCacheMiss).Once()
+		mockUC.On("ResolveDomain", mock.Anything, "test-a.local.").Return(nil, errors.New("connection refused")).Once()
+
+		req := new(dns.Msg)
+		req.SetQuestion("test-a.local.", dns.TypeA)
+		w := &mockResponseWriter{}
+
+		server.handleRequest(w, req)
+
+		require.NotNil(t, w.msg)
+		assert.Equal(t, dns.RcodeServerFailure, w.msg.Rcode)
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.Queries.WithLabelValues("A", "SERVFAIL", "udp")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.BackendErrors.WithLabelValues(metrics.BackendDatabase)))
+		assert.Equal(t, 1, testutil.CollectAndCount(m.RequestDuration))
+	})
+}
+
+func TestServer_Metrics(t *testing.T) {
+	aRecord := &domain.DNSRecord{DomainName: "test-a.local.", Type: domain.A, Value: "1.2.3.4"}
+
+	mockUC := new(MockDNSRecordUseCase)
+	mockCache := new(MockDNSRecordCache)
+	reg := prometheus.NewRegistry()
+	m := metrics.NewDNSMetrics(reg)
+	server := NewServer(":53535", mockUC, mockCache, WithMetrics(m))
+
+	mockCache.On("Get", mock.Anything, "test-a.local.").Return(aRecord, nil).Once()
+	mockCache.On("Get", mock.Anything, "missing.local.").Return(nil, cache.ErrCacheMiss).Once()
+	mockUC.On("ResolveDomain", mock.Anything, "missing.local.").Return(nil, repository.ErrDNSRecordNotFound).Once()
+
+	hit := new(dns.Msg)
+	hit.SetQuestion("test-a.local.", dns.TypeA)
+	server.handleRequest(&mockResponseWriter{}, hit)
+
+	miss := new(dns.Msg)
+	miss.SetQuestion("missing.local.", dns.TypeA)
+	server.handleRequest(&mockResponseWriter{tcp: true}, miss)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Queries.WithLabelValues("A", "NOERROR", "udp")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Queries.WithLabelValues("A", "NXDOMAIN", "tcp")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CacheLookups.WithLabelValues(metrics.CacheHit)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CacheLookups.WithLabelValues(metrics.CacheMiss)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.BackendErrors.WithLabelValues(metrics.BackendDatabase)))
+}
+
+func TestServer_QueryLog(t *testing.T) {
+	aRecord := &domain.DNSRecord{DomainName: "test-a.local.", Type: domain.A, Value: "1.2.3.4"}
+
+	mockUC := new(MockDNSRecordUseCase)
+	mockCache := new(MockDNSRecordCache)
+	var buf bytes.Buffer
+	logger := querylog.New(querylog.Config{SampleRate: 1, BufferSize: 10}, nil, querylog.NewJSONSink(&buf))
+	server := NewServer(":53535", mockUC, mockCache, WithQueryLog(logger))
+
+	mockCache.On("Get", mock.Anything, "test-a.local.").Return(aRecord, nil).Once()
+
+	req := new(dns.Msg)
+	req.SetQuestion("Test-A.local.", dns.TypeA)
+	server.handleRequest(&mockResponseWriter{}, req)
+	require.NoError(t, logger.Close())
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "127.0.0.1", entry["client"])
+	assert.Equal(t, "test-a.local", entry["qname"])
+	assert.Equal(t, "A", entry["qtype"])
+	assert.Equal(t, "NOERROR", entry["rcode"])
+	assert.Equal(t, "udp", entry["transport"])
+	assert.Equal(t, metrics.CacheHit, entry["cacheStatus"])
+	assert.Equal(t, 1.0, entry["answers"])
+	assert.Contains(t, entry, "latencyMs")
+}
+
+// MockQueryStats is a mock implementation of usecase.QueryStatsUseCase
+type MockQueryStats struct {
+	mock.Mock
+}
+
+func (m *MockQueryStats) Record(domainName string, at time.Time) {
+	m.Called(domainName, at)
+}
+func (m *MockQueryStats) FlushToStore(ctx context.Context) error {
+	return m.Called(ctx).Error(0)
+}
+func (m *MockQueryStats) FlushToDatabase(ctx context.Context) error {
+	return m.Called(ctx).Error(0)
+}
+
+func TestServer_QueryStats(t *testing.T) {
+	aRecord := &domain.DNSRecord{DomainName: "test-a.local.", Type: domain.A, Value: "1.2.3.4"}
+
+	mockUC := new(MockDNSRecordUseCase)
+	mockCache := new(MockDNSRecordCache)
+	stats := new(MockQueryStats)
+	server := NewServer(":53535", mockUC, mockCache, WithQueryStats(stats))
+
+	mockCache.On("Get", mock.Anything, "test-a.local.").Return(aRecord, nil).Once()
+	mockCache.On("Get", mock.Anything, "missing.local.").Return(nil, cache.ErrCacheMiss).Once()
+	mockUC.On("ResolveDomain", mock.Anything, "missing.local.").Return(nil, repository.ErrDNSRecordNotFound).Once()
+	stats.On("Record", "test-a.local.", mock.AnythingOfType("time.Time")).Once()
+
+	req := new(dns.Msg)
+	req.SetQuestion("test-a.local.", dns.TypeA)
+	server.handleRequest(&mockResponseWriter{}, req)
+
+	// Names without a record are not counted
+	req.SetQuestion("missing.local.", dns.TypeA)
+	server.handleRequest(&mockResponseWriter{}, req)
+
+	stats.AssertExpectations(t)
+}
+
+type stubHealthFilter map[string]bool
+
+func (f stubHealthFilter) Healthy(record *domain.DNSRecord) []string {
+	var addrs []string
+	for _, addr := range record.Addresses() {
+		if f[addr] {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func TestServer_Health(t *testing.T) {
+	record := &domain.DNSRecord{DomainName: "svc.local.", Type: domain.A, Value: "10.0.0.1", AdditionalValues: []string{"10.0.0.2", "10.0.0.3"}}
+
+	mockUC := new(MockDNSRecordUseCase)
+	mockCache := new(MockDNSRecordCache)
+	mockCache.On("Get", mock.Anything, "svc.local.").Return(record, nil)
+
+	req := new(dns.Msg)
+	req.SetQuestion("svc.local.", dns.TypeA)
+
+	answers := func(w *mockResponseWriter) []string {
+		var ips []string
+		for _, rr := range w.msg.Answer {
+			ips = append(ips, rr.(*dns.A).A.String())
+		}
+		return ips
+	}
+
+	// Without a filter every address is answered
+	w := &mockResponseWriter{}
+	NewServer(":53535", mockUC, mockCache).handleRequest(w, req)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, answers(w))
+
+	w = &mockResponseWriter{}
+	server := NewServer(":53535", mockUC, mockCache, WithHealth(stubHealthFilter{"10.0.0.2": true}))
+	server.handleRequest(w, req)
+	assert.Equal(t, []string{"10.0.0.2"}, answers(w))
+
+	// With no address healthy, all of them are answered
+	w = &mockResponseWriter{}
+	NewServer(":53535", mockUC, mockCache, WithHealth(stubHealthFilter{})).handleRequest(w, req)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, answers(w))
 }
 
 func BenchmarkServer_handleRequest(b *testing.B) {
@@ -159,11 +360,14 @@ func BenchmarkServer_handleRequest(b *testing.B) {
 }
 
 type mockResponseWriter struct {
-	msg *dns.Msg
+	msg      *dns.Msg
+	tcp      bool
+	remoteIP string // defaults to 127.0.0.1
 }
 
-func (m *mockResponseWriter) LocalAddr() net.Addr         { return &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53} }
-func (m *mockResponseWriter) RemoteAddr() net.Addr        { return &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 12345} }
+func (m *mockResponseWriter) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53}
+}
 func (m *mockResponseWriter) WriteMsg(msg *dns.Msg) error { m.msg = msg; return nil }
 func (m *mockResponseWriter) Write([]byte) (int, error)   { return 0, nil }
 func (m *mockResponseWriter) Close() error                { return nil }
@@ -171,3 +375,13 @@ func (m *mockResponseWriter) TsigStatus() error           { return nil }
 func (m *mockResponseWriter) TsigTimersOnly(bool)         {}
 func (m *mockResponseWriter) Hijack()                     {}
 
+func (m *mockResponseWriter) RemoteAddr() net.Addr {
+	ip := net.ParseIP("127.0.0.1")
+	if m.remoteIP != "" {
+		ip = net.ParseIP(m.remoteIP)
+	}
+	if m.tcp {
+		return &net.TCPAddr{IP: ip, Port: 12345}
+	}
+	return &net.UDPAddr{IP: ip, Port: 12345}
+}
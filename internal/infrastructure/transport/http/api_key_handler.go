@@ -0,0 +1,280 @@
+package http
+
+import (
+	"errors"
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CreateServiceAccountRequest defines the payload for creating a service account.
+type CreateServiceAccountRequest struct {
+	Username string `json:"username"`
+}
+
+// CreateAPIKeyRequest defines the payload for creating an API key.
+type CreateAPIKeyRequest struct {
+	Name      string               `json:"name"`
+	Scopes    []domain.APIKeyScope `json:"scopes"`             // records:read, records:write
+	Suffixes  []string             `json:"suffixes,omitempty"` // names the key can write; all if empty
+	ExpiresAt *time.Time           `json:"expiresAt,omitempty"`
+}
+
+// APIKeyResponse is an API key without its secret.
+type APIKeyResponse struct {
+	ID         int64                `json:"id"`
+	UserID     int64                `json:"userId"`
+	Name       string               `json:"name"`
+	Prefix     string               `json:"prefix"`
+	Scopes     []domain.APIKeyScope `json:"scopes"`
+	Suffixes   []string             `json:"suffixes"`
+	ExpiresAt  *time.Time           `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time           `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time            `json:"createdAt"`
+	RotatedAt  *time.Time           `json:"rotatedAt,omitempty"`
+	RevokedAt  *time.Time           `json:"revokedAt,omitempty"`
+}
+
+// IssuedAPIKeyResponse is a created or rotated API key, with the key itself.
+type IssuedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"` // shown only once
+}
+
+func toAPIKeyResponse(k *domain.APIKey) APIKeyResponse {
+	suffixes := k.Suffixes
+	if suffixes == nil {
+		suffixes = []string{}
+	}
+	return APIKeyResponse{
+		ID:         k.ID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		Suffixes:   suffixes,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		CreatedAt:  k.CreatedAt,
+		RotatedAt:  k.RotatedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
+
+// APIKeyHandler handles service accounts and their API keys.
+type APIKeyHandler struct {
+	apiKeyUC usecase.APIKeyUseCase
+}
+
+// NewAPIKeyHandler creates a new APIKeyHandler.
+func NewAPIKeyHandler(apiKeyUC usecase.APIKeyUseCase) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyUC: apiKeyUC}
+}
+
+// CreateServiceAccount godoc
+// @Summary Create a service account
+// @Description Creates a user for automation such as CI pipelines. Service accounts cannot log in; they authenticate with API keys in the X-API-Key header. (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param account body CreateServiceAccountRequest true "Service account"
+// @Success 201 {object} UserResponse
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 409 {object} map[string]string "Username already exists"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/service-accounts [post]
+func (h *APIKeyHandler) CreateServiceAccount(c echo.Context) error {
+	actor, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid actor in context"})
+	}
+
+	var req CreateServiceAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	user, err := h.apiKeyUC.CreateServiceAccount(c.Request().Context(), actor.ID, req.Username)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUserAlreadyExists):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrUsernameTooShort):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create service account"})
+		}
+	}
+	return c.JSON(http.StatusCreated, toUserResponse(user))
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Creates an API key of a service account. records:read allows reading records, records:write also changing them; with suffixes, only names under one of them can be changed. The key is returned only once. (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Param key body CreateAPIKeyRequest true "API key"
+// @Success 201 {object} IssuedAPIKeyResponse
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/service-accounts/{id}/keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
+	actor, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid actor in context"})
+	}
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	k, key, err := h.apiKeyUC.CreateKey(c.Request().Context(), actor.ID, userID, req.Name, req.Scopes, req.Suffixes, req.ExpiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUserNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+		case errors.Is(err, domain.ErrNotServiceAccount), errors.Is(err, domain.ErrAPIKeyNameRequired),
+			errors.Is(err, domain.ErrInvalidAPIKeyScope), errors.Is(err, domain.ErrInvalidNameSuffix),
+			errors.Is(err, domain.ErrSuffixesRequireWrite), errors.Is(err, domain.ErrInvalidAPIKeyExpiry):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create API key"})
+		}
+	}
+	return c.JSON(http.StatusCreated, IssuedAPIKeyResponse{APIKeyResponse: toAPIKeyResponse(k), Key: key})
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description Lists the API keys of a service account, including revoked ones, newest first. (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Success 200 {array} APIKeyResponse
+// @Failure 400 {object} map[string]string "Invalid user ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/service-accounts/{id}/keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	keys, err := h.apiKeyUC.ListKeys(c.Request().Context(), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve API keys"})
+	}
+	res := make([]APIKeyResponse, len(keys))
+	for i, k := range keys {
+		res[i] = toAPIKeyResponse(k)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// RotateAPIKey godoc
+// @Summary Rotate an API key
+// @Description Replaces the secret of an API key, keeping its scopes and expiry. The old key stops working at once; the new one is returned only once. (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Param keyId path int true "API key ID"
+// @Success 200 {object} IssuedAPIKeyResponse
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "API key not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/service-accounts/{id}/keys/{keyId}/rotate [post]
+func (h *APIKeyHandler) RotateAPIKey(c echo.Context) error {
+	actor, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid actor in context"})
+	}
+	userID, keyID, ok := parseAPIKeyPath(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	k, key, err := h.apiKeyUC.RotateKey(c.Request().Context(), actor.ID, userID, keyID)
+	if err != nil {
+		if errors.Is(err, domain.ErrAPIKeyNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "API key not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to rotate API key"})
+	}
+	return c.JSON(http.StatusOK, IssuedAPIKeyResponse{APIKeyResponse: toAPIKeyResponse(k), Key: key})
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revokes an API key of a service account; it stops working at once. (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Param keyId path int true "API key ID"
+// @Success 204 "API key revoked"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "API key not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/service-accounts/{id}/keys/{keyId} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c echo.Context) error {
+	actor, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid actor in context"})
+	}
+	userID, keyID, ok := parseAPIKeyPath(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	if err := h.apiKeyUC.RevokeKey(c.Request().Context(), actor.ID, userID, keyID); err != nil {
+		if errors.Is(err, domain.ErrAPIKeyNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "API key not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke API key"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func parseAPIKeyPath(c echo.Context) (userID, keyID int64, ok bool) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	keyID, err = strconv.ParseInt(c.Param("keyId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return userID, keyID, true
+}
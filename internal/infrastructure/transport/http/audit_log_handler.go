@@ -0,0 +1,555 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/usecase"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// auditCSVColumns is the header row of audit log CSV exports.
+var auditCSVColumns = []string{"id", "timestamp", "userId", "username", "action", "targetId", "changeId", "oldValue", "newValue", "prevHash", "hash"}
+
+// AuditLogResponse is one audit log entry. OldValue and NewValue hold the
+// JSON state before and after the action.
+type AuditLogResponse struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"userId"`
+	Username  string          `json:"username,omitempty"`
+	Action    string          `json:"action"`
+	TargetID  int64           `json:"targetId,omitempty"`
+	ChangeID  string          `json:"changeId,omitempty"`
+	OldValue  json.RawMessage `json:"oldValue,omitempty" swaggertype:"object"`
+	NewValue  json.RawMessage `json:"newValue,omitempty" swaggertype:"object"`
+	Timestamp time.Time       `json:"timestamp"`
+	PrevHash  string          `json:"prevHash,omitempty"`
+	Hash      string          `json:"hash,omitempty"`
+}
+
+// AuditLogPageResponse is one page of audit log entries. Pass nextCursor as
+// the cursor parameter to get the next page; it is omitted on the last one.
+type AuditLogPageResponse struct {
+	Items      []AuditLogResponse `json:"items"`
+	NextCursor int64              `json:"nextCursor,omitempty"`
+}
+
+// AuditChainBreakResponse is the first entry or checkpoint that failed
+// verification.
+type AuditChainBreakResponse struct {
+	LogID        int64  `json:"logId"`
+	CheckpointID int64  `json:"checkpointId,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// AuditChainReportResponse is the result of verifying the audit log hash
+// chain. Entries written before hashing was introduced are counted as
+// unhashed; gaps are runs of archived entries that were skipped.
+type AuditChainReportResponse struct {
+	Valid       bool                     `json:"valid"`
+	Checked     int64                    `json:"checked"`
+	Unhashed    int64                    `json:"unhashed"`
+	FirstID     int64                    `json:"firstId,omitempty"`
+	LastID      int64                    `json:"lastId,omitempty"`
+	Checkpoints int                      `json:"checkpoints"`
+	Gaps        int                      `json:"gaps"`
+	Broken      *AuditChainBreakResponse `json:"broken,omitempty"`
+}
+
+// AuditCheckpointResponse is a signed hash of the audit log up to an entry.
+type AuditCheckpointResponse struct {
+	ID        int64     `json:"id"`
+	LogID     int64     `json:"logId"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AuditRetentionResponse is how long audit entries are kept: a number of
+// days such as "30d", a duration, or "forever".
+type AuditRetentionResponse struct {
+	Default string            `json:"default"`
+	Actions map[string]string `json:"actions"`
+}
+
+// AuditPartitionResponse is a partition of the audit log holding the entries
+// created in [from, to). The partition of entries from before partitioning
+// has no from, the default partition neither.
+type AuditPartitionResponse struct {
+	Name string     `json:"name"`
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+	Rows int64      `json:"rows"` // Estimated
+}
+
+// AuditArchiveResponse is a file that removed audit entries were archived to.
+type AuditArchiveResponse struct {
+	ID        int64     `json:"id"`
+	Partition string    `json:"partition"`
+	File      string    `json:"file"`
+	Entries   int64     `json:"entries"`
+	FirstID   int64     `json:"firstId,omitempty"`
+	LastID    int64     `json:"lastId,omitempty"`
+	Dropped   bool      `json:"dropped"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AuditArchiveRunResponse is the last run of the archiver on this server.
+type AuditArchiveRunResponse struct {
+	StartedAt  time.Time              `json:"startedAt"`
+	FinishedAt time.Time              `json:"finishedAt"`
+	Archives   []AuditArchiveResponse `json:"archives"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// AuditArchiveStatusResponse describes the retention of the audit log.
+type AuditArchiveStatusResponse struct {
+	Retention  AuditRetentionResponse   `json:"retention"`
+	Partitions []AuditPartitionResponse `json:"partitions"`
+	Archives   []AuditArchiveResponse   `json:"archives"`
+	LastRun    *AuditArchiveRunResponse `json:"lastRun,omitempty"`
+}
+
+// AuditLogHandler handles audit log HTTP requests.
+type AuditLogHandler struct {
+	auditLogUC usecase.AuditLogUseCase
+	chainUC    usecase.AuditChainUseCase
+	archiveUC  usecase.AuditArchiveUseCase
+}
+
+// NewAuditLogHandler creates a new AuditLogHandler.
+func NewAuditLogHandler(auditLogUC usecase.AuditLogUseCase, chainUC usecase.AuditChainUseCase, archiveUC usecase.AuditArchiveUseCase) *AuditLogHandler {
+	return &AuditLogHandler{auditLogUC: auditLogUC, chainUC: chainUC, archiveUC: archiveUC}
+}
+
+// ListAuditLogs godoc
+// @Summary List audit log entries
+// @Description Lists audit log entries, newest first, filtered by actor, action, target, change set and time range. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param userId query int false "Acting user ID"
+// @Param action query string false "Comma-separated action types, e.g. CREATE_DNS_RECORD,DELETE_DNS_RECORD"
+// @Param targetId query int false "Target ID"
+// @Param changeId query string false "Change set ID"
+// @Param from query string false "Start of the time range (RFC 3339, inclusive)"
+// @Param to query string false "End of the time range (RFC 3339, exclusive)"
+// @Param cursor query int false "nextCursor of the previous page"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Success 200 {object} AuditLogPageResponse
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/audit-logs [get]
+func (h *AuditLogHandler) ListAuditLogs(c echo.Context) error {
+	filter, err := parseAuditLogFilter(c, true)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	page, err := h.auditLogUC.ListAuditLogs(c.Request().Context(), filter)
+	if err != nil {
+		return auditLogError(c, err)
+	}
+	return c.JSON(http.StatusOK, toAuditLogPageResponse(page))
+}
+
+// ExportAuditLogs godoc
+// @Summary Export audit log entries
+// @Description Streams every audit log entry matching the filters, newest first, as CSV (default) or as a JSON array. CSV cells that spreadsheets would run as formulas are prefixed with '. Admin only.
+// @Tags admin
+// @Produce json,text/csv
+// @Security BearerAuth
+// @Param format query string false "csv or json" Enums(csv, json)
+// @Param userId query int false "Acting user ID"
+// @Param action query string false "Comma-separated action types"
+// @Param targetId query int false "Target ID"
+// @Param changeId query string false "Change set ID"
+// @Param from query string false "Start of the time range (RFC 3339, inclusive)"
+// @Param to query string false "End of the time range (RFC 3339, exclusive)"
+// @Success 200 {array} AuditLogResponse
+// @Failure 400 {object} map[string]string "Invalid filter or format"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/audit-logs/export [get]
+func (h *AuditLogHandler) ExportAuditLogs(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format != "" && format != "csv" && format != "json" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid format, use csv or json"})
+	}
+	filter, err := parseAuditLogFilter(c, true)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// The response is streamed, so the status is sent with the first entry
+	res := c.Response()
+	var write func(*domain.AuditLog) error
+	var finish func() error
+	if format == "json" {
+		first := true
+		enc := json.NewEncoder(res)
+		write = func(l *domain.AuditLog) error {
+			sep := ","
+			if first {
+				res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+				res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="audit-logs.json"`)
+				res.WriteHeader(http.StatusOK)
+				sep, first = "[", false
+			}
+			if _, err := res.Write([]byte(sep)); err != nil {
+				return err
+			}
+			return enc.Encode(toAuditLogResponse(l))
+		}
+		finish = func() error {
+			if first {
+				return c.JSON(http.StatusOK, []AuditLogResponse{})
+			}
+			_, err := res.Write([]byte("]\n"))
+			return err
+		}
+	} else {
+		w := csv.NewWriter(res)
+		res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="audit-logs.csv"`)
+		write = func(l *domain.AuditLog) error {
+			if !res.Committed {
+				res.WriteHeader(http.StatusOK)
+				w.Write(auditCSVColumns)
+			}
+			var targetID string
+			if l.TargetID != 0 {
+				targetID = strconv.FormatInt(l.TargetID, 10)
+			}
+			// Usernames and values are user input
+			w.Write(escapeCSVRow([]string{
+				strconv.FormatInt(l.ID, 10),
+				l.Timestamp.UTC().Format(time.RFC3339Nano),
+				strconv.FormatInt(l.UserID, 10),
+				l.Username,
+				string(l.Action),
+				targetID,
+				l.ChangeID,
+				string(l.OldValue),
+				string(l.NewValue),
+				l.PrevHash,
+				l.Hash,
+			}))
+			return w.Error()
+		}
+		finish = func() error {
+			if !res.Committed {
+				res.WriteHeader(http.StatusOK)
+				w.Write(auditCSVColumns)
+			}
+			w.Flush()
+			return w.Error()
+		}
+	}
+
+	if err := h.auditLogUC.ExportAuditLogs(c.Request().Context(), filter, write); err != nil {
+		if res.Committed {
+			// Too late to report the error; the client gets a truncated file
+			log.Printf("Audit log export aborted: %v", err)
+			return nil
+		}
+		return auditLogError(c, err)
+	}
+	return finish()
+}
+
+// MyActivity godoc
+// @Summary List my activity
+// @Description Lists the audit log entries of actions taken by the authenticated user, newest first.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param action query string false "Comma-separated action types"
+// @Param targetId query int false "Target ID"
+// @Param changeId query string false "Change set ID"
+// @Param from query string false "Start of the time range (RFC 3339, inclusive)"
+// @Param to query string false "End of the time range (RFC 3339, exclusive)"
+// @Param cursor query int false "nextCursor of the previous page"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Success 200 {object} AuditLogPageResponse
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /me/activity [get]
+func (h *AuditLogHandler) MyActivity(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	filter, err := parseAuditLogFilter(c, false)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	page, err := h.auditLogUC.ListUserActivity(c.Request().Context(), user.ID, filter)
+	if err != nil {
+		return auditLogError(c, err)
+	}
+	return c.JSON(http.StatusOK, toAuditLogPageResponse(page))
+}
+
+// VerifyChain godoc
+// @Summary Verify the audit log hash chain
+// @Description Walks the audit log, checking every entry against its hash and the entry before it, and every checkpoint against its signature. Reports the first broken link. Admin only.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} AuditChainReportResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/audit-logs/verify [get]
+func (h *AuditLogHandler) VerifyChain(c echo.Context) error {
+	report, err := h.chainUC.VerifyChain(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify audit logs"})
+	}
+
+	resp := AuditChainReportResponse{
+		Valid:       report.Valid,
+		Checked:     report.Checked,
+		Unhashed:    report.Unhashed,
+		FirstID:     report.FirstID,
+		LastID:      report.LastID,
+		Checkpoints: report.Checkpoints,
+		Gaps:        report.Gaps,
+	}
+	if report.Broken != nil {
+		resp.Broken = &AuditChainBreakResponse{
+			LogID:        report.Broken.LogID,
+			CheckpointID: report.Broken.CheckpointID,
+			Reason:       report.Broken.Reason,
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// CreateCheckpoint godoc
+// @Summary Create an audit log checkpoint
+// @Description Signs the hash of the newest audit log entry. Without new entries the latest checkpoint is returned. Admin only.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} AuditCheckpointResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 409 {object} map[string]string "No hashed entries"
+// @Failure 501 {object} map[string]string "Checkpoints are not configured"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/audit-logs/checkpoints [post]
+func (h *AuditLogHandler) CreateCheckpoint(c echo.Context) error {
+	cp, err := h.chainUC.CreateCheckpoint(c.Request().Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrAuditChainEmpty):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrAuditCheckpointsNotSet):
+			return c.JSON(http.StatusNotImplemented, map[string]string{"error": err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create audit checkpoint"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, AuditCheckpointResponse{
+		ID:        cp.ID,
+		LogID:     cp.LogID,
+		Hash:      cp.Hash,
+		Signature: cp.Signature,
+		CreatedAt: cp.CreatedAt,
+	})
+}
+
+// ArchiveStatus godoc
+// @Summary Get the audit log archive status
+// @Description Shows the retention policy, the partitions of the audit log, the most recent archives of removed entries and the last run of the archiver on the server that answers. Admin only.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} AuditArchiveStatusResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/audit-logs/archive [get]
+func (h *AuditLogHandler) ArchiveStatus(c echo.Context) error {
+	status, err := h.archiveUC.Status(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get audit archive status"})
+	}
+
+	resp := AuditArchiveStatusResponse{
+		Retention: AuditRetentionResponse{
+			Default: formatRetention(status.Retention.Default),
+			Actions: make(map[string]string, len(status.Retention.Actions)),
+		},
+		Partitions: make([]AuditPartitionResponse, len(status.Partitions)),
+		Archives:   toAuditArchiveResponses(status.Archives),
+	}
+	for action, d := range status.Retention.Actions {
+		resp.Retention.Actions[string(action)] = formatRetention(d)
+	}
+	for i, p := range status.Partitions {
+		resp.Partitions[i] = AuditPartitionResponse{Name: p.Name, Rows: p.Rows}
+		if !p.From.IsZero() {
+			resp.Partitions[i].From = &p.From
+		}
+		if !p.To.IsZero() {
+			resp.Partitions[i].To = &p.To
+		}
+	}
+	if run := status.LastRun; run != nil {
+		resp.LastRun = &AuditArchiveRunResponse{
+			StartedAt:  run.StartedAt,
+			FinishedAt: run.FinishedAt,
+			Archives:   toAuditArchiveResponses(run.Archives),
+			Error:      run.Error,
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// parseAuditLogFilter reads the filter query parameters. The userId
+// parameter is only accepted if withUser is set.
+func parseAuditLogFilter(c echo.Context, withUser bool) (domain.AuditLogFilter, error) {
+	var filter domain.AuditLogFilter
+
+	parseID := func(name string) (*int64, error) {
+		v := c.QueryParam(name)
+		if v == "" {
+			return nil, nil
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			return nil, errors.New("Invalid " + name)
+		}
+		return &id, nil
+	}
+	parseTime := func(name string) (time.Time, error) {
+		v := c.QueryParam(name)
+		if v == "" {
+			return time.Time{}, nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, errors.New("Invalid " + name + ", use RFC 3339")
+		}
+		return t, nil
+	}
+
+	var err error
+	if withUser {
+		if filter.UserID, err = parseID("userId"); err != nil {
+			return filter, err
+		}
+	}
+	if filter.TargetID, err = parseID("targetId"); err != nil {
+		return filter, err
+	}
+	if filter.From, err = parseTime("from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTime("to"); err != nil {
+		return filter, err
+	}
+	for _, a := range strings.Split(c.QueryParam("action"), ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			filter.Actions = append(filter.Actions, domain.ActionType(strings.ToUpper(a)))
+		}
+	}
+	filter.ChangeID = c.QueryParam("changeId")
+
+	if v := c.QueryParam("cursor"); v != "" {
+		cursor, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || cursor < 1 {
+			return filter, errors.New("Invalid cursor")
+		}
+		filter.BeforeID = cursor
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return filter, errors.New("Invalid limit")
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+func auditLogError(c echo.Context, err error) error {
+	if errors.Is(err, domain.ErrInvalidAuditLogFilter) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid audit log filter"})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve audit logs"})
+}
+
+func toAuditLogResponse(l *domain.AuditLog) AuditLogResponse {
+	return AuditLogResponse{
+		ID:        l.ID,
+		UserID:    l.UserID,
+		Username:  l.Username,
+		Action:    string(l.Action),
+		TargetID:  l.TargetID,
+		ChangeID:  l.ChangeID,
+		OldValue:  l.OldValue,
+		NewValue:  l.NewValue,
+		Timestamp: l.Timestamp,
+		PrevHash:  l.PrevHash,
+		Hash:      l.Hash,
+	}
+}
+
+func toAuditLogPageResponse(page *domain.AuditLogPage) AuditLogPageResponse {
+	resp := AuditLogPageResponse{Items: make([]AuditLogResponse, 0, len(page.Items)), NextCursor: page.NextCursor}
+	for _, l := range page.Items {
+		resp.Items = append(resp.Items, toAuditLogResponse(l))
+	}
+	return resp
+}
+
+func toAuditArchiveResponses(archives []*domain.AuditArchive) []AuditArchiveResponse {
+	resp := make([]AuditArchiveResponse, len(archives))
+	for i, a := range archives {
+		resp[i] = AuditArchiveResponse{
+			ID:        a.ID,
+			Partition: a.Partition,
+			File:      a.File,
+			Entries:   a.Entries,
+			FirstID:   a.FirstID,
+			LastID:    a.LastID,
+			Dropped:   a.Dropped,
+			CreatedAt: a.CreatedAt,
+		}
+	}
+	return resp
+}
+
+func formatRetention(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d == 0:
+		return "forever"
+	case d%day == 0:
+		return fmt.Sprintf("%dd", d/day)
+	default:
+		return d.String()
+	}
+}
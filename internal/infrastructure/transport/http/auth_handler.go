@@ -3,9 +3,12 @@ package http
 import (
 	"errors"
 	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
 	"internal-dns/internal/repository"
 	"internal-dns/internal/usecase"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -24,11 +27,52 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
 type AuthResponse struct {
 	AccessToken  string `json:"accessToken"`  // Changed to camelCase
 	RefreshToken string `json:"refreshToken"` // Changed to camelCase
 }
 
+// LoginResponse holds either the tokens, or the token of the MFA challenge
+// to answer at /auth/mfa/verify.
+type LoginResponse struct {
+	AccessToken  string `json:"accessToken,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+
+	MFARequired bool   `json:"mfaRequired,omitempty"`
+	MFAToken    string `json:"mfaToken,omitempty"`
+	// MFAEnrollmentRequired is set for admins without two-factor
+	// authentication, who enroll at /auth/mfa/enroll first.
+	MFAEnrollmentRequired bool `json:"mfaEnrollmentRequired,omitempty"`
+
+	// RecoveryCodes are returned once, when the login enabled two-factor
+	// authentication.
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+	Code     string `json:"code" validate:"required"` // TOTP or recovery code
+}
+
+func newLoginResponse(result *domain.LoginResult) LoginResponse {
+	return LoginResponse{
+		AccessToken:           result.AccessToken,
+		RefreshToken:          result.RefreshToken,
+		MFARequired:           result.MFAToken != "",
+		MFAToken:              result.MFAToken,
+		MFAEnrollmentRequired: result.MFAEnrollmentRequired,
+		RecoveryCodes:         result.RecoveryCodes,
+	}
+}
+
 func NewAuthHandler(authUC usecase.AuthUseCase) *AuthHandler {
 	return &AuthHandler{authUC: authUC}
 }
@@ -72,13 +116,14 @@ func (h *AuthHandler) Register(c echo.Context) error {
 
 // Login godoc
 // @Summary Log in a user
-// @Description Authenticates a user and returns access and refresh tokens.
+// @Description Authenticates a user with the configured backends (local passwords, LDAP) and returns access and refresh tokens. Users with two-factor authentication, and all admins, get an MFA challenge token instead, to answer at /auth/mfa/verify within 5 minutes. Failed logins of a username or from a client IP delay their next logins, and enough of them lock them out for a while, whether or not the account exists.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param credentials body LoginRequest true "Login Credentials"
-// @Success 200 {object} AuthResponse
+// @Success 200 {object} LoginResponse
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 429 {object} map[string]string "Too many failed logins; see the Retry-After header"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c echo.Context) error {
@@ -87,13 +132,123 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
 	}
 
-	accessToken, refreshToken, err := h.authUC.Login(c.Request().Context(), req.Username, req.Password)
+	result, err := h.authUC.Login(c.Request().Context(), req.Username, req.Password, c.RealIP())
 	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) { // Using errors.Is
 			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid username or password"}) // Refined error message
 		}
+		if errors.Is(err, domain.ErrLoginThrottled) {
+			return loginThrottled(c, err)
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to login"})
+	}
+
+	return c.JSON(http.StatusOK, newLoginResponse(result))
+}
+
+// EnrollMFA godoc
+// @Summary Enroll in two-factor authentication while logging in
+// @Description For logins with mfaEnrollmentRequired: creates a TOTP secret to add to an authenticator app. The login is completed at /auth/mfa/verify with a code of the new secret.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body MFAEnrollRequest true "MFA challenge"
+// @Success 200 {object} TOTPProvisioningResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Invalid or expired MFA challenge"
+// @Failure 409 {object} map[string]string "Two-factor authentication is already enabled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/mfa/enroll [post]
+func (h *AuthHandler) EnrollMFA(c echo.Context) error {
+	var req MFAEnrollRequest
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "mfaToken is required"})
+	}
+
+	provisioning, err := h.authUC.BeginMFAEnrollment(c.Request().Context(), req.MFAToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidMFAChallenge):
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrMFAAlreadyEnabled):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enroll in two-factor authentication"})
+	}
+	return c.JSON(http.StatusOK, newTOTPProvisioningResponse(provisioning))
+}
+
+// VerifyMFA godoc
+// @Summary Complete a login with a two-factor authentication code
+// @Description Answers the MFA challenge of a login with a TOTP code, or a recovery code, and returns access and refresh tokens. Every code works once. After 5 wrong codes the user has to log in again. Wrong codes count as failed logins. Logins that enrolled also return the recovery codes, once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body MFAVerifyRequest true "MFA challenge and code"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Invalid or expired MFA challenge, or invalid code"
+// @Failure 409 {object} map[string]string "Enrollment has not been started"
+// @Failure 429 {object} map[string]string "Too many failed logins; see the Retry-After header"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/mfa/verify [post]
+func (h *AuthHandler) VerifyMFA(c echo.Context) error {
+	var req MFAVerifyRequest
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "mfaToken and code are required"})
+	}
+
+	result, err := h.authUC.VerifyMFA(c.Request().Context(), req.MFAToken, req.Code, c.RealIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrLoginThrottled):
+			return loginThrottled(c, err)
+		case errors.Is(err, domain.ErrInvalidMFAChallenge), errors.Is(err, domain.ErrInvalidMFACode):
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrMFANotEnrolled), errors.Is(err, domain.ErrMFAAlreadyEnabled):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to login"})
 	}
+	return c.JSON(http.StatusOK, newLoginResponse(result))
+}
+
+// loginThrottled writes the response for a login refused after too many
+// failed ones.
+func loginThrottled(c echo.Context, err error) error {
+	var throttled *domain.LoginThrottledError
+	if errors.As(err, &throttled) {
+		// Whole seconds, rounded up
+		c.Response().Header().Set(echo.HeaderRetryAfter, strconv.FormatInt(int64((throttled.RetryAfter+time.Second-1)/time.Second), 10))
+	}
+	return c.JSON(http.StatusTooManyRequests, map[string]string{"error": domain.ErrLoginThrottled.Error()})
+}
+
+// Refresh godoc
+// @Summary Refresh the tokens
+// @Description Exchanges a refresh token for a new access token and a new refresh token. Each refresh token can be used once; using it again revokes every token issued with it.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body RefreshRequest true "Refresh Token"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Invalid, expired or reused refresh token"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	var req RefreshRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	accessToken, refreshToken, err := h.authUC.Refresh(c.Request().Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidRefreshToken) || errors.Is(err, domain.ErrRefreshTokenReused) {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh token"})
+	}
 
 	return c.JSON(http.StatusOK, AuthResponse{
 		AccessToken:  accessToken,
@@ -101,3 +256,59 @@ func (h *AuthHandler) Login(c echo.Context) error {
 	})
 }
 
+// Logout godoc
+// @Summary Log out
+// @Description Revokes the session of the access token: its refresh token can no longer be used, and its access tokens are rejected from now on.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 204 "Logged out"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+	sessionID, _ := c.Get(string(middleware.SessionContextKey)).(string)
+
+	if err := h.authUC.Logout(c.Request().Context(), user.ID, sessionID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to logout"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UnlockLogin godoc
+// @Summary Unlock the logins of a user
+// @Description Forgets the failed logins of a user, so that a user who is locked out can log in again right away. Failed logins from client IPs still count. (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 204 "Unlocked"
+// @Failure 400 {object} map[string]string "Invalid user ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/users/{id}/login-lockout [delete]
+func (h *AuthHandler) UnlockLogin(c echo.Context) error {
+	actor, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid actor in context"})
+	}
+
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	if err := h.authUC.UnlockLogin(c.Request().Context(), actor.ID, userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to unlock logins"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
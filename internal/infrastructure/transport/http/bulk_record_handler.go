@@ -0,0 +1,289 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxBulkImportSize limits the size of bulk import bodies.
+const maxBulkImportSize = 10 << 20
+
+// bulkCSVColumns are the columns of bulk CSV files. additionalValues is
+// optional on import and holds addresses separated by spaces or semicolons.
+var bulkCSVColumns = []string{"domainName", "type", "value", "additionalValues"}
+
+type BulkRecordHandler struct {
+	bulkUC usecase.BulkRecordUseCase
+}
+
+// BulkRecordRow is one record of a bulk import or export. On import, an
+// absent additionalValues keeps those of an existing record.
+type BulkRecordRow struct {
+	DomainName       string   `json:"domainName"`
+	Type             string   `json:"type"`
+	Value            string   `json:"value"`
+	AdditionalValues []string `json:"additionalValues"`
+}
+
+// BulkImportRowResponse is the outcome of one row. Rows are numbered from 1
+// for JSON arrays and by line for CSV files.
+type BulkImportRowResponse struct {
+	Row        int    `json:"row"`
+	DomainName string `json:"domainName"`
+	Action     string `json:"action,omitempty"`
+	RecordID   int64  `json:"recordId,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// BulkImportResponse is the result of a bulk import.
+type BulkImportResponse struct {
+	Mode      string                  `json:"mode"`
+	Applied   bool                    `json:"applied"`
+	Created   int                     `json:"created"`
+	Updated   int                     `json:"updated"`
+	Unchanged int                     `json:"unchanged"`
+	Failed    int                     `json:"failed"`
+	Rows      []BulkImportRowResponse `json:"rows"`
+}
+
+func toBulkImportResponse(result *domain.RecordImport) BulkImportResponse {
+	rows := make([]BulkImportRowResponse, 0, len(result.Rows))
+	for _, r := range result.Rows {
+		row := BulkImportRowResponse{
+			Row:        r.Row,
+			DomainName: r.DomainName,
+			Action:     string(r.Action),
+			Error:      r.Error,
+		}
+		if r.Record != nil && result.Applied {
+			row.RecordID = r.Record.ID
+		}
+		rows = append(rows, row)
+	}
+	return BulkImportResponse{
+		Mode:      string(result.Mode),
+		Applied:   result.Applied,
+		Created:   result.Created,
+		Updated:   result.Updated,
+		Unchanged: result.Unchanged,
+		Failed:    result.Failed,
+		Rows:      rows,
+	}
+}
+
+// NewBulkRecordHandler creates a new BulkRecordHandler.
+func NewBulkRecordHandler(bulkUC usecase.BulkRecordUseCase) *BulkRecordHandler {
+	return &BulkRecordHandler{bulkUC: bulkUC}
+}
+
+// ImportRecords godoc
+// @Summary Import DNS records in bulk
+// @Description Creates or updates records from a CSV file (Content-Type text/csv, header domainName,type,value[,additionalValues]) or a JSON array. Each row is validated like a single record. In atomic mode (default) nothing is stored if any row is invalid; in best-effort mode invalid rows are skipped. Stored rows are written in one transaction.
+// @Tags dns-records
+// @Accept json,text/csv
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param mode query string false "atomic or best-effort" Enums(atomic, best-effort)
+// @Param records body []BulkRecordRow true "Records"
+// @Success 200 {object} BulkImportResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Conflicting concurrent change"
+// @Failure 413 {object} map[string]string "Import too large"
+// @Failure 422 {object} BulkImportResponse "Invalid rows; nothing was stored"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/import [post]
+func (h *BulkRecordHandler) ImportRecords(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	mode, err := domain.ParseImportMode(c.QueryParam("mode"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxBulkImportSize))
+	if err != nil {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "Import too large"})
+	}
+
+	var rows []domain.RecordImportRow
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		rows, err = parseBulkCSV(body)
+	} else {
+		rows, err = parseBulkJSON(body)
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	result, err := h.bulkUC.ImportRecords(c.Request().Context(), user.ID, rows, mode)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidImportMode):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrTooManyImportRows):
+			return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("At most %d rows can be imported at once", domain.MaxImportRows)})
+		case errors.Is(err, repository.ErrDuplicateDomainName):
+			return c.JSON(http.StatusConflict, map[string]string{"error": "A record was created concurrently, retry the import"})
+		default:
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to import records"})
+		}
+	}
+	if !result.Applied {
+		return c.JSON(http.StatusUnprocessableEntity, toBulkImportResponse(result))
+	}
+	return c.JSON(http.StatusOK, toBulkImportResponse(result))
+}
+
+func parseBulkJSON(body []byte) ([]domain.RecordImportRow, error) {
+	var input []BulkRecordRow
+	if err := json.Unmarshal(body, &input); err != nil {
+		return nil, errors.New("Invalid request payload, expected a JSON array of records")
+	}
+	rows := make([]domain.RecordImportRow, 0, len(input))
+	for i, r := range input {
+		rows = append(rows, domain.RecordImportRow{
+			Row:              i + 1,
+			DomainName:       r.DomainName,
+			Type:             domain.RecordType(strings.ToUpper(strings.TrimSpace(r.Type))),
+			Value:            r.Value,
+			AdditionalValues: r.AdditionalValues,
+		})
+	}
+	return rows, nil
+}
+
+func parseBulkCSV(body []byte) ([]domain.RecordImportRow, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("Invalid CSV: missing header row")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets may start the file with a byte order mark
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range bulkCSVColumns[:3] {
+		if _, ok := columns[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("Invalid CSV: missing column %q", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[strings.ToLower(name)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return unescapeCSVCell(strings.TrimSpace(record[i]))
+	}
+	_, hasAdditional := columns[strings.ToLower("additionalValues")]
+
+	var rows []domain.RecordImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		row := domain.RecordImportRow{
+			Row:        line,
+			DomainName: field(record, "domainName"),
+			Type:       domain.RecordType(strings.ToUpper(field(record, "type"))),
+			Value:      field(record, "value"),
+		}
+		if row.DomainName == "" && row.Type == "" && row.Value == "" {
+			continue // blank spreadsheet row
+		}
+		if hasAdditional {
+			row.AdditionalValues = strings.FieldsFunc(field(record, "additionalValues"), func(r rune) bool {
+				return r == ';' || r == ' '
+			})
+			if row.AdditionalValues == nil {
+				row.AdditionalValues = []string{}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ExportRecords godoc
+// @Summary Export DNS records in bulk
+// @Description Returns all records of the authenticated user as CSV (default) or as a JSON array, in the format accepted by the import. CSV cells that spreadsheets would run as formulas are prefixed with ', which the import removes.
+// @Tags dns-records
+// @Produce json,text/csv
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param format query string false "csv or json" Enums(csv, json)
+// @Success 200 {array} BulkRecordRow
+// @Failure 400 {object} map[string]string "Invalid format"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/export [get]
+func (h *BulkRecordHandler) ExportRecords(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	format := c.QueryParam("format")
+	if format != "" && format != "csv" && format != "json" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid format, use csv or json"})
+	}
+
+	records, err := h.bulkUC.ExportRecords(c.Request().Context(), user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to export records"})
+	}
+
+	rows := make([]BulkRecordRow, 0, len(records))
+	for _, r := range records {
+		rows = append(rows, BulkRecordRow{
+			DomainName:       r.DomainName,
+			Type:             string(r.Type),
+			Value:            r.Value,
+			AdditionalValues: append([]string{}, r.AdditionalValues...),
+		})
+	}
+
+	if format == "json" {
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="dns-records.json"`)
+		return c.JSON(http.StatusOK, rows)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(bulkCSVColumns)
+	for _, r := range rows {
+		w.Write(escapeCSVRow([]string{r.DomainName, r.Type, r.Value, strings.Join(r.AdditionalValues, " ")}))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to export records"})
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="dns-records.csv"`)
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
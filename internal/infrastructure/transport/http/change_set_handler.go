@@ -0,0 +1,176 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ChangeSetHandler struct {
+	changeSetUC usecase.ChangeSetUseCase
+}
+
+// RecordChangeRequest is one operation of a change set. id is required for
+// updates and deletes; domainName, type and value for creates and updates.
+type RecordChangeRequest struct {
+	Op         string `json:"op" example:"update"`
+	ID         int64  `json:"id,omitempty"`
+	DomainName string `json:"domainName,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Value      string `json:"value,omitempty"`
+}
+
+// ChangeSetRequest is a list of operations. Fingerprint, as returned by a
+// preview, makes the apply fail if the records changed in between.
+type ChangeSetRequest struct {
+	Operations  []RecordChangeRequest `json:"operations"`
+	Fingerprint string                `json:"fingerprint,omitempty"`
+}
+
+// ChangeSetItemResponse is the outcome of one operation.
+type ChangeSetItemResponse struct {
+	Index    int                `json:"index"`
+	Op       string             `json:"op"`
+	Record   *DNSRecordResponse `json:"record,omitempty"`
+	Previous *DNSRecordResponse `json:"previous,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// ChangeSetResponse is the diff and validation result of a change set. The
+// change ID is set once it is applied.
+type ChangeSetResponse struct {
+	ChangeID    string                  `json:"changeId,omitempty"`
+	Valid       bool                    `json:"valid"`
+	Applied     bool                    `json:"applied"`
+	Fingerprint string                  `json:"fingerprint"`
+	Items       []ChangeSetItemResponse `json:"items"`
+}
+
+func toChangeSetResponse(cs *domain.ChangeSet) ChangeSetResponse {
+	items := make([]ChangeSetItemResponse, 0, len(cs.Items))
+	for _, item := range cs.Items {
+		res := ChangeSetItemResponse{Index: item.Index, Op: string(item.Op), Error: item.Error}
+		if item.Record != nil {
+			record := toDNSRecordResponse(item.Record)
+			res.Record = &record
+		}
+		if item.Previous != nil {
+			previous := toDNSRecordResponse(item.Previous)
+			res.Previous = &previous
+		}
+		items = append(items, res)
+	}
+	return ChangeSetResponse{
+		ChangeID:    cs.ID,
+		Valid:       cs.Valid,
+		Applied:     cs.Applied,
+		Fingerprint: cs.Fingerprint,
+		Items:       items,
+	}
+}
+
+// NewChangeSetHandler creates a new ChangeSetHandler.
+func NewChangeSetHandler(changeSetUC usecase.ChangeSetUseCase) *ChangeSetHandler {
+	return &ChangeSetHandler{changeSetUC: changeSetUC}
+}
+
+func toRecordChanges(req *ChangeSetRequest) []domain.RecordChange {
+	changes := make([]domain.RecordChange, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		changes = append(changes, domain.RecordChange{
+			Op:         domain.ChangeOp(strings.ToLower(op.Op)),
+			RecordID:   op.ID,
+			DomainName: op.DomainName,
+			Type:       domain.RecordType(strings.ToUpper(op.Type)),
+			Value:      op.Value,
+		})
+	}
+	return changes
+}
+
+// PreviewChangeSet godoc
+// @Summary Preview a change set
+// @Description Computes the diff of a list of create, update and delete operations and validates them against the current records, without changing anything. Records may take names freed by other operations of the same change set.
+// @Tags change-sets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param changeSet body ChangeSetRequest true "Operations"
+// @Success 200 {object} ChangeSetResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /change-sets/preview [post]
+func (h *ChangeSetHandler) PreviewChangeSet(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	var req ChangeSetRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	cs, err := h.changeSetUC.PreviewChangeSet(c.Request().Context(), user.ID, toRecordChanges(&req))
+	if err != nil {
+		return changeSetError(c, err)
+	}
+	return c.JSON(http.StatusOK, toChangeSetResponse(cs))
+}
+
+// ApplyChangeSet godoc
+// @Summary Apply a change set
+// @Description Validates a list of operations and applies all of them in one transaction, or none if any is invalid. With the fingerprint of a preview, the change set is refused if the records it touches changed since. Audit entries of the change set share its changeId.
+// @Tags change-sets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param changeSet body ChangeSetRequest true "Operations"
+// @Success 200 {object} ChangeSetResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Records changed since the preview"
+// @Failure 422 {object} ChangeSetResponse "Invalid operations; nothing was changed"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /change-sets/apply [post]
+func (h *ChangeSetHandler) ApplyChangeSet(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	var req ChangeSetRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	cs, err := h.changeSetUC.ApplyChangeSet(c.Request().Context(), user.ID, toRecordChanges(&req), req.Fingerprint)
+	if err != nil {
+		return changeSetError(c, err)
+	}
+	if !cs.Applied {
+		return c.JSON(http.StatusUnprocessableEntity, toChangeSetResponse(cs))
+	}
+	return c.JSON(http.StatusOK, toChangeSetResponse(cs))
+}
+
+func changeSetError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrEmptyChangeSet), errors.Is(err, domain.ErrChangeSetTooLarge):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrChangeSetStale), errors.Is(err, repository.ErrDuplicateDomainName):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to process change set"})
+	}
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+
+	"internal-dns/internal/util"
+)
+
+// NewIPExtractor returns how the client IP of requests is found, which
+// throttles logins and rate limits requests. Without trusted proxies it is
+// the address of the peer, since headers such as X-Forwarded-For are set by
+// the client. Behind reverse proxies, given as CIDRs or addresses, it is the last address
+// in X-Forwarded-For that is not one of them.
+func NewIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	// Only the configured proxies are trusted, not every private address
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range trustedProxies {
+		ipNet, err := util.ParseNetwork(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIPExtractor(t *testing.T) {
+	// clientIP returns the client IP of a request from peer with the
+	// X-Forwarded-For and X-Real-IP headers set by an attacker
+	clientIP := func(t *testing.T, trustedProxies []string, peer, xff string) string {
+		extractor, err := NewIPExtractor(trustedProxies)
+		require.NoError(t, err)
+		e := echo.New()
+		e.IPExtractor = extractor
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil)
+		req.RemoteAddr = peer + ":52000"
+		req.Header.Set(echo.HeaderXForwardedFor, xff)
+		req.Header.Set(echo.HeaderXRealIP, "203.0.113.99")
+		return e.NewContext(req, httptest.NewRecorder()).RealIP()
+	}
+
+	t.Run("Headers are ignored without trusted proxies", func(t *testing.T) {
+		assert.Equal(t, "198.51.100.7", clientIP(t, nil, "198.51.100.7", "203.0.113.1"))
+		assert.Equal(t, "10.0.0.5", clientIP(t, nil, "10.0.0.5", "203.0.113.1"))
+	})
+
+	t.Run("Trusted proxies forward the client IP", func(t *testing.T) {
+		proxies := []string{"10.0.0.0/24"}
+		assert.Equal(t, "198.51.100.7", clientIP(t, proxies, "10.0.0.5", "198.51.100.7"))
+		// Addresses that the client put in front of its own are skipped
+		assert.Equal(t, "198.51.100.7", clientIP(t, proxies, "10.0.0.5", "203.0.113.1, 198.51.100.7"))
+		assert.Equal(t, "198.51.100.7", clientIP(t, proxies, "10.0.0.5", "203.0.113.1, 198.51.100.7, 10.0.0.6"))
+	})
+
+	t.Run("Other peers cannot forward an IP", func(t *testing.T) {
+		proxies := []string{"10.0.0.0/24"}
+		assert.Equal(t, "198.51.100.7", clientIP(t, proxies, "198.51.100.7", "203.0.113.1"))
+		assert.Equal(t, "192.168.1.9", clientIP(t, proxies, "192.168.1.9", "203.0.113.1"))
+	})
+
+	t.Run("A bare address trusts that proxy only", func(t *testing.T) {
+		proxies := []string{"10.0.0.5"}
+		assert.Equal(t, "203.0.113.1", clientIP(t, proxies, "10.0.0.5", "203.0.113.1"))
+		assert.Equal(t, "10.0.0.6", clientIP(t, proxies, "10.0.0.6", "203.0.113.1"))
+	})
+
+	t.Run("Invalid proxies are rejected", func(t *testing.T) {
+		_, err := NewIPExtractor([]string{"10.0.0.0/33"})
+		assert.Error(t, err)
+		_, err = NewIPExtractor([]string{"proxy.local"})
+		assert.Error(t, err)
+	})
+}
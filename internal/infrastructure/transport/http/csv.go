@@ -0,0 +1,26 @@
+package http
+
+import "strings"
+
+// csvFormulaPrefixes start the cells that spreadsheets evaluate as formulas.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// escapeCSVRow prefixes the cells of an exported row that a spreadsheet
+// would evaluate as formulas with ', which makes them text. The row is
+// changed in place and returned.
+func escapeCSVRow(row []string) []string {
+	for i, cell := range row {
+		if cell != "" && strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+			row[i] = "'" + cell
+		}
+	}
+	return row
+}
+
+// unescapeCSVCell undoes escapeCSVRow for a cell of an imported file.
+func unescapeCSVCell(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeCSVRow(t *testing.T) {
+	row := escapeCSVRow([]string{
+		"=HYPERLINK(\"http://evil.example\")",
+		"+1",
+		"-2+3",
+		"@SUM(A1)",
+		"\t=1",
+		"www.example.com",
+		"",
+		"{\"a\":\"=1\"}",
+	})
+	assert.Equal(t, []string{
+		"'=HYPERLINK(\"http://evil.example\")",
+		"'+1",
+		"'-2+3",
+		"'@SUM(A1)",
+		"'\t=1",
+		"www.example.com",
+		"",
+		"{\"a\":\"=1\"}",
+	}, row)
+
+	// The escaped cells are still quoted as CSV requires
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	require.NoError(t, w.Write(escapeCSVRow([]string{"=1,2", "ok"})))
+	w.Flush()
+	assert.Equal(t, "\"'=1,2\",ok\n", buf.String())
+}
+
+func TestBulkCSV_RoundTripsEscapedCells(t *testing.T) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	require.NoError(t, w.Write(bulkCSVColumns))
+	require.NoError(t, w.Write(escapeCSVRow([]string{"www.example.com", "TXT", "=cmd|' /C calc'!A0", ""})))
+	require.NoError(t, w.Write(escapeCSVRow([]string{"api.example.com", "TXT", "'quoted'", ""})))
+	w.Flush()
+	assert.Contains(t, buf.String(), "'=cmd")
+
+	rows, err := parseBulkCSV(buf.Bytes())
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "=cmd|' /C calc'!A0", rows[0].Value)
+	assert.Equal(t, "'quoted'", rows[1].Value)
+}
@@ -42,6 +42,16 @@ type DNSRecordResponse struct {
 	Value      string    `json:"value"`
 	CreatedAt  time.Time `json:"createdAt"`  // Changed to camelCase
 	UpdatedAt  time.Time `json:"updatedAt"`  // Changed to camelCase
+
+	QueryCount    int64      `json:"queryCount"`
+	LastQueriedAt *time.Time `json:"lastQueriedAt"`
+
+	AdditionalValues []string        `json:"additionalValues"`
+	HealthCheck      *HealthCheckDTO `json:"healthCheck"`
+
+	TrafficPolicy *TrafficPolicyDTO `json:"trafficPolicy"`
+
+	DeletedAt *time.Time `json:"deletedAt,omitempty"` // set for records in the trash
 }
 
 func toDNSRecordResponse(record *domain.DNSRecord) DNSRecordResponse {
@@ -53,6 +63,16 @@ func toDNSRecordResponse(record *domain.DNSRecord) DNSRecordResponse {
 		Value:      record.Value,
 		CreatedAt:  record.CreatedAt,
 		UpdatedAt:  record.UpdatedAt,
+
+		QueryCount:    record.QueryCount,
+		LastQueriedAt: record.LastQueriedAt,
+
+		AdditionalValues: append([]string{}, record.AdditionalValues...),
+		HealthCheck:      toHealthCheckDTO(record.HealthCheck),
+
+		TrafficPolicy: toTrafficPolicyDTO(record.TrafficPolicy),
+
+		DeletedAt: record.DeletedAt,
 	}
 }
 
@@ -63,6 +83,7 @@ func toDNSRecordResponse(record *domain.DNSRecord) DNSRecordResponse {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security APIKeyAuth
 // @Param record body CreateDNSRecordRequest true "DNS Record"
 // @Success 201 {object} DNSRecordResponse
 // @Failure 400 {object} map[string]string "Invalid input"
@@ -86,6 +107,8 @@ func (h *DNSRecordHandler) CreateRecord(c echo.Context) error {
 		switch {
 		case errors.Is(err, repository.ErrDuplicateDomainName):
 			return c.JSON(http.StatusConflict, map[string]string{"error": "Domain name already exists"}) // Refined error message
+		case errors.Is(err, domain.ErrNameOutOfScope):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
 		case errors.Is(err, domain.ErrInvalidDomainName), errors.Is(err, domain.ErrInvalidRecordType), errors.Is(err, domain.ErrInvalidRecordValue):
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		default:
@@ -103,6 +126,7 @@ func (h *DNSRecordHandler) CreateRecord(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security APIKeyAuth
 // @Param id path int true "Record ID"
 // @Success 200 {object} DNSRecordResponse
 // @Failure 400 {object} map[string]string "Invalid ID"
@@ -139,6 +163,7 @@ func (h *DNSRecordHandler) GetRecord(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security APIKeyAuth
 // @Param page query int false "Page number" default(1)
 // @Param pageSize query int false "Page size" default(10)
 // @Success 200 {array} DNSRecordResponse
@@ -182,6 +207,7 @@ func (h *DNSRecordHandler) ListRecords(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security APIKeyAuth
 // @Param id path int true "Record ID"
 // @Param record body UpdateDNSRecordRequest true "Updated DNS Record"
 // @Success 200 {object} DNSRecordResponse
@@ -214,6 +240,8 @@ func (h *DNSRecordHandler) UpdateRecord(c echo.Context) error {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "Record not found or not owned by user"}) // Refined error message
 		case errors.Is(err, repository.ErrDuplicateDomainName):
 			return c.JSON(http.StatusConflict, map[string]string{"error": "Domain name already exists"}) // Refined error message
+		case errors.Is(err, domain.ErrNameOutOfScope):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
 		case errors.Is(err, domain.ErrInvalidDomainName), errors.Is(err, domain.ErrInvalidRecordType), errors.Is(err, domain.ErrInvalidRecordValue):
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		default:
@@ -226,11 +254,12 @@ func (h *DNSRecordHandler) UpdateRecord(c echo.Context) error {
 
 // DeleteRecord godoc
 // @Summary Delete a DNS record
-// @Description Deletes a DNS record by its ID.
+// @Description Moves a DNS record to the trash. It is no longer answered, and its name stays reserved until it is purged.
 // @Tags dns-records
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security APIKeyAuth
 // @Param id path int true "Record ID"
 // @Success 204 "No Content"
 // @Failure 400 {object} map[string]string "Invalid ID"
@@ -254,9 +283,119 @@ func (h *DNSRecordHandler) DeleteRecord(c echo.Context) error {
 		if errors.Is(err, repository.ErrDNSRecordNotFound) {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "Record not found or not owned by user"}) // Refined error message
 		}
+		if errors.Is(err, domain.ErrNameOutOfScope) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete DNS record"}) // Refined error message
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
 
+// ListTrash godoc
+// @Summary List deleted DNS records
+// @Description Retrieves the authenticated user's records in the trash, most recently deleted first. They can be restored until they are purged.
+// @Tags dns-records
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Success 200 {array} DNSRecordResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/trash [get]
+func (h *DNSRecordHandler) ListTrash(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	records, err := h.dnsUC.ListDeletedRecords(c.Request().Context(), user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve deleted DNS records"})
+	}
+
+	resp := make([]DNSRecordResponse, 0, len(records))
+	for _, record := range records {
+		resp = append(resp, toDNSRecordResponse(record))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RestoreRecord godoc
+// @Summary Restore a deleted DNS record
+// @Description Takes a record of the authenticated user out of the trash, so that it is answered again.
+// @Tags dns-records
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "Record ID"
+// @Success 200 {object} DNSRecordResponse
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Record not found in the trash"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/{id}/restore [post]
+func (h *DNSRecordHandler) RestoreRecord(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid record ID"})
+	}
+
+	record, err := h.dnsUC.RestoreRecord(c.Request().Context(), user.ID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrDNSRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Record not found in the trash"})
+		}
+		if errors.Is(err, domain.ErrNameOutOfScope) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to restore DNS record"})
+	}
+
+	return c.JSON(http.StatusOK, toDNSRecordResponse(record))
+}
+
+
+// ListUnusedRecords godoc
+// @Summary List unused DNS records
+// @Description Retrieves all DNS records that have not been queried in the last N days, least recently queried first. Records created within that period are not reported. (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param days query int false "Days without queries" default(30)
+// @Success 200 {array} DNSRecordResponse
+// @Failure 400 {object} map[string]string "Invalid days"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/dns-records/unused [get]
+func (h *DNSRecordHandler) ListUnusedRecords(c echo.Context) error {
+	days := 30
+	if v := c.QueryParam("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "days must be a positive integer"})
+		}
+		days = parsed
+	}
+
+	records, err := h.dnsUC.ListUnusedRecords(c.Request().Context(), time.Duration(days)*24*time.Hour)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list unused DNS records"})
+	}
+
+	resp := make([]DNSRecordResponse, 0, len(records))
+	for _, r := range records {
+		resp = append(resp, toDNSRecordResponse(r))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
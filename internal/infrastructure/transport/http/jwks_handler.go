@@ -0,0 +1,36 @@
+package http
+
+import (
+	"internal-dns/internal/usecase"
+	"internal-dns/pkg/token"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// jwksMaxAge is how long clients may cache the public keys. New keys are
+// published an hour before they sign tokens.
+const jwksMaxAge = "900"
+
+// JWKSHandler publishes the public keys that tokens are signed with.
+type JWKSHandler struct {
+	signingKeyUC usecase.SigningKeyUseCase
+}
+
+// NewJWKSHandler creates a new JWKSHandler.
+func NewJWKSHandler(signingKeyUC usecase.SigningKeyUseCase) *JWKSHandler {
+	return &JWKSHandler{signingKeyUC: signingKeyUC}
+}
+
+// JWKS serves the JSON Web Key Set at /.well-known/jwks.json, outside of the
+// versioned API, so that other services can verify tokens. It includes the
+// next key before it activates and previous keys while their tokens can
+// still be valid.
+func (h *JWKSHandler) JWKS(c echo.Context) error {
+	set, err := token.NewJWKS(h.signingKeyUC.PublicKeys())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to encode keys"})
+	}
+	c.Response().Header().Set("Cache-Control", "public, max-age="+jwksMaxAge)
+	return c.JSON(http.StatusOK, set)
+}
@@ -0,0 +1,243 @@
+package http
+
+import (
+	"errors"
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MFAStatusResponse describes the two-factor authentication of the user.
+type MFAStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"` // for admins, who cannot disable it
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+// TOTPProvisioningResponse is added to an authenticator app by scanning
+// otpauthUri as a QR code or typing in secret.
+type TOTPProvisioningResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+func newTOTPProvisioningResponse(p *domain.TOTPProvisioning) TOTPProvisioningResponse {
+	return TOTPProvisioningResponse{Secret: p.Secret, OTPAuthURI: p.URI}
+}
+
+// MFAHandler handles the two-factor authentication of users.
+type MFAHandler struct {
+	mfaUC usecase.MFAUseCase
+}
+
+// NewMFAHandler creates a new MFAHandler.
+func NewMFAHandler(mfaUC usecase.MFAUseCase) *MFAHandler {
+	return &MFAHandler{mfaUC: mfaUC}
+}
+
+// mfaError writes the response for the errors of the MFA use case.
+func mfaError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidMFACode):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrMFARequired):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrMFANotEnrolled), errors.Is(err, domain.ErrMFAAlreadyEnabled):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
+
+// GetStatus godoc
+// @Summary Get my two-factor authentication
+// @Description Returns whether the authenticated user has enabled two-factor authentication, whether it is required (for admins), and how many unused recovery codes they have.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} MFAStatusResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /me/mfa [get]
+func (h *MFAHandler) GetStatus(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	status, err := h.mfaUC.Status(c.Request().Context(), user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve two-factor authentication"})
+	}
+	return c.JSON(http.StatusOK, MFAStatusResponse{
+		Enabled:           status.Enabled,
+		Required:          status.Required,
+		RecoveryCodesLeft: status.RecoveryCodesLeft,
+	})
+}
+
+// Enroll godoc
+// @Summary Start enrolling in two-factor authentication
+// @Description Creates a TOTP secret for the authenticated user to add to an authenticator app, replacing one that has not been confirmed. Two-factor authentication is enabled by confirming a code of the secret.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} TOTPProvisioningResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Already enabled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /me/mfa/enroll [post]
+func (h *MFAHandler) Enroll(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	provisioning, err := h.mfaUC.BeginEnrollment(c.Request().Context(), user.ID)
+	if err != nil {
+		return mfaError(c, err, "Failed to enroll in two-factor authentication")
+	}
+	return c.JSON(http.StatusOK, newTOTPProvisioningResponse(provisioning))
+}
+
+// Confirm godoc
+// @Summary Enable two-factor authentication
+// @Description Enables two-factor authentication with a code of the secret from /me/mfa/enroll and returns 10 recovery codes, which are shown only once. Each recovery code can replace a TOTP code once, e.g. when the device is lost.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "TOTP code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized or invalid code"
+// @Failure 409 {object} map[string]string "Not enrolled or already enabled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /me/mfa/confirm [post]
+func (h *MFAHandler) Confirm(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code is required"})
+	}
+
+	codes, err := h.mfaUC.ConfirmEnrollment(c.Request().Context(), user.ID, req.Code)
+	if err != nil {
+		return mfaError(c, err, "Failed to enable two-factor authentication")
+	}
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable godoc
+// @Summary Disable two-factor authentication
+// @Description Disables the two-factor authentication of the authenticated user after checking a TOTP or recovery code. Admins cannot disable it.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "TOTP or recovery code"
+// @Success 204 "Disabled"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized or invalid code"
+// @Failure 403 {object} map[string]string "Required for admins"
+// @Failure 409 {object} map[string]string "Not enabled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /me/mfa/disable [post]
+func (h *MFAHandler) Disable(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code is required"})
+	}
+
+	if err := h.mfaUC.Disable(c.Request().Context(), user.ID, req.Code); err != nil {
+		return mfaError(c, err, "Failed to disable two-factor authentication")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Replace my recovery codes
+// @Description Replaces the recovery codes of the authenticated user with 10 new ones after checking a TOTP or recovery code. The old codes stop working.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized or invalid code"
+// @Failure 409 {object} map[string]string "Not enabled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /me/mfa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code is required"})
+	}
+
+	codes, err := h.mfaUC.RegenerateRecoveryCodes(c.Request().Context(), user.ID, req.Code)
+	if err != nil {
+		return mfaError(c, err, "Failed to replace recovery codes")
+	}
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Reset godoc
+// @Summary Reset the two-factor authentication of a user
+// @Description Disables the two-factor authentication of a user who lost their device and their recovery codes. Admins have to enroll again at their next login. (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 204 "Reset"
+// @Failure 400 {object} map[string]string "Invalid user ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "User not found or not enrolled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/users/{id}/mfa [delete]
+func (h *MFAHandler) Reset(c echo.Context) error {
+	actor, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid actor in context"})
+	}
+
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	if err := h.mfaUC.Reset(c.Request().Context(), actor.ID, userID); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUserNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+		case errors.Is(err, domain.ErrMFANotEnrolled):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to reset two-factor authentication"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
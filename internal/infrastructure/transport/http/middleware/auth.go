@@ -1,12 +1,16 @@
 package middleware
 
 import (
+	"errors"
 	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
 	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
 	"internal-dns/pkg/token"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -15,21 +19,63 @@ type contextKey string
 
 const UserContextKey = contextKey("user")
 
+// SessionContextKey holds the ID of the session of the access token.
+const SessionContextKey = contextKey("session")
+
+// APIKeyContextKey holds the API key of requests authenticated with one.
+const APIKeyContextKey = contextKey("api_key")
+
+// APIKeyHeader carries the API key of a service account.
+const APIKeyHeader = "X-API-Key"
+
 type JWTMiddleware struct {
 	tokenGenerator token.Generator
 	userRepo       repository.UserRepository
+	denylist       cache.SessionDenylist
+	apiKeyUC       usecase.APIKeyUseCase
 }
 
-func NewJWTMiddleware(tg token.Generator, ur repository.UserRepository) *JWTMiddleware {
+func NewJWTMiddleware(tg token.Generator, ur repository.UserRepository, denylist cache.SessionDenylist, apiKeyUC usecase.APIKeyUseCase) *JWTMiddleware {
 	return &JWTMiddleware{
 		tokenGenerator: tg,
 		userRepo:       ur,
+		denylist:       denylist,
+		apiKeyUC:       apiKeyUC,
 	}
 }
 
+// RecordScope is the scope that API keys need for a request to records:
+// records:read to read them, records:write for everything else.
+func RecordScope(c echo.Context) domain.APIKeyScope {
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead:
+		return domain.ScopeRecordsRead
+	}
+	return domain.ScopeRecordsWrite
+}
+
+// Auth authenticates requests with an access token. API keys are refused.
 func (m *JWTMiddleware) Auth(requiredRoles ...domain.UserRole) echo.MiddlewareFunc {
+	return m.auth(nil, requiredRoles)
+}
+
+// AuthOrAPIKey is Auth that also accepts the API key of a service account
+// in the X-API-Key header, if the key was granted the scope that scope
+// returns for the request.
+func (m *JWTMiddleware) AuthOrAPIKey(scope func(echo.Context) domain.APIKeyScope, requiredRoles ...domain.UserRole) echo.MiddlewareFunc {
+	return m.auth(scope, requiredRoles)
+}
+
+func (m *JWTMiddleware) auth(scope func(echo.Context) domain.APIKeyScope, requiredRoles []domain.UserRole) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			if key := c.Request().Header.Get(APIKeyHeader); key != "" && c.Request().Header.Get("Authorization") == "" {
+				if scope == nil {
+					return c.JSON(http.StatusForbidden, map[string]string{"error": "API keys cannot be used for this endpoint"})
+				}
+				return m.authAPIKey(c, next, key, scope(c), requiredRoles)
+			}
+
 			authHeader := c.Request().Header.Get("Authorization")
 			if authHeader == "" {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header required"})
@@ -46,6 +92,20 @@ func (m *JWTMiddleware) Auth(requiredRoles ...domain.UserRole) echo.MiddlewareFu
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
 			}
 
+			// Check if the token was revoked, e.g. by logging out
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			revoked, err := m.denylist.IsRevoked(c.Request().Context(), claims.SessionID, claims.UserID, issuedAt)
+			if err != nil {
+				log.Printf("Failed to check token revocation: %v", err)
+				return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Failed to check token revocation"})
+			}
+			if revoked {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Token has been revoked"})
+			}
+
 			// Check if user exists and is enabled
 			user, err := m.userRepo.FindByID(c.Request().Context(), claims.UserID)
 			if err != nil || !user.IsEnabled {
@@ -53,17 +113,8 @@ func (m *JWTMiddleware) Auth(requiredRoles ...domain.UserRole) echo.MiddlewareFu
 			}
 
 			// Check role
-			if len(requiredRoles) > 0 {
-				isAllowed := false
-				for _, role := range requiredRoles {
-					if user.Role == role {
-						isAllowed = true
-						break
-					}
-				}
-				if !isAllowed {
-					return c.JSON(http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
-				}
+			if !hasRole(user, requiredRoles) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
 			}
 
 			log.Println("\n\nUser from context: ", user.ID, user.Username, user.Role, user.IsEnabled)
@@ -72,8 +123,45 @@ func (m *JWTMiddleware) Auth(requiredRoles ...domain.UserRole) echo.MiddlewareFu
 			// ctx := context.WithValue(c.Request().Context(), UserContextKey, user)
 			// c.SetRequest(c.Request().WithContext(ctx))
 			c.Set(string(UserContextKey), user)
+			c.Set(string(SessionContextKey), claims.SessionID)
 
 			return next(c)
 		}
 	}
 }
+
+func (m *JWTMiddleware) authAPIKey(c echo.Context, next echo.HandlerFunc, key string, scope domain.APIKeyScope, requiredRoles []domain.UserRole) error {
+	user, apiKey, err := m.apiKeyUC.Authenticate(c.Request().Context(), key)
+	if errors.Is(err, domain.ErrInvalidAPIKey) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid, expired or revoked API key"})
+	}
+	if err != nil {
+		log.Printf("Failed to check API key: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check API key"})
+	}
+
+	if !apiKey.HasScope(scope) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "API key lacks the " + string(scope) + " scope"})
+	}
+	if !hasRole(user, requiredRoles) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
+	}
+
+	// Services check the names the key writes against its suffixes
+	c.SetRequest(c.Request().WithContext(domain.ContextWithAPIKey(c.Request().Context(), apiKey)))
+	c.Set(string(UserContextKey), user)
+	c.Set(string(APIKeyContextKey), apiKey)
+	return next(c)
+}
+
+func hasRole(user *domain.User, roles []domain.UserRole) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		if user.Role == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAPIKeyUseCase struct {
+	mock.Mock
+}
+
+func (m *mockAPIKeyUseCase) CreateServiceAccount(ctx context.Context, actorID int64, username string) (*domain.User, error) {
+	panic("not used")
+}
+
+func (m *mockAPIKeyUseCase) CreateKey(ctx context.Context, actorID, userID int64, name string, scopes []domain.APIKeyScope, suffixes []string, expiresAt *time.Time) (*domain.APIKey, string, error) {
+	panic("not used")
+}
+
+func (m *mockAPIKeyUseCase) ListKeys(ctx context.Context, userID int64) ([]*domain.APIKey, error) {
+	panic("not used")
+}
+
+func (m *mockAPIKeyUseCase) RotateKey(ctx context.Context, actorID, userID, keyID int64) (*domain.APIKey, string, error) {
+	panic("not used")
+}
+
+func (m *mockAPIKeyUseCase) RevokeKey(ctx context.Context, actorID, userID, keyID int64) error {
+	panic("not used")
+}
+
+func (m *mockAPIKeyUseCase) Authenticate(ctx context.Context, key string) (*domain.User, *domain.APIKey, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*domain.User), args.Get(1).(*domain.APIKey), args.Error(2)
+}
+
+func TestJWTMiddleware_APIKey(t *testing.T) {
+	account := &domain.User{ID: 2, Role: domain.RoleUser, IsEnabled: true, IsServiceAccount: true}
+	readKey := &domain.APIKey{ID: 5, UserID: 2, Scopes: []domain.APIKeyScope{domain.ScopeRecordsRead}}
+	apiKeys := new(mockAPIKeyUseCase)
+	apiKeys.On("Authenticate", mock.Anything, "idns_read_secret").Return(account, readKey, nil)
+	apiKeys.On("Authenticate", mock.Anything, "idns_bad_secret").Return(nil, nil, domain.ErrInvalidAPIKey)
+	m := NewJWTMiddleware(nil, nil, nil, apiKeys)
+
+	handler := func(c echo.Context) error {
+		assert.Same(t, account, c.Get(string(UserContextKey)))
+		assert.Same(t, readKey, domain.APIKeyFromContext(c.Request().Context()))
+		return c.NoContent(http.StatusOK)
+	}
+	serve := func(mw echo.MiddlewareFunc, method, key string) int {
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		if err := mw(handler)(c); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code
+	}
+	records := m.AuthOrAPIKey(RecordScope, domain.RoleUser, domain.RoleAdmin)
+
+	assert.Equal(t, http.StatusOK, serve(records, http.MethodGet, "idns_read_secret"))
+	assert.Equal(t, http.StatusForbidden, serve(records, http.MethodPost, "idns_read_secret"), "lacks records:write")
+	assert.Equal(t, http.StatusUnauthorized, serve(records, http.MethodGet, "idns_bad_secret"))
+	assert.Equal(t, http.StatusForbidden, serve(m.AuthOrAPIKey(RecordScope, domain.RoleAdmin), http.MethodGet, "idns_read_secret"))
+	assert.Equal(t, http.StatusForbidden, serve(m.Auth(domain.RoleUser), http.MethodGet, "idns_read_secret"), "JWT only")
+}
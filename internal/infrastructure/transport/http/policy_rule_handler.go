@@ -0,0 +1,239 @@
+package http
+
+import (
+	"errors"
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PolicyRuleRequest defines the payload for creating or updating a response policy rule.
+type PolicyRuleRequest struct {
+	Pattern    string `json:"pattern"`
+	Match      string `json:"match"`  // exact, suffix or wildcard
+	Action     string `json:"action"` // NXDOMAIN, NODATA, REDIRECT or PASSTHRU
+	RedirectIP string `json:"redirectIp"`
+	Comment    string `json:"comment"`
+}
+
+// PolicyRuleResponse is a DTO for response policy rules sent to clients.
+type PolicyRuleResponse struct {
+	ID         int64     `json:"id"`
+	Pattern    string    `json:"pattern"`
+	Match      string    `json:"match"`
+	Action     string    `json:"action"`
+	RedirectIP string    `json:"redirectIp,omitempty"`
+	Comment    string    `json:"comment"`
+	CreatedBy  int64     `json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func toPolicyRuleResponse(rule *domain.PolicyRule) PolicyRuleResponse {
+	return PolicyRuleResponse{
+		ID:         rule.ID,
+		Pattern:    rule.Pattern,
+		Match:      string(rule.Match),
+		Action:     string(rule.Action),
+		RedirectIP: rule.RedirectIP,
+		Comment:    rule.Comment,
+		CreatedBy:  rule.CreatedBy,
+		CreatedAt:  rule.CreatedAt,
+		UpdatedAt:  rule.UpdatedAt,
+	}
+}
+
+// PolicyRuleHandler handles response policy rule HTTP requests.
+type PolicyRuleHandler struct {
+	policyUC usecase.PolicyRuleUseCase
+}
+
+// NewPolicyRuleHandler creates a new PolicyRuleHandler.
+func NewPolicyRuleHandler(policyUC usecase.PolicyRuleUseCase) *PolicyRuleHandler {
+	return &PolicyRuleHandler{policyUC: policyUC}
+}
+
+// ListRules godoc
+// @Summary List response policy rules
+// @Description Retrieves all admin-managed response policy rules. Rules loaded from RPZ zone files are not included. (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} PolicyRuleResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/policy-rules [get]
+func (h *PolicyRuleHandler) ListRules(c echo.Context) error {
+	rules, err := h.policyUC.ListRules(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list policy rules"})
+	}
+
+	resp := make([]PolicyRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		resp = append(resp, toPolicyRuleResponse(rule))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetRule godoc
+// @Summary Get a response policy rule
+// @Description Retrieves a single response policy rule by its ID. (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Rule ID"
+// @Success 200 {object} PolicyRuleResponse
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Rule not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/policy-rules/{id} [get]
+func (h *PolicyRuleHandler) GetRule(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid rule ID"})
+	}
+
+	rule, err := h.policyUC.GetRule(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrPolicyRuleNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Policy rule not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve policy rule"})
+	}
+
+	return c.JSON(http.StatusOK, toPolicyRuleResponse(rule))
+}
+
+// CreateRule godoc
+// @Summary Create a response policy rule
+// @Description Creates a rule that rewrites answers for matching names. DNS servers pick up changes on their next refresh. (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param rule body PolicyRuleRequest true "Policy Rule"
+// @Success 201 {object} PolicyRuleResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 409 {object} map[string]string "Duplicate rule"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/policy-rules [post]
+func (h *PolicyRuleHandler) CreateRule(c echo.Context) error {
+	actor, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid actor in context"})
+	}
+
+	var req PolicyRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	rule, err := h.policyUC.CreateRule(c.Request().Context(), actor.ID, req.Pattern, domain.PolicyMatch(req.Match), domain.PolicyAction(req.Action), req.RedirectIP, req.Comment)
+	if err != nil {
+		return policyRuleError(c, err, "Failed to create policy rule")
+	}
+
+	return c.JSON(http.StatusCreated, toPolicyRuleResponse(rule))
+}
+
+// UpdateRule godoc
+// @Summary Update a response policy rule
+// @Description Replaces an existing response policy rule. (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Rule ID"
+// @Param rule body PolicyRuleRequest true "Updated Policy Rule"
+// @Success 200 {object} PolicyRuleResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Rule not found"
+// @Failure 409 {object} map[string]string "Duplicate rule"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/policy-rules/{id} [put]
+func (h *PolicyRuleHandler) UpdateRule(c echo.Context) error {
+	actor, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid actor in context"})
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid rule ID"})
+	}
+
+	var req PolicyRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	rule, err := h.policyUC.UpdateRule(c.Request().Context(), actor.ID, id, req.Pattern, domain.PolicyMatch(req.Match), domain.PolicyAction(req.Action), req.RedirectIP, req.Comment)
+	if err != nil {
+		return policyRuleError(c, err, "Failed to update policy rule")
+	}
+
+	return c.JSON(http.StatusOK, toPolicyRuleResponse(rule))
+}
+
+// DeleteRule godoc
+// @Summary Delete a response policy rule
+// @Description Deletes a response policy rule by its ID. (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Rule ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Rule not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/policy-rules/{id} [delete]
+func (h *PolicyRuleHandler) DeleteRule(c echo.Context) error {
+	actor, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid actor in context"})
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid rule ID"})
+	}
+
+	if err := h.policyUC.DeleteRule(c.Request().Context(), actor.ID, id); err != nil {
+		return policyRuleError(c, err, "Failed to delete policy rule")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func policyRuleError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, repository.ErrPolicyRuleNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Policy rule not found"})
+	case errors.Is(err, repository.ErrDuplicatePolicyRule):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "A rule for this pattern and match type already exists"})
+	case errors.Is(err, domain.ErrInvalidPolicyPattern), errors.Is(err, domain.ErrInvalidPolicyMatch),
+		errors.Is(err, domain.ErrInvalidPolicyAction), errors.Is(err, domain.ErrInvalidPolicyRedirect):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fallback})
+	}
+}
@@ -0,0 +1,206 @@
+package http
+
+import (
+	"errors"
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HealthCheckDTO describes how the addresses of an A record are probed.
+type HealthCheckDTO struct {
+	Type               string `json:"type"` // tcp or http
+	Port               int    `json:"port"`
+	Path               string `json:"path,omitempty"`
+	ExpectedStatus     int    `json:"expectedStatus,omitempty"`
+	IntervalSeconds    int    `json:"intervalSeconds"`
+	TimeoutSeconds     int    `json:"timeoutSeconds"`
+	HealthyThreshold   int    `json:"healthyThreshold"`
+	UnhealthyThreshold int    `json:"unhealthyThreshold"`
+}
+
+func toHealthCheckDTO(hc *domain.HealthCheck) *HealthCheckDTO {
+	if hc == nil {
+		return nil
+	}
+	return &HealthCheckDTO{
+		Type:               string(hc.Type),
+		Port:               hc.Port,
+		Path:               hc.Path,
+		ExpectedStatus:     hc.ExpectedStatus,
+		IntervalSeconds:    hc.IntervalSeconds,
+		TimeoutSeconds:     hc.TimeoutSeconds,
+		HealthyThreshold:   hc.HealthyThreshold,
+		UnhealthyThreshold: hc.UnhealthyThreshold,
+	}
+}
+
+// SetHealthCheckRequest defines the payload for configuring failover of an A record.
+type SetHealthCheckRequest struct {
+	AdditionalValues []string        `json:"additionalValues"` // addresses answered alongside the record's value
+	HealthCheck      *HealthCheckDTO `json:"healthCheck"`      // null disables health checking
+}
+
+// TargetHealthResponse is the current health of one address of a record.
+type TargetHealthResponse struct {
+	Target               string    `json:"target"`
+	Healthy              bool      `json:"healthy"`
+	ConsecutiveFailures  int       `json:"consecutiveFailures"`
+	ConsecutiveSuccesses int       `json:"consecutiveSuccesses"`
+	LastError            string    `json:"lastError,omitempty"`
+	LastCheckedAt        time.Time `json:"lastCheckedAt"`
+	LastChangedAt        time.Time `json:"lastChangedAt"`
+}
+
+// HealthEventResponse is a change of a target between healthy and unhealthy.
+type HealthEventResponse struct {
+	Target    string    `json:"target"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RecordHealthResponse is the health of all addresses of a record.
+type RecordHealthResponse struct {
+	Record  DNSRecordResponse      `json:"record"`
+	Targets []TargetHealthResponse `json:"targets"`
+	History []HealthEventResponse  `json:"history"`
+}
+
+// RecordHealthHandler handles health check HTTP requests for DNS records.
+type RecordHealthHandler struct {
+	healthUC usecase.RecordHealthUseCase
+}
+
+// NewRecordHealthHandler creates a new RecordHealthHandler.
+func NewRecordHealthHandler(healthUC usecase.RecordHealthUseCase) *RecordHealthHandler {
+	return &RecordHealthHandler{healthUC: healthUC}
+}
+
+// SetHealthCheck godoc
+// @Summary Configure failover for a DNS record
+// @Description Sets the additional addresses of an A record and how they are health checked. Unhealthy addresses are left out of answers; if all are down, all are answered.
+// @Tags dns-records
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "Record ID"
+// @Param healthCheck body SetHealthCheckRequest true "Failover settings"
+// @Success 200 {object} DNSRecordResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Record not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/{id}/health-check [put]
+func (h *RecordHealthHandler) SetHealthCheck(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid record ID"})
+	}
+
+	var req SetHealthCheckRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	var check *domain.HealthCheck
+	if req.HealthCheck != nil {
+		hc := req.HealthCheck
+		check, err = domain.NewHealthCheck(domain.HealthCheckType(hc.Type), hc.Port, hc.Path, hc.ExpectedStatus,
+			hc.IntervalSeconds, hc.TimeoutSeconds, hc.HealthyThreshold, hc.UnhealthyThreshold)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	}
+
+	record, err := h.healthUC.SetHealthCheck(c.Request().Context(), user.ID, id, req.AdditionalValues, check)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDNSRecordNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Record not found or not owned by user"})
+		case errors.Is(err, domain.ErrNameOutOfScope):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrHealthCheckRequiresA), errors.Is(err, domain.ErrInvalidRecordValue):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update health check"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, toDNSRecordResponse(record))
+}
+
+// GetHealth godoc
+// @Summary Get the health of a DNS record
+// @Description Retrieves the current health of each address of a record and its recent health changes, newest first.
+// @Tags dns-records
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "Record ID"
+// @Param limit query int false "Number of health changes to return" default(50)
+// @Success 200 {object} RecordHealthResponse
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Record not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/{id}/health [get]
+func (h *RecordHealthHandler) GetHealth(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid record ID"})
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	health, err := h.healthUC.GetRecordHealth(c.Request().Context(), user.ID, id, limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrDNSRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Record not found or not owned by user"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve record health"})
+	}
+
+	resp := RecordHealthResponse{
+		Record:  toDNSRecordResponse(health.Record),
+		Targets: make([]TargetHealthResponse, 0, len(health.Targets)),
+		History: make([]HealthEventResponse, 0, len(health.History)),
+	}
+	for _, t := range health.Targets {
+		resp.Targets = append(resp.Targets, TargetHealthResponse{
+			Target:               t.Target,
+			Healthy:              t.Healthy,
+			ConsecutiveFailures:  t.ConsecutiveFailures,
+			ConsecutiveSuccesses: t.ConsecutiveSuccesses,
+			LastError:            t.LastError,
+			LastCheckedAt:        t.LastCheckedAt,
+			LastChangedAt:        t.LastChangedAt,
+		})
+	}
+	for _, e := range health.History {
+		resp.History = append(resp.History, HealthEventResponse{
+			Target:    e.Target,
+			Healthy:   e.Healthy,
+			Error:     e.Error,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
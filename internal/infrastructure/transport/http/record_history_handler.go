@@ -0,0 +1,146 @@
+package http
+
+import (
+	"errors"
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RecordVersionResponse is one version of a DNS record. Record is null for
+// the version that deleted it.
+type RecordVersionResponse struct {
+	Version   int                `json:"version"`
+	Action    string             `json:"action"`
+	UserID    int64              `json:"userId"`
+	Username  string             `json:"username,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+	ChangeID  string             `json:"changeId,omitempty"`
+	Deleted   bool               `json:"deleted"`
+	Record    *DNSRecordResponse `json:"record"`
+}
+
+// RecordHistoryHandler handles version history HTTP requests for DNS records.
+type RecordHistoryHandler struct {
+	historyUC usecase.RecordHistoryUseCase
+}
+
+// NewRecordHistoryHandler creates a new RecordHistoryHandler.
+func NewRecordHistoryHandler(historyUC usecase.RecordHistoryUseCase) *RecordHistoryHandler {
+	return &RecordHistoryHandler{historyUC: historyUC}
+}
+
+// GetHistory godoc
+// @Summary Get the version history of a DNS record
+// @Description Lists every version of a record, oldest first, with who changed it and when. The history of deleted records remains available.
+// @Tags dns-records
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "Record ID"
+// @Success 200 {array} RecordVersionResponse
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Record not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/{id}/history [get]
+func (h *RecordHistoryHandler) GetHistory(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid record ID"})
+	}
+
+	versions, err := h.historyUC.GetRecordHistory(c.Request().Context(), user.ID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrDNSRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Record not found or not owned by user"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve record history"})
+	}
+
+	resp := make([]RecordVersionResponse, 0, len(versions))
+	for _, v := range versions {
+		res := RecordVersionResponse{
+			Version:   v.Version,
+			Action:    string(v.Action),
+			UserID:    v.UserID,
+			Username:  v.Username,
+			Timestamp: v.Timestamp,
+			ChangeID:  v.ChangeID,
+			Deleted:   v.Record == nil,
+		}
+		if v.Record != nil {
+			record := toDNSRecordResponse(v.Record)
+			res.Record = &record
+		}
+		resp = append(resp, res)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Rollback godoc
+// @Summary Roll a DNS record back to a previous version
+// @Description Restores the name, type, value, additional values, health check and traffic policy of a version, validated like an update. Deleted records are restored under their ID. The rollback becomes a new version.
+// @Tags dns-records
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "Record ID"
+// @Param version query int true "Version to restore"
+// @Success 200 {object} DNSRecordResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Record or version not found"
+// @Failure 409 {object} map[string]string "Domain name already exists"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/{id}/rollback [post]
+func (h *RecordHistoryHandler) Rollback(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid record ID"})
+	}
+	version, err := strconv.Atoi(c.QueryParam("version"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid version"})
+	}
+
+	record, err := h.historyUC.RollbackRecord(c.Request().Context(), user.ID, id, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDNSRecordNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Record not found or not owned by user"})
+		case errors.Is(err, domain.ErrRecordVersionNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, repository.ErrDuplicateDomainName):
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Domain name already exists"})
+		case errors.Is(err, domain.ErrNameOutOfScope):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrRollbackToDeleted), errors.Is(err, domain.ErrInvalidDomainName),
+			errors.Is(err, domain.ErrInvalidRecordType), errors.Is(err, domain.ErrInvalidRecordValue),
+			errors.Is(err, domain.ErrHealthCheckRequiresA), errors.Is(err, domain.ErrTrafficPolicyRequiresA):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to roll back record"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, toDNSRecordResponse(record))
+}
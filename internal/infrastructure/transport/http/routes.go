@@ -3,6 +3,7 @@ package http
 import (
 	"internal-dns/configs"
 	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
 	"internal-dns/internal/infrastructure/transport/http/middleware"
 	"internal-dns/internal/repository"
 	"internal-dns/internal/usecase"
@@ -15,7 +16,7 @@ import (
 	_ "internal-dns/docs" // docs is generated by Swag CLI
 )
 
-func RegisterRoutes(e *echo.Echo, cfg *configs.Config, authUC usecase.AuthUseCase, userUC usecase.UserUseCase, dnsUC usecase.DNSRecordUseCase, userRepo repository.UserRepository, tokenGenerator token.Generator) {
+func RegisterRoutes(e *echo.Echo, cfg *configs.Config, authUC usecase.AuthUseCase, userUC usecase.UserUseCase, dnsUC usecase.DNSRecordUseCase, policyUC usecase.PolicyRuleUseCase, healthUC usecase.RecordHealthUseCase, trafficUC usecase.TrafficPolicyUseCase, zoneUC usecase.ZoneUseCase, bulkUC usecase.BulkRecordUseCase, changeSetUC usecase.ChangeSetUseCase, historyUC usecase.RecordHistoryUseCase, auditLogUC usecase.AuditLogUseCase, auditChainUC usecase.AuditChainUseCase, auditArchiveUC usecase.AuditArchiveUseCase, signingKeyUC usecase.SigningKeyUseCase, apiKeyUC usecase.APIKeyUseCase, ssoUC usecase.SSOUseCase, mfaUC usecase.MFAUseCase, userRepo repository.UserRepository, tokenGenerator token.Generator, sessionDenylist cache.SessionDenylist) {
 	// Prometheus Middleware
 	p := prometheus.NewPrometheus("echo", nil)
 	p.Use(e)
@@ -27,6 +28,10 @@ func RegisterRoutes(e *echo.Echo, cfg *configs.Config, authUC usecase.AuthUseCas
 	// Swagger
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
+	// Public keys of the token signing keys
+	jwksHandler := NewJWKSHandler(signingKeyUC)
+	e.GET("/.well-known/jwks.json", jwksHandler.JWKS)
+
 	// Rate Limiter
 	rateLimiterConfig := middleware.RateLimiterConfig{
 		Enabled: cfg.RATE_LIMITER_ENABLED,
@@ -38,11 +43,22 @@ func RegisterRoutes(e *echo.Echo, cfg *configs.Config, authUC usecase.AuthUseCas
 
 	// Handlers
 	authHandler := NewAuthHandler(authUC)
+	sessionHandler := NewSessionHandler(authUC)
 	userHandler := NewUserHandler(userUC)
 	dnsRecordHandler := NewDNSRecordHandler(dnsUC) // Renamed for consistency
+	policyRuleHandler := NewPolicyRuleHandler(policyUC)
+	recordHealthHandler := NewRecordHealthHandler(healthUC)
+	trafficPolicyHandler := NewTrafficPolicyHandler(trafficUC)
+	zoneHandler := NewZoneHandler(zoneUC)
+	bulkRecordHandler := NewBulkRecordHandler(bulkUC)
+	changeSetHandler := NewChangeSetHandler(changeSetUC)
+	recordHistoryHandler := NewRecordHistoryHandler(historyUC)
+	auditLogHandler := NewAuditLogHandler(auditLogUC, auditChainUC, auditArchiveUC)
+	apiKeyHandler := NewAPIKeyHandler(apiKeyUC)
+	mfaHandler := NewMFAHandler(mfaUC)
 
 	// JWT Middleware
-	jwtMiddleware := middleware.NewJWTMiddleware(tokenGenerator, userRepo)
+	jwtMiddleware := middleware.NewJWTMiddleware(tokenGenerator, userRepo, sessionDenylist, apiKeyUC)
 
 	// Group routes
 	v1 := e.Group("/api/v1")
@@ -52,6 +68,17 @@ func RegisterRoutes(e *echo.Echo, cfg *configs.Config, authUC usecase.AuthUseCas
 	{
 		authGroup.POST("/register", authHandler.Register)
 		authGroup.POST("/login", authHandler.Login)
+		authGroup.POST("/mfa/enroll", authHandler.EnrollMFA)
+		authGroup.POST("/mfa/verify", authHandler.VerifyMFA)
+		authGroup.POST("/refresh", authHandler.Refresh)
+		authGroup.POST("/logout", authHandler.Logout, jwtMiddleware.Auth(domain.RoleUser, domain.RoleAdmin))
+	}
+
+	// Single sign-on routes, if an identity provider is configured
+	if ssoUC != nil {
+		ssoHandler := NewSSOHandler(ssoUC)
+		authGroup.GET("/oidc/login", ssoHandler.Login)
+		authGroup.GET("/oidc/callback", ssoHandler.Callback)
 	}
 
 	// Admin routes
@@ -61,17 +88,77 @@ func RegisterRoutes(e *echo.Echo, cfg *configs.Config, authUC usecase.AuthUseCas
 		adminGroup.GET("/users", userHandler.ListUsers)
 		adminGroup.GET("/users/:id", userHandler.GetUser)
 		adminGroup.PUT("/users/:id/status", userHandler.UpdateUserStatus) // Changed PATCH to PUT
+		adminGroup.DELETE("/users/:id/sessions", sessionHandler.RevokeAllSessions)
+		adminGroup.DELETE("/users/:id/mfa", mfaHandler.Reset)
+		adminGroup.DELETE("/users/:id/login-lockout", authHandler.UnlockLogin)
+		adminGroup.POST("/service-accounts", apiKeyHandler.CreateServiceAccount)
+		adminGroup.GET("/service-accounts/:id/keys", apiKeyHandler.ListAPIKeys)
+		adminGroup.POST("/service-accounts/:id/keys", apiKeyHandler.CreateAPIKey)
+		adminGroup.POST("/service-accounts/:id/keys/:keyId/rotate", apiKeyHandler.RotateAPIKey)
+		adminGroup.DELETE("/service-accounts/:id/keys/:keyId", apiKeyHandler.RevokeAPIKey)
+		adminGroup.GET("/dns-records/unused", dnsRecordHandler.ListUnusedRecords)
+		adminGroup.GET("/policy-rules", policyRuleHandler.ListRules)
+		adminGroup.POST("/policy-rules", policyRuleHandler.CreateRule)
+		adminGroup.GET("/policy-rules/:id", policyRuleHandler.GetRule)
+		adminGroup.PUT("/policy-rules/:id", policyRuleHandler.UpdateRule)
+		adminGroup.DELETE("/policy-rules/:id", policyRuleHandler.DeleteRule)
+		adminGroup.GET("/audit-logs", auditLogHandler.ListAuditLogs)
+		adminGroup.GET("/audit-logs/export", auditLogHandler.ExportAuditLogs)
+		adminGroup.GET("/audit-logs/verify", auditLogHandler.VerifyChain)
+		adminGroup.POST("/audit-logs/checkpoints", auditLogHandler.CreateCheckpoint)
+		adminGroup.GET("/audit-logs/archive", auditLogHandler.ArchiveStatus)
+	}
+
+	// Routes of the authenticated user
+	meGroup := v1.Group("/me")
+	meGroup.Use(jwtMiddleware.Auth(domain.RoleUser, domain.RoleAdmin))
+	{
+		meGroup.GET("/activity", auditLogHandler.MyActivity)
+		meGroup.GET("/sessions", sessionHandler.ListSessions)
+		meGroup.DELETE("/sessions/:id", sessionHandler.RevokeSession)
+		meGroup.GET("/mfa", mfaHandler.GetStatus)
+		meGroup.POST("/mfa/enroll", mfaHandler.Enroll)
+		meGroup.POST("/mfa/confirm", mfaHandler.Confirm)
+		meGroup.POST("/mfa/disable", mfaHandler.Disable)
+		meGroup.POST("/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
 	}
 
-	// DNS Record routes
+	// DNS Record routes, also for API keys of service accounts
 	dnsGroup := v1.Group("/dns-records")
-	dnsGroup.Use(jwtMiddleware.Auth(domain.RoleUser, domain.RoleAdmin))
+	dnsGroup.Use(jwtMiddleware.AuthOrAPIKey(middleware.RecordScope, domain.RoleUser, domain.RoleAdmin))
 	{
 		dnsGroup.POST("", dnsRecordHandler.CreateRecord)
 		dnsGroup.GET("", dnsRecordHandler.ListRecords)
+		dnsGroup.GET("/zonefile", zoneHandler.ExportUserRecords)
+		dnsGroup.POST("/import", bulkRecordHandler.ImportRecords)
+		dnsGroup.GET("/export", bulkRecordHandler.ExportRecords)
+		dnsGroup.GET("/trash", dnsRecordHandler.ListTrash)
 		dnsGroup.GET("/:id", dnsRecordHandler.GetRecord)
 		dnsGroup.PUT("/:id", dnsRecordHandler.UpdateRecord)
 		dnsGroup.DELETE("/:id", dnsRecordHandler.DeleteRecord)
+		dnsGroup.POST("/:id/restore", dnsRecordHandler.RestoreRecord)
+		dnsGroup.PUT("/:id/health-check", recordHealthHandler.SetHealthCheck)
+		dnsGroup.GET("/:id/health", recordHealthHandler.GetHealth)
+		dnsGroup.PUT("/:id/traffic-policy", trafficPolicyHandler.SetTrafficPolicy)
+		dnsGroup.DELETE("/:id/traffic-policy", trafficPolicyHandler.DeleteTrafficPolicy)
+		dnsGroup.GET("/:id/history", recordHistoryHandler.GetHistory)
+		dnsGroup.POST("/:id/rollback", recordHistoryHandler.Rollback)
+	}
+
+	// Zone file routes
+	zoneGroup := v1.Group("/zones")
+	zoneGroup.Use(jwtMiddleware.AuthOrAPIKey(middleware.RecordScope, domain.RoleUser, domain.RoleAdmin))
+	{
+		zoneGroup.POST("/:zone/import", zoneHandler.ImportZone)
+		zoneGroup.GET("/:zone/export", zoneHandler.ExportZone)
+	}
+
+	// Change set routes
+	changeSetGroup := v1.Group("/change-sets")
+	changeSetGroup.Use(jwtMiddleware.AuthOrAPIKey(middleware.RecordScope, domain.RoleUser, domain.RoleAdmin))
+	{
+		changeSetGroup.POST("/preview", changeSetHandler.PreviewChangeSet)
+		changeSetGroup.POST("/apply", changeSetHandler.ApplyChangeSet)
 	}
 }
 
@@ -0,0 +1,128 @@
+package http
+
+import (
+	"errors"
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SessionResponse is a login of the user.
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"` // whether the request was made with this session
+}
+
+// SessionHandler handles the sessions of users.
+type SessionHandler struct {
+	authUC usecase.AuthUseCase
+}
+
+// NewSessionHandler creates a new SessionHandler.
+func NewSessionHandler(authUC usecase.AuthUseCase) *SessionHandler {
+	return &SessionHandler{authUC: authUC}
+}
+
+// ListSessions godoc
+// @Summary List my sessions
+// @Description Lists the sessions of the authenticated user that have not expired or been revoked, most recently used first. Every login starts a session.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} SessionResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /me/sessions [get]
+func (h *SessionHandler) ListSessions(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+	current, _ := c.Get(string(middleware.SessionContextKey)).(string)
+
+	sessions, err := h.authUC.ListSessions(c.Request().Context(), user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve sessions"})
+	}
+	res := make([]SessionResponse, len(sessions))
+	for i, s := range sessions {
+		res[i] = SessionResponse{
+			ID:         s.ID,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    s.ID == current,
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// RevokeSession godoc
+// @Summary Revoke one of my sessions
+// @Description Revokes a session of the authenticated user, e.g. of a lost device. Its refresh token can no longer be used, and its access tokens are rejected from now on.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Session ID"
+// @Success 204 "Session revoked"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Session not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /me/sessions/{id} [delete]
+func (h *SessionHandler) RevokeSession(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	if err := h.authUC.RevokeSession(c.Request().Context(), user.ID, c.Param("id")); err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Session not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke session"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeAllSessions godoc
+// @Summary Revoke all sessions of a user
+// @Description Revokes every session of a user: their refresh tokens can no longer be used, and access tokens issued until now are rejected. The user has to log in again. (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 204 "Sessions revoked"
+// @Failure 400 {object} map[string]string "Invalid user ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/users/{id}/sessions [delete]
+func (h *SessionHandler) RevokeAllSessions(c echo.Context) error {
+	actor, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid actor in context"})
+	}
+
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	if err := h.authUC.RevokeAllSessions(c.Request().Context(), actor.ID, userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke sessions"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"errors"
+	"internal-dns/internal/domain"
+	"internal-dns/internal/usecase"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SSOHandler struct {
+	ssoUC usecase.SSOUseCase
+}
+
+func NewSSOHandler(ssoUC usecase.SSOUseCase) *SSOHandler {
+	return &SSOHandler{ssoUC: ssoUC}
+}
+
+// Login godoc
+// @Summary Log in with single sign-on
+// @Description Redirects to the OpenID Connect identity provider, which redirects back to the callback after the user logged in. Only available if an identity provider is configured.
+// @Tags auth
+// @Produce json
+// @Success 302 "Redirect to the identity provider"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/oidc/login [get]
+func (h *SSOHandler) Login(c echo.Context) error {
+	authURL, err := h.ssoUC.BeginLogin(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start login"})
+	}
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback godoc
+// @Summary Complete a single sign-on login
+// @Description Redirect target of the identity provider. Exchanges the authorization code for the user's ID token and returns access and refresh tokens. Users with two-factor authentication, and all admins, get an MFA challenge token instead, to answer at /auth/mfa/verify as after /auth/login. Users are created on their first login and get the role granted to their groups; existing users of the same name are not linked.
+// @Tags auth
+// @Produce json
+// @Param state query string true "State of the login"
+// @Param code query string true "Authorization code"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Login failed or expired"
+// @Failure 403 {object} map[string]string "User is disabled or not in a group that is granted access"
+// @Failure 409 {object} map[string]string "Username belongs to an existing user"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/oidc/callback [get]
+func (h *SSOHandler) Callback(c echo.Context) error {
+	// The provider reports errors such as a denied consent as parameters
+	if providerErr := c.QueryParam("error"); providerErr != "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Identity provider login failed: " + providerErr})
+	}
+	state, code := c.QueryParam("state"), c.QueryParam("code")
+	if state == "" || code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "state and code are required"})
+	}
+
+	result, err := h.ssoUC.CompleteLogin(c.Request().Context(), state, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidSSOLogin), errors.Is(err, domain.ErrSSOLoginFailed), errors.Is(err, domain.ErrMissingUsername):
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrNoRoleForGroups), errors.Is(err, domain.ErrUserDisabled):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrUsernameUnlinkable):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrUsernameTooShort):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to login"})
+	}
+
+	return c.JSON(http.StatusOK, newLoginResponse(result))
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"errors"
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TrafficTargetDTO is a candidate address of a traffic policy.
+type TrafficTargetDTO struct {
+	Value  string `json:"value"`
+	Weight int    `json:"weight"` // relative share of answers, 1-1000 (default 1)
+}
+
+// SubnetPreferenceDTO restricts clients in a subnet to some targets.
+type SubnetPreferenceDTO struct {
+	Subnet  string   `json:"subnet"`
+	Targets []string `json:"targets"`
+}
+
+// TrafficPolicyDTO steers the answers of an A record between several targets.
+type TrafficPolicyDTO struct {
+	Targets           []TrafficTargetDTO    `json:"targets"`
+	SubnetPreferences []SubnetPreferenceDTO `json:"subnetPreferences"`
+	MaxAnswers        int                   `json:"maxAnswers"` // 0 answers with every selected target
+}
+
+func toTrafficPolicyDTO(policy *domain.TrafficPolicy) *TrafficPolicyDTO {
+	if policy == nil {
+		return nil
+	}
+	dto := &TrafficPolicyDTO{
+		Targets:           make([]TrafficTargetDTO, 0, len(policy.Targets)),
+		SubnetPreferences: make([]SubnetPreferenceDTO, 0, len(policy.SubnetPreferences)),
+		MaxAnswers:        policy.MaxAnswers,
+	}
+	for _, t := range policy.Targets {
+		dto.Targets = append(dto.Targets, TrafficTargetDTO{Value: t.Value, Weight: t.Weight})
+	}
+	for _, p := range policy.SubnetPreferences {
+		dto.SubnetPreferences = append(dto.SubnetPreferences, SubnetPreferenceDTO{Subnet: p.Subnet, Targets: p.Targets})
+	}
+	return dto
+}
+
+// TrafficPolicyHandler handles traffic policy HTTP requests for DNS records.
+type TrafficPolicyHandler struct {
+	trafficUC usecase.TrafficPolicyUseCase
+}
+
+// NewTrafficPolicyHandler creates a new TrafficPolicyHandler.
+func NewTrafficPolicyHandler(trafficUC usecase.TrafficPolicyUseCase) *TrafficPolicyHandler {
+	return &TrafficPolicyHandler{trafficUC: trafficUC}
+}
+
+// SetTrafficPolicy godoc
+// @Summary Set the traffic policy of a DNS record
+// @Description Attaches a traffic policy to an A record. Each query is answered with targets picked by weighted random choice, preferring the targets configured for the client's subnet (or its EDNS Client Subnet), skipping unhealthy ones and limited to maxAnswers.
+// @Tags dns-records
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "Record ID"
+// @Param policy body TrafficPolicyDTO true "Traffic policy"
+// @Success 200 {object} DNSRecordResponse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Record not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/{id}/traffic-policy [put]
+func (h *TrafficPolicyHandler) SetTrafficPolicy(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid record ID"})
+	}
+
+	var req TrafficPolicyDTO
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	targets := make([]domain.TrafficTarget, 0, len(req.Targets))
+	for _, t := range req.Targets {
+		targets = append(targets, domain.TrafficTarget{Value: t.Value, Weight: t.Weight})
+	}
+	preferences := make([]domain.SubnetPreference, 0, len(req.SubnetPreferences))
+	for _, p := range req.SubnetPreferences {
+		preferences = append(preferences, domain.SubnetPreference{Subnet: p.Subnet, Targets: p.Targets})
+	}
+	policy, err := domain.NewTrafficPolicy(targets, preferences, req.MaxAnswers)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	record, err := h.trafficUC.SetTrafficPolicy(c.Request().Context(), user.ID, id, policy)
+	if err != nil {
+		return trafficPolicyError(c, err)
+	}
+	return c.JSON(http.StatusOK, toDNSRecordResponse(record))
+}
+
+// DeleteTrafficPolicy godoc
+// @Summary Remove the traffic policy of a DNS record
+// @Description Detaches the traffic policy of a record, which is then answered with its own addresses again.
+// @Tags dns-records
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "Record ID"
+// @Success 200 {object} DNSRecordResponse
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Record not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/{id}/traffic-policy [delete]
+func (h *TrafficPolicyHandler) DeleteTrafficPolicy(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid record ID"})
+	}
+
+	record, err := h.trafficUC.SetTrafficPolicy(c.Request().Context(), user.ID, id, nil)
+	if err != nil {
+		return trafficPolicyError(c, err)
+	}
+	return c.JSON(http.StatusOK, toDNSRecordResponse(record))
+}
+
+func trafficPolicyError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrDNSRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Record not found or not owned by user"})
+	case errors.Is(err, domain.ErrNameOutOfScope):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrTrafficPolicyRequiresA):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update traffic policy"})
+	}
+}
@@ -21,6 +21,8 @@ type UserResponse struct {
 	IsEnabled bool            `json:"isEnabled"` // Changed to camelCase
 	CreatedAt time.Time       `json:"createdAt"` // Changed to camelCase
 	UpdatedAt time.Time       `json:"updatedAt"` // Changed to camelCase
+
+	IsServiceAccount bool `json:"isServiceAccount"`
 }
 
 func toUserResponse(user *domain.User) UserResponse {
@@ -31,6 +33,8 @@ func toUserResponse(user *domain.User) UserResponse {
 		IsEnabled: user.IsEnabled,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
+
+		IsServiceAccount: user.IsServiceAccount,
 	}
 }
 
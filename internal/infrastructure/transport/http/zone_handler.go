@@ -0,0 +1,209 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/transport/http/middleware"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxZoneFileSize limits the size of imported zone files.
+const maxZoneFileSize = 5 << 20
+
+// zoneFileContentType is the media type of zone files (RFC 4027).
+const zoneFileContentType = "text/dns; charset=utf-8"
+
+type ZoneHandler struct {
+	zoneUC usecase.ZoneUseCase
+}
+
+// ZoneIssueResponse is an error or warning about one line of a zone file.
+type ZoneIssueResponse struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ZoneChangeResponse is one entry of an import diff. The previous fields are
+// set for updated and unchanged records.
+type ZoneChangeResponse struct {
+	Line                     int      `json:"line"`
+	Action                   string   `json:"action"`
+	DomainName               string   `json:"domainName"`
+	Type                     string   `json:"type"`
+	Value                    string   `json:"value"`
+	AdditionalValues         []string `json:"additionalValues"`
+	RecordID                 int64    `json:"recordId,omitempty"`
+	PreviousType             string   `json:"previousType,omitempty"`
+	PreviousValue            string   `json:"previousValue,omitempty"`
+	PreviousAdditionalValues []string `json:"previousAdditionalValues,omitempty"`
+}
+
+// ZoneImportResponse is the result of a zone file import.
+type ZoneImportResponse struct {
+	Zone     string               `json:"zone"`
+	DryRun   bool                 `json:"dryRun"`
+	Applied  bool                 `json:"applied"`
+	Changes  []ZoneChangeResponse `json:"changes"`
+	Errors   []ZoneIssueResponse  `json:"errors"`
+	Warnings []ZoneIssueResponse  `json:"warnings"`
+}
+
+func toZoneIssueResponses(issues []domain.ZoneIssue) []ZoneIssueResponse {
+	res := make([]ZoneIssueResponse, 0, len(issues))
+	for _, issue := range issues {
+		res = append(res, ZoneIssueResponse{Line: issue.Line, Message: issue.Message})
+	}
+	return res
+}
+
+func toZoneImportResponse(result *domain.ZoneImport) ZoneImportResponse {
+	changes := make([]ZoneChangeResponse, 0, len(result.Changes))
+	for _, change := range result.Changes {
+		res := ZoneChangeResponse{
+			Line:             change.Line,
+			Action:           string(change.Action),
+			DomainName:       change.Record.DomainName,
+			Type:             string(change.Record.Type),
+			Value:            change.Record.Value,
+			AdditionalValues: append([]string{}, change.Record.AdditionalValues...),
+		}
+		if change.Previous != nil {
+			res.RecordID = change.Previous.ID
+			res.PreviousType = string(change.Previous.Type)
+			res.PreviousValue = change.Previous.Value
+			res.PreviousAdditionalValues = change.Previous.AdditionalValues
+		}
+		changes = append(changes, res)
+	}
+	return ZoneImportResponse{
+		Zone:     result.Zone,
+		DryRun:   result.DryRun,
+		Applied:  result.Applied,
+		Changes:  changes,
+		Errors:   toZoneIssueResponses(result.Errors),
+		Warnings: toZoneIssueResponses(result.Warnings),
+	}
+}
+
+// NewZoneHandler creates a new ZoneHandler.
+func NewZoneHandler(zoneUC usecase.ZoneUseCase) *ZoneHandler {
+	return &ZoneHandler{zoneUC: zoneUC}
+}
+
+// ImportZone godoc
+// @Summary Import a zone file
+// @Description Parses an RFC 1035 master file ($ORIGIN, $TTL and relative names are supported) and creates or updates the A and CNAME records it contains for the zone. Several A records of one name become one record with additional values; SOA, NS and other types are skipped with a warning. Nothing is changed if any line has an error or with dryRun, which only returns the diff. Records are never deleted.
+// @Tags zones
+// @Accept plain
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param zone path string true "Zone name, e.g. corp.example.com"
+// @Param dryRun query bool false "Only return the diff"
+// @Param zonefile body string true "Zone file content"
+// @Success 200 {object} ZoneImportResponse
+// @Failure 400 {object} map[string]string "Invalid zone or body"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Conflicting concurrent change"
+// @Failure 413 {object} map[string]string "Zone file too large"
+// @Failure 422 {object} ZoneImportResponse "The file has errors; nothing was changed"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /zones/{zone}/import [post]
+func (h *ZoneHandler) ImportZone(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	dryRun := false
+	if v := c.QueryParam("dryRun"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid dryRun parameter"})
+		}
+		dryRun = parsed
+	}
+
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, maxZoneFileSize)
+	result, err := h.zoneUC.ImportZone(c.Request().Context(), user.ID, c.Param("zone"), body, dryRun)
+	if err != nil {
+		return zoneError(c, err)
+	}
+	if len(result.Errors) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, toZoneImportResponse(result))
+	}
+	return c.JSON(http.StatusOK, toZoneImportResponse(result))
+}
+
+// ExportZone godoc
+// @Summary Export a zone file
+// @Description Renders the records of a zone as a zone file with names relative to the zone. Users get their own records; admins get every record in the zone.
+// @Tags zones
+// @Produce plain
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param zone path string true "Zone name, e.g. corp.example.com"
+// @Success 200 {string} string "Zone file"
+// @Failure 400 {object} map[string]string "Invalid zone"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /zones/{zone}/export [get]
+func (h *ZoneHandler) ExportZone(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	data, err := h.zoneUC.ExportZone(c.Request().Context(), user.ID, c.Param("zone"), user.Role == domain.RoleAdmin)
+	if err != nil {
+		return zoneError(c, err)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", c.Param("zone")+".zone"))
+	return c.Blob(http.StatusOK, zoneFileContentType, data)
+}
+
+// ExportUserRecords godoc
+// @Summary Export own records as a zone file
+// @Description Renders all records of the authenticated user as a zone file with fully qualified names.
+// @Tags dns-records
+// @Produce plain
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Success 200 {string} string "Zone file"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /dns-records/zonefile [get]
+func (h *ZoneHandler) ExportUserRecords(c echo.Context) error {
+	user, ok := c.Get(string(middleware.UserContextKey)).(*domain.User)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user in context"})
+	}
+
+	data, err := h.zoneUC.ExportUserRecords(c.Request().Context(), user.ID)
+	if err != nil {
+		return zoneError(c, err)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="records.zone"`)
+	return c.Blob(http.StatusOK, zoneFileContentType, data)
+}
+
+func zoneError(c echo.Context, err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, domain.ErrInvalidZone):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.As(err, &maxBytesErr):
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "Zone file too large"})
+	case errors.Is(err, repository.ErrDuplicateDomainName):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "A record in the zone was created concurrently, retry the import"})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to process zone file"})
+	}
+}
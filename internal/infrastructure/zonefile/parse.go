@@ -0,0 +1,263 @@
+// Package zonefile reads and writes RFC 1035 master (BIND zone) files.
+package zonefile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"internal-dns/internal/domain"
+)
+
+// defaultTTL is used for entries before any $TTL directive.
+const defaultTTL = 3600
+
+// Entry is one resource record of a zone file. Names are lowercase without
+// the trailing dot; Type is the mnemonic, e.g. "A".
+type Entry struct {
+	Line  int
+	Name  string
+	Type  string
+	TTL   uint32
+	Value string
+}
+
+// Result holds the parsed entries and the problems found, each tied to the
+// line its entry starts on.
+type Result struct {
+	Entries  []Entry
+	Errors   []domain.ZoneIssue
+	Warnings []domain.ZoneIssue
+}
+
+// parseErrorLocation is the position suffix of miekg/dns parse errors, which
+// is relative to the single entry handed to the parser.
+var parseErrorLocation = regexp.MustCompile(` at line: \d+:\d+$`)
+
+type parser struct {
+	origin    string // FQDN
+	ttl       uint32
+	lastOwner string // FQDN
+	result    *Result
+}
+
+// Parse reads a zone file with origin as the initial $ORIGIN. $ORIGIN, $TTL,
+// relative names, blank owners, parentheses and comments are supported;
+// $INCLUDE and $GENERATE are not. Problems are collected per line rather
+// than aborting; the error is only set if r cannot be read.
+func Parse(r io.Reader, origin string) (*Result, error) {
+	p := &parser{
+		origin: dns.Fqdn(strings.ToLower(origin)),
+		ttl:    defaultTTL,
+		result: &Result{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var (
+		entry      strings.Builder
+		entryLine  int
+		blankOwner bool
+		depth      int
+		lineNo     int
+	)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		text, parens := stripComment(line)
+
+		if depth > 0 {
+			entry.WriteString(" ")
+			entry.WriteString(text)
+			depth += parens
+			if depth <= 0 {
+				p.entry(entryLine, entry.String(), blankOwner)
+				depth = 0
+			}
+			continue
+		}
+
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "$") {
+			p.directive(lineNo, text)
+			continue
+		}
+
+		entry.Reset()
+		entry.WriteString(strings.TrimSpace(text))
+		entryLine = lineNo
+		blankOwner = line[0] == ' ' || line[0] == '\t'
+		depth = parens
+		if depth <= 0 {
+			p.entry(entryLine, entry.String(), blankOwner)
+			depth = 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if depth > 0 {
+		p.fail(entryLine, "unbalanced parentheses")
+	}
+	return p.result, nil
+}
+
+func (p *parser) fail(line int, format string, args ...any) {
+	p.result.Errors = append(p.result.Errors, domain.ZoneIssue{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) warn(line int, format string, args ...any) {
+	p.result.Warnings = append(p.result.Warnings, domain.ZoneIssue{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) directive(line int, text string) {
+	fields := strings.Fields(text)
+	switch strings.ToUpper(fields[0]) {
+	case "$ORIGIN":
+		if len(fields) != 2 {
+			p.fail(line, "$ORIGIN takes exactly one name")
+			return
+		}
+		name := strings.ToLower(fields[1])
+		if !dns.IsFqdn(name) {
+			name = dns.Fqdn(name + "." + p.origin)
+		}
+		if _, ok := dns.IsDomainName(name); !ok {
+			p.fail(line, "invalid $ORIGIN %q", fields[1])
+			return
+		}
+		p.origin = name
+	case "$TTL":
+		if len(fields) != 2 {
+			p.fail(line, "$TTL takes exactly one value")
+			return
+		}
+		ttl, ok := parseTTL(fields[1])
+		if !ok {
+			p.fail(line, "invalid $TTL %q", fields[1])
+			return
+		}
+		p.ttl = ttl
+	case "$INCLUDE", "$GENERATE":
+		p.fail(line, "%s is not supported", fields[0])
+	default:
+		p.fail(line, "unknown directive %s", fields[0])
+	}
+}
+
+func (p *parser) entry(line int, text string, blankOwner bool) {
+	if blankOwner {
+		if p.lastOwner == "" {
+			p.fail(line, "record has no owner name")
+			return
+		}
+		text = p.lastOwner + " " + text
+	}
+
+	zp := dns.NewZoneParser(strings.NewReader(fmt.Sprintf("$TTL %d\n%s\n", p.ttl, text)), p.origin, "")
+	rr, ok := zp.Next()
+	if err := zp.Err(); err != nil {
+		p.fail(line, "%s", parseErrorLocation.ReplaceAllString(err.Error(), ""))
+		return
+	}
+	if !ok {
+		return
+	}
+
+	hdr := rr.Header()
+	p.lastOwner = hdr.Name
+	e := Entry{
+		Line: line,
+		Name: normalizeName(hdr.Name),
+		Type: dns.TypeToString[hdr.Rrtype],
+		TTL:  hdr.Ttl,
+	}
+	switch v := rr.(type) {
+	case *dns.A:
+		if v.A != nil {
+			e.Value = v.A.String()
+		}
+	case *dns.CNAME:
+		e.Value = normalizeName(v.Target)
+	default:
+		e.Value = strings.TrimPrefix(rr.String(), hdr.String())
+	}
+	if e.Value == "" {
+		p.fail(line, "%s record without data", e.Type)
+		return
+	}
+	if hdr.Class != dns.ClassINET {
+		p.warn(line, "class %s ignored", dns.ClassToString[hdr.Class])
+	}
+	p.result.Entries = append(p.result.Entries, e)
+}
+
+// stripComment removes a ";" comment outside quotes and returns the net
+// number of parentheses opened on the line.
+func stripComment(line string) (string, int) {
+	var quoted, escaped bool
+	depth := 0
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == ';':
+			return line[:i], depth
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		}
+	}
+	return line, depth
+}
+
+// parseTTL parses a TTL in seconds or in BIND notation such as "1h30m".
+func parseTTL(s string) (uint32, bool) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(n), true
+	}
+	if s == "" {
+		return 0, false
+	}
+	units := map[byte]uint64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+	var total, current uint64
+	var digits bool
+	for i := 0; i < len(s); i++ {
+		c := s[i] | 0x20 // lowercase letters
+		switch {
+		case s[i] >= '0' && s[i] <= '9':
+			current = current*10 + uint64(s[i]-'0')
+			digits = true
+		case units[c] > 0 && digits:
+			total += current * units[c]
+			current, digits = 0, false
+		default:
+			return 0, false
+		}
+		if total+current > 1<<31-1 {
+			return 0, false
+		}
+	}
+	if digits {
+		return 0, false
+	}
+	return uint32(total), true
+}
+
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
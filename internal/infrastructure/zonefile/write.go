@@ -0,0 +1,63 @@
+package zonefile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"internal-dns/internal/domain"
+)
+
+// RecordTTL is the TTL the DNS server answers with, written to exports.
+const RecordTTL = 300
+
+// Write renders records as a zone file. With an origin, names are written
+// relative to it; without one they are written fully qualified. A records
+// with several addresses become one line per address. Health checks and
+// traffic policies have no zone file representation and are noted in
+// comments only.
+func Write(w io.Writer, origin string, records []*domain.DNSRecord) error {
+	sorted := make([]*domain.DNSRecord, len(records))
+	copy(sorted, records)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].DomainName < sorted[j].DomainName })
+
+	bw := bufio.NewWriter(w)
+	if origin != "" {
+		fmt.Fprintf(bw, "$ORIGIN %s.\n", origin)
+	}
+	fmt.Fprintf(bw, "$TTL %d\n", RecordTTL)
+
+	for _, record := range sorted {
+		owner := ownerName(record.DomainName, origin)
+		switch record.Type {
+		case domain.A:
+			if record.HealthCheck != nil {
+				fmt.Fprintf(bw, "; %s: health check %s on port %d\n", owner, record.HealthCheck.Type, record.HealthCheck.Port)
+			}
+			if record.TrafficPolicy != nil {
+				fmt.Fprintf(bw, "; %s: traffic policy not exported\n", owner)
+			}
+			for _, addr := range append([]string{record.Value}, record.AdditionalValues...) {
+				fmt.Fprintf(bw, "%-31s IN A     %s\n", owner, addr)
+			}
+		case domain.CNAME:
+			fmt.Fprintf(bw, "%-31s IN CNAME %s.\n", owner, record.Value)
+		}
+	}
+	return bw.Flush()
+}
+
+func ownerName(name, origin string) string {
+	switch {
+	case origin == "":
+		return name + "."
+	case name == origin:
+		return "@"
+	case strings.HasSuffix(name, "."+origin):
+		return strings.TrimSuffix(name, "."+origin)
+	default:
+		return name + "."
+	}
+}
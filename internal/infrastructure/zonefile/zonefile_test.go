@@ -0,0 +1,124 @@
+package zonefile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"internal-dns/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("Resolves relative names, directives and blank owners", func(t *testing.T) {
+		content := `$TTL 1h
+@       IN SOA ns1 hostmaster (
+                2024010101 ; serial
+                3600 900 604800 300 )
+        IN NS  ns1
+www     IN A   10.0.0.1
+        IN A   10.0.0.2 ; second address
+api 60  IN CNAME www
+$ORIGIN sub
+host    IN A   10.0.1.1
+abs.other.test. IN A 10.0.2.1
+`
+		result, err := Parse(strings.NewReader(content), "example.com.")
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		require.Len(t, result.Entries, 7)
+
+		assert.Equal(t, Entry{Line: 2, Name: "example.com", Type: "SOA", TTL: 3600, Value: result.Entries[0].Value}, result.Entries[0])
+		assert.Equal(t, "NS", result.Entries[1].Type)
+		assert.Equal(t, "example.com", result.Entries[1].Name)
+		assert.Equal(t, Entry{Line: 6, Name: "www.example.com", Type: "A", TTL: 3600, Value: "10.0.0.1"}, result.Entries[2])
+		assert.Equal(t, Entry{Line: 7, Name: "www.example.com", Type: "A", TTL: 3600, Value: "10.0.0.2"}, result.Entries[3])
+		assert.Equal(t, Entry{Line: 8, Name: "api.example.com", Type: "CNAME", TTL: 60, Value: "www.example.com"}, result.Entries[4])
+		assert.Equal(t, "host.sub.example.com", result.Entries[5].Name)
+		assert.Equal(t, "abs.other.test", result.Entries[6].Name)
+	})
+
+	t.Run("Reports errors per line and keeps going", func(t *testing.T) {
+		content := `www IN A 10.0.0.300
+$INCLUDE other.zone
+$TTL forever
+ok  IN A 10.0.0.1
+bad IN CNAME
+`
+		result, err := Parse(strings.NewReader(content), "example.com")
+		require.NoError(t, err)
+		require.Len(t, result.Entries, 1)
+		assert.Equal(t, "ok.example.com", result.Entries[0].Name)
+
+		var lines []int
+		for _, issue := range result.Errors {
+			lines = append(lines, issue.Line)
+			assert.NotContains(t, issue.Message, "at line:")
+		}
+		assert.Equal(t, []int{1, 2, 3, 5}, lines)
+	})
+
+	t.Run("Reports a blank owner without a previous name", func(t *testing.T) {
+		result, err := Parse(strings.NewReader("  IN A 10.0.0.1\n"), "example.com")
+		require.NoError(t, err)
+		require.Len(t, result.Errors, 1)
+		assert.Equal(t, 1, result.Errors[0].Line)
+	})
+
+	t.Run("Reports unbalanced parentheses", func(t *testing.T) {
+		result, err := Parse(strings.NewReader("www IN A (\n10.0.0.1\n"), "example.com")
+		require.NoError(t, err)
+		require.Len(t, result.Errors, 1)
+		assert.Equal(t, 1, result.Errors[0].Line)
+	})
+}
+
+func TestParseTTL(t *testing.T) {
+	for in, want := range map[string]uint32{"300": 300, "1h": 3600, "1h30m": 5400, "1W": 604800, "2d1s": 172801} {
+		got, ok := parseTTL(in)
+		assert.True(t, ok, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "h", "1x", "10h5", "-1"} {
+		_, ok := parseTTL(in)
+		assert.False(t, ok, in)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	apex := &domain.DNSRecord{DomainName: "example.com", Type: domain.A, Value: "10.0.0.9"}
+	www := &domain.DNSRecord{DomainName: "www.example.com", Type: domain.A, Value: "10.0.0.1", AdditionalValues: []string{"10.0.0.2"}}
+	api := &domain.DNSRecord{DomainName: "api.example.com", Type: domain.CNAME, Value: "www.example.com"}
+
+	t.Run("Writes relative names that parse back", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, "example.com", []*domain.DNSRecord{www, apex, api}))
+		assert.Contains(t, buf.String(), "$ORIGIN example.com.\n")
+		assert.Contains(t, buf.String(), "\n@ ")
+
+		result, err := Parse(&buf, "ignored.test")
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+
+		var got []string
+		for _, e := range result.Entries {
+			got = append(got, e.Name+" "+e.Type+" "+e.Value)
+			assert.Equal(t, uint32(RecordTTL), e.TTL)
+		}
+		assert.Equal(t, []string{
+			"api.example.com CNAME www.example.com",
+			"example.com A 10.0.0.9",
+			"www.example.com A 10.0.0.1",
+			"www.example.com A 10.0.0.2",
+		}, got)
+	})
+
+	t.Run("Writes fully qualified names without an origin", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, "", []*domain.DNSRecord{api}))
+		assert.NotContains(t, buf.String(), "$ORIGIN")
+		assert.Contains(t, buf.String(), "api.example.com.")
+	})
+}
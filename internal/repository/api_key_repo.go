@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"internal-dns/internal/domain"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	// FindByID returns domain.ErrAPIKeyNotFound if there is no such key.
+	FindByID(ctx context.Context, id int64) (*domain.APIKey, error)
+	// FindByPrefix returns domain.ErrAPIKeyNotFound if there is no such key.
+	FindByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	// ListByUserID returns the keys of a user, including revoked ones,
+	// newest first.
+	ListByUserID(ctx context.Context, userID int64) ([]*domain.APIKey, error)
+	// Rotate stores the new prefix and secret of a key that is not revoked.
+	// It returns domain.ErrAPIKeyNotFound otherwise.
+	Rotate(ctx context.Context, key *domain.APIKey) error
+	// Revoke revokes a key at at. It returns domain.ErrAPIKeyNotFound if the
+	// key does not exist or was already revoked.
+	Revoke(ctx context.Context, id int64, at time.Time) error
+	// Touch records that a key was used at at.
+	Touch(ctx context.Context, id int64, at time.Time) error
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"internal-dns/internal/domain"
+	"time"
+)
+
+// AuditArchiveWriter receives the entries removed from the audit log, in ID
+// order. Close finishes the archive and returns the gaps that the entries
+// leave in the hash chain; the entries are only removed if it succeeds.
+type AuditArchiveWriter interface {
+	Write(log *domain.AuditLog) error
+	Close() ([]*domain.AuditChainGap, error)
+}
+
+type AuditArchiveRepository interface {
+	// EnsurePartition creates the partition of the month that starts at
+	// month, moving its entries out of the default partition.
+	EnsurePartition(ctx context.Context, month time.Time) error
+	ListPartitions(ctx context.Context) ([]*domain.AuditPartition, error)
+	// Archive writes entries of archive.Partition to w and removes them:
+	// all of them, dropping the partition, if archive.Dropped is set, and
+	// otherwise those that expired under retention at archive.CreatedAt.
+	// The archive and its gaps are recorded unless nothing was removed. It
+	// returns domain.ErrAuditArchiveBusy while another archive is written.
+	Archive(ctx context.Context, archive *domain.AuditArchive, retention domain.AuditRetention, w AuditArchiveWriter) error
+	// ListArchives returns the newest archives first.
+	ListArchives(ctx context.Context, limit int) ([]*domain.AuditArchive, error)
+	ListGaps(ctx context.Context) ([]*domain.AuditChainGap, error)
+}
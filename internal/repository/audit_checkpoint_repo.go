@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"internal-dns/internal/domain"
+)
+
+type AuditCheckpointRepository interface {
+	Create(ctx context.Context, cp *domain.AuditCheckpoint) error
+	// Latest returns the checkpoint of the newest entry, or nil if there is
+	// none.
+	Latest(ctx context.Context) (*domain.AuditCheckpoint, error)
+	// List returns all checkpoints ordered by the entry they cover.
+	List(ctx context.Context) ([]*domain.AuditCheckpoint, error)
+}
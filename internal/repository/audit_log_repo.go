@@ -7,4 +7,15 @@ import (
 
 type AuditLogRepository interface {
 	Create(ctx context.Context, log *domain.AuditLog) error
+	// CreateBatch stores logs in one transaction. Entries with an EventID that
+	// is already stored are skipped.
+	CreateBatch(ctx context.Context, logs []*domain.AuditLog) error
+	// FindByTarget returns the entries with one of actions about targetID,
+	// oldest first.
+	FindByTarget(ctx context.Context, targetID int64, actions []domain.ActionType) ([]*domain.AuditLog, error)
+	// Find returns up to filter.Limit entries matching filter, newest first.
+	Find(ctx context.Context, filter domain.AuditLogFilter) ([]*domain.AuditLog, error)
+	// FindAfter returns up to limit entries with an ID above afterID, oldest
+	// first.
+	FindAfter(ctx context.Context, afterID int64, limit int) ([]*domain.AuditLog, error)
 }
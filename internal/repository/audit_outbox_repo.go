@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"internal-dns/internal/domain"
+)
+
+// AuditOutboxRepository queues audit log entries until they are appended to
+// the audit log. Entries are queued within the transaction of the caller,
+// if any. Claimed entries stay queued until they are acknowledged, so that
+// entries of a relay that fails or dies are delivered again.
+type AuditOutboxRepository interface {
+	Push(ctx context.Context, log *domain.AuditLog) error
+	// Claim returns up to count entries for consumer, oldest first: the
+	// ones it claimed before without acknowledging them, the ones other
+	// consumers have not acknowledged for minIdle, and new ones.
+	Claim(ctx context.Context, consumer string, count int, minIdle time.Duration) ([]domain.AuditOutboxEntry, error)
+	Ack(ctx context.Context, ids ...int64) error
+	// Len returns the number of queued entries, claimed or not.
+	Len(ctx context.Context) (int64, error)
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"internal-dns/internal/domain"
 )
@@ -10,16 +11,29 @@ import (
 var (
 	ErrDNSRecordNotFound   = errors.New("dns record not found")
 	ErrDuplicateDomainName = errors.New("a record with this domain name already exists")
+	ErrDomainNameInTrash   = errors.New("domain name is reserved by a deleted record")
 )
 
 type DNSRecordRepository interface {
 	Create(ctx context.Context, record *domain.DNSRecord) error
 	FindByID(ctx context.Context, id int64) (*domain.DNSRecord, error)
 	FindByDomainName(ctx context.Context, domainName string) (*domain.DNSRecord, error)
+	FindByDomainNames(ctx context.Context, domainNames []string) ([]*domain.DNSRecord, error)
 	FindByUserID(ctx context.Context, userID int64, page, pageSize int) ([]*domain.DNSRecord, error)
 	Update(ctx context.Context, record *domain.DNSRecord) error
 	Delete(ctx context.Context, id int64) error
 	CountByUserID(ctx context.Context, userID int64) (int, error)
 	GetAllDomainNames(ctx context.Context) ([]string, error)
+	AddQueryStats(ctx context.Context, stats []domain.QueryStat) error
+	FindUnqueriedSince(ctx context.Context, since time.Time) ([]*domain.DNSRecord, error)
+	FindWithHealthChecks(ctx context.Context) ([]*domain.DNSRecord, error)
+	FindByZone(ctx context.Context, zone string) ([]*domain.DNSRecord, error)
+	// ApplyBatch stores all changes in one transaction, or none of them.
+	ApplyBatch(ctx context.Context, creates, updates []*domain.DNSRecord, deletes []int64) error
+	// Restore brings back a deleted or purged record under its previous ID.
+	Restore(ctx context.Context, record *domain.DNSRecord) error
+	FindDeletedByID(ctx context.Context, id int64) (*domain.DNSRecord, error)
+	FindDeletedByUserID(ctx context.Context, userID int64) ([]*domain.DNSRecord, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
 }
 
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"internal-dns/internal/domain"
+)
+
+type MFARepository interface {
+	// Find returns the TOTP enrollment of a user, or
+	// domain.ErrMFANotEnrolled if they have none.
+	Find(ctx context.Context, userID int64) (*domain.TOTPEnrollment, error)
+	// SavePending stores a pending enrollment, replacing a pending one of
+	// the same user. It returns domain.ErrMFAAlreadyEnabled if the user has
+	// a confirmed enrollment.
+	SavePending(ctx context.Context, enrollment *domain.TOTPEnrollment) error
+	// Confirm confirms the pending enrollment of a user with the code of
+	// step and replaces their recovery codes.
+	Confirm(ctx context.Context, userID, step int64, recoveryCodeHashes []string) error
+	// UseStep records that the code of step was used. It returns
+	// domain.ErrInvalidMFACode if a code of this step or a later one was
+	// used already.
+	UseStep(ctx context.Context, userID, step int64) error
+	// UseRecoveryCode marks an unused recovery code as used. It returns
+	// domain.ErrInvalidMFACode if the user has no such unused code.
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	// Delete removes the enrollment and recovery codes of a user.
+	Delete(ctx context.Context, userID int64) error
+}
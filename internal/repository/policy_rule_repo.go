@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"internal-dns/internal/domain"
+)
+
+var (
+	ErrPolicyRuleNotFound  = errors.New("policy rule not found")
+	ErrDuplicatePolicyRule = errors.New("policy rule for this pattern already exists")
+)
+
+// PolicyRuleRepository defines the interface for response policy rule persistence.
+type PolicyRuleRepository interface {
+	Create(ctx context.Context, rule *domain.PolicyRule) error
+	FindByID(ctx context.Context, id int64) (*domain.PolicyRule, error)
+	FindAll(ctx context.Context) ([]*domain.PolicyRule, error)
+	Update(ctx context.Context, rule *domain.PolicyRule) error
+	Delete(ctx context.Context, id int64) error
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"internal-dns/internal/domain"
+)
+
+// RecordHealthRepository defines the interface for health check state persistence.
+type RecordHealthRepository interface {
+	SaveStatus(ctx context.Context, status *domain.TargetHealth) error
+	AddEvent(ctx context.Context, event *domain.HealthEvent) error
+	FindStatusByRecordID(ctx context.Context, recordID int64) ([]*domain.TargetHealth, error)
+	FindEventsByRecordID(ctx context.Context, recordID int64, limit int) ([]*domain.HealthEvent, error)
+	DeleteStatusByRecordID(ctx context.Context, recordID int64) error
+}
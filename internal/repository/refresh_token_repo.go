@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"internal-dns/internal/domain"
+)
+
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, t *domain.RefreshToken) error
+	FindByID(ctx context.Context, id string) (*domain.RefreshToken, error)
+	// Rotate marks the token id as used and replaced by next, and stores
+	// next, in one transaction. It returns domain.ErrRefreshTokenReused if
+	// the token was used or revoked in the meantime.
+	Rotate(ctx context.Context, id string, next *domain.RefreshToken) error
+	// RevokeFamily revokes every token of a family.
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeUser revokes every token of a user.
+	RevokeUser(ctx context.Context, userID int64) error
+	// ListSessions returns the sessions of a user that hold a usable token,
+	// most recently used first.
+	ListSessions(ctx context.Context, userID int64) ([]*domain.Session, error)
+	// DeleteExpired deletes the tokens that expired before before.
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
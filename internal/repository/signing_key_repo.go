@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"internal-dns/internal/domain"
+)
+
+type SigningKeyRepository interface {
+	// Add stores key if latestID is still the ID of the key that activates
+	// last, or if there are no keys and latestID is empty. It returns
+	// domain.ErrSigningKeyConflict otherwise.
+	Add(ctx context.Context, key *domain.SigningKey, latestID string) error
+	// List returns all keys in the order they activate.
+	List(ctx context.Context) ([]*domain.SigningKey, error)
+	Delete(ctx context.Context, id string) error
+}
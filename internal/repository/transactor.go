@@ -0,0 +1,13 @@
+package repository
+
+import "context"
+
+// Transactor runs functions in a database transaction, so that a change and
+// its audit log entry are stored together or not at all.
+type Transactor interface {
+	// WithinTx runs fn in a transaction that is committed if fn returns nil
+	// and rolled back otherwise. Repositories called with the context passed
+	// to fn take part in the transaction. Called within a transaction, fn
+	// joins it.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"internal-dns/internal/domain"
+)
+
+type UserIdentityRepository interface {
+	Create(ctx context.Context, identity *domain.UserIdentity) error
+	// FindBySubject returns domain.ErrIdentityNotFound if no user is linked
+	// to the account subject of issuer.
+	FindBySubject(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error)
+}
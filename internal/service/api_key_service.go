@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+// apiKeyTouchInterval is how often the last use of a key is recorded, so
+// that not every request writes to the database.
+const apiKeyTouchInterval = time.Minute
+
+type apiKeyService struct {
+	userRepo  repository.UserRepository
+	keyRepo   repository.APIKeyRepository
+	auditRepo repository.AuditLogRepository
+	tx        repository.Transactor
+	now       func() time.Time
+}
+
+// NewAPIKeyService creates a new APIKeyUseCase implementation.
+func NewAPIKeyService(userRepo repository.UserRepository, keyRepo repository.APIKeyRepository, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.APIKeyUseCase {
+	return &apiKeyService{
+		userRepo:  userRepo,
+		keyRepo:   keyRepo,
+		auditRepo: auditRepo,
+		tx:        tx,
+		now:       time.Now,
+	}
+}
+
+func (s *apiKeyService) CreateServiceAccount(ctx context.Context, actorID int64, username string) (*domain.User, error) {
+	_, err := s.userRepo.FindByUsername(ctx, username)
+	if err == nil {
+		return nil, repository.ErrUserAlreadyExists
+	}
+	if !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, err
+	}
+
+	user, err := domain.NewServiceAccount(username)
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, domain.ActionCreateServiceAccount, user.ID, nil, map[string]string{"username": user.Username})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *apiKeyService) CreateKey(ctx context.Context, actorID, userID int64, name string, scopes []domain.APIKeyScope, suffixes []string, expiresAt *time.Time) (*domain.APIKey, string, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !user.IsServiceAccount {
+		return nil, "", domain.ErrNotServiceAccount
+	}
+
+	k, key, err := domain.NewAPIKey(userID, name, scopes, suffixes, expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.keyRepo.Create(ctx, k); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, domain.ActionCreateAPIKey, k.ID, nil, k)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return k, key, nil
+}
+
+func (s *apiKeyService) ListKeys(ctx context.Context, userID int64) ([]*domain.APIKey, error) {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.keyRepo.ListByUserID(ctx, userID)
+}
+
+func (s *apiKeyService) RotateKey(ctx context.Context, actorID, userID, keyID int64) (*domain.APIKey, string, error) {
+	k, err := s.findKey(ctx, userID, keyID)
+	if err != nil {
+		return nil, "", err
+	}
+	if k.RevokedAt != nil {
+		return nil, "", domain.ErrAPIKeyNotFound
+	}
+	oldPrefix := k.Prefix
+
+	key, err := k.Rotate()
+	if err != nil {
+		return nil, "", err
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.keyRepo.Rotate(ctx, k); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, domain.ActionRotateAPIKey, k.ID, map[string]string{"prefix": oldPrefix}, map[string]string{"prefix": k.Prefix})
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return k, key, nil
+}
+
+func (s *apiKeyService) RevokeKey(ctx context.Context, actorID, userID, keyID int64) error {
+	k, err := s.findKey(ctx, userID, keyID)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.keyRepo.Revoke(ctx, k.ID, s.now().UTC()); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, domain.ActionRevokeAPIKey, k.ID, k, nil)
+	})
+}
+
+// audit records a change of a service account or its keys, in the
+// transaction of the change.
+func (s *apiKeyService) audit(ctx context.Context, actorID int64, action domain.ActionType, targetID int64, oldValue, newValue interface{}) error {
+	auditLog, err := domain.NewAuditLog(actorID, action, targetID, oldValue, newValue)
+	if err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		return fmt.Errorf("failed to create audit log for %s: %w", action, err)
+	}
+	return nil
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, key string) (*domain.User, *domain.APIKey, error) {
+	prefix, secret, err := domain.ParseAPIKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	k, err := s.keyRepo.FindByPrefix(ctx, prefix)
+	if errors.Is(err, domain.ErrAPIKeyNotFound) {
+		return nil, nil, domain.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := s.now().UTC()
+	if !k.Verify(secret, now) {
+		return nil, nil, domain.ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.FindByID(ctx, k.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, nil, domain.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsEnabled || !user.IsServiceAccount {
+		return nil, nil, domain.ErrInvalidAPIKey
+	}
+
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.keyRepo.Touch(context.WithoutCancel(ctx), k.ID, now); err != nil {
+			log.Printf("Failed to record use of API key %d: %v", k.ID, err)
+		} else {
+			k.LastUsedAt = &now
+		}
+	}
+	return user, k, nil
+}
+
+// findKey returns a key of the service account userID.
+func (s *apiKeyService) findKey(ctx context.Context, userID, keyID int64) (*domain.APIKey, error) {
+	k, err := s.keyRepo.FindByID(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if k.UserID != userID {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	return k, nil
+}
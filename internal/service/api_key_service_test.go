@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAPIKeyRepository is a mock of APIKeyRepository
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) FindByID(ctx context.Context, id int64) (*domain.APIKey, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) FindByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	args := m.Called(ctx, prefix)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) ListByUserID(ctx context.Context, userID int64) ([]*domain.APIKey, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Rotate(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) Revoke(ctx context.Context, id int64, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) Touch(ctx context.Context, id int64, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func TestAPIKeyService(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	setup := func() (*MockUserRepository, *MockAPIKeyRepository, *MockAuditLogRepository, *apiKeyService) {
+		users, keys, audit := new(MockUserRepository), new(MockAPIKeyRepository), new(MockAuditLogRepository)
+		s := NewAPIKeyService(users, keys, audit, noTx{}).(*apiKeyService)
+		s.now = func() time.Time { return now }
+		return users, keys, audit, s
+	}
+	isAction := func(action domain.ActionType) interface{} {
+		return mock.MatchedBy(func(l *domain.AuditLog) bool { return l.Action == action && l.UserID == 1 })
+	}
+	account := &domain.User{ID: 2, Username: "ci", Role: domain.RoleUser, IsEnabled: true, IsServiceAccount: true}
+
+	t.Run("Create a service account", func(t *testing.T) {
+		users, _, audit, s := setup()
+		users.On("FindByUsername", ctx, "terraform").Return(nil, repository.ErrUserNotFound).Once()
+		users.On("Create", ctx, mock.MatchedBy(func(u *domain.User) bool {
+			return u.IsServiceAccount && u.PasswordHash == "" && u.Role == domain.RoleUser
+		})).Return(nil).Once()
+		audit.On("Create", mock.Anything, isAction(domain.ActionCreateServiceAccount)).Return(nil).Once()
+
+		user, err := s.CreateServiceAccount(ctx, 1, "terraform")
+		require.NoError(t, err)
+		assert.False(t, user.ValidatePassword(""), "service accounts cannot log in")
+		users.AssertExpectations(t)
+		audit.AssertExpectations(t)
+	})
+
+	t.Run("Create a key", func(t *testing.T) {
+		users, keys, audit, s := setup()
+		users.On("FindByID", ctx, int64(2)).Return(account, nil).Once()
+		keys.On("Create", ctx, mock.AnythingOfType("*domain.APIKey")).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.APIKey).ID = 5
+		}).Return(nil).Once()
+		audit.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+			return l.Action == domain.ActionCreateAPIKey && l.TargetID == 5 && !strings.Contains(string(l.NewValue), "SecretHash")
+		})).Return(nil).Once()
+
+		k, key, err := s.CreateKey(ctx, 1, 2, "deploy", []domain.APIKeyScope{domain.ScopeRecordsWrite}, []string{"dev.example.com"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), k.ID)
+		prefix, _, err := domain.ParseAPIKey(key)
+		require.NoError(t, err)
+		assert.Equal(t, k.Prefix, prefix)
+		audit.AssertExpectations(t)
+	})
+
+	t.Run("Only service accounts have keys", func(t *testing.T) {
+		users, keys, _, s := setup()
+		users.On("FindByID", ctx, int64(3)).Return(&domain.User{ID: 3, IsEnabled: true}, nil).Once()
+
+		_, _, err := s.CreateKey(ctx, 1, 3, "deploy", []domain.APIKeyScope{domain.ScopeRecordsRead}, nil, nil)
+		assert.ErrorIs(t, err, domain.ErrNotServiceAccount)
+		keys.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		users, keys, _, s := setup()
+		k, key, err := domain.NewAPIKey(2, "deploy", []domain.APIKeyScope{domain.ScopeRecordsRead}, nil, nil)
+		require.NoError(t, err)
+		k.ID = 5
+		keys.On("FindByPrefix", ctx, k.Prefix).Return(k, nil)
+		users.On("FindByID", ctx, int64(2)).Return(account, nil)
+		keys.On("Touch", mock.Anything, int64(5), now).Return(nil).Once()
+
+		user, got, err := s.Authenticate(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, account, user)
+		assert.Equal(t, &now, got.LastUsedAt)
+
+		// The last use is recorded at most every apiKeyTouchInterval
+		_, _, err = s.Authenticate(ctx, key)
+		require.NoError(t, err)
+		keys.AssertNumberOfCalls(t, "Touch", 1)
+
+		_, _, err = s.Authenticate(ctx, key+"x")
+		assert.ErrorIs(t, err, domain.ErrInvalidAPIKey)
+		_, _, err = s.Authenticate(ctx, "not a key")
+		assert.ErrorIs(t, err, domain.ErrInvalidAPIKey)
+	})
+
+	t.Run("Authenticate rejects keys of disabled accounts", func(t *testing.T) {
+		users, keys, _, s := setup()
+		k, key, err := domain.NewAPIKey(2, "deploy", []domain.APIKeyScope{domain.ScopeRecordsRead}, nil, nil)
+		require.NoError(t, err)
+		keys.On("FindByPrefix", ctx, k.Prefix).Return(k, nil)
+		disabled := *account
+		disabled.IsEnabled = false
+		users.On("FindByID", ctx, int64(2)).Return(&disabled, nil)
+
+		_, _, err = s.Authenticate(ctx, key)
+		assert.ErrorIs(t, err, domain.ErrInvalidAPIKey)
+		keys.AssertNotCalled(t, "Touch", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Authenticate rejects unknown keys", func(t *testing.T) {
+		_, keys, _, s := setup()
+		keys.On("FindByPrefix", ctx, "abc").Return(nil, domain.ErrAPIKeyNotFound)
+
+		_, _, err := s.Authenticate(ctx, "idns_abc_secret")
+		assert.ErrorIs(t, err, domain.ErrInvalidAPIKey)
+	})
+
+	t.Run("Rotate a key", func(t *testing.T) {
+		_, keys, audit, s := setup()
+		k, oldKey, err := domain.NewAPIKey(2, "deploy", []domain.APIKeyScope{domain.ScopeRecordsRead}, nil, nil)
+		require.NoError(t, err)
+		k.ID = 5
+		oldPrefix := k.Prefix
+		keys.On("FindByID", ctx, int64(5)).Return(k, nil)
+		keys.On("Rotate", ctx, k).Return(nil).Once()
+		audit.On("Create", mock.Anything, isAction(domain.ActionRotateAPIKey)).Return(nil).Once()
+
+		rotated, key, err := s.RotateKey(ctx, 1, 2, 5)
+		require.NoError(t, err)
+		assert.NotEqual(t, oldKey, key)
+		assert.NotEqual(t, oldPrefix, rotated.Prefix)
+		keys.AssertExpectations(t)
+
+		// Keys of other service accounts are not found
+		_, _, err = s.RotateKey(ctx, 1, 3, 5)
+		assert.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+	})
+
+	t.Run("Revoke a key", func(t *testing.T) {
+		_, keys, audit, s := setup()
+		keys.On("FindByID", ctx, int64(5)).Return(&domain.APIKey{ID: 5, UserID: 2}, nil)
+		keys.On("Revoke", ctx, int64(5), now).Return(nil).Once()
+		audit.On("Create", mock.Anything, isAction(domain.ActionRevokeAPIKey)).Return(nil).Once()
+
+		require.NoError(t, s.RevokeKey(ctx, 1, 2, 5))
+		keys.AssertExpectations(t)
+		audit.AssertExpectations(t)
+
+		// Revoked keys cannot be rotated
+		keys.On("FindByID", ctx, int64(6)).Return(&domain.APIKey{ID: 6, UserID: 2, RevokedAt: &now}, nil)
+		_, _, err := s.RotateKey(ctx, 1, 2, 6)
+		assert.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+	})
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+// auditPartitionsAhead is the number of monthly partitions that exist ahead
+// of the current one.
+const auditPartitionsAhead = 2
+
+// auditArchiveStatusSize is the number of recent archives in the status.
+const auditArchiveStatusSize = 50
+
+type auditArchiveService struct {
+	archiveRepo repository.AuditArchiveRepository
+	retention   domain.AuditRetention
+	dir         string
+	key         []byte
+	now         func() time.Time
+
+	mu      sync.Mutex
+	lastRun *domain.AuditArchiveRun
+}
+
+// NewAuditArchiveService creates a new AuditArchiveUseCase implementation
+// that writes archives to dir. Gaps in the hash chain are signed with key,
+// the audit checkpoint key.
+func NewAuditArchiveService(archiveRepo repository.AuditArchiveRepository, retention domain.AuditRetention, dir string, key []byte) usecase.AuditArchiveUseCase {
+	return &auditArchiveService{
+		archiveRepo: archiveRepo,
+		retention:   retention,
+		dir:         dir,
+		key:         key,
+		now:         time.Now,
+	}
+}
+
+// Run creates the partitions of the coming months, then archives partitions
+// in which every entry has expired and drops them, and archives and removes
+// the expired entries of the others. It stops without an error if another
+// server is archiving.
+func (s *auditArchiveService) Run(ctx context.Context) (*domain.AuditArchiveRun, error) {
+	now := s.now().UTC().Truncate(time.Second)
+	run := &domain.AuditArchiveRun{StartedAt: now}
+	err := s.run(ctx, run, now)
+	run.FinishedAt = s.now().UTC()
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.lastRun = run
+	s.mu.Unlock()
+	return run, err
+}
+
+func (s *auditArchiveService) run(ctx context.Context, run *domain.AuditArchiveRun, now time.Time) error {
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= auditPartitionsAhead; i++ {
+		if err := s.archiveRepo.EnsurePartition(ctx, month.AddDate(0, i, 0)); err != nil {
+			return fmt.Errorf("creating partition: %w", err)
+		}
+	}
+
+	shortest := s.retention.Shortest()
+	if shortest == 0 {
+		return nil
+	}
+	longest := s.retention.Longest()
+
+	partitions, err := s.archiveRepo.ListPartitions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range partitions {
+		archive := &domain.AuditArchive{Partition: p.Name, CreatedAt: now}
+		switch {
+		case longest > 0 && !p.To.IsZero() && !p.To.After(now.Add(-longest)):
+			archive.Dropped = true
+		case !p.From.IsZero() && !p.From.Before(now.Add(-shortest)):
+			continue // Nothing in it has expired yet
+		}
+
+		err := s.archive(ctx, archive)
+		if errors.Is(err, domain.ErrAuditArchiveBusy) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("archiving %s: %w", p.Name, err)
+		}
+		if archive.ID != 0 {
+			run.Archives = append(run.Archives, archive)
+		}
+	}
+	return nil
+}
+
+func (s *auditArchiveService) archive(ctx context.Context, archive *domain.AuditArchive) error {
+	name := fmt.Sprintf("%s-%s.jsonl.gz", archive.Partition, archive.CreatedAt.Format("20060102T150405Z"))
+	archive.File = filepath.Join(s.dir, name)
+	f, err := createAuditArchiveFile(archive.File, s.key)
+	if err != nil {
+		return err
+	}
+	if err := s.archiveRepo.Archive(ctx, archive, s.retention, f); err != nil {
+		f.discard()
+		return err
+	}
+	if archive.ID == 0 {
+		f.discard() // Nothing expired
+	}
+	return nil
+}
+
+func (s *auditArchiveService) Status(ctx context.Context) (*domain.AuditArchiveStatus, error) {
+	partitions, err := s.archiveRepo.ListPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	archives, err := s.archiveRepo.ListArchives(ctx, auditArchiveStatusSize)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	lastRun := s.lastRun
+	s.mu.Unlock()
+	return &domain.AuditArchiveStatus{
+		Retention:  s.retention,
+		Partitions: partitions,
+		Archives:   archives,
+		LastRun:    lastRun,
+	}, nil
+}
+
+// RunAuditArchiver runs the archiver at once and then every interval until
+// ctx is cancelled.
+func RunAuditArchiver(ctx context.Context, uc usecase.AuditArchiveUseCase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		run, err := uc.Run(ctx)
+		if err != nil {
+			log.Printf("Failed to archive audit log: %v", err)
+		}
+		for _, a := range run.Archives {
+			log.Printf("Archived %d audit log entries of %s to %s", a.Entries, a.Partition, a.File)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// auditArchiveLine is one entry of an archive file. It keeps the hashes, so
+// that the archived part of the chain can still be verified.
+type auditArchiveLine struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"userId"`
+	Action    string          `json:"action"`
+	TargetID  int64           `json:"targetId"`
+	OldValue  json.RawMessage `json:"oldValue,omitempty"`
+	NewValue  json.RawMessage `json:"newValue,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	ChangeID  string          `json:"changeId,omitempty"`
+	EventID   string          `json:"eventId,omitempty"`
+	PrevHash  string          `json:"prevHash,omitempty"`
+	Hash      string          `json:"hash,omitempty"`
+}
+
+// auditArchiveFile writes entries as gzip-compressed JSON lines. It writes to
+// a temporary file that is renamed once it is complete.
+type auditArchiveFile struct {
+	path string
+	key  []byte
+	f    *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+	gaps domain.AuditGapTracker
+}
+
+func createAuditArchiveFile(path string, key []byte) (*auditArchiveFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return &auditArchiveFile{path: path, key: key, f: f, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+func (a *auditArchiveFile) Write(l *domain.AuditLog) error {
+	a.gaps.Add(l)
+	return a.enc.Encode(auditArchiveLine{
+		ID:        l.ID,
+		UserID:    l.UserID,
+		Action:    string(l.Action),
+		TargetID:  l.TargetID,
+		OldValue:  l.OldValue,
+		NewValue:  l.NewValue,
+		Timestamp: l.Timestamp,
+		ChangeID:  l.ChangeID,
+		EventID:   l.EventID,
+		PrevHash:  l.PrevHash,
+		Hash:      l.Hash,
+	})
+}
+
+// Close flushes the file to disk before it is renamed, since the entries
+// are deleted from the database afterwards.
+func (a *auditArchiveFile) Close() ([]*domain.AuditChainGap, error) {
+	err := a.gz.Close()
+	if err == nil {
+		err = a.f.Sync()
+	}
+	if closeErr := a.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(a.path+".tmp", a.path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a.gaps.Gaps(a.key), nil
+}
+
+// discard removes the file of an archive that was not recorded.
+func (a *auditArchiveFile) discard() {
+	a.f.Close()
+	os.Remove(a.path + ".tmp")
+	os.Remove(a.path)
+}
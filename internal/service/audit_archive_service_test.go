@@ -0,0 +1,218 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAuditArchiveRepository is a mock of AuditArchiveRepository
+type MockAuditArchiveRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditArchiveRepository) EnsurePartition(ctx context.Context, month time.Time) error {
+	args := m.Called(ctx, month)
+	return args.Error(0)
+}
+
+func (m *MockAuditArchiveRepository) ListPartitions(ctx context.Context) ([]*domain.AuditPartition, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.AuditPartition), args.Error(1)
+}
+
+func (m *MockAuditArchiveRepository) Archive(ctx context.Context, archive *domain.AuditArchive, retention domain.AuditRetention, w repository.AuditArchiveWriter) error {
+	args := m.Called(ctx, archive, retention, w)
+	return args.Error(0)
+}
+
+func (m *MockAuditArchiveRepository) ListArchives(ctx context.Context, limit int) ([]*domain.AuditArchive, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.AuditArchive), args.Error(1)
+}
+
+func (m *MockAuditArchiveRepository) ListGaps(ctx context.Context) ([]*domain.AuditChainGap, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.AuditChainGap), args.Error(1)
+}
+
+func forPartition(name string) interface{} {
+	return mock.MatchedBy(func(a *domain.AuditArchive) bool { return a.Partition == name })
+}
+
+// archiveEntries makes a mocked Archive write logs and record the archive.
+func archiveEntries(logs ...*domain.AuditLog) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		archive, w := args.Get(1).(*domain.AuditArchive), args.Get(3).(repository.AuditArchiveWriter)
+		for _, l := range logs {
+			if err := w.Write(l); err != nil {
+				panic(err)
+			}
+		}
+		if _, err := w.Close(); err != nil {
+			panic(err)
+		}
+		archive.Entries = int64(len(logs))
+		if len(logs) > 0 || archive.Dropped {
+			archive.ID = 1
+		}
+	}
+}
+
+func readArchive(t *testing.T, path string) []auditArchiveLine {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	var lines []auditArchiveLine
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var line auditArchiveLine
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func TestAuditArchiveService_Run(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	month := func(m time.Month) time.Time { return time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC) }
+	retention, err := domain.ParseAuditRetention("USER_LOGIN_FAILURE=30d,*=90d")
+	require.NoError(t, err)
+
+	newService := func(t *testing.T, repo *MockAuditArchiveRepository) (*auditArchiveService, string) {
+		dir := t.TempDir()
+		s := NewAuditArchiveService(repo, retention, dir, []byte("key")).(*auditArchiveService)
+		s.now = func() time.Time { return now }
+		for m := time.June; m <= time.August; m++ {
+			repo.On("EnsurePartition", ctx, month(m)).Return(nil).Once()
+		}
+		return s, dir
+	}
+
+	t.Run("Drops expired partitions and prunes the others", func(t *testing.T) {
+		repo := new(MockAuditArchiveRepository)
+		s, dir := newService(t, repo)
+		repo.On("ListPartitions", ctx).Return([]*domain.AuditPartition{
+			{Name: "audit_logs_legacy", To: month(time.February)},
+			{Name: "audit_logs_2024_03", From: month(time.March), To: month(time.April)},
+			{Name: "audit_logs_2024_05", From: month(time.May), To: month(time.June)},
+			{Name: "audit_logs_2024_06", From: month(time.June), To: month(time.July)},
+			{Name: "audit_logs_default"},
+		}, nil).Once()
+
+		logs := auditChain(3, 0)
+		dropped := mock.MatchedBy(func(a *domain.AuditArchive) bool { return a.Partition == "audit_logs_legacy" && a.Dropped })
+		repo.On("Archive", ctx, dropped, retention, mock.Anything).Run(archiveEntries(logs...)).Return(nil).Once()
+		repo.On("Archive", ctx, forPartition("audit_logs_2024_03"), retention, mock.Anything).Run(archiveEntries()).Return(nil).Once()
+		repo.On("Archive", ctx, forPartition("audit_logs_2024_05"), retention, mock.Anything).Run(archiveEntries()).Return(nil).Once()
+		repo.On("Archive", ctx, forPartition("audit_logs_default"), retention, mock.Anything).Run(archiveEntries()).Return(nil).Once()
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		repo.AssertExpectations(t)
+
+		// The partition of this month has nothing expired
+		repo.AssertNumberOfCalls(t, "Archive", 4)
+		require.Len(t, run.Archives, 1)
+		archive := run.Archives[0]
+		assert.Equal(t, filepath.Join(dir, "audit_logs_legacy-20240615T120000Z.jsonl.gz"), archive.File)
+		assert.Equal(t, now, archive.CreatedAt)
+
+		lines := readArchive(t, archive.File)
+		require.Len(t, lines, 3)
+		assert.Equal(t, int64(1), lines[0].ID)
+		assert.Equal(t, logs[2].Hash, lines[2].Hash)
+		assert.JSONEq(t, `{"value":"10.0.0.1"}`, string(lines[0].NewValue))
+
+		// Archives without entries leave no file behind
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, files, 1)
+	})
+
+	t.Run("Stops while another server archives", func(t *testing.T) {
+		repo := new(MockAuditArchiveRepository)
+		s, dir := newService(t, repo)
+		repo.On("ListPartitions", ctx).Return([]*domain.AuditPartition{
+			{Name: "audit_logs_2024_03", From: month(time.March), To: month(time.April)},
+			{Name: "audit_logs_2024_05", From: month(time.May), To: month(time.June)},
+		}, nil).Once()
+		repo.On("Archive", ctx, forPartition("audit_logs_2024_03"), retention, mock.Anything).Return(domain.ErrAuditArchiveBusy).Once()
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, run.Archives)
+		repo.AssertNumberOfCalls(t, "Archive", 1)
+
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("Keeps everything without a retention policy", func(t *testing.T) {
+		repo := new(MockAuditArchiveRepository)
+		s, _ := newService(t, repo)
+		s.retention = domain.AuditRetention{}
+
+		_, err := s.Run(ctx)
+		require.NoError(t, err)
+		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "ListPartitions", mock.Anything)
+	})
+}
+
+func TestAuditArchiveService_Status(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockAuditArchiveRepository)
+	retention := domain.AuditRetention{Default: time.Hour}
+	s := NewAuditArchiveService(repo, retention, t.TempDir(), nil)
+
+	// Nothing in the partition has expired yet
+	partitions := []*domain.AuditPartition{{Name: "audit_logs_2024_06", From: time.Now(), Rows: 10}}
+	archives := []*domain.AuditArchive{{ID: 2}, {ID: 1}}
+	repo.On("EnsurePartition", ctx, mock.Anything).Return(nil)
+	repo.On("ListPartitions", ctx).Return(partitions, nil)
+	repo.On("ListArchives", ctx, auditArchiveStatusSize).Return(archives, nil)
+
+	status, err := s.Status(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, status.LastRun)
+
+	_, err = s.Run(ctx)
+	require.NoError(t, err)
+	status, err = s.Status(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, retention, status.Retention)
+	assert.Equal(t, partitions, status.Partitions)
+	assert.Equal(t, archives, status.Archives)
+	require.NotNil(t, status.LastRun)
+	assert.Empty(t, status.LastRun.Error)
+}
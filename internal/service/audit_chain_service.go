@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+// auditVerifyPageSize is the number of entries read at a time while
+// verifying the chain.
+const auditVerifyPageSize = 1000
+
+type auditChainService struct {
+	auditRepo      repository.AuditLogRepository
+	checkpointRepo repository.AuditCheckpointRepository
+	archiveRepo    repository.AuditArchiveRepository
+	key            []byte
+}
+
+// NewAuditChainService creates a new AuditChainUseCase implementation.
+// Checkpoints and gaps left by archived entries are signed with key; without
+// a key checkpoints are neither created nor verified, and gaps are trusted.
+func NewAuditChainService(auditRepo repository.AuditLogRepository, checkpointRepo repository.AuditCheckpointRepository, archiveRepo repository.AuditArchiveRepository, key []byte) usecase.AuditChainUseCase {
+	return &auditChainService{
+		auditRepo:      auditRepo,
+		checkpointRepo: checkpointRepo,
+		archiveRepo:    archiveRepo,
+		key:            key,
+	}
+}
+
+// VerifyChain checks every hashed entry against its content and its
+// predecessor, oldest first. Entries written before hashing are skipped. The
+// first verified entry is trusted to follow on from its predecessor, which
+// may have been removed by retention; checkpoints of later entries detect a
+// chain that was rewritten or cut short. Entries removed by retention later
+// in the chain are skipped through the gaps recorded for them.
+func (s *auditChainService) VerifyChain(ctx context.Context) (*domain.AuditChainReport, error) {
+	var checkpoints []*domain.AuditCheckpoint
+	if len(s.key) > 0 {
+		var err error
+		if checkpoints, err = s.checkpointRepo.List(ctx); err != nil {
+			return nil, err
+		}
+	}
+	gapList, err := s.archiveRepo.ListGaps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gaps := make(map[string]*domain.AuditChainGap, len(gapList))
+	for _, g := range gapList {
+		gaps[g.ToHash] = g
+	}
+
+	report := &domain.AuditChainReport{Valid: true}
+	fail := func(logID, checkpointID int64, reason string) {
+		report.Valid = false
+		report.Broken = &domain.AuditChainBreak{LogID: logID, CheckpointID: checkpointID, Reason: reason}
+	}
+
+	var prev *domain.AuditLog
+	var afterID int64
+	next := 0
+walk:
+	for {
+		logs, err := s.auditRepo.FindAfter(ctx, afterID, auditVerifyPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range logs {
+			afterID = l.ID
+			if l.Hash == "" && report.Checked == 0 {
+				report.Unhashed++
+				prev = l
+				continue
+			}
+
+			switch {
+			case l.Hash == "":
+				fail(l.ID, 0, "entry is not hashed")
+			case l.ComputeHash(l.PrevHash) != l.Hash:
+				fail(l.ID, 0, "entry does not match its hash")
+			case prev != nil && l.PrevHash != prev.Hash:
+				n, reason := s.bridge(gaps, l.PrevHash, prev.Hash)
+				if reason != "" {
+					fail(l.ID, 0, reason)
+				}
+				report.Gaps += n
+			}
+			if !report.Valid {
+				break walk
+			}
+			if report.Checked == 0 {
+				report.FirstID = l.ID
+			}
+			report.Checked++
+			report.LastID = l.ID
+			prev = l
+
+			for ; next < len(checkpoints) && checkpoints[next].LogID <= l.ID; next++ {
+				cp := checkpoints[next]
+				switch {
+				case cp.LogID < report.FirstID:
+					continue // Covers entries before the verified ones
+				case !cp.VerifySignature(s.key):
+					fail(cp.LogID, cp.ID, "checkpoint signature is invalid")
+				case cp.LogID < l.ID:
+					fail(cp.LogID, cp.ID, "entry of the checkpoint is missing")
+				case cp.Hash != l.Hash:
+					fail(l.ID, cp.ID, "entry does not match the checkpoint")
+				}
+				if !report.Valid {
+					break walk
+				}
+				report.Checkpoints++
+			}
+		}
+		if len(logs) < auditVerifyPageSize {
+			break
+		}
+	}
+
+	// Checkpoints beyond the last entry mean that entries were cut off
+	if report.Valid && next < len(checkpoints) {
+		cp := checkpoints[next]
+		if !cp.VerifySignature(s.key) {
+			fail(cp.LogID, cp.ID, "checkpoint signature is invalid")
+		} else {
+			fail(cp.LogID, cp.ID, "entries up to the checkpoint are missing")
+		}
+	}
+	return report, nil
+}
+
+// bridge follows the gaps left by archived entries back from hash, the
+// previous hash of an entry, to prevHash, the hash of the entry before it.
+// It returns the number of gaps in between, or why they do not lead there.
+func (s *auditChainService) bridge(gaps map[string]*domain.AuditChainGap, hash, prevHash string) (int, string) {
+	n := 0
+	for hash != prevHash {
+		g, ok := gaps[hash]
+		if !ok || n == len(gaps) {
+			return 0, "previous entry was changed or deleted"
+		}
+		if len(s.key) > 0 && !g.VerifySignature(s.key) {
+			return 0, "gap signature is invalid"
+		}
+		hash = g.FromHash
+		n++
+	}
+	return n, ""
+}
+
+func (s *auditChainService) CreateCheckpoint(ctx context.Context) (*domain.AuditCheckpoint, error) {
+	if len(s.key) == 0 {
+		return nil, domain.ErrAuditCheckpointsNotSet
+	}
+
+	logs, err := s.auditRepo.Find(ctx, domain.AuditLogFilter{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 || logs[0].Hash == "" {
+		return nil, domain.ErrAuditChainEmpty
+	}
+	head := logs[0]
+
+	latest, err := s.checkpointRepo.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.LogID == head.ID {
+		return latest, nil
+	}
+
+	cp := domain.NewAuditCheckpoint(head, s.key)
+	if err := s.checkpointRepo.Create(ctx, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// RunAuditCheckpointer signs the head of the audit log every interval until
+// ctx is cancelled.
+func RunAuditCheckpointer(ctx context.Context, uc usecase.AuditChainUseCase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := uc.CreateCheckpoint(ctx); err != nil && !errors.Is(err, domain.ErrAuditChainEmpty) {
+				log.Printf("Failed to create audit checkpoint: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAuditCheckpointRepository is a mock of AuditCheckpointRepository
+type MockAuditCheckpointRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditCheckpointRepository) Create(ctx context.Context, cp *domain.AuditCheckpoint) error {
+	args := m.Called(ctx, cp)
+	return args.Error(0)
+}
+
+func (m *MockAuditCheckpointRepository) Latest(ctx context.Context) (*domain.AuditCheckpoint, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AuditCheckpoint), args.Error(1)
+}
+
+func (m *MockAuditCheckpointRepository) List(ctx context.Context) ([]*domain.AuditCheckpoint, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.AuditCheckpoint), args.Error(1)
+}
+
+// auditChain returns entries 1 to n, the first unhashed ones written before
+// hashing was introduced and the others chained like the repository does.
+func auditChain(n, unhashed int) []*domain.AuditLog {
+	at := time.Unix(1700000000, 0).UTC()
+	logs := make([]*domain.AuditLog, n)
+	var prevHash string
+	for i := range logs {
+		l := &domain.AuditLog{
+			ID:        int64(i + 1),
+			UserID:    1,
+			Action:    domain.ActionUpdateDNSRecord,
+			TargetID:  7,
+			NewValue:  json.RawMessage(`{"value":"10.0.0.1"}`),
+			Timestamp: at.Add(time.Duration(i) * time.Second),
+		}
+		if i >= unhashed {
+			l.PrevHash = prevHash
+			l.Hash = l.ComputeHash(prevHash)
+			prevHash = l.Hash
+		}
+		logs[i] = l
+	}
+	return logs
+}
+
+func without(logs []*domain.AuditLog, ids ...int64) []*domain.AuditLog {
+	var out []*domain.AuditLog
+outer:
+	for _, l := range logs {
+		for _, id := range ids {
+			if l.ID == id {
+				continue outer
+			}
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+func TestAuditChainService_VerifyChain(t *testing.T) {
+	ctx := context.Background()
+	key := []byte("checkpoint-key")
+
+	verifyWithGaps := func(t *testing.T, logs []*domain.AuditLog, gaps []*domain.AuditChainGap, checkpoints ...*domain.AuditCheckpoint) *domain.AuditChainReport {
+		t.Helper()
+		mockAuditRepo := new(MockAuditLogRepository)
+		mockCheckpointRepo := new(MockAuditCheckpointRepository)
+		mockArchiveRepo := new(MockAuditArchiveRepository)
+		mockAuditRepo.On("FindAfter", ctx, int64(0), auditVerifyPageSize).Return(logs, nil).Once()
+		mockCheckpointRepo.On("List", ctx).Return(checkpoints, nil).Once()
+		mockArchiveRepo.On("ListGaps", ctx).Return(gaps, nil).Once()
+
+		report, err := NewAuditChainService(mockAuditRepo, mockCheckpointRepo, mockArchiveRepo, key).VerifyChain(ctx)
+		require.NoError(t, err)
+		return report
+	}
+	verify := func(t *testing.T, logs []*domain.AuditLog, checkpoints ...*domain.AuditCheckpoint) *domain.AuditChainReport {
+		t.Helper()
+		return verifyWithGaps(t, logs, nil, checkpoints...)
+	}
+	archived := func(logs []*domain.AuditLog, key []byte) []*domain.AuditChainGap {
+		var tracker domain.AuditGapTracker
+		for _, l := range logs {
+			tracker.Add(l)
+		}
+		return tracker.Gaps(key)
+	}
+
+	t.Run("Intact chain after unhashed entries", func(t *testing.T) {
+		logs := auditChain(6, 2)
+		report := verify(t, logs, domain.NewAuditCheckpoint(logs[3], key), domain.NewAuditCheckpoint(logs[5], key))
+
+		assert.True(t, report.Valid)
+		assert.Nil(t, report.Broken)
+		assert.Equal(t, int64(2), report.Unhashed)
+		assert.Equal(t, int64(4), report.Checked)
+		assert.Equal(t, int64(3), report.FirstID)
+		assert.Equal(t, int64(6), report.LastID)
+		assert.Equal(t, 2, report.Checkpoints)
+	})
+
+	t.Run("Edited entry", func(t *testing.T) {
+		logs := auditChain(5, 0)
+		logs[2].NewValue = json.RawMessage(`{"value":"10.6.6.6"}`)
+		report := verify(t, logs)
+
+		assert.False(t, report.Valid)
+		assert.Equal(t, &domain.AuditChainBreak{LogID: 3, Reason: "entry does not match its hash"}, report.Broken)
+		assert.Equal(t, int64(2), report.Checked)
+	})
+
+	t.Run("Deleted entry", func(t *testing.T) {
+		report := verify(t, without(auditChain(5, 0), 3))
+
+		assert.False(t, report.Valid)
+		assert.Equal(t, int64(4), report.Broken.LogID)
+		assert.Equal(t, "previous entry was changed or deleted", report.Broken.Reason)
+	})
+
+	t.Run("Rewritten chain is caught by a checkpoint", func(t *testing.T) {
+		logs := auditChain(5, 0)
+		cp := domain.NewAuditCheckpoint(logs[3], key)
+
+		// Edit entry 2 and recompute the hashes after it
+		logs[1].UserID = 2
+		for i := 1; i < len(logs); i++ {
+			logs[i].PrevHash = logs[i-1].Hash
+			logs[i].Hash = logs[i].ComputeHash(logs[i].PrevHash)
+		}
+		report := verify(t, logs, cp)
+
+		assert.False(t, report.Valid)
+		assert.Equal(t, &domain.AuditChainBreak{LogID: 4, CheckpointID: cp.ID, Reason: "entry does not match the checkpoint"}, report.Broken)
+	})
+
+	t.Run("Cut off entries are caught by a checkpoint", func(t *testing.T) {
+		logs := auditChain(5, 0)
+		cp := domain.NewAuditCheckpoint(logs[4], key)
+		report := verify(t, logs[:3], cp)
+
+		assert.False(t, report.Valid)
+		assert.Equal(t, int64(5), report.Broken.LogID)
+		assert.Equal(t, "entries up to the checkpoint are missing", report.Broken.Reason)
+	})
+
+	t.Run("Forged checkpoint", func(t *testing.T) {
+		logs := auditChain(3, 0)
+		cp := domain.NewAuditCheckpoint(logs[1], []byte("other-key"))
+		report := verify(t, logs, cp)
+
+		assert.False(t, report.Valid)
+		assert.Equal(t, "checkpoint signature is invalid", report.Broken.Reason)
+	})
+
+	t.Run("Pruned entries and their checkpoints are skipped", func(t *testing.T) {
+		logs := auditChain(6, 0)
+		old := domain.NewAuditCheckpoint(logs[1], key)
+		report := verify(t, logs[3:], old, domain.NewAuditCheckpoint(logs[4], key))
+
+		assert.True(t, report.Valid)
+		assert.Equal(t, int64(4), report.FirstID)
+		assert.Equal(t, 1, report.Checkpoints)
+	})
+
+	t.Run("Archived entries are skipped through their gaps", func(t *testing.T) {
+		logs := auditChain(8, 0)
+		// Entries 3 and 4 were archived in one run, entry 5 in a later one
+		gaps := append(archived(logs[2:4], key), archived(logs[4:5], key)...)
+		gaps = append(gaps, archived(logs[6:7], key)...)
+		report := verifyWithGaps(t, without(logs, 3, 4, 5, 7), gaps, domain.NewAuditCheckpoint(logs[7], key))
+
+		assert.True(t, report.Valid)
+		assert.Equal(t, int64(4), report.Checked)
+		assert.Equal(t, 3, report.Gaps)
+		assert.Equal(t, 1, report.Checkpoints)
+	})
+
+	t.Run("Deleted entry next to a gap", func(t *testing.T) {
+		logs := auditChain(6, 0)
+		report := verifyWithGaps(t, without(logs, 2, 3, 4), archived(logs[2:4], key))
+
+		assert.False(t, report.Valid)
+		assert.Equal(t, &domain.AuditChainBreak{LogID: 5, Reason: "previous entry was changed or deleted"}, report.Broken)
+	})
+
+	t.Run("Forged gap", func(t *testing.T) {
+		logs := auditChain(5, 0)
+		report := verifyWithGaps(t, without(logs, 3), archived(logs[2:3], []byte("other-key")))
+
+		assert.False(t, report.Valid)
+		assert.Equal(t, &domain.AuditChainBreak{LogID: 4, Reason: "gap signature is invalid"}, report.Broken)
+	})
+}
+
+func TestAuditChainService_CreateCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	key := []byte("checkpoint-key")
+	head := auditChain(3, 0)[2]
+
+	t.Run("Signs the newest entry", func(t *testing.T) {
+		mockAuditRepo := new(MockAuditLogRepository)
+		mockCheckpointRepo := new(MockAuditCheckpointRepository)
+		mockAuditRepo.On("Find", ctx, domain.AuditLogFilter{Limit: 1}).Return([]*domain.AuditLog{head}, nil).Once()
+		mockCheckpointRepo.On("Latest", ctx).Return(&domain.AuditCheckpoint{LogID: 2}, nil).Once()
+		mockCheckpointRepo.On("Create", ctx, mock.Anything).Return(nil).Once()
+
+		cp, err := NewAuditChainService(mockAuditRepo, mockCheckpointRepo, new(MockAuditArchiveRepository), key).CreateCheckpoint(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(3), cp.LogID)
+		assert.Equal(t, head.Hash, cp.Hash)
+		assert.True(t, cp.VerifySignature(key))
+		mockCheckpointRepo.AssertExpectations(t)
+	})
+
+	t.Run("Keeps the latest checkpoint without new entries", func(t *testing.T) {
+		mockAuditRepo := new(MockAuditLogRepository)
+		mockCheckpointRepo := new(MockAuditCheckpointRepository)
+		latest := &domain.AuditCheckpoint{ID: 9, LogID: 3}
+		mockAuditRepo.On("Find", ctx, domain.AuditLogFilter{Limit: 1}).Return([]*domain.AuditLog{head}, nil).Once()
+		mockCheckpointRepo.On("Latest", ctx).Return(latest, nil).Once()
+
+		cp, err := NewAuditChainService(mockAuditRepo, mockCheckpointRepo, new(MockAuditArchiveRepository), key).CreateCheckpoint(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, latest, cp)
+		mockCheckpointRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Requires a key", func(t *testing.T) {
+		_, err := NewAuditChainService(new(MockAuditLogRepository), new(MockAuditCheckpointRepository), new(MockAuditArchiveRepository), nil).CreateCheckpoint(ctx)
+		assert.ErrorIs(t, err, domain.ErrAuditCheckpointsNotSet)
+	})
+}
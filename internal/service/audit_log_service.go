@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+// auditExportPageSize is the number of entries read at a time while exporting.
+const auditExportPageSize = 1000
+
+type auditLogService struct {
+	auditRepo repository.AuditLogRepository
+}
+
+// NewAuditLogService creates a new AuditLogUseCase implementation.
+func NewAuditLogService(auditRepo repository.AuditLogRepository) usecase.AuditLogUseCase {
+	return &auditLogService{auditRepo: auditRepo}
+}
+
+// ListAuditLogs returns one page of entries matching filter, newest first.
+func (s *auditLogService) ListAuditLogs(ctx context.Context, filter domain.AuditLogFilter) (*domain.AuditLogPage, error) {
+	if err := validateAuditLogFilter(filter); err != nil {
+		return nil, err
+	}
+	if filter.Limit < 1 {
+		filter.Limit = domain.DefaultAuditLogPageSize
+	}
+	if filter.Limit > domain.MaxAuditLogPageSize {
+		filter.Limit = domain.MaxAuditLogPageSize
+	}
+
+	// One more entry than requested tells whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	logs, err := s.auditRepo.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.AuditLogPage{Items: logs}
+	if len(logs) > limit {
+		page.Items = logs[:limit]
+		page.NextCursor = page.Items[limit-1].ID
+	}
+	return page, nil
+}
+
+// ListUserActivity returns one page of the entries of actions taken by the
+// user.
+func (s *auditLogService) ListUserActivity(ctx context.Context, userID int64, filter domain.AuditLogFilter) (*domain.AuditLogPage, error) {
+	filter.UserID = &userID
+	return s.ListAuditLogs(ctx, filter)
+}
+
+// ExportAuditLogs reads all entries matching filter page by page.
+func (s *auditLogService) ExportAuditLogs(ctx context.Context, filter domain.AuditLogFilter, fn func(*domain.AuditLog) error) error {
+	if err := validateAuditLogFilter(filter); err != nil {
+		return err
+	}
+	filter.BeforeID = 0
+	filter.Limit = auditExportPageSize
+	for {
+		logs, err := s.auditRepo.Find(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, log := range logs {
+			if err := fn(log); err != nil {
+				return err
+			}
+		}
+		if len(logs) < filter.Limit {
+			return nil
+		}
+		filter.BeforeID = logs[len(logs)-1].ID
+	}
+}
+
+func validateAuditLogFilter(filter domain.AuditLogFilter) error {
+	if filter.BeforeID < 0 {
+		return domain.ErrInvalidAuditLogFilter
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return domain.ErrInvalidAuditLogFilter
+	}
+	return nil
+}
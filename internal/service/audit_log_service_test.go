@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func auditLogsWithIDs(ids ...int64) []*domain.AuditLog {
+	logs := make([]*domain.AuditLog, len(ids))
+	for i, id := range ids {
+		logs[i] = &domain.AuditLog{ID: id, Action: domain.ActionCreateDNSRecord}
+	}
+	return logs
+}
+
+func TestAuditLogService_ListAuditLogs(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Returns a cursor when there are more entries", func(t *testing.T) {
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewAuditLogService(mockAuditRepo)
+		mockAuditRepo.On("Find", ctx, domain.AuditLogFilter{Actions: []domain.ActionType{domain.ActionCreateDNSRecord}, BeforeID: 100, Limit: 4}).
+			Return(auditLogsWithIDs(99, 97, 96, 90), nil).Once()
+
+		page, err := service.ListAuditLogs(ctx, domain.AuditLogFilter{Actions: []domain.ActionType{domain.ActionCreateDNSRecord}, BeforeID: 100, Limit: 3})
+		require.NoError(t, err)
+
+		require.Len(t, page.Items, 3)
+		assert.Equal(t, int64(96), page.NextCursor)
+		mockAuditRepo.AssertExpectations(t)
+	})
+
+	t.Run("Last page has no cursor and limits are clamped", func(t *testing.T) {
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewAuditLogService(mockAuditRepo)
+		mockAuditRepo.On("Find", ctx, domain.AuditLogFilter{Limit: domain.MaxAuditLogPageSize + 1}).
+			Return(auditLogsWithIDs(2, 1), nil).Once()
+
+		page, err := service.ListAuditLogs(ctx, domain.AuditLogFilter{Limit: 10000})
+		require.NoError(t, err)
+
+		assert.Len(t, page.Items, 2)
+		assert.Zero(t, page.NextCursor)
+	})
+
+	t.Run("Activity is limited to the user", func(t *testing.T) {
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewAuditLogService(mockAuditRepo)
+		mockAuditRepo.On("Find", ctx, mock.MatchedBy(func(f domain.AuditLogFilter) bool {
+			return f.UserID != nil && *f.UserID == 7 && f.Limit == domain.DefaultAuditLogPageSize+1
+		})).Return(auditLogsWithIDs(5), nil).Once()
+
+		other := int64(8)
+		page, err := service.ListUserActivity(ctx, 7, domain.AuditLogFilter{UserID: &other})
+		require.NoError(t, err)
+		assert.Len(t, page.Items, 1)
+		mockAuditRepo.AssertExpectations(t)
+	})
+
+	t.Run("Rejects an empty time range", func(t *testing.T) {
+		service := NewAuditLogService(new(MockAuditLogRepository))
+		now := time.Now()
+		_, err := service.ListAuditLogs(ctx, domain.AuditLogFilter{From: now, To: now})
+		assert.ErrorIs(t, err, domain.ErrInvalidAuditLogFilter)
+	})
+}
+
+func TestAuditLogService_ExportAuditLogs(t *testing.T) {
+	ctx := context.Background()
+	mockAuditRepo := new(MockAuditLogRepository)
+	service := NewAuditLogService(mockAuditRepo)
+
+	full := make([]int64, auditExportPageSize)
+	for i := range full {
+		full[i] = int64(2000 - i)
+	}
+	mockAuditRepo.On("Find", ctx, domain.AuditLogFilter{Limit: auditExportPageSize}).
+		Return(auditLogsWithIDs(full...), nil).Once()
+	mockAuditRepo.On("Find", ctx, domain.AuditLogFilter{BeforeID: 1001, Limit: auditExportPageSize}).
+		Return(auditLogsWithIDs(3, 2, 1), nil).Once()
+
+	var count int
+	var last int64
+	err := service.ExportAuditLogs(ctx, domain.AuditLogFilter{BeforeID: 50, Limit: 5}, func(l *domain.AuditLog) error {
+		count++
+		last = l.ID
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, auditExportPageSize+3, count)
+	assert.Equal(t, int64(1), last)
+	mockAuditRepo.AssertExpectations(t)
+}
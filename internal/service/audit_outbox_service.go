@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/metrics"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+const (
+	// auditRelayBatchSize is the number of entries stored per transaction.
+	auditRelayBatchSize = 500
+	// auditClaimIdle is how long entries claimed by another relay stay
+	// unacknowledged before they are taken over.
+	auditClaimIdle = time.Minute
+)
+
+type auditOutboxService struct {
+	outbox   repository.AuditOutboxRepository
+	repo     repository.AuditLogRepository
+	metrics  *metrics.AuditMetrics
+	consumer string
+}
+
+// NewAuditOutboxService creates a new AuditOutboxUseCase implementation that
+// queues entries in outbox and relays them to repo.
+func NewAuditOutboxService(outbox repository.AuditOutboxRepository, repo repository.AuditLogRepository, m *metrics.AuditMetrics) usecase.AuditOutboxUseCase {
+	host, _ := os.Hostname()
+	return &auditOutboxService{
+		outbox:   outbox,
+		repo:     repo,
+		metrics:  m,
+		consumer: fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}
+}
+
+// Create queues entry, within the transaction of ctx if there is one, so
+// that the entry is committed or rolled back with the change it describes.
+func (s *auditOutboxService) Create(ctx context.Context, entry *domain.AuditLog) error {
+	if entry.EventID == "" {
+		entry.EventID = domain.NewChangeID()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+
+	if err := s.outbox.Push(ctx, entry); err != nil {
+		s.metrics.Failures.WithLabelValues(metrics.AuditStageEnqueue).Inc()
+		return err
+	}
+	s.metrics.Enqueued.Inc()
+	return nil
+}
+
+func (s *auditOutboxService) CreateBatch(ctx context.Context, entries []*domain.AuditLog) error {
+	var errs []error
+	for _, entry := range entries {
+		if err := s.Create(ctx, entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *auditOutboxService) FindByTarget(ctx context.Context, targetID int64, actions []domain.ActionType) ([]*domain.AuditLog, error) {
+	return s.repo.FindByTarget(ctx, targetID, actions)
+}
+
+func (s *auditOutboxService) Find(ctx context.Context, filter domain.AuditLogFilter) ([]*domain.AuditLog, error) {
+	return s.repo.Find(ctx, filter)
+}
+
+func (s *auditOutboxService) FindAfter(ctx context.Context, afterID int64, limit int) ([]*domain.AuditLog, error) {
+	return s.repo.FindAfter(ctx, afterID, limit)
+}
+
+// Relay stores a batch of queued entries in one transaction. If that fails,
+// the entries are stored one by one so that a single bad entry does not hold
+// up the others. Entries are acknowledged only once they are stored; stored
+// entries that are delivered again are skipped by their event ID.
+func (s *auditOutboxService) Relay(ctx context.Context) (int, error) {
+	defer s.updateBacklog(ctx)
+
+	entries, err := s.outbox.Claim(ctx, s.consumer, auditRelayBatchSize, auditClaimIdle)
+	if err != nil {
+		s.metrics.Failures.WithLabelValues(metrics.AuditStageClaim).Inc()
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	logs := make([]*domain.AuditLog, len(entries))
+	ids := make([]int64, len(entries))
+	for i, entry := range entries {
+		logs[i], ids[i] = entry.Log, entry.ID
+	}
+
+	var deliverErr error
+	if err := s.repo.CreateBatch(ctx, logs); err != nil {
+		s.metrics.Failures.WithLabelValues(metrics.AuditStageDeliver).Inc()
+		deliverErr = fmt.Errorf("failed to store audit logs: %w", err)
+
+		ids = ids[:0]
+		for i, l := range logs {
+			if err := s.repo.Create(ctx, l); err != nil {
+				continue
+			}
+			ids = append(ids, entries[i].ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, deliverErr
+	}
+
+	if err := s.outbox.Ack(ctx, ids...); err != nil {
+		s.metrics.Failures.WithLabelValues(metrics.AuditStageAck).Inc()
+		return 0, err
+	}
+	s.metrics.Delivered.Add(float64(len(ids)))
+	return len(ids), deliverErr
+}
+
+func (s *auditOutboxService) Flush(ctx context.Context) error {
+	for {
+		n, err := s.Relay(ctx)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+func (s *auditOutboxService) updateBacklog(ctx context.Context) {
+	if n, err := s.outbox.Len(ctx); err == nil {
+		s.metrics.Backlog.Set(float64(n))
+	}
+}
+
+// RunAuditOutboxRelay relays queued audit log entries to the database until
+// ctx is cancelled, then flushes the outbox. A full backlog is relayed without
+// pause; after a failure the relay waits twice as long as before, up to
+// maxBackoff.
+func RunAuditOutboxRelay(ctx context.Context, uc usecase.AuditOutboxUseCase, interval, maxBackoff time.Duration) {
+	backoff := interval
+	for {
+		delay := interval
+		n, err := uc.Relay(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to relay audit logs, retrying in %s: %v", backoff, err)
+			}
+			delay = backoff
+			backoff = min(backoff*2, maxBackoff)
+		} else {
+			backoff = interval
+			if n > 0 {
+				delay = 0
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			// Use a fresh context so the final flush is not cancelled with ctx
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := uc.Flush(flushCtx); err != nil {
+				log.Printf("Failed to flush audit outbox, entries stay queued: %v", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memAuditOutbox is an AuditOutboxRepository in memory. Push fails with
+// err if it is set.
+type memAuditOutbox struct {
+	mu      sync.Mutex
+	nextID  int64
+	entries map[int64]*memAuditOutboxEntry
+	err     error
+}
+
+type memAuditOutboxEntry struct {
+	log       *domain.AuditLog
+	claimedBy string
+	claimedAt time.Time
+}
+
+func newMemAuditOutbox() *memAuditOutbox {
+	return &memAuditOutbox{entries: make(map[int64]*memAuditOutboxEntry)}
+}
+
+func (o *memAuditOutbox) Push(ctx context.Context, log *domain.AuditLog) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return o.err
+	}
+	o.nextID++
+	o.entries[o.nextID] = &memAuditOutboxEntry{log: log}
+	return nil
+}
+
+func (o *memAuditOutbox) Claim(ctx context.Context, consumer string, count int, minIdle time.Duration) ([]domain.AuditOutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ids := make([]int64, 0, len(o.entries))
+	for id := range o.entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var claimed []domain.AuditOutboxEntry
+	for _, id := range ids {
+		e := o.entries[id]
+		if e.claimedBy != "" && e.claimedBy != consumer && time.Since(e.claimedAt) < minIdle {
+			continue
+		}
+		e.claimedBy, e.claimedAt = consumer, time.Now()
+		claimed = append(claimed, domain.AuditOutboxEntry{ID: id, Log: e.log})
+		if len(claimed) == count {
+			break
+		}
+	}
+	return claimed, nil
+}
+
+func (o *memAuditOutbox) Ack(ctx context.Context, ids ...int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, id := range ids {
+		delete(o.entries, id)
+	}
+	return nil
+}
+
+func (o *memAuditOutbox) Len(ctx context.Context) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return int64(len(o.entries)), nil
+}
+
+// storedAuditLogs records the entries stored through a MockAuditLogRepository
+// by event ID, the way the database skips redelivered entries.
+type storedAuditLogs struct {
+	mu   sync.Mutex
+	logs map[string]*domain.AuditLog
+}
+
+func (s *storedAuditLogs) add(logs ...*domain.AuditLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range logs {
+		s.logs[l.EventID] = l
+	}
+}
+
+func setupAuditOutbox() (*memAuditOutbox, *MockAuditLogRepository, *metrics.AuditMetrics, *storedAuditLogs) {
+	stored := &storedAuditLogs{logs: make(map[string]*domain.AuditLog)}
+	return newMemAuditOutbox(), new(MockAuditLogRepository), metrics.NewAuditMetrics(prometheus.NewRegistry()), stored
+}
+
+func newTestAuditOutbox(outbox *memAuditOutbox, repo *MockAuditLogRepository, m *metrics.AuditMetrics) *auditOutboxService {
+	return NewAuditOutboxService(outbox, repo, m).(*auditOutboxService)
+}
+
+func queueAuditLogs(t *testing.T, outbox *auditOutboxService, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		auditLog, err := domain.NewAuditLog(1, domain.ActionCreateDNSRecord, int64(i+1), nil, map[string]int{"i": i})
+		require.NoError(t, err)
+		require.NoError(t, outbox.Create(context.Background(), auditLog))
+	}
+}
+
+func TestAuditOutboxService(t *testing.T) {
+	ctx := context.Background()
+	dbDown := errors.New("connection refused")
+
+	t.Run("No entry is lost when the database fails transiently", func(t *testing.T) {
+		store, mockAuditRepo, m, stored := setupAuditOutbox()
+		outbox := newTestAuditOutbox(store, mockAuditRepo, m)
+		queueAuditLogs(t, outbox, 5)
+
+		// The first batch and one of the retried entries fail, then the database recovers
+		mockAuditRepo.On("CreateBatch", ctx, mock.Anything).Return(dbDown).Once()
+		mockAuditRepo.On("Create", ctx, mock.Anything).Return(dbDown).Once()
+		mockAuditRepo.On("Create", ctx, mock.Anything).Run(func(args mock.Arguments) {
+			stored.add(args.Get(1).(*domain.AuditLog))
+		}).Return(nil).Times(4)
+		mockAuditRepo.On("CreateBatch", ctx, mock.Anything).Return(dbDown).Once()
+		mockAuditRepo.On("CreateBatch", ctx, mock.Anything).Run(func(args mock.Arguments) {
+			stored.add(args.Get(1).([]*domain.AuditLog)...)
+		}).Return(nil).Once()
+
+		n, err := outbox.Relay(ctx)
+		assert.ErrorIs(t, err, dbDown)
+		assert.Equal(t, 4, n)
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.Backlog))
+
+		// The entry that failed is retried and fails again
+		mockAuditRepo.On("Create", ctx, mock.Anything).Return(dbDown).Once()
+		_, err = outbox.Relay(ctx)
+		assert.ErrorIs(t, err, dbDown)
+
+		require.NoError(t, outbox.Flush(ctx))
+		assert.Len(t, stored.logs, 5)
+		for _, l := range stored.logs {
+			assert.NotEmpty(t, l.EventID)
+			assert.False(t, l.Timestamp.IsZero())
+		}
+		assert.Equal(t, 5.0, testutil.ToFloat64(m.Enqueued))
+		assert.Equal(t, 5.0, testutil.ToFloat64(m.Delivered))
+		assert.Equal(t, 2.0, testutil.ToFloat64(m.Failures.WithLabelValues(metrics.AuditStageDeliver)))
+		assert.Zero(t, testutil.ToFloat64(m.Backlog))
+		mockAuditRepo.AssertExpectations(t)
+	})
+
+	t.Run("Entries survive a restart of the relay", func(t *testing.T) {
+		store, mockAuditRepo, m, stored := setupAuditOutbox()
+		queueAuditLogs(t, newTestAuditOutbox(store, mockAuditRepo, m), 3)
+
+		mockAuditRepo.On("CreateBatch", ctx, mock.Anything).Run(func(args mock.Arguments) {
+			stored.add(args.Get(1).([]*domain.AuditLog)...)
+		}).Return(nil).Once()
+
+		require.NoError(t, newTestAuditOutbox(store, mockAuditRepo, m).Flush(ctx))
+		assert.Len(t, stored.logs, 3)
+	})
+
+	t.Run("Returns the error when the outbox is down", func(t *testing.T) {
+		store, mockAuditRepo, m, _ := setupAuditOutbox()
+		outbox := newTestAuditOutbox(store, mockAuditRepo, m)
+		store.err = dbDown
+
+		// The caller rolls back the change; nothing is stored around the outbox
+		auditLog, err := domain.NewAuditLog(1, domain.ActionDeleteDNSRecord, 1, nil, nil)
+		require.NoError(t, err)
+		assert.ErrorIs(t, outbox.Create(ctx, auditLog), dbDown)
+
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.Failures.WithLabelValues(metrics.AuditStageEnqueue)))
+		assert.Zero(t, testutil.ToFloat64(m.Enqueued))
+		mockAuditRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("The relay flushes the outbox on shutdown", func(t *testing.T) {
+		store, mockAuditRepo, m, stored := setupAuditOutbox()
+		outbox := newTestAuditOutbox(store, mockAuditRepo, m)
+		queueAuditLogs(t, outbox, 2)
+
+		mockAuditRepo.On("CreateBatch", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored.add(args.Get(1).([]*domain.AuditLog)...)
+		}).Return(nil).Once()
+
+		stopped, stop := context.WithCancel(ctx)
+		stop()
+		RunAuditOutboxRelay(stopped, outbox, time.Hour, time.Hour)
+		assert.Len(t, stored.logs, 2)
+	})
+}
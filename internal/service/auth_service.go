@@ -3,26 +3,50 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log" // Added log import
+	"slices"
+	"strings"
+	"time"
 
 	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
 	"internal-dns/internal/repository"
 	"internal-dns/internal/usecase" // Keep usecase import for interface
 	"internal-dns/pkg/token"
 )
 
 type authService struct {
-	userRepo       repository.UserRepository
-	tokenGenerator token.Generator
-	auditRepo      repository.AuditLogRepository // Added auditRepo
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	denylist         cache.SessionDenylist
+	tokenGenerator   token.Generator
+	auditRepo        repository.AuditLogRepository // Added auditRepo
+	authenticators   []usecase.Authenticator
+	mfa              usecase.MFAUseCase
+	challenges       cache.MFAChallengeStore
+	throttle         cache.LoginThrottle
+	throttling       domain.LoginThrottling
+	tx               repository.Transactor
 }
 
-// NewAuthService creates a new authentication service.
-func NewAuthService(userRepo repository.UserRepository, tokenGenerator token.Generator, auditRepo repository.AuditLogRepository) usecase.AuthUseCase { // Changed signature, kept usecase interface
+// NewAuthService creates a new authentication service. Logins try the
+// authenticators in order, then ask for the second factor of mfa. Failed
+// logins are throttled per username and client IP as throttling says; a
+// nil throttle turns throttling off.
+func NewAuthService(userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository, denylist cache.SessionDenylist, tokenGenerator token.Generator, auditRepo repository.AuditLogRepository, authenticators []usecase.Authenticator, mfa usecase.MFAUseCase, challenges cache.MFAChallengeStore, throttle cache.LoginThrottle, throttling domain.LoginThrottling, tx repository.Transactor) usecase.AuthUseCase { // Changed signature, kept usecase interface
 	return &authService{
-		userRepo:       userRepo,
-		tokenGenerator: tokenGenerator,
-		auditRepo:      auditRepo,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		denylist:         denylist,
+		tokenGenerator:   tokenGenerator,
+		auditRepo:        auditRepo,
+		authenticators:   authenticators,
+		mfa:              mfa,
+		challenges:       challenges,
+		throttle:         throttle,
+		throttling:       throttling,
+		tx:               tx,
 	}
 }
 
@@ -42,71 +66,517 @@ func (s *authService) Register(ctx context.Context, username, password string) e
 		return err
 	}
 
-	if err := s.userRepo.Create(ctx, user); err != nil {
-		return err
+	// Create and audit log in one transaction
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		return s.audit(ctx, user.ID, domain.ActionUserRegister, user.ID, map[string]string{"username": user.Username})
+	})
+}
+
+func (s *authService) Login(ctx context.Context, username, password, clientIP string) (*domain.LoginResult, error) {
+	// Throttled logins are refused before anything is looked up, so that
+	// they do not tell whether the account exists
+	attempts, err := s.reserveLogin(ctx, username, clientIP)
+	if err != nil {
+		return nil, err
 	}
 
-	// Fire-and-forget audit log
-	go func() {
-		auditLog, err := domain.NewAuditLog(user.ID, domain.ActionUserRegister, user.ID, nil, map[string]string{"username": user.Username})
-		if err == nil {
-			if err := s.auditRepo.Create(context.Background(), auditLog); err != nil {
-				log.Printf("failed to create audit log for user registration: %v", err)
-			}
+	user, authenticator, err := s.authenticate(ctx, username, password)
+	if errors.Is(err, domain.ErrInvalidCredentials) {
+		// Failures are recorded for the user of that name, if there is one
+		var userID int64
+		if user, err := s.userRepo.FindByUsername(ctx, username); err == nil {
+			userID = user.ID
+		}
+
+		if err := s.auditLoginFailure(ctx, attempts, userID, map[string]string{"username": username}); err != nil {
+			return nil, err
+		}
+		return nil, repository.ErrUserNotFound // Use same error to prevent username enumeration
+	}
+	if err != nil {
+		s.releaseLogin(ctx, attempts)
+		return nil, err
+	}
+
+	// The password is not enough for users with a second factor, and admins
+	// have to enroll one before they get tokens
+	result, err := challengeMFA(ctx, s.mfa, s.challenges, user, authenticator)
+	if err != nil || result != nil {
+		s.releaseLogin(ctx, attempts)
+		return result, err
+	}
+
+	result, err = s.completeLogin(ctx, user, map[string]string{"authenticator": authenticator})
+	if err != nil {
+		s.releaseLogin(ctx, attempts)
+		return nil, err
+	}
+	s.loginSucceeded(ctx, attempts)
+	return result, nil
+}
+
+func (s *authService) BeginMFAEnrollment(ctx context.Context, mfaToken string) (*domain.TOTPProvisioning, error) {
+	challenge, err := s.challenges.Take(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	// The challenge stays valid for the code of the new secret
+	defer func() {
+		if err := s.challenges.Save(context.WithoutCancel(ctx), challenge); err != nil {
+			log.Printf("failed to save MFA challenge of user %d: %v", challenge.UserID, err)
 		}
 	}()
 
+	if !challenge.EnrollmentRequired {
+		return nil, domain.ErrMFAAlreadyEnabled
+	}
+	return s.mfa.BeginEnrollment(ctx, challenge.UserID)
+}
+
+func (s *authService) VerifyMFA(ctx context.Context, mfaToken, code, clientIP string) (*domain.LoginResult, error) {
+	challenge, err := s.challenges.Take(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	// The challenge can be answered once the logins are accepted again
+	attempts, err := s.reserveLogin(ctx, challenge.Username, clientIP)
+	if err != nil {
+		if err := s.challenges.Save(context.WithoutCancel(ctx), challenge); err != nil {
+			log.Printf("failed to save MFA challenge of user %d: %v", challenge.UserID, err)
+		}
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if challenge.EnrollmentRequired {
+		recoveryCodes, err = s.mfa.ConfirmEnrollment(ctx, challenge.UserID, code)
+	} else {
+		err = s.mfa.Verify(ctx, challenge.UserID, code)
+	}
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidMFACode) {
+			challenge.Attempts++
+			if auditErr := s.auditLoginFailure(ctx, attempts, challenge.UserID, map[string]string{"username": challenge.Username, "error": domain.ErrInvalidMFACode.Error()}); auditErr != nil {
+				err = auditErr
+			}
+		} else {
+			s.releaseLogin(ctx, attempts)
+		}
+		// Guessing codes ends with the challenge
+		if challenge.Attempts < domain.MFAChallengeMaxAttempts {
+			if err := s.challenges.Save(context.WithoutCancel(ctx), challenge); err != nil {
+				log.Printf("failed to save MFA challenge of user %d: %v", challenge.UserID, err)
+			}
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, challenge.UserID)
+	if err != nil {
+		s.releaseLogin(ctx, attempts)
+		return nil, err
+	}
+	result, err := s.completeLogin(ctx, user, map[string]string{"authenticator": challenge.Authenticator, "mfa": "totp"})
+	if err != nil {
+		s.releaseLogin(ctx, attempts)
+		return nil, err
+	}
+	s.loginSucceeded(ctx, attempts)
+	result.RecoveryCodes = recoveryCodes
+	return result, nil
+}
+
+// completeLogin starts the session of a user whose login succeeded.
+func (s *authService) completeLogin(ctx context.Context, user *domain.User, details map[string]string) (*domain.LoginResult, error) {
+	// Session and audit log in one transaction
+	var result domain.LoginResult
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		result.AccessToken, result.RefreshToken, err = startSession(ctx, s.refreshTokenRepo, s.tokenGenerator, user)
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, user.ID, domain.ActionUserLoginSuccess, user.ID, details)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// auditLoginFailure records a failed login of userID, or of a username
+// without a user if it is 0, with the lockouts that its attempts reach. The
+// failure is audited even if the client has gone.
+func (s *authService) auditLoginFailure(ctx context.Context, attempts []*loginAttempt, userID int64, details map[string]string) error {
+	return s.tx.WithinTx(context.WithoutCancel(ctx), func(ctx context.Context) error {
+		if err := s.audit(ctx, userID, domain.ActionUserLoginFailure, userID, details); err != nil {
+			return err
+		}
+		return s.auditLockouts(ctx, attempts, userID)
+	})
+}
+
+// audit records an event of the logins or sessions of a user, in the
+// transaction of the change it describes.
+func (s *authService) audit(ctx context.Context, actorID int64, action domain.ActionType, targetID int64, newValue interface{}) error {
+	auditLog, err := domain.NewAuditLog(actorID, action, targetID, nil, newValue)
+	if err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		return fmt.Errorf("failed to create audit log for %s: %w", action, err)
+	}
 	return nil
 }
 
-func (s *authService) Login(ctx context.Context, username, password string) (accessToken, refreshToken string, err error) {
-	user, err := s.userRepo.FindByUsername(ctx, username)
+// loginAttempt is a login counted as failed for a username or a client IP
+// before its credentials are checked, so that concurrent logins cannot get
+// past the throttle.
+type loginAttempt struct {
+	key      string
+	policy   domain.LoginThrottlePolicy
+	failures int64 // in a row, including this login
+	blocked  bool  // refused the next logins for the delay of its failure
+	username bool  // counts for a username rather than a client IP
+	details  map[string]string
+}
+
+// reserveLogin counts a login of username from clientIP as failed until it
+// is released, or returns a *domain.LoginThrottledError if it is refused.
+// It returns no attempts if throttling is off.
+func (s *authService) reserveLogin(ctx context.Context, username, clientIP string) ([]*loginAttempt, error) {
+	if s.throttle == nil {
+		return nil, nil
+	}
+	retryAfter, err := s.throttle.RetryAfter(ctx, usernameThrottleKey(username), ipThrottleKey(clientIP))
 	if err != nil {
-		// Fire-and-forget audit log for failed login
-		go func() {
-			auditLog, err := domain.NewAuditLog(0, domain.ActionUserLoginFailure, 0, nil, map[string]string{"username": username})
+		return nil, err
+	}
+	if retryAfter > 0 {
+		return nil, &domain.LoginThrottledError{RetryAfter: retryAfter}
+	}
+
+	var attempts []*loginAttempt
+	for _, a := range []*loginAttempt{
+		{key: usernameThrottleKey(username), policy: s.throttling.Username, username: true, details: map[string]string{"username": username}},
+		{key: ipThrottleKey(clientIP), policy: s.throttling.IP, details: map[string]string{"clientIp": clientIP}},
+	} {
+		a.failures, err = s.throttle.RecordAttempt(ctx, a.key, a.policy.LockoutDuration)
+		if err != nil {
+			s.releaseLogin(ctx, attempts)
+			return nil, err
+		}
+		attempts = append(attempts, a)
+
+		// Past the free attempts, a login takes the block that its failure
+		// leaves up front; concurrent logins find it taken and are refused
+		delay := a.policy.Delay(a.failures)
+		if delay <= 0 {
+			continue
+		}
+		a.blocked, err = s.throttle.Block(ctx, a.key, delay)
+		if err == nil && !a.blocked {
+			retryAfter, err = s.throttle.RetryAfter(ctx, a.key)
 			if err == nil {
-				if err := s.auditRepo.Create(context.Background(), auditLog); err != nil {
-					log.Printf("failed to create audit log for failed login: %v", err)
-				}
+				err = &domain.LoginThrottledError{RetryAfter: max(retryAfter, time.Second)}
 			}
-		}()
-		return "", "", repository.ErrUserNotFound // Use same error to prevent username enumeration
+		}
+		if err != nil {
+			s.releaseLogin(ctx, attempts)
+			return nil, err
+		}
 	}
+	return attempts, nil
+}
 
-	if !user.ValidatePassword(password) {
-		// Fire-and-forget audit log for failed login
-		go func() {
-			auditLog, err := domain.NewAuditLog(user.ID, domain.ActionUserLoginFailure, user.ID, nil, map[string]string{"username": username})
-			if err == nil {
-				if err := s.auditRepo.Create(context.Background(), auditLog); err != nil {
-					log.Printf("failed to create audit log for failed login: %v", err)
-				}
+// auditLockouts audits the lockouts that the attempts of a failed login
+// reach; the attempts stay counted. userID is the user of the username, or 0
+// if there is none.
+func (s *authService) auditLockouts(ctx context.Context, attempts []*loginAttempt, userID int64) error {
+	for _, a := range attempts {
+		// Only the failure that locks logins out is audited
+		if a.failures != int64(a.policy.LockoutThreshold) {
+			continue
+		}
+		var auditUserID int64
+		if a.username {
+			auditUserID = userID
+		}
+		if err := s.audit(ctx, auditUserID, domain.ActionLockOutLogin, auditUserID, a.details); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseLogin takes back the attempts of a login that did not fail, e.g.
+// because a second factor is still due or an authenticator is unavailable.
+func (s *authService) releaseLogin(ctx context.Context, attempts []*loginAttempt) {
+	ctx = context.WithoutCancel(ctx)
+	for _, a := range attempts {
+		if err := s.throttle.Release(ctx, a.key); err != nil {
+			log.Printf("failed to release login attempt: %v", err)
+		}
+		if !a.blocked {
+			continue
+		}
+		if err := s.throttle.Unblock(ctx, a.key); err != nil {
+			log.Printf("failed to unblock logins: %v", err)
+		}
+	}
+}
+
+// loginSucceeded forgets the failed logins of the username of a successful
+// login. Those from the client IP still count, as an attacker could log in
+// to an account of their own in between guesses; only the attempt of the
+// login itself is taken back.
+func (s *authService) loginSucceeded(ctx context.Context, attempts []*loginAttempt) {
+	for _, a := range attempts {
+		if !a.username {
+			s.releaseLogin(ctx, []*loginAttempt{a})
+			continue
+		}
+		if err := s.throttle.Reset(context.WithoutCancel(ctx), a.key); err != nil {
+			log.Printf("failed to reset failed logins: %v", err)
+		}
+	}
+}
+
+// usernameThrottleKey returns the key of the login throttle for a
+// username. Case variants of a username share it.
+func usernameThrottleKey(username string) string {
+	return "username:" + strings.ToLower(username)
+}
+
+func ipThrottleKey(clientIP string) string {
+	return "ip:" + clientIP
+}
+
+// authenticate tries the authenticators in order and returns the user of
+// the first one that accepts the credentials, and its name. An unavailable
+// authenticator does not keep the next ones from being tried; its error is
+// returned if none of them accepts the credentials.
+func (s *authService) authenticate(ctx context.Context, username, password string) (*domain.User, string, error) {
+	var failure error
+	for _, authenticator := range s.authenticators {
+		user, err := authenticator.Authenticate(ctx, username, password)
+		if err == nil {
+			return user, authenticator.Name(), nil
+		}
+		if !errors.Is(err, domain.ErrInvalidCredentials) {
+			log.Printf("%s authenticator failed: %v", authenticator.Name(), err)
+			if failure == nil {
+				failure = err
 			}
-		}()
-		return "", "", repository.ErrUserNotFound // Use same error to prevent username enumeration
+		}
 	}
+	if failure != nil {
+		return nil, "", failure
+	}
+	return nil, "", domain.ErrInvalidCredentials
+}
 
-	accessToken, err = s.tokenGenerator.GenerateAccessToken(user)
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (string, string, error) {
+	claims, err := s.tokenGenerator.ValidateRefreshToken(refreshToken)
+	if err != nil || claims.ID == "" {
+		return "", "", domain.ErrInvalidRefreshToken
+	}
+
+	record, err := s.refreshTokenRepo.FindByID(ctx, claims.ID)
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+		return "", "", domain.ErrInvalidRefreshToken
+	}
 	if err != nil {
 		return "", "", err
 	}
+	if record.UserID != claims.UserID || record.RevokedAt != nil {
+		return "", "", domain.ErrInvalidRefreshToken
+	}
+	if record.UsedAt != nil {
+		// The token was stolen, or its successor was: both parties hold a
+		// valid token until the whole family is revoked
+		if err := s.revokeReused(ctx, record); err != nil {
+			return "", "", err
+		}
+		return "", "", domain.ErrRefreshTokenReused
+	}
+	if !record.Usable(time.Now()) {
+		return "", "", domain.ErrInvalidRefreshToken
+	}
 
-	refreshToken, err = s.tokenGenerator.GenerateRefreshToken(user)
+	user, err := s.userRepo.FindByID(ctx, record.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return "", "", domain.ErrInvalidRefreshToken
+	}
 	if err != nil {
 		return "", "", err
 	}
+	if !user.IsEnabled {
+		if err := s.refreshTokenRepo.RevokeFamily(ctx, record.FamilyID); err != nil {
+			log.Printf("failed to revoke refresh tokens of disabled user %d: %v", user.ID, err)
+		}
+		return "", "", domain.ErrInvalidRefreshToken
+	}
 
-	// Fire-and-forget audit log for successful login
-	go func() {
-		auditLog, err := domain.NewAuditLog(user.ID, domain.ActionUserLoginSuccess, user.ID, nil, nil)
-		if err == nil {
-			if err := s.auditRepo.Create(context.Background(), auditLog); err != nil {
-				log.Printf("failed to create audit log for successful login: %v", err)
+	next := record.Next()
+	if err := s.refreshTokenRepo.Rotate(ctx, record.ID, next); err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenReused) {
+			if err := s.revokeReused(ctx, record); err != nil {
+				return "", "", err
 			}
 		}
-	}()
+		return "", "", err
+	}
+	return issueTokens(s.tokenGenerator, user, next)
+}
+
+// revokeReused revokes the session of a refresh token that was used again,
+// even if the client has gone.
+func (s *authService) revokeReused(ctx context.Context, record *domain.RefreshToken) error {
+	return s.revokeSession(context.WithoutCancel(ctx), record.UserID, domain.ActionRefreshTokenReused, record.FamilyID, map[string]string{"familyId": record.FamilyID})
+}
+
+// challengeMFA returns the challenge that a login of user accepted by
+// authenticator has to answer at VerifyMFA, if user has a second factor or
+// has to enroll one, or nil otherwise.
+func challengeMFA(ctx context.Context, mfa usecase.MFAUseCase, challenges cache.MFAChallengeStore, user *domain.User, authenticator string) (*domain.LoginResult, error) {
+	status, err := mfa.Status(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Enabled && !status.Required {
+		return nil, nil
+	}
+	challenge, err := domain.NewMFAChallenge(user, authenticator, !status.Enabled)
+	if err != nil {
+		return nil, err
+	}
+	if err := challenges.Save(ctx, challenge); err != nil {
+		return nil, err
+	}
+	return &domain.LoginResult{MFAToken: challenge.Token, MFAEnrollmentRequired: challenge.EnrollmentRequired}, nil
+}
+
+// startSession starts a session of a user, i.e. a new family of refresh
+// tokens, and issues its first tokens.
+func startSession(ctx context.Context, refreshTokenRepo repository.RefreshTokenRepository, tokenGenerator token.Generator, user *domain.User) (accessToken, refreshToken string, err error) {
+	record := domain.NewRefreshToken(user.ID)
+	if err := refreshTokenRepo.Create(ctx, record); err != nil {
+		return "", "", err
+	}
+	return issueTokens(tokenGenerator, user, record)
+}
 
+func issueTokens(tokenGenerator token.Generator, user *domain.User, record *domain.RefreshToken) (accessToken, refreshToken string, err error) {
+	accessToken, err = tokenGenerator.GenerateAccessToken(user, record.FamilyID)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err = tokenGenerator.GenerateRefreshToken(user, record.ID)
+	if err != nil {
+		return "", "", err
+	}
 	return accessToken, refreshToken, nil
 }
 
+func (s *authService) Logout(ctx context.Context, userID int64, sessionID string) error {
+	// Tokens issued before sessions were tracked cannot be revoked one by one
+	if sessionID == "" {
+		return nil
+	}
+	return s.revokeSession(ctx, userID, domain.ActionUserLogout, sessionID, map[string]string{"sessionId": sessionID})
+}
+
+func (s *authService) ListSessions(ctx context.Context, userID int64) ([]*domain.Session, error) {
+	return s.refreshTokenRepo.ListSessions(ctx, userID)
+}
+
+func (s *authService) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	sessions, err := s.refreshTokenRepo.ListSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(sessions, func(session *domain.Session) bool { return session.ID == sessionID }) {
+		return domain.ErrSessionNotFound
+	}
+	return s.revokeSession(ctx, userID, domain.ActionRevokeSession, sessionID, map[string]string{"sessionId": sessionID})
+}
+
+func (s *authService) RevokeAllSessions(ctx context.Context, actorID, userID int64) error {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return err
+	}
+	// Revocation and audit log in one transaction, which is only committed
+	// once the access tokens are revoked too
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.refreshTokenRepo.RevokeUser(ctx, userID); err != nil {
+			return err
+		}
+		if err := s.audit(ctx, actorID, domain.ActionRevokeAllSessions, userID, nil); err != nil {
+			return err
+		}
+		return s.denylist.RevokeUser(ctx, userID, time.Now())
+	})
+}
+
+// revokeSession revokes the refresh tokens of a session of userID with the
+// audit log of action, then its access tokens. Nothing is committed unless
+// both are revoked.
+func (s *authService) revokeSession(ctx context.Context, userID int64, action domain.ActionType, sessionID string, details map[string]string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.refreshTokenRepo.RevokeFamily(ctx, sessionID); err != nil {
+			return err
+		}
+		if err := s.audit(ctx, userID, action, userID, details); err != nil {
+			return err
+		}
+		return s.denylist.RevokeSession(ctx, sessionID)
+	})
+}
+
+func (s *authService) UnlockLogin(ctx context.Context, actorID, userID int64) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// The audit log is committed once the logins are unlocked
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.audit(ctx, actorID, domain.ActionUnlockLogin, userID, map[string]string{"username": user.Username}); err != nil {
+			return err
+		}
+		if s.throttle == nil {
+			return nil
+		}
+		return s.throttle.Reset(ctx, usernameThrottleKey(user.Username))
+	})
+}
+
+func (s *authService) PurgeExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	return s.refreshTokenRepo.DeleteExpired(ctx, time.Now())
+}
+
+// RunRefreshTokenPurger deletes the records of expired refresh tokens every
+// interval until ctx is cancelled.
+func RunRefreshTokenPurger(ctx context.Context, uc usecase.AuthUseCase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := uc.PurgeExpiredRefreshTokens(ctx)
+			if err != nil {
+				log.Printf("Failed to purge expired refresh tokens: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d expired refresh tokens", purged)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -4,15 +4,22 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
 	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
 	"internal-dns/pkg/token"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockUserRepository is a mock implementation of UserRepository
@@ -59,13 +66,13 @@ type MockTokenGenerator struct {
 	mock.Mock
 }
 
-func (m *MockTokenGenerator) GenerateAccessToken(user *domain.User) (string, error) {
-	args := m.Called(user)
+func (m *MockTokenGenerator) GenerateAccessToken(user *domain.User, sessionID string) (string, error) {
+	args := m.Called(user, sessionID)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockTokenGenerator) GenerateRefreshToken(user *domain.User) (string, error) {
-	args := m.Called(user)
+func (m *MockTokenGenerator) GenerateRefreshToken(user *domain.User, id string) (string, error) {
+	args := m.Called(user, id)
 	return args.String(0), args.Error(1)
 }
 
@@ -77,6 +84,80 @@ func (m *MockTokenGenerator) ValidateToken(tokenString string) (*token.CustomCla
 	return args.Get(0).(*token.CustomClaims), args.Error(1)
 }
 
+func (m *MockTokenGenerator) ValidateRefreshToken(tokenString string) (*token.CustomClaims, error) {
+	args := m.Called(tokenString)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*token.CustomClaims), args.Error(1)
+}
+
+// MockRefreshTokenRepository is a mock of RefreshTokenRepository
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) Create(ctx context.Context, t *domain.RefreshToken) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) FindByID(ctx context.Context, id string) (*domain.RefreshToken, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) Rotate(ctx context.Context, id string, next *domain.RefreshToken) error {
+	args := m.Called(ctx, id, next)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeUser(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) ListSessions(ctx context.Context, userID int64) ([]*domain.Session, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Session), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockSessionDenylist is a mock of SessionDenylist
+type MockSessionDenylist struct {
+	mock.Mock
+}
+
+func (m *MockSessionDenylist) RevokeSession(ctx context.Context, sessionID string) error {
+	args := m.Called(ctx, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockSessionDenylist) RevokeUser(ctx context.Context, userID int64, at time.Time) error {
+	args := m.Called(ctx, userID, at)
+	return args.Error(0)
+}
+
+func (m *MockSessionDenylist) IsRevoked(ctx context.Context, sessionID string, userID int64, issuedAt time.Time) (bool, error) {
+	args := m.Called(ctx, sessionID, userID, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
+
 // MockAuditLogRepository is a mock of AuditLogRepository
 type MockAuditLogRepository struct {
 	mock.Mock
@@ -87,11 +168,70 @@ func (m *MockAuditLogRepository) Create(ctx context.Context, log *domain.AuditLo
 	return args.Error(0)
 }
 
+func (m *MockAuditLogRepository) CreateBatch(ctx context.Context, logs []*domain.AuditLog) error {
+	args := m.Called(ctx, logs)
+	return args.Error(0)
+}
+
+func (m *MockAuditLogRepository) FindByTarget(ctx context.Context, targetID int64, actions []domain.ActionType) ([]*domain.AuditLog, error) {
+	args := m.Called(ctx, targetID, actions)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.AuditLog), args.Error(1)
+}
+
+func (m *MockAuditLogRepository) Find(ctx context.Context, filter domain.AuditLogFilter) ([]*domain.AuditLog, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.AuditLog), args.Error(1)
+}
+
+func (m *MockAuditLogRepository) FindAfter(ctx context.Context, afterID int64, limit int) ([]*domain.AuditLog, error) {
+	args := m.Called(ctx, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.AuditLog), args.Error(1)
+}
+
+// noTx runs functions without a transaction, for services whose
+// repositories are mocked.
+type noTx struct{}
+
+func (noTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// recordingTx is a Transactor that counts the transactions it commits and
+// rolls back. Mocks tell the context of a transaction with inTx.
+type recordingTx struct {
+	committed, rolledBack int
+}
+
+type recordingTxKey struct{}
+
+func (r *recordingTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(context.WithValue(ctx, recordingTxKey{}, r)); err != nil {
+		r.rolledBack++
+		return err
+	}
+	r.committed++
+	return nil
+}
+
+// inTx reports whether ctx is the context of a recordingTx transaction.
+func inTx(ctx context.Context) bool {
+	return ctx.Value(recordingTxKey{}) != nil
+}
+
 func TestAuthService_Register(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
 	mockTokenGenerator := new(MockTokenGenerator)
 	mockAuditRepo := new(MockAuditLogRepository)
-	authService := NewAuthService(mockUserRepo, mockTokenGenerator, mockAuditRepo) // Changed service initialization
+	authService := NewAuthService(mockUserRepo, new(MockRefreshTokenRepository), new(MockSessionDenylist), mockTokenGenerator, mockAuditRepo, nil, nil, nil, nil, domain.LoginThrottling{}, noTx{}) // Changed service initialization
 	ctx := context.Background()
 
 	username := "testuser"
@@ -198,3 +338,466 @@ func TestAuthService_Register(t *testing.T) {
 // 		mockAuditRepo.AssertExpectations(t) // Assert audit log
 // 	})
 // }
+
+func TestAuthService_Login(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(MockUserRepository)
+	mockRefreshRepo := new(MockRefreshTokenRepository)
+	mockTokenGenerator := new(MockTokenGenerator)
+	mockAuditRepo := new(MockAuditLogRepository)
+	mockMFA := new(MockMFAUseCase)
+	authService := NewAuthService(mockUserRepo, mockRefreshRepo, new(MockSessionDenylist), mockTokenGenerator, mockAuditRepo, []usecase.Authenticator{NewLocalAuthenticator(mockUserRepo)}, mockMFA, nil, nil, domain.LoginThrottling{}, noTx{})
+
+	user, _ := domain.NewUser("testuser", "password123", domain.RoleUser)
+	user.ID = 1
+	mockUserRepo.On("FindByUsername", ctx, "testuser").Return(user, nil).Once()
+	mockMFA.On("Status", ctx, user.ID).Return(&domain.MFAStatus{}, nil).Once()
+
+	// The login starts a new family
+	var record *domain.RefreshToken
+	mockRefreshRepo.On("Create", ctx, mock.AnythingOfType("*domain.RefreshToken")).
+		Run(func(args mock.Arguments) { record = args.Get(1).(*domain.RefreshToken) }).
+		Return(nil).Once()
+	mockTokenGenerator.On("GenerateAccessToken", user, mock.AnythingOfType("string")).Return("access_token", nil).Once()
+	mockTokenGenerator.On("GenerateRefreshToken", user, mock.AnythingOfType("string")).Return("refresh_token", nil).Once()
+	mockAuditRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.AuditLog")).Return(nil).Once()
+
+	result, err := authService.Login(ctx, "testuser", "password123", "192.0.2.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "access_token", result.AccessToken)
+	assert.Equal(t, "refresh_token", result.RefreshToken)
+	assert.Empty(t, result.MFAToken)
+
+	mockTokenGenerator.AssertCalled(t, "GenerateRefreshToken", user, record.ID)
+	mockTokenGenerator.AssertCalled(t, "GenerateAccessToken", user, record.FamilyID)
+	assert.Equal(t, record.ID, record.FamilyID)
+	assert.Equal(t, record.CreatedAt, record.SessionCreatedAt)
+	assert.Equal(t, user.ID, record.UserID)
+	assert.WithinDuration(t, time.Now().Add(domain.RefreshTokenTTL), record.ExpiresAt, 5*time.Second)
+}
+
+func TestAuthService_AuditInTransaction(t *testing.T) {
+	ctx := context.Background()
+	auditErr := errors.New("outbox unavailable")
+
+	t.Run("Register", func(t *testing.T) {
+		users, audit, tx := new(MockUserRepository), new(MockAuditLogRepository), &recordingTx{}
+		svc := NewAuthService(users, new(MockRefreshTokenRepository), new(MockSessionDenylist), new(MockTokenGenerator), audit, nil, nil, nil, nil, domain.LoginThrottling{}, tx)
+		users.On("FindByUsername", ctx, "newuser").Return(nil, repository.ErrUserNotFound).Once()
+		users.On("Create", mock.MatchedBy(inTx), mock.AnythingOfType("*domain.User")).Return(nil).Once()
+		audit.On("Create", mock.MatchedBy(inTx), mock.AnythingOfType("*domain.AuditLog")).Return(auditErr).Once()
+
+		assert.ErrorIs(t, svc.Register(ctx, "newuser", "password123"), auditErr)
+		users.AssertExpectations(t)
+		assert.Equal(t, 1, tx.rolledBack)
+	})
+
+	t.Run("Login", func(t *testing.T) {
+		users, refresh, tokens, audit, mfa, tx := new(MockUserRepository), new(MockRefreshTokenRepository), new(MockTokenGenerator), new(MockAuditLogRepository), new(MockMFAUseCase), &recordingTx{}
+		svc := NewAuthService(users, refresh, new(MockSessionDenylist), tokens, audit, []usecase.Authenticator{NewLocalAuthenticator(users)}, mfa, nil, nil, domain.LoginThrottling{}, tx)
+		user, _ := domain.NewUser("testuser", "password123", domain.RoleUser)
+		user.ID = 1
+		users.On("FindByUsername", ctx, "testuser").Return(user, nil).Once()
+		mfa.On("Status", ctx, user.ID).Return(&domain.MFAStatus{}, nil).Once()
+		refresh.On("Create", mock.MatchedBy(inTx), mock.AnythingOfType("*domain.RefreshToken")).Return(nil).Once()
+		tokens.On("GenerateAccessToken", user, mock.Anything).Return("access_token", nil).Once()
+		tokens.On("GenerateRefreshToken", user, mock.Anything).Return("refresh_token", nil).Once()
+		audit.On("Create", mock.MatchedBy(inTx), mock.AnythingOfType("*domain.AuditLog")).Return(auditErr).Once()
+
+		// The session is not started without its audit log
+		result, err := svc.Login(ctx, "testuser", "password123", "192.0.2.1")
+		assert.ErrorIs(t, err, auditErr)
+		assert.Nil(t, result)
+		refresh.AssertExpectations(t)
+		assert.Equal(t, 1, tx.rolledBack)
+	})
+
+	t.Run("Failed login", func(t *testing.T) {
+		users, audit, tx := new(MockUserRepository), new(MockAuditLogRepository), &recordingTx{}
+		svc := NewAuthService(users, new(MockRefreshTokenRepository), new(MockSessionDenylist), new(MockTokenGenerator), audit, []usecase.Authenticator{NewLocalAuthenticator(users)}, nil, nil, nil, domain.LoginThrottling{}, tx)
+		users.On("FindByUsername", ctx, "nobody").Return(nil, repository.ErrUserNotFound)
+		audit.On("Create", mock.MatchedBy(inTx), mock.AnythingOfType("*domain.AuditLog")).Return(auditErr).Once()
+
+		_, err := svc.Login(ctx, "nobody", "wrong", "192.0.2.1")
+		assert.ErrorIs(t, err, auditErr)
+	})
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	ctx := context.Background()
+	user, _ := domain.NewUser("testuser", "password123", domain.RoleUser)
+	user.ID = 1
+	user.IsEnabled = true
+
+	type mocks struct {
+		users    *MockUserRepository
+		refresh  *MockRefreshTokenRepository
+		denylist *MockSessionDenylist
+		tokens   *MockTokenGenerator
+		audit    *MockAuditLogRepository
+	}
+	setup := func(record *domain.RefreshToken) (*mocks, func() (string, string, error)) {
+		m := &mocks{new(MockUserRepository), new(MockRefreshTokenRepository), new(MockSessionDenylist), new(MockTokenGenerator), new(MockAuditLogRepository)}
+		m.tokens.On("ValidateRefreshToken", "old").
+			Return(&token.CustomClaims{UserID: user.ID, Type: token.TypeRefresh, RegisteredClaims: jwt.RegisteredClaims{ID: record.ID}}, nil)
+		m.refresh.On("FindByID", ctx, record.ID).Return(record, nil)
+		svc := NewAuthService(m.users, m.refresh, m.denylist, m.tokens, m.audit, nil, nil, nil, nil, domain.LoginThrottling{}, noTx{})
+		return m, func() (string, string, error) { return svc.Refresh(ctx, "old") }
+	}
+
+	t.Run("Rotates the token", func(t *testing.T) {
+		record := domain.NewRefreshToken(user.ID).Next()
+		m, refresh := setup(record)
+		m.users.On("FindByID", ctx, user.ID).Return(user, nil).Once()
+		isNext := mock.MatchedBy(func(next *domain.RefreshToken) bool {
+			return next.FamilyID == record.FamilyID && next.ID != record.ID && next.UserID == user.ID &&
+				next.SessionCreatedAt.Equal(record.SessionCreatedAt)
+		})
+		m.refresh.On("Rotate", ctx, record.ID, isNext).Return(nil).Once()
+		m.tokens.On("GenerateAccessToken", user, record.FamilyID).Return("access_token", nil).Once()
+		m.tokens.On("GenerateRefreshToken", user, mock.AnythingOfType("string")).Return("new", nil).Once()
+
+		accessToken, refreshToken, err := refresh()
+		assert.NoError(t, err)
+		assert.Equal(t, "access_token", accessToken)
+		assert.Equal(t, "new", refreshToken)
+		m.refresh.AssertExpectations(t)
+		m.refresh.AssertNotCalled(t, "RevokeFamily", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Reuse revokes the family", func(t *testing.T) {
+		record := domain.NewRefreshToken(user.ID).Next()
+		usedAt := time.Now().Add(-time.Minute)
+		record.UsedAt, record.ReplacedBy = &usedAt, "next"
+		m, refresh := setup(record)
+		m.refresh.On("RevokeFamily", mock.Anything, record.FamilyID).Return(nil).Once()
+		m.denylist.On("RevokeSession", mock.Anything, record.FamilyID).Return(nil).Once()
+		m.audit.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+			return l.Action == domain.ActionRefreshTokenReused && l.TargetID == user.ID
+		})).Return(nil).Once()
+
+		_, _, err := refresh()
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+		m.refresh.AssertExpectations(t)
+		m.denylist.AssertExpectations(t)
+		m.audit.AssertExpectations(t)
+		m.tokens.AssertNotCalled(t, "GenerateAccessToken", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Concurrent use revokes the family", func(t *testing.T) {
+		record := domain.NewRefreshToken(user.ID).Next()
+		m, refresh := setup(record)
+		m.users.On("FindByID", ctx, user.ID).Return(user, nil).Once()
+		m.refresh.On("Rotate", ctx, record.ID, mock.Anything).Return(domain.ErrRefreshTokenReused).Once()
+		m.refresh.On("RevokeFamily", mock.Anything, record.FamilyID).Return(nil).Once()
+		m.denylist.On("RevokeSession", mock.Anything, record.FamilyID).Return(nil).Once()
+		m.audit.On("Create", mock.Anything, mock.AnythingOfType("*domain.AuditLog")).Return(nil).Once()
+
+		_, _, err := refresh()
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+		m.refresh.AssertExpectations(t)
+	})
+
+	t.Run("Revoked, expired and foreign tokens are rejected", func(t *testing.T) {
+		revoked := domain.NewRefreshToken(user.ID)
+		revokedAt := time.Now()
+		revoked.RevokedAt = &revokedAt
+		expired := domain.NewRefreshToken(user.ID)
+		expired.ExpiresAt = time.Now().Add(-time.Second)
+		foreign := domain.NewRefreshToken(user.ID + 1)
+
+		for _, record := range []*domain.RefreshToken{revoked, expired, foreign} {
+			m, refresh := setup(record)
+			_, _, err := refresh()
+			assert.ErrorIs(t, err, domain.ErrInvalidRefreshToken)
+			m.refresh.AssertNotCalled(t, "Rotate", mock.Anything, mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("Disabled user", func(t *testing.T) {
+		record := domain.NewRefreshToken(user.ID).Next()
+		m, refresh := setup(record)
+		disabled := *user
+		disabled.IsEnabled = false
+		m.users.On("FindByID", ctx, user.ID).Return(&disabled, nil).Once()
+		m.refresh.On("RevokeFamily", ctx, record.FamilyID).Return(nil).Once()
+
+		_, _, err := refresh()
+		assert.ErrorIs(t, err, domain.ErrInvalidRefreshToken)
+		m.refresh.AssertExpectations(t)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		m := new(MockTokenGenerator)
+		m.On("ValidateRefreshToken", "access").Return(nil, errors.New("unexpected token type")).Once()
+		svc := NewAuthService(new(MockUserRepository), new(MockRefreshTokenRepository), new(MockSessionDenylist), m, new(MockAuditLogRepository), nil, nil, nil, nil, domain.LoginThrottling{}, noTx{})
+
+		_, _, err := svc.Refresh(ctx, "access")
+		assert.ErrorIs(t, err, domain.ErrInvalidRefreshToken)
+	})
+}
+
+func TestAuthService_Sessions(t *testing.T) {
+	ctx := context.Background()
+	setup := func() (*MockUserRepository, *MockRefreshTokenRepository, *MockSessionDenylist, *MockAuditLogRepository, usecase.AuthUseCase) {
+		users, refresh, denylist, audit := new(MockUserRepository), new(MockRefreshTokenRepository), new(MockSessionDenylist), new(MockAuditLogRepository)
+		return users, refresh, denylist, audit, NewAuthService(users, refresh, denylist, new(MockTokenGenerator), audit, nil, nil, nil, nil, domain.LoginThrottling{}, noTx{})
+	}
+	isAction := func(action domain.ActionType) interface{} {
+		return mock.MatchedBy(func(l *domain.AuditLog) bool { return l.Action == action })
+	}
+
+	t.Run("Logout revokes the session", func(t *testing.T) {
+		_, refresh, denylist, audit, svc := setup()
+		refresh.On("RevokeFamily", ctx, "session").Return(nil).Once()
+		denylist.On("RevokeSession", ctx, "session").Return(nil).Once()
+		audit.On("Create", mock.Anything, isAction(domain.ActionUserLogout)).Return(nil).Once()
+
+		assert.NoError(t, svc.Logout(ctx, 1, "session"))
+		refresh.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+		audit.AssertExpectations(t)
+	})
+
+	t.Run("Logout fails if the denylist is unavailable", func(t *testing.T) {
+		refresh, denylist, audit, tx := new(MockRefreshTokenRepository), new(MockSessionDenylist), new(MockAuditLogRepository), &recordingTx{}
+		svc := NewAuthService(new(MockUserRepository), refresh, denylist, new(MockTokenGenerator), audit, nil, nil, nil, nil, domain.LoginThrottling{}, tx)
+		refresh.On("RevokeFamily", mock.MatchedBy(inTx), "session").Return(nil).Once()
+		audit.On("Create", mock.MatchedBy(inTx), isAction(domain.ActionUserLogout)).Return(nil).Once()
+		denylist.On("RevokeSession", mock.Anything, "session").Return(errors.New("redis down")).Once()
+
+		// The revocation of the refresh tokens and its audit log are rolled
+		// back
+		assert.Error(t, svc.Logout(ctx, 1, "session"))
+		assert.Equal(t, 1, tx.rolledBack)
+		assert.Zero(t, tx.committed)
+	})
+
+	t.Run("Revoke a session of the user", func(t *testing.T) {
+		_, refresh, denylist, audit, svc := setup()
+		refresh.On("ListSessions", ctx, int64(1)).Return([]*domain.Session{{ID: "a", UserID: 1}, {ID: "b", UserID: 1}}, nil)
+		refresh.On("RevokeFamily", ctx, "b").Return(nil).Once()
+		denylist.On("RevokeSession", ctx, "b").Return(nil).Once()
+		audit.On("Create", mock.Anything, isAction(domain.ActionRevokeSession)).Return(nil).Once()
+
+		assert.NoError(t, svc.RevokeSession(ctx, 1, "b"))
+		refresh.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+
+		// Sessions of other users are not found
+		err := svc.RevokeSession(ctx, 1, "c")
+		assert.ErrorIs(t, err, domain.ErrSessionNotFound)
+		refresh.AssertNumberOfCalls(t, "RevokeFamily", 1)
+	})
+
+	t.Run("Revoke all sessions of a user", func(t *testing.T) {
+		users, refresh, denylist, audit, svc := setup()
+		users.On("FindByID", ctx, int64(2)).Return(&domain.User{ID: 2}, nil).Once()
+		refresh.On("RevokeUser", ctx, int64(2)).Return(nil).Once()
+		denylist.On("RevokeUser", ctx, int64(2), mock.AnythingOfType("time.Time")).Return(nil).Once()
+		audit.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+			return l.Action == domain.ActionRevokeAllSessions && l.UserID == 1 && l.TargetID == 2
+		})).Return(nil).Once()
+
+		assert.NoError(t, svc.RevokeAllSessions(ctx, 1, 2))
+		refresh.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+		audit.AssertExpectations(t)
+	})
+
+	t.Run("Revoke all sessions of an unknown user", func(t *testing.T) {
+		users, refresh, _, _, svc := setup()
+		users.On("FindByID", ctx, int64(3)).Return(nil, repository.ErrUserNotFound).Once()
+
+		err := svc.RevokeAllSessions(ctx, 1, 3)
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+		refresh.AssertNotCalled(t, "RevokeUser", mock.Anything, mock.Anything)
+	})
+}
+
+// countingAuthenticator counts the credentials that reach an authenticator.
+type countingAuthenticator struct {
+	usecase.Authenticator
+	calls *atomic.Int64
+}
+
+func (a countingAuthenticator) Authenticate(ctx context.Context, username, password string) (*domain.User, error) {
+	a.calls.Add(1)
+	return a.Authenticator.Authenticate(ctx, username, password)
+}
+
+func TestAuthService_LoginThrottling(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	throttle := cache.NewLoginThrottle(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	throttling := domain.LoginThrottling{
+		Username: domain.LoginThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Minute, LockoutThreshold: 4, LockoutDuration: 15 * time.Minute},
+		IP:       domain.LoginThrottlePolicy{FreeAttempts: 5, BaseDelay: time.Second, MaxDelay: time.Minute, LockoutThreshold: 10, LockoutDuration: 15 * time.Minute},
+	}
+
+	var authenticated atomic.Int64
+
+	// setup creates an auth service with the local user alice, whose
+	// password is "password123"
+	setup := func() (*MockAuditLogRepository, usecase.AuthUseCase) {
+		mr.FlushAll()
+		authenticated.Store(0)
+		alice, err := domain.NewUser("alice", "password123", domain.RoleUser)
+		require.NoError(t, err)
+		alice.ID = 1
+		users := new(MockUserRepository)
+		users.On("FindByUsername", ctx, "alice").Return(alice, nil)
+		users.On("FindByUsername", ctx, mock.Anything).Return(nil, repository.ErrUserNotFound)
+		users.On("FindByID", ctx, int64(1)).Return(alice, nil)
+		refresh := new(MockRefreshTokenRepository)
+		refresh.On("Create", ctx, mock.Anything).Return(nil)
+		tokens := new(MockTokenGenerator)
+		tokens.On("GenerateAccessToken", mock.Anything, mock.Anything).Return("access_token", nil)
+		tokens.On("GenerateRefreshToken", mock.Anything, mock.Anything).Return("refresh_token", nil)
+		audit := new(MockAuditLogRepository)
+		audit.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mfa := new(MockMFAUseCase)
+		mfa.On("Status", ctx, mock.Anything).Return(&domain.MFAStatus{}, nil)
+
+		return audit, NewAuthService(users, refresh, new(MockSessionDenylist), tokens, audit, []usecase.Authenticator{countingAuthenticator{NewLocalAuthenticator(users), &authenticated}}, mfa, nil, throttle, throttling, noTx{})
+	}
+	lastAudit := func(audit *MockAuditLogRepository) *domain.AuditLog {
+		return audit.Calls[len(audit.Calls)-1].Arguments.Get(1).(*domain.AuditLog)
+	}
+	retryAfter := func(t *testing.T, err error) time.Duration {
+		var throttled *domain.LoginThrottledError
+		require.ErrorAs(t, err, &throttled)
+		return throttled.RetryAfter
+	}
+
+	t.Run("Failed logins are delayed, then locked out", func(t *testing.T) {
+		audit, svc := setup()
+		for _, username := range []string{"alice", "nobody"} {
+			ip := "192.0.2.1"
+			if username == "nobody" {
+				ip = "192.0.2.2"
+			}
+
+			for i := 0; i < 3; i++ {
+				_, err := svc.Login(ctx, username, "wrong", ip)
+				assert.ErrorIs(t, err, repository.ErrUserNotFound)
+			}
+			// The third failure delays the next login, even with the right password
+			_, err := svc.Login(ctx, username, "password123", ip)
+			assert.InDelta(t, time.Second.Seconds(), retryAfter(t, err).Seconds(), 0.1)
+
+			mr.FastForward(time.Second)
+			_, err = svc.Login(ctx, username, "wrong", ip)
+			assert.ErrorIs(t, err, repository.ErrUserNotFound)
+			_, err = svc.Login(ctx, username, "password123", ip)
+			assert.InDelta(t, (15 * time.Minute).Seconds(), retryAfter(t, err).Seconds(), 0.1)
+			assert.Equal(t, domain.ActionLockOutLogin, lastAudit(audit).Action)
+
+			mr.FastForward(15 * time.Minute)
+		}
+		// The lockout of the unknown user looked the same
+		assert.Equal(t, int64(0), lastAudit(audit).UserID)
+
+		// Failures are forgotten after the lockout
+		_, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Concurrent logins do not get past the throttle", func(t *testing.T) {
+		_, svc := setup()
+		var wg sync.WaitGroup
+		var throttled atomic.Int64
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := svc.Login(ctx, "alice", "wrong", "192.0.2.1"); errors.Is(err, domain.ErrLoginThrottled) {
+					throttled.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// Only the free attempts and the one that waits for the delay after
+		// them check the password
+		assert.Equal(t, int64(3), authenticated.Load())
+		assert.Equal(t, int64(17), throttled.Load())
+		failures, err := mr.Get("login_failures:username:alice")
+		require.NoError(t, err)
+		assert.Equal(t, "3", failures)
+	})
+
+	t.Run("Logins that do not fail are not counted", func(t *testing.T) {
+		_, svc := setup()
+		for i := 0; i < 3; i++ {
+			_, err := svc.Login(ctx, "bob", "wrong", "192.0.2.1")
+			assert.ErrorIs(t, err, repository.ErrUserNotFound)
+		}
+		for i := 0; i < 3; i++ {
+			_, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+			require.NoError(t, err)
+		}
+		failures, err := mr.Get("login_failures:ip:192.0.2.1")
+		require.NoError(t, err)
+		assert.Equal(t, "3", failures)
+		retryAfter, err := throttle.RetryAfter(ctx, "ip:192.0.2.1")
+		require.NoError(t, err)
+		assert.Zero(t, retryAfter)
+	})
+
+	t.Run("Usernames are throttled from every client IP", func(t *testing.T) {
+		_, svc := setup()
+		for i := 0; i < 3; i++ {
+			_, err := svc.Login(ctx, "alice", "wrong", "192.0.2."+string(rune('1'+i)))
+			assert.ErrorIs(t, err, repository.ErrUserNotFound)
+		}
+		_, err := svc.Login(ctx, "ALICE", "password123", "198.51.100.1")
+		assert.ErrorIs(t, err, domain.ErrLoginThrottled)
+	})
+
+	t.Run("Client IPs are throttled across usernames", func(t *testing.T) {
+		_, svc := setup()
+		for _, username := range []string{"u1", "u2", "u3", "u4", "u5", "u6"} {
+			_, err := svc.Login(ctx, username, "wrong", "192.0.2.1")
+			assert.ErrorIs(t, err, repository.ErrUserNotFound)
+		}
+		_, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		assert.ErrorIs(t, err, domain.ErrLoginThrottled)
+		_, err = svc.Login(ctx, "alice", "password123", "192.0.2.2")
+		assert.NoError(t, err)
+	})
+
+	t.Run("A successful login forgets the failures of the username", func(t *testing.T) {
+		_, svc := setup()
+		for i := 0; i < 2; i++ {
+			_, err := svc.Login(ctx, "alice", "wrong", "192.0.2.1")
+			assert.ErrorIs(t, err, repository.ErrUserNotFound)
+		}
+		_, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err := svc.Login(ctx, "alice", "wrong", "192.0.2.1")
+			assert.ErrorIs(t, err, repository.ErrUserNotFound)
+		}
+		_, err = svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Admins unlock users", func(t *testing.T) {
+		audit, svc := setup()
+		for i := 0; i < 3; i++ {
+			_, _ = svc.Login(ctx, "alice", "wrong", "192.0.2."+string(rune('1'+i)))
+		}
+		_, err := svc.Login(ctx, "alice", "password123", "198.51.100.1")
+		require.ErrorIs(t, err, domain.ErrLoginThrottled)
+
+		require.NoError(t, svc.UnlockLogin(ctx, 9, 1))
+		assert.Equal(t, domain.ActionUnlockLogin, lastAudit(audit).Action)
+		assert.Equal(t, int64(9), lastAudit(audit).UserID)
+		_, err = svc.Login(ctx, "alice", "password123", "198.51.100.1")
+		assert.NoError(t, err)
+	})
+}
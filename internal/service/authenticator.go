@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/ldap"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+// Names of the authenticators, as configured in AUTH_BACKENDS.
+const (
+	AuthenticatorLocal = "local"
+	AuthenticatorLDAP  = "ldap"
+)
+
+type localAuthenticator struct {
+	userRepo repository.UserRepository
+}
+
+// NewLocalAuthenticator creates an Authenticator of the local users and
+// their bcrypt password hashes.
+func NewLocalAuthenticator(userRepo repository.UserRepository) usecase.Authenticator {
+	return &localAuthenticator{userRepo: userRepo}
+}
+
+func (a *localAuthenticator) Name() string {
+	return AuthenticatorLocal
+}
+
+func (a *localAuthenticator) Authenticate(ctx context.Context, username, password string) (*domain.User, error) {
+	user, err := a.userRepo.FindByUsername(ctx, username)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.ValidatePassword(password) {
+		return nil, domain.ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+type ldapAuthenticator struct {
+	directory ldap.Directory
+	roles     domain.RoleMapping
+	users     *identityLinker
+}
+
+// NewLDAPAuthenticator creates an Authenticator of the users of an LDAP
+// directory, who get the roles granted to their groups by roles. Users are
+// provisioned on their first login; a local user of the same name is not
+// linked, and refuses the login.
+func NewLDAPAuthenticator(directory ldap.Directory, roles domain.RoleMapping, userRepo repository.UserRepository, identityRepo repository.UserIdentityRepository, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.Authenticator {
+	return &ldapAuthenticator{
+		directory: directory,
+		roles:     roles,
+		users:     &identityLinker{userRepo: userRepo, identityRepo: identityRepo, auditRepo: auditRepo, tx: tx},
+	}
+}
+
+func (a *ldapAuthenticator) Name() string {
+	return AuthenticatorLDAP
+}
+
+func (a *ldapAuthenticator) Authenticate(ctx context.Context, username, password string) (*domain.User, error) {
+	entry, err := a.directory.Authenticate(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Directory users without access are refused like wrong passwords, so
+	// that the next authenticator can be tried
+	role, err := a.roles.Role(entry.Groups)
+	if err != nil {
+		log.Printf("LDAP user %s: %v", entry.DN, err)
+		return nil, domain.ErrInvalidCredentials
+	}
+	user, err := a.users.resolve(ctx, entry.Directory, entry.DN, entry.Username, role)
+	if errors.Is(err, domain.ErrUsernameUnlinkable) || errors.Is(err, domain.ErrUsernameTooShort) {
+		log.Printf("LDAP user %s: %v", entry.DN, err)
+		return nil, domain.ErrInvalidCredentials
+	}
+	return user, err
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockDirectory is a mock of ldap.Directory
+type MockDirectory struct {
+	mock.Mock
+}
+
+func (m *MockDirectory) Authenticate(ctx context.Context, username, password string) (*domain.DirectoryUser, error) {
+	args := m.Called(ctx, username, password)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DirectoryUser), args.Error(1)
+}
+
+func TestAuthService_Authenticators(t *testing.T) {
+	ctx := context.Background()
+	roles := domain.RoleMapping{AdminGroups: []string{"dns-admins"}, UserGroups: []string{"staff"}}
+	carol := &domain.DirectoryUser{Directory: "ldap://ldap.example.com", DN: "uid=carol,ou=people,dc=example,dc=com", Username: "carol", Groups: []string{"staff"}}
+	errUnavailable := errors.New("failed to connect to LDAP directory")
+
+	type mocks struct {
+		users      *MockUserRepository
+		identities *MockUserIdentityRepository
+		directory  *MockDirectory
+		audit      *MockAuditLogRepository
+	}
+	// setup creates an auth service that tries the local users, then LDAP
+	setup := func() (*mocks, usecase.AuthUseCase) {
+		m := &mocks{new(MockUserRepository), new(MockUserIdentityRepository), new(MockDirectory), new(MockAuditLogRepository)}
+		refresh := new(MockRefreshTokenRepository)
+		refresh.On("Create", ctx, mock.Anything).Return(nil)
+		tokens := new(MockTokenGenerator)
+		tokens.On("GenerateAccessToken", mock.Anything, mock.Anything).Return("access_token", nil)
+		tokens.On("GenerateRefreshToken", mock.Anything, mock.Anything).Return("refresh_token", nil)
+		m.audit.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mfa := new(MockMFAUseCase)
+		mfa.On("Status", ctx, mock.Anything).Return(&domain.MFAStatus{}, nil)
+
+		svc := NewAuthService(m.users, refresh, new(MockSessionDenylist), tokens, m.audit, []usecase.Authenticator{
+			NewLocalAuthenticator(m.users),
+			NewLDAPAuthenticator(m.directory, roles, m.users, m.identities, m.audit, noTx{}),
+		}, mfa, nil, nil, domain.LoginThrottling{}, noTx{})
+		return m, svc
+	}
+	lastAudit := func(m *mocks) *domain.AuditLog {
+		return m.audit.Calls[len(m.audit.Calls)-1].Arguments.Get(1).(*domain.AuditLog)
+	}
+
+	t.Run("Local users are found first", func(t *testing.T) {
+		m, svc := setup()
+		alice, _ := domain.NewUser("alice", "password123", domain.RoleUser)
+		m.users.On("FindByUsername", ctx, "alice").Return(alice, nil)
+
+		_, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		require.NoError(t, err)
+		m.directory.AssertNotCalled(t, "Authenticate", mock.Anything, mock.Anything, mock.Anything)
+		assert.Contains(t, string(lastAudit(m).NewValue), `"authenticator":"local"`)
+	})
+
+	t.Run("First LDAP login provisions the user", func(t *testing.T) {
+		m, svc := setup()
+		m.users.On("FindByUsername", ctx, "carol").Return(nil, repository.ErrUserNotFound)
+		m.directory.On("Authenticate", ctx, "carol", "ldap-secret").Return(carol, nil)
+		m.identities.On("FindBySubject", ctx, carol.Directory, carol.DN).Return(nil, domain.ErrIdentityNotFound)
+		m.users.On("Create", ctx, mock.MatchedBy(func(u *domain.User) bool {
+			return u.Username == "carol" && u.Role == domain.RoleUser && u.PasswordHash == ""
+		})).Run(func(args mock.Arguments) { args.Get(1).(*domain.User).ID = 9 }).Return(nil).Once()
+		m.identities.On("Create", ctx, mock.MatchedBy(func(i *domain.UserIdentity) bool {
+			return i.UserID == 9 && i.Issuer == carol.Directory && i.Subject == carol.DN
+		})).Return(nil).Once()
+
+		result, err := svc.Login(ctx, "carol", "ldap-secret", "192.0.2.1")
+		require.NoError(t, err)
+		assert.Equal(t, "access_token", result.AccessToken)
+		m.users.AssertExpectations(t)
+		m.identities.AssertExpectations(t)
+		assert.Equal(t, domain.ActionUserLoginSuccess, lastAudit(m).Action)
+		assert.Contains(t, string(lastAudit(m).NewValue), `"authenticator":"ldap"`)
+	})
+
+	t.Run("LDAP groups grant the role", func(t *testing.T) {
+		m, svc := setup()
+		admin := *carol
+		admin.Groups = []string{"staff", "dns-admins"}
+		user := &domain.User{ID: 9, Username: "carol", Role: domain.RoleUser, IsEnabled: true}
+		m.users.On("FindByUsername", ctx, "carol").Return(user, nil)
+		m.directory.On("Authenticate", ctx, "carol", "ldap-secret").Return(&admin, nil)
+		m.identities.On("FindBySubject", ctx, carol.Directory, carol.DN).Return(&domain.UserIdentity{UserID: 9}, nil)
+		m.users.On("FindByID", ctx, int64(9)).Return(user, nil)
+		m.users.On("Update", ctx, user).Return(nil).Once()
+
+		_, err := svc.Login(ctx, "carol", "ldap-secret", "192.0.2.1")
+		require.NoError(t, err)
+		assert.Equal(t, domain.RoleAdmin, user.Role)
+	})
+
+	t.Run("LDAP users are not linked to the local user of the same name", func(t *testing.T) {
+		m, svc := setup()
+		local, _ := domain.NewUser("carol", "password123", domain.RoleAdmin)
+		local.ID = 3
+		m.users.On("FindByUsername", ctx, "carol").Return(local, nil)
+		m.directory.On("Authenticate", ctx, "carol", "ldap-secret").Return(carol, nil)
+		m.identities.On("FindBySubject", ctx, carol.Directory, carol.DN).Return(nil, domain.ErrIdentityNotFound)
+
+		_, err := svc.Login(ctx, "carol", "ldap-secret", "192.0.2.1")
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+		m.identities.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		m.users.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		assert.Equal(t, domain.ActionUserLoginFailure, lastAudit(m).Action)
+	})
+
+	t.Run("LDAP users without a mapped group are refused", func(t *testing.T) {
+		m, svc := setup()
+		outsider := *carol
+		outsider.Groups = []string{"contractors"}
+		m.users.On("FindByUsername", ctx, "carol").Return(nil, repository.ErrUserNotFound)
+		m.directory.On("Authenticate", ctx, "carol", "ldap-secret").Return(&outsider, nil)
+
+		_, err := svc.Login(ctx, "carol", "ldap-secret", "192.0.2.1")
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+		m.identities.AssertNotCalled(t, "FindBySubject", mock.Anything, mock.Anything, mock.Anything)
+		assert.Equal(t, domain.ActionUserLoginFailure, lastAudit(m).Action)
+	})
+
+	t.Run("Wrong credentials everywhere", func(t *testing.T) {
+		m, svc := setup()
+		alice, _ := domain.NewUser("alice", "password123", domain.RoleUser)
+		alice.ID = 1
+		m.users.On("FindByUsername", ctx, "alice").Return(alice, nil)
+		m.directory.On("Authenticate", ctx, "alice", "wrong").Return(nil, domain.ErrInvalidCredentials)
+
+		_, err := svc.Login(ctx, "alice", "wrong", "192.0.2.1")
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+		assert.Equal(t, domain.ActionUserLoginFailure, lastAudit(m).Action)
+		assert.Equal(t, int64(1), lastAudit(m).UserID)
+	})
+
+	t.Run("An unavailable directory does not block local users", func(t *testing.T) {
+		m, svc := setup()
+		m.users.On("FindByUsername", ctx, "alice").Return(nil, repository.ErrUserNotFound)
+		m.directory.On("Authenticate", ctx, "alice", "password123").Return(nil, errUnavailable)
+
+		// Nobody else accepted the credentials, so the outage is reported
+		_, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		assert.ErrorIs(t, err, errUnavailable)
+
+		m, svc = setup()
+		alice, _ := domain.NewUser("alice", "password123", domain.RoleUser)
+		m.users.On("FindByUsername", ctx, "alice").Return(alice, nil)
+		_, err = svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"internal-dns/pkg/bloomfilter"
+)
+
+type bulkRecordService struct {
+	dnsRepo     repository.DNSRecordRepository
+	bloomFilter bloomfilter.Filter
+	cache       cache.DNSRecordCache
+	auditRepo   repository.AuditLogRepository
+	tx          repository.Transactor
+}
+
+// NewBulkRecordService creates a new BulkRecordUseCase implementation.
+func NewBulkRecordService(dnsRepo repository.DNSRecordRepository, bf bloomfilter.Filter, cache cache.DNSRecordCache, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.BulkRecordUseCase {
+	return &bulkRecordService{
+		dnsRepo:     dnsRepo,
+		bloomFilter: bf,
+		cache:       cache,
+		auditRepo:   auditRepo,
+		tx:          tx,
+	}
+}
+
+// ImportRecords validates each row and creates or updates the records of the
+// user. Names owned by another user and names repeated in the input are
+// invalid. In atomic mode nothing is stored if any row is invalid; in
+// best-effort mode invalid rows are skipped. The stored rows are written in
+// one transaction, with their audit entries.
+func (s *bulkRecordService) ImportRecords(ctx context.Context, userID int64, rows []domain.RecordImportRow, mode domain.ImportMode) (*domain.RecordImport, error) {
+	if mode != domain.ImportAtomic && mode != domain.ImportBestEffort {
+		return nil, domain.ErrInvalidImportMode
+	}
+	if len(rows) > domain.MaxImportRows {
+		return nil, domain.ErrTooManyImportRows
+	}
+
+	result := &domain.RecordImport{Mode: mode, Rows: make([]domain.RecordImportRowResult, len(rows))}
+	records := make([]*domain.DNSRecord, len(rows))
+	seen := make(map[string]int, len(rows))
+	var names []string
+	for i, row := range rows {
+		res := &result.Rows[i]
+		res.Row = row.Row
+		res.DomainName = row.DomainName
+
+		record, err := domain.NewDNSRecord(userID, row.DomainName, row.Value, row.Type)
+		if err == nil {
+			err = domain.CheckNameScope(ctx, record.DomainName)
+		}
+		if err == nil && len(row.AdditionalValues) > 0 {
+			err = record.SetHealthCheck(row.AdditionalValues, nil)
+		}
+		if err != nil {
+			res.Error = err.Error()
+			continue
+		}
+		res.DomainName = record.DomainName
+		if first, ok := seen[record.DomainName]; ok {
+			res.Error = fmt.Sprintf("duplicate of row %d", first)
+			continue
+		}
+		seen[record.DomainName] = row.Row
+		records[i] = record
+		names = append(names, record.DomainName)
+	}
+
+	stored := make(map[string]*domain.DNSRecord)
+	if len(names) > 0 {
+		existing, err := s.dnsRepo.FindByDomainNames(ctx, names)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range existing {
+			stored[record.DomainName] = record
+		}
+	}
+
+	var creates, updates []*domain.DNSRecord
+	var changes []domain.ChangeSetItem
+	for i, record := range records {
+		if record == nil {
+			continue
+		}
+		res := &result.Rows[i]
+		old, ok := stored[record.DomainName]
+		switch {
+		case !ok:
+			res.Action = domain.ZoneChangeCreate
+			creates = append(creates, record)
+			changes = append(changes, domain.ChangeSetItem{Op: domain.ChangeCreate, Record: record})
+		case old.DeletedAt != nil:
+			res.Error = repository.ErrDomainNameInTrash.Error()
+			continue
+		case old.UserID != userID:
+			res.Error = repository.ErrDuplicateDomainName.Error()
+			continue
+		default:
+			record.ID = old.ID
+			record.CreatedAt = old.CreatedAt
+			if record.Type == domain.A && old.Type == domain.A {
+				if rows[i].AdditionalValues == nil {
+					record.AdditionalValues = old.AdditionalValues
+				}
+				record.HealthCheck = old.HealthCheck
+				record.TrafficPolicy = old.TrafficPolicy
+			}
+			if sameRecordData(old, record) {
+				res.Action = domain.ZoneChangeUnchanged
+				record = old
+			} else {
+				res.Action = domain.ZoneChangeUpdate
+				updates = append(updates, record)
+				changes = append(changes, domain.ChangeSetItem{Op: domain.ChangeUpdate, Record: record, Previous: old})
+			}
+		}
+		res.Record = record
+	}
+
+	for _, res := range result.Rows {
+		switch {
+		case res.Error != "":
+			result.Failed++
+		case res.Action == domain.ZoneChangeCreate:
+			result.Created++
+		case res.Action == domain.ZoneChangeUpdate:
+			result.Updated++
+		case res.Action == domain.ZoneChangeUnchanged:
+			result.Unchanged++
+		}
+	}
+	if result.Failed > 0 && mode == domain.ImportAtomic {
+		return result, nil
+	}
+
+	summary := map[string]interface{}{
+		"mode":      mode,
+		"created":   result.Created,
+		"updated":   result.Updated,
+		"unchanged": result.Unchanged,
+		"failed":    result.Failed,
+	}
+	changeID := domain.NewChangeID()
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if len(creates)+len(updates) > 0 {
+			if err := s.dnsRepo.ApplyBatch(ctx, creates, updates, nil); err != nil {
+				return err
+			}
+		}
+		if err := auditRecordChanges(ctx, s.auditRepo, userID, changeID, changes); err != nil {
+			return err
+		}
+		auditLog, err := domain.NewAuditLog(userID, domain.ActionImportDNSRecords, 0, nil, summary)
+		if err != nil {
+			return err
+		}
+		auditLog.ChangeID = changeID
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for DNS record import: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Applied = true
+
+	for _, record := range creates {
+		if err := s.bloomFilter.Add(ctx, record.DomainName); err != nil {
+			log.Printf("Failed to add domain to Bloom filter: %v", err)
+		}
+	}
+	for _, record := range updates {
+		if err := s.cache.Delete(ctx, record.DomainName); err != nil {
+			log.Printf("Failed to delete domain from cache: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// ExportRecords returns all records of the user.
+func (s *bulkRecordService) ExportRecords(ctx context.Context, userID int64) ([]*domain.DNSRecord, error) {
+	return findAllUserRecords(ctx, s.dnsRepo, userID)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"internal-dns/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkRecordService_ImportRecords(t *testing.T) {
+	ctx := context.Background()
+
+	rows := []domain.RecordImportRow{
+		{Row: 1, DomainName: "new.local", Type: domain.A, Value: "10.0.0.1"},
+		{Row: 2, DomainName: "Mine.local", Type: domain.A, Value: "10.0.0.2"},
+		{Row: 3, DomainName: "same.local", Type: domain.CNAME, Value: "new.local"},
+		{Row: 4, DomainName: "bad_name", Type: domain.A, Value: "10.0.0.3"},
+		{Row: 5, DomainName: "new.local", Type: domain.A, Value: "10.0.0.4"},
+		{Row: 6, DomainName: "theirs.local", Type: domain.A, Value: "10.0.0.5"},
+	}
+	existing := func() []*domain.DNSRecord {
+		return []*domain.DNSRecord{
+			{ID: 2, UserID: 1, DomainName: "mine.local", Type: domain.A, Value: "10.0.0.9", AdditionalValues: []string{"10.0.0.10"}},
+			{ID: 3, UserID: 1, DomainName: "same.local", Type: domain.CNAME, Value: "new.local"},
+			{ID: 4, UserID: 2, DomainName: "theirs.local", Type: domain.A, Value: "10.0.0.5"},
+		}
+	}
+	names := []string{"new.local", "mine.local", "same.local", "theirs.local"}
+
+	t.Run("Atomic mode stores nothing when a row is invalid", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		service := NewBulkRecordService(mockRepo, new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+		mockRepo.On("FindByDomainNames", ctx, names).Return(existing(), nil).Once()
+
+		result, err := service.ImportRecords(ctx, 1, rows, domain.ImportAtomic)
+		require.NoError(t, err)
+
+		assert.False(t, result.Applied)
+		assert.Equal(t, 3, result.Failed)
+		assert.Equal(t, domain.ZoneChangeCreate, result.Rows[0].Action)
+		assert.Equal(t, domain.ZoneChangeUpdate, result.Rows[1].Action)
+		assert.Equal(t, domain.ZoneChangeUnchanged, result.Rows[2].Action)
+		assert.Equal(t, domain.ErrInvalidDomainName.Error(), result.Rows[3].Error)
+		assert.Equal(t, "duplicate of row 1", result.Rows[4].Error)
+		assert.NotEmpty(t, result.Rows[5].Error)
+		mockRepo.AssertNotCalled(t, "ApplyBatch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Best-effort mode stores the valid rows in one batch", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(3)
+
+		mockRepo := new(MockDNSRecordRepository)
+		mockBF := new(MockBloomFilter)
+		mockCache := new(MockDNSRecordCache)
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewBulkRecordService(mockRepo, mockBF, mockCache, mockAuditRepo, noTx{})
+
+		mockRepo.On("FindByDomainNames", ctx, names).Return(existing(), nil).Once()
+		mockRepo.On("ApplyBatch", ctx,
+			mock.MatchedBy(func(creates []*domain.DNSRecord) bool {
+				return len(creates) == 1 && creates[0].DomainName == "new.local"
+			}),
+			mock.MatchedBy(func(updates []*domain.DNSRecord) bool {
+				// Rows without additional values keep the stored ones
+				return len(updates) == 1 && updates[0].ID == 2 && updates[0].Value == "10.0.0.2" &&
+					assert.ObjectsAreEqual([]string{"10.0.0.10"}, updates[0].AdditionalValues)
+			}),
+			[]int64(nil),
+		).Return(nil).Once()
+		mockBF.On("Add", ctx, "new.local").Return(nil).Once()
+		mockCache.On("Delete", ctx, "mine.local").Return(nil).Once()
+		// One entry per changed record and a summary, all sharing the change ID
+		changeIDs := make(map[domain.ActionType]string)
+		var mu sync.Mutex
+		for _, action := range []domain.ActionType{domain.ActionCreateDNSRecord, domain.ActionUpdateDNSRecord, domain.ActionImportDNSRecords} {
+			action := action
+			mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+				return l.Action == action
+			})).Run(func(args mock.Arguments) {
+				mu.Lock()
+				changeIDs[action] = args.Get(1).(*domain.AuditLog).ChangeID
+				mu.Unlock()
+				wg.Done()
+			}).Return(nil).Once()
+		}
+
+		result, err := service.ImportRecords(ctx, 1, rows, domain.ImportBestEffort)
+		require.NoError(t, err)
+		waitForAudit(t, &wg)
+
+		assert.True(t, result.Applied)
+		assert.Equal(t, 1, result.Created)
+		assert.Equal(t, 1, result.Updated)
+		assert.Equal(t, 1, result.Unchanged)
+		assert.Equal(t, 3, result.Failed)
+		mockRepo.AssertExpectations(t)
+		mockBF.AssertExpectations(t)
+		mockCache.AssertExpectations(t)
+		mockAuditRepo.AssertExpectations(t)
+		assert.NotEmpty(t, changeIDs[domain.ActionImportDNSRecords])
+		assert.Equal(t, changeIDs[domain.ActionImportDNSRecords], changeIDs[domain.ActionCreateDNSRecord])
+		assert.Equal(t, changeIDs[domain.ActionImportDNSRecords], changeIDs[domain.ActionUpdateDNSRecord])
+	})
+
+	t.Run("Rejects too many rows", func(t *testing.T) {
+		service := NewBulkRecordService(new(MockDNSRecordRepository), new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+		_, err := service.ImportRecords(ctx, 1, make([]domain.RecordImportRow, domain.MaxImportRows+1), domain.ImportAtomic)
+		assert.ErrorIs(t, err, domain.ErrTooManyImportRows)
+	})
+}
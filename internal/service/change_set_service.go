@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"internal-dns/pkg/bloomfilter"
+)
+
+type changeSetService struct {
+	dnsRepo     repository.DNSRecordRepository
+	bloomFilter bloomfilter.Filter
+	cache       cache.DNSRecordCache
+	auditRepo   repository.AuditLogRepository
+	tx          repository.Transactor
+}
+
+// NewChangeSetService creates a new ChangeSetUseCase implementation.
+func NewChangeSetService(dnsRepo repository.DNSRecordRepository, bf bloomfilter.Filter, cache cache.DNSRecordCache, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.ChangeSetUseCase {
+	return &changeSetService{
+		dnsRepo:     dnsRepo,
+		bloomFilter: bf,
+		cache:       cache,
+		auditRepo:   auditRepo,
+		tx:          tx,
+	}
+}
+
+// PreviewChangeSet computes the diff of the changes and validates them
+// against the current records without changing anything.
+func (s *changeSetService) PreviewChangeSet(ctx context.Context, userID int64, changes []domain.RecordChange) (*domain.ChangeSet, error) {
+	return s.plan(ctx, userID, changes)
+}
+
+// ApplyChangeSet applies all changes in one transaction, or none if any of
+// them is invalid. Audit entries of the change set share its ID and are
+// written in the same transaction.
+func (s *changeSetService) ApplyChangeSet(ctx context.Context, userID int64, changes []domain.RecordChange, fingerprint string) (*domain.ChangeSet, error) {
+	cs, err := s.plan(ctx, userID, changes)
+	if err != nil {
+		return nil, err
+	}
+	if !cs.Valid {
+		return cs, nil
+	}
+	if fingerprint != "" && fingerprint != cs.Fingerprint {
+		return nil, domain.ErrChangeSetStale
+	}
+
+	var creates, updates []*domain.DNSRecord
+	var deletes []int64
+	for _, item := range cs.Items {
+		switch item.Op {
+		case domain.ChangeCreate:
+			creates = append(creates, item.Record)
+		case domain.ChangeUpdate:
+			updates = append(updates, item.Record)
+		case domain.ChangeDelete:
+			deletes = append(deletes, item.Previous.ID)
+		}
+	}
+	changeID := domain.NewChangeID()
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.dnsRepo.ApplyBatch(ctx, creates, updates, deletes); err != nil {
+			return err
+		}
+		return auditRecordChanges(ctx, s.auditRepo, userID, changeID, cs.Items)
+	})
+	if err != nil {
+		return nil, err
+	}
+	cs.Applied = true
+	cs.ID = changeID
+
+	for _, item := range cs.Items {
+		if item.Op == domain.ChangeCreate {
+			if err := s.bloomFilter.Add(ctx, item.Record.DomainName); err != nil {
+				log.Printf("Failed to add domain to Bloom filter: %v", err)
+			}
+			continue
+		}
+		names := []string{item.Previous.DomainName}
+		if item.Record != nil && item.Record.DomainName != item.Previous.DomainName {
+			names = append(names, item.Record.DomainName)
+		}
+		for _, name := range names {
+			if err := s.cache.Delete(ctx, name); err != nil {
+				log.Printf("Failed to delete domain from cache: %v", err)
+			}
+		}
+	}
+
+	return cs, nil
+}
+
+// auditRecordChanges writes one audit entry per changed record, all sharing
+// changeID, so that each change shows up in the history of its record.
+func auditRecordChanges(ctx context.Context, auditRepo repository.AuditLogRepository, userID int64, changeID string, items []domain.ChangeSetItem) error {
+	for _, item := range items {
+		action, targetID := domain.ActionCreateDNSRecord, int64(0)
+		var oldValue, newValue interface{}
+		switch item.Op {
+		case domain.ChangeCreate:
+			targetID, newValue = item.Record.ID, item.Record
+		case domain.ChangeUpdate:
+			action, targetID, oldValue, newValue = domain.ActionUpdateDNSRecord, item.Record.ID, item.Previous, item.Record
+		case domain.ChangeDelete:
+			action, targetID, oldValue = domain.ActionDeleteDNSRecord, item.Previous.ID, item.Previous
+		}
+		auditLog, err := domain.NewAuditLog(userID, action, targetID, oldValue, newValue)
+		if err != nil {
+			return err
+		}
+		auditLog.ChangeID = changeID
+		if err := auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for change %s: %w", changeID, err)
+		}
+	}
+	return nil
+}
+
+// plan validates each operation and the resulting set of names. Records can
+// take names that other operations of the same change set free.
+func (s *changeSetService) plan(ctx context.Context, userID int64, changes []domain.RecordChange) (*domain.ChangeSet, error) {
+	if len(changes) == 0 {
+		return nil, domain.ErrEmptyChangeSet
+	}
+	if len(changes) > domain.MaxChangeSetOperations {
+		return nil, domain.ErrChangeSetTooLarge
+	}
+
+	cs := &domain.ChangeSet{Items: make([]domain.ChangeSetItem, len(changes))}
+	loaded := make(map[int64]*domain.DNSRecord)
+	touched := make(map[int64]int)
+	for i, ch := range changes {
+		item := &cs.Items[i]
+		item.Index = i
+		item.Op = ch.Op
+
+		if ch.Op == domain.ChangeUpdate || ch.Op == domain.ChangeDelete {
+			if first, ok := touched[ch.RecordID]; ok {
+				item.Error = fmt.Sprintf("record %d is already changed by operation %d", ch.RecordID, first)
+				continue
+			}
+			old, err := s.dnsRepo.FindByID(ctx, ch.RecordID)
+			if errors.Is(err, repository.ErrDNSRecordNotFound) || (err == nil && old.UserID != userID) {
+				item.Error = repository.ErrDNSRecordNotFound.Error()
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			touched[ch.RecordID] = i
+			loaded[old.ID] = old
+			item.Previous = old
+			if err := domain.CheckNameScope(ctx, old.DomainName); err != nil {
+				item.Error = err.Error()
+				continue
+			}
+		}
+
+		switch ch.Op {
+		case domain.ChangeCreate, domain.ChangeUpdate:
+			record, err := domain.NewDNSRecord(userID, ch.DomainName, ch.Value, ch.Type)
+			if err == nil {
+				err = domain.CheckNameScope(ctx, record.DomainName)
+			}
+			if err != nil {
+				item.Error = err.Error()
+				continue
+			}
+			if old := item.Previous; old != nil {
+				record.ID = old.ID
+				record.CreatedAt = old.CreatedAt
+				if record.Type == domain.A && old.Type == domain.A {
+					record.AdditionalValues = old.AdditionalValues
+					record.HealthCheck = old.HealthCheck
+					record.TrafficPolicy = old.TrafficPolicy
+				}
+			}
+			item.Record = record
+		case domain.ChangeDelete:
+		default:
+			item.Error = fmt.Sprintf("unknown operation %q", ch.Op)
+		}
+	}
+
+	// Names taken by the change set, and records leaving their current name
+	claimed := make(map[string]int)
+	leaving := make(map[int64]bool)
+	var names []string
+	for i := range cs.Items {
+		item := &cs.Items[i]
+		if item.Previous != nil && (item.Record == nil || item.Record.DomainName != item.Previous.DomainName) {
+			leaving[item.Previous.ID] = true
+		}
+		if item.Record == nil || item.Error != "" {
+			continue
+		}
+		if first, ok := claimed[item.Record.DomainName]; ok {
+			item.Error = fmt.Sprintf("%s is also used by operation %d", item.Record.DomainName, first)
+			continue
+		}
+		claimed[item.Record.DomainName] = i
+		names = append(names, item.Record.DomainName)
+	}
+
+	var existing []*domain.DNSRecord
+	if len(names) > 0 {
+		var err error
+		existing, err = s.dnsRepo.FindByDomainNames(ctx, names)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, record := range existing {
+		loaded[record.ID] = record
+		item := &cs.Items[claimed[record.DomainName]]
+		if item.Error != "" || record.ID == item.Record.ID || leaving[record.ID] {
+			continue
+		}
+		if record.DeletedAt != nil {
+			item.Error = repository.ErrDomainNameInTrash.Error()
+			continue
+		}
+		item.Error = repository.ErrDuplicateDomainName.Error()
+	}
+
+	cs.Valid = true
+	for _, item := range cs.Items {
+		if item.Error != "" {
+			cs.Valid = false
+		}
+	}
+	cs.Fingerprint = fingerprint(loaded)
+	return cs, nil
+}
+
+// fingerprint identifies the state of a set of records.
+func fingerprint(records map[int64]*domain.DNSRecord) string {
+	ids := make([]int64, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	h := sha256.New()
+	for _, id := range ids {
+		r := records[id]
+		fmt.Fprintf(h, "%d %s %d\n", id, r.DomainName, r.UpdatedAt.UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeSetService(t *testing.T) {
+	ctx := context.Background()
+	updatedAt := time.Unix(1700000000, 0)
+
+	oldHost := func() *domain.DNSRecord {
+		return &domain.DNSRecord{ID: 1, UserID: 1, DomainName: "old.svc.local", Type: domain.A, Value: "10.0.0.1", UpdatedAt: updatedAt}
+	}
+	alias := func() *domain.DNSRecord {
+		return &domain.DNSRecord{ID: 2, UserID: 1, DomainName: "svc.local", Type: domain.CNAME, Value: "old.svc.local", UpdatedAt: updatedAt}
+	}
+	// Moving a service: delete the old host, create two new ones, repoint the alias
+	changes := []domain.RecordChange{
+		{Op: domain.ChangeDelete, RecordID: 1},
+		{Op: domain.ChangeCreate, DomainName: "a.svc.local", Type: domain.A, Value: "10.0.1.1"},
+		{Op: domain.ChangeCreate, DomainName: "old.svc.local", Type: domain.A, Value: "10.0.1.2"},
+		{Op: domain.ChangeUpdate, RecordID: 2, DomainName: "svc.local", Type: domain.CNAME, Value: "a.svc.local"},
+	}
+	names := []string{"a.svc.local", "old.svc.local", "svc.local"}
+
+	setup := func() (*MockDNSRecordRepository, *MockBloomFilter, *MockDNSRecordCache, *MockAuditLogRepository) {
+		mockRepo := new(MockDNSRecordRepository)
+		mockRepo.On("FindByID", ctx, int64(1)).Return(oldHost(), nil)
+		mockRepo.On("FindByID", ctx, int64(2)).Return(alias(), nil)
+		mockRepo.On("FindByDomainNames", ctx, names).Return([]*domain.DNSRecord{oldHost(), alias()}, nil)
+		return mockRepo, new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository)
+	}
+
+	t.Run("Preview computes the diff and allows reusing freed names", func(t *testing.T) {
+		mockRepo, mockBF, mockCache, mockAuditRepo := setup()
+		service := NewChangeSetService(mockRepo, mockBF, mockCache, mockAuditRepo, noTx{})
+
+		cs, err := service.PreviewChangeSet(ctx, 1, changes)
+		require.NoError(t, err)
+
+		assert.True(t, cs.Valid)
+		assert.False(t, cs.Applied)
+		assert.Empty(t, cs.ID)
+		assert.NotEmpty(t, cs.Fingerprint)
+		require.Len(t, cs.Items, 4)
+		assert.Equal(t, int64(1), cs.Items[0].Previous.ID)
+		assert.Nil(t, cs.Items[0].Record)
+		assert.Equal(t, "a.svc.local", cs.Items[3].Record.Value)
+		assert.Equal(t, "old.svc.local", cs.Items[3].Previous.Value)
+		mockRepo.AssertNotCalled(t, "ApplyBatch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Apply stores everything in one batch under one change ID", func(t *testing.T) {
+		mockRepo, mockBF, mockCache, mockAuditRepo := setup()
+		service := NewChangeSetService(mockRepo, mockBF, mockCache, mockAuditRepo, noTx{})
+
+		preview, err := service.PreviewChangeSet(ctx, 1, changes)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		wg.Add(4)
+		mockRepo.On("ApplyBatch", ctx,
+			mock.MatchedBy(func(creates []*domain.DNSRecord) bool { return len(creates) == 2 }),
+			mock.MatchedBy(func(updates []*domain.DNSRecord) bool { return len(updates) == 1 && updates[0].ID == 2 }),
+			[]int64{1},
+		).Run(func(args mock.Arguments) {
+			for i, r := range args.Get(1).([]*domain.DNSRecord) {
+				r.ID = int64(10 + i)
+			}
+		}).Return(nil).Once()
+		mockBF.On("Add", ctx, "a.svc.local").Return(nil).Once()
+		mockBF.On("Add", ctx, "old.svc.local").Return(nil).Once()
+		mockCache.On("Delete", ctx, "old.svc.local").Return(nil).Once()
+		mockCache.On("Delete", ctx, "svc.local").Return(nil).Once()
+
+		var changeIDs []string
+		var mu sync.Mutex
+		mockAuditRepo.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			mu.Lock()
+			changeIDs = append(changeIDs, args.Get(1).(*domain.AuditLog).ChangeID)
+			mu.Unlock()
+			wg.Done()
+		}).Return(nil).Times(4)
+
+		cs, err := service.ApplyChangeSet(ctx, 1, changes, preview.Fingerprint)
+		require.NoError(t, err)
+		waitForAudit(t, &wg)
+
+		assert.True(t, cs.Applied)
+		assert.Len(t, cs.ID, 36)
+		assert.Equal(t, []string{cs.ID, cs.ID, cs.ID, cs.ID}, changeIDs)
+		mockRepo.AssertExpectations(t)
+		mockBF.AssertExpectations(t)
+		mockCache.AssertExpectations(t)
+	})
+
+	t.Run("Apply refuses a stale fingerprint", func(t *testing.T) {
+		mockRepo, mockBF, mockCache, mockAuditRepo := setup()
+		service := NewChangeSetService(mockRepo, mockBF, mockCache, mockAuditRepo, noTx{})
+
+		_, err := service.ApplyChangeSet(ctx, 1, changes, "stale")
+		assert.ErrorIs(t, err, domain.ErrChangeSetStale)
+		mockRepo.AssertNotCalled(t, "ApplyBatch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid operations fail the whole change set", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		service := NewChangeSetService(mockRepo, new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+		taken := &domain.DNSRecord{ID: 5, UserID: 2, DomainName: "taken.local", Type: domain.A, Value: "10.0.0.5"}
+		mockRepo.On("FindByID", ctx, int64(2)).Return(alias(), nil)
+		mockRepo.On("FindByID", ctx, int64(9)).Return(nil, repository.ErrDNSRecordNotFound)
+		mockRepo.On("FindByDomainNames", ctx, []string{"taken.local", "dup.local"}).Return([]*domain.DNSRecord{taken}, nil)
+
+		cs, err := service.ApplyChangeSet(ctx, 1, []domain.RecordChange{
+			{Op: domain.ChangeCreate, DomainName: "taken.local", Type: domain.A, Value: "10.0.0.1"},
+			{Op: domain.ChangeDelete, RecordID: 9},
+			{Op: domain.ChangeCreate, DomainName: "dup.local", Type: domain.A, Value: "10.0.0.1"},
+			{Op: domain.ChangeCreate, DomainName: "dup.local", Type: domain.A, Value: "10.0.0.2"},
+			{Op: domain.ChangeDelete, RecordID: 2},
+			{Op: domain.ChangeUpdate, RecordID: 2, DomainName: "svc.local", Type: domain.A, Value: "10.0.0.3"},
+			{Op: "rename"},
+		}, "")
+		require.NoError(t, err)
+
+		assert.False(t, cs.Valid)
+		assert.False(t, cs.Applied)
+		var failed []int
+		for _, item := range cs.Items {
+			if item.Error != "" {
+				failed = append(failed, item.Index)
+			}
+		}
+		assert.Equal(t, []int{0, 1, 3, 5, 6}, failed)
+		mockRepo.AssertNotCalled(t, "ApplyBatch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("API keys only change names under their suffixes", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		service := NewChangeSetService(mockRepo, new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+		keyCtx := domain.ContextWithAPIKey(ctx, &domain.APIKey{Scopes: []domain.APIKeyScope{domain.ScopeRecordsWrite}, Suffixes: []string{"a.svc.local"}})
+		mockRepo.On("FindByID", keyCtx, int64(1)).Return(oldHost(), nil)
+		mockRepo.On("FindByID", keyCtx, int64(2)).Return(alias(), nil)
+		mockRepo.On("FindByDomainNames", keyCtx, mock.Anything).Return([]*domain.DNSRecord{}, nil)
+
+		cs, err := service.PreviewChangeSet(keyCtx, 1, changes)
+		require.NoError(t, err)
+
+		assert.False(t, cs.Valid)
+		var failed []int
+		for _, item := range cs.Items {
+			if item.Error != "" {
+				assert.Equal(t, domain.ErrNameOutOfScope.Error(), item.Error)
+				failed = append(failed, item.Index)
+			}
+		}
+		assert.Equal(t, []int{0, 2, 3}, failed)
+	})
+
+	t.Run("Empty change set", func(t *testing.T) {
+		service := NewChangeSetService(new(MockDNSRecordRepository), new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+		_, err := service.PreviewChangeSet(ctx, 1, nil)
+		assert.ErrorIs(t, err, domain.ErrEmptyChangeSet)
+	})
+}
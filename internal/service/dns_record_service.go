@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log" // Added log import
+	"time"
 
 	"internal-dns/internal/domain"
 	"internal-dns/internal/infrastructure/cache"
@@ -17,15 +19,18 @@ type dnsRecordService struct {
 	bloomFilter bloomfilter.Filter
 	cache       cache.DNSRecordCache
 	auditRepo   repository.AuditLogRepository // Added auditRepo
+	tx          repository.Transactor
 }
 
 // NewDNSRecordService creates a new DNSRecordUseCase implementation.
-func NewDNSRecordService(dnsRepo repository.DNSRecordRepository, bf bloomfilter.Filter, cache cache.DNSRecordCache, auditRepo repository.AuditLogRepository) usecase.DNSRecordUseCase { // Changed signature, kept usecase interface
+// Changes are audited in the transaction that stores them.
+func NewDNSRecordService(dnsRepo repository.DNSRecordRepository, bf bloomfilter.Filter, cache cache.DNSRecordCache, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.DNSRecordUseCase { // Changed signature, kept usecase interface
 	return &dnsRecordService{
 		dnsRepo:     dnsRepo,
 		bloomFilter: bf,
 		cache:       cache,
 		auditRepo:   auditRepo,
+		tx:          tx,
 	}
 }
 
@@ -52,9 +57,25 @@ func (s *dnsRecordService) CreateRecord(ctx context.Context, userID int64, domai
 	if err != nil {
 		return nil, err
 	}
+	if err := domain.CheckNameScope(ctx, record.DomainName); err != nil {
+		return nil, err
+	}
 
-	// 4. Persist to the database
-	if err := s.dnsRepo.Create(ctx, record); err != nil {
+	// 4. Persist to the database, with the audit log
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.dnsRepo.Create(ctx, record); err != nil {
+			return err
+		}
+		auditLog, err := domain.NewAuditLog(userID, domain.ActionCreateDNSRecord, record.ID, nil, record)
+		if err != nil {
+			return err
+		}
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for DNS record creation: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -64,16 +85,6 @@ func (s *dnsRecordService) CreateRecord(ctx context.Context, userID int64, domai
 		log.Printf("Failed to add domain to Bloom filter: %v", err) // Added log
 	}
 
-	// 6. Fire-and-forget audit log
-	go func() {
-		auditLog, err := domain.NewAuditLog(userID, domain.ActionCreateDNSRecord, record.ID, nil, record)
-		if err == nil {
-			if err := s.auditRepo.Create(context.Background(), auditLog); err != nil {
-				log.Printf("failed to create audit log for DNS record creation: %v", err)
-			}
-		}
-	}()
-
 	return record, nil
 }
 
@@ -124,11 +135,33 @@ func (s *dnsRecordService) UpdateRecord(ctx context.Context, userID int64, recor
 	if err != nil {
 		return nil, err
 	}
+	if err := domain.CheckNameScope(ctx, oldRecord.DomainName, updatedRecord.DomainName); err != nil {
+		return nil, err
+	}
 	updatedRecord.ID = recordID                   // Preserve original ID
 	updatedRecord.CreatedAt = oldRecord.CreatedAt // Preserve original creation time
+	if updatedRecord.Type == domain.A {
+		// Keep failover and traffic settings; they are managed separately
+		updatedRecord.AdditionalValues = oldRecord.AdditionalValues
+		updatedRecord.HealthCheck = oldRecord.HealthCheck
+		updatedRecord.TrafficPolicy = oldRecord.TrafficPolicy
+	}
 
-	// 3. Persist the update
-	if err := s.dnsRepo.Update(ctx, updatedRecord); err != nil {
+	// 3. Persist the update, with the audit log
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.dnsRepo.Update(ctx, updatedRecord); err != nil {
+			return err
+		}
+		auditLog, err := domain.NewAuditLog(userID, domain.ActionUpdateDNSRecord, recordID, oldRecord, updatedRecord)
+		if err != nil {
+			return err
+		}
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for DNS record update: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -142,28 +175,36 @@ func (s *dnsRecordService) UpdateRecord(ctx context.Context, userID int64, recor
 		}
 	}
 
-	// 5. Fire-and-forget audit log
-	go func() {
-		auditLog, err := domain.NewAuditLog(userID, domain.ActionUpdateDNSRecord, recordID, oldRecord, updatedRecord)
-		if err == nil {
-			if err := s.auditRepo.Create(context.Background(), auditLog); err != nil {
-				log.Printf("failed to create audit log for DNS record update: %v", err)
-			}
-		}
-	}()
-
 	return updatedRecord, nil
 }
 
+// DeleteRecord moves a record to the trash. It is no longer answered, but
+// its name stays reserved until it is purged.
 func (s *dnsRecordService) DeleteRecord(ctx context.Context, userID int64, recordID int64) error {
 	// 1. Verify ownership and get the record to be deleted
 	record, err := s.GetRecordByID(ctx, userID, recordID) // Use GetRecordByID for ownership check
 	if err != nil {
 		return err
 	}
+	if err := domain.CheckNameScope(ctx, record.DomainName); err != nil {
+		return err
+	}
 
-	// 2. Delete from the database
-	if err := s.dnsRepo.Delete(ctx, recordID); err != nil {
+	// 2. Move to the trash, with the audit log
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.dnsRepo.Delete(ctx, recordID); err != nil {
+			return err
+		}
+		auditLog, err := domain.NewAuditLog(userID, domain.ActionDeleteDNSRecord, recordID, record, nil)
+		if err != nil {
+			return err
+		}
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for DNS record deletion: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
@@ -172,19 +213,86 @@ func (s *dnsRecordService) DeleteRecord(ctx context.Context, userID int64, recor
 		log.Printf("Failed to delete domain from cache: %v", err) // Added log
 	}
 
-	// 4. Fire-and-forget audit log
-	go func() {
-		auditLog, err := domain.NewAuditLog(userID, domain.ActionDeleteDNSRecord, recordID, record, nil)
-		if err == nil {
-			if err := s.auditRepo.Create(context.Background(), auditLog); err != nil {
-				log.Printf("failed to create audit log for DNS record deletion: %v", err)
-			}
-		}
-	}()
-
 	return nil
 }
 
 func (s *dnsRecordService) ResolveDomain(ctx context.Context, domainName string) (*domain.DNSRecord, error) {
 	return s.dnsRepo.FindByDomainName(ctx, domainName)
 }
+
+// ListDeletedRecords returns the records of the user in the trash.
+func (s *dnsRecordService) ListDeletedRecords(ctx context.Context, userID int64) ([]*domain.DNSRecord, error) {
+	return s.dnsRepo.FindDeletedByUserID(ctx, userID)
+}
+
+// RestoreRecord takes a record of the user out of the trash.
+func (s *dnsRecordService) RestoreRecord(ctx context.Context, userID int64, recordID int64) (*domain.DNSRecord, error) {
+	record, err := s.dnsRepo.FindDeletedByID(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if record.UserID != userID {
+		return nil, repository.ErrDNSRecordNotFound // Hide existence from other users
+	}
+	if err := domain.CheckNameScope(ctx, record.DomainName); err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.dnsRepo.Restore(ctx, record); err != nil {
+			return err
+		}
+		auditLog, err := domain.NewAuditLog(userID, domain.ActionRestoreDNSRecord, recordID, nil, record)
+		if err != nil {
+			return err
+		}
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for DNS record restore: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cache.Delete(ctx, record.DomainName); err != nil {
+		log.Printf("Failed to delete domain from cache: %v", err)
+	}
+
+	return record, nil
+}
+
+// PurgeDeletedRecords removes records that have been in the trash for at
+// least deletedFor, releasing their names.
+func (s *dnsRecordService) PurgeDeletedRecords(ctx context.Context, deletedFor time.Duration) (int64, error) {
+	return s.dnsRepo.PurgeDeleted(ctx, time.Now().Add(-deletedFor))
+}
+
+// RunTrashPurger purges records that have been in the trash for retention,
+// every interval until ctx is cancelled.
+func RunTrashPurger(ctx context.Context, uc usecase.DNSRecordUseCase, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := uc.PurgeDeletedRecords(ctx, retention)
+			if err != nil {
+				log.Printf("Failed to purge deleted DNS records: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d deleted DNS records", purged)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ListUnusedRecords returns records that have not been queried for at least
+// unusedFor. Records created more recently than that are not reported.
+func (s *dnsRecordService) ListUnusedRecords(ctx context.Context, unusedFor time.Duration) ([]*domain.DNSRecord, error) {
+	return s.dnsRepo.FindUnqueriedSince(ctx, time.Now().Add(-unusedFor))
+}
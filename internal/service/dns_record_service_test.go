@@ -13,6 +13,7 @@ import (
 
 	"internal-dns/internal/domain"
 	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
 )
 
 // MockDNSRecordRepository is a mock implementation of DNSRecordRepository
@@ -65,6 +66,71 @@ func (m *MockDNSRecordRepository) GetAllDomainNames(ctx context.Context) ([]stri
 	}
 	return args.Get(0).([]string), args.Error(1)
 }
+func (m *MockDNSRecordRepository) AddQueryStats(ctx context.Context, stats []domain.QueryStat) error {
+	args := m.Called(ctx, stats)
+	return args.Error(0)
+}
+func (m *MockDNSRecordRepository) FindUnqueriedSince(ctx context.Context, since time.Time) ([]*domain.DNSRecord, error) {
+	args := m.Called(ctx, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.DNSRecord), args.Error(1)
+}
+func (m *MockDNSRecordRepository) FindWithHealthChecks(ctx context.Context) ([]*domain.DNSRecord, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.DNSRecord), args.Error(1)
+}
+
+func (m *MockDNSRecordRepository) FindByDomainNames(ctx context.Context, domainNames []string) ([]*domain.DNSRecord, error) {
+	args := m.Called(ctx, domainNames)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.DNSRecord), args.Error(1)
+}
+
+func (m *MockDNSRecordRepository) FindByZone(ctx context.Context, zone string) ([]*domain.DNSRecord, error) {
+	args := m.Called(ctx, zone)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.DNSRecord), args.Error(1)
+}
+
+func (m *MockDNSRecordRepository) ApplyBatch(ctx context.Context, creates, updates []*domain.DNSRecord, deletes []int64) error {
+	args := m.Called(ctx, creates, updates, deletes)
+	return args.Error(0)
+}
+
+func (m *MockDNSRecordRepository) Restore(ctx context.Context, record *domain.DNSRecord) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (m *MockDNSRecordRepository) FindDeletedByID(ctx context.Context, id int64) (*domain.DNSRecord, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DNSRecord), args.Error(1)
+}
+
+func (m *MockDNSRecordRepository) FindDeletedByUserID(ctx context.Context, userID int64) ([]*domain.DNSRecord, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.DNSRecord), args.Error(1)
+}
+
+func (m *MockDNSRecordRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
 
 // MockBloomFilter is a mock implementation of bloomfilter.Filter
 type MockBloomFilter struct {
@@ -113,7 +179,7 @@ func TestDNSRecordService_CreateRecord(t *testing.T) {
 	mockBF := new(MockBloomFilter)
 	mockCache := new(MockDNSRecordCache)
 	mockAuditRepo := new(MockAuditLogRepository)
-	service := NewDNSRecordService(mockRepo, mockBF, mockCache, mockAuditRepo) // Changed service initialization
+	service := NewDNSRecordService(mockRepo, mockBF, mockCache, mockAuditRepo, noTx{}) // Changed service initialization
 
 	domainName := "test.service.local"
 	value := "10.0.0.1"
@@ -180,6 +246,19 @@ func TestDNSRecordService_CreateRecord(t *testing.T) {
 	// 	mockAuditRepo.AssertNotCalled(t, "Create")
 	// })
 
+	t.Run("Name outside the suffixes of the API key", func(t *testing.T) {
+		mockRepo, mockBF := new(MockDNSRecordRepository), new(MockBloomFilter)
+		service := NewDNSRecordService(mockRepo, mockBF, mockCache, mockAuditRepo, noTx{})
+		key := &domain.APIKey{Scopes: []domain.APIKeyScope{domain.ScopeRecordsWrite}, Suffixes: []string{"ci.service.local"}}
+		keyCtx := domain.ContextWithAPIKey(ctx, key)
+		mockBF.On("Test", keyCtx, domainName).Return(false, nil).Once()
+
+		_, err := service.CreateRecord(keyCtx, 1, domainName, value, recordType)
+
+		assert.ErrorIs(t, err, domain.ErrNameOutOfScope)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
 	t.Run("Database Create Error", func(t *testing.T) {
 		dbErr := errors.New("database error")
 		mockBF.On("Test", ctx, domainName).Return(false, nil).Once()
@@ -193,4 +272,113 @@ func TestDNSRecordService_CreateRecord(t *testing.T) {
 		mockBF.AssertExpectations(t)
 		mockAuditRepo.AssertNotCalled(t, "Create") // No audit log on DB error
 	})
+
+	t.Run("Audit log error fails the change", func(t *testing.T) {
+		mockRepo, mockBF, mockAuditRepo := new(MockDNSRecordRepository), new(MockBloomFilter), new(MockAuditLogRepository)
+		service := NewDNSRecordService(mockRepo, mockBF, mockCache, mockAuditRepo, noTx{})
+		auditErr := errors.New("outbox unavailable")
+		mockBF.On("Test", ctx, domainName).Return(false, nil).Once()
+		mockRepo.On("Create", ctx, mock.AnythingOfType("*domain.DNSRecord")).Return(nil).Once()
+		mockAuditRepo.On("Create", ctx, mock.AnythingOfType("*domain.AuditLog")).Return(auditErr).Once()
+
+		_, err := service.CreateRecord(ctx, 1, domainName, value, recordType)
+
+		// The transaction is rolled back, so the name is not added either
+		assert.ErrorIs(t, err, auditErr)
+		mockBF.AssertNotCalled(t, "Add", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDNSRecordService_AuditInTransaction(t *testing.T) {
+	ctx := context.Background()
+	auditErr := errors.New("outbox unavailable")
+	existing := &domain.DNSRecord{ID: 5, UserID: 1, DomainName: "www.example.com", Type: domain.A, Value: "192.0.2.1"}
+
+	for name, change := range map[string]func(svc usecase.DNSRecordUseCase) error{
+		"Create": func(svc usecase.DNSRecordUseCase) error {
+			_, err := svc.CreateRecord(ctx, 1, "new.example.com", "192.0.2.2", domain.A)
+			return err
+		},
+		"Update": func(svc usecase.DNSRecordUseCase) error {
+			_, err := svc.UpdateRecord(ctx, 1, 5, "www.example.com", "192.0.2.2", domain.A)
+			return err
+		},
+		"Delete": func(svc usecase.DNSRecordUseCase) error {
+			return svc.DeleteRecord(ctx, 1, 5)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockRepo, mockBF, mockAuditRepo := new(MockDNSRecordRepository), new(MockBloomFilter), new(MockAuditLogRepository)
+			tx := &recordingTx{}
+			svc := NewDNSRecordService(mockRepo, mockBF, new(MockDNSRecordCache), mockAuditRepo, tx)
+			mockBF.On("Test", ctx, mock.Anything).Return(false, nil)
+			mockRepo.On("FindByID", ctx, int64(5)).Return(existing, nil)
+			mockRepo.On(name, mock.MatchedBy(inTx), mock.Anything).Return(nil).Once()
+			mockAuditRepo.On("Create", mock.MatchedBy(inTx), mock.AnythingOfType("*domain.AuditLog")).Return(auditErr).Once()
+
+			// The record is written in the transaction of its audit log,
+			// which is rolled back with it
+			assert.ErrorIs(t, change(svc), auditErr)
+			mockRepo.AssertCalled(t, name, mock.Anything, mock.Anything)
+			assert.Equal(t, 1, tx.rolledBack)
+			assert.Zero(t, tx.committed)
+		})
+	}
+}
+
+func TestDNSRecordService_Trash(t *testing.T) {
+	ctx := context.Background()
+	deletedAt := time.Now().Add(-time.Hour)
+	trashed := func() *domain.DNSRecord {
+		return &domain.DNSRecord{ID: 5, UserID: 1, DomainName: "gone.service.local", Type: domain.A, Value: "10.0.0.5", DeletedAt: &deletedAt}
+	}
+
+	t.Run("Restore takes a record out of the trash", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		mockRepo := new(MockDNSRecordRepository)
+		mockCache := new(MockDNSRecordCache)
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewDNSRecordService(mockRepo, new(MockBloomFilter), mockCache, mockAuditRepo, noTx{})
+
+		mockRepo.On("FindDeletedByID", ctx, int64(5)).Return(trashed(), nil).Once()
+		mockRepo.On("Restore", ctx, mock.MatchedBy(func(r *domain.DNSRecord) bool { return r.ID == 5 })).
+			Run(func(args mock.Arguments) { args.Get(1).(*domain.DNSRecord).DeletedAt = nil }).
+			Return(nil).Once()
+		mockCache.On("Delete", ctx, "gone.service.local").Return(nil).Once()
+		mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+			return l.Action == domain.ActionRestoreDNSRecord && l.TargetID == 5
+		})).Run(func(args mock.Arguments) { wg.Done() }).Return(nil).Once()
+
+		record, err := service.RestoreRecord(ctx, 1, 5)
+		require.NoError(t, err)
+		waitForAudit(t, &wg)
+
+		assert.Nil(t, record.DeletedAt)
+		mockRepo.AssertExpectations(t)
+		mockCache.AssertExpectations(t)
+	})
+
+	t.Run("Restore hides other users' records", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		service := NewDNSRecordService(mockRepo, new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+		mockRepo.On("FindDeletedByID", ctx, int64(5)).Return(trashed(), nil).Once()
+
+		_, err := service.RestoreRecord(ctx, 2, 5)
+		assert.ErrorIs(t, err, repository.ErrDNSRecordNotFound)
+		mockRepo.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Purge removes records deleted before the retention", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		service := NewDNSRecordService(mockRepo, new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+		mockRepo.On("PurgeDeleted", ctx, mock.MatchedBy(func(before time.Time) bool {
+			return time.Since(before) >= 24*time.Hour && time.Since(before) < 25*time.Hour
+		})).Return(int64(3), nil).Once()
+
+		purged, err := service.PurgeDeletedRecords(ctx, 24*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), purged)
+	})
 }
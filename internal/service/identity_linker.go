@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+// identityLinker finds the users of accounts at identity providers and
+// directories, which manage the roles of their users.
+type identityLinker struct {
+	userRepo     repository.UserRepository
+	identityRepo repository.UserIdentityRepository
+	auditRepo    repository.AuditLogRepository
+	tx           repository.Transactor
+}
+
+// resolve returns the user linked to the account subject of issuer, with
+// role. On the first login of the account, it provisions a new user named
+// username. Existing users are never linked by name: anyone who controls a
+// username at the provider would take over the user of that name.
+func (l *identityLinker) resolve(ctx context.Context, issuer, subject, username string, role domain.UserRole) (*domain.User, error) {
+	identity, err := l.identityRepo.FindBySubject(ctx, issuer, subject)
+	var user *domain.User
+	switch {
+	case err == nil:
+		user, err = l.userRepo.FindByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+	case errors.Is(err, domain.ErrIdentityNotFound):
+		user, err = l.link(ctx, issuer, subject, username, role)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if user.Role != role {
+		if err := l.syncRole(ctx, user, role); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// link provisions the user of a new account, linked to it. The user, the
+// link and their audit logs are committed together, so that a failed link
+// leaves no user behind that would refuse the next logins of the account.
+func (l *identityLinker) link(ctx context.Context, issuer, subject, username string, role domain.UserRole) (*domain.User, error) {
+	if username == "" {
+		return nil, domain.ErrMissingUsername
+	}
+	user, err := domain.NewExternalUser(username, role)
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.tx.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := l.userRepo.FindByUsername(ctx, username)
+		if err == nil {
+			return domain.ErrUsernameUnlinkable
+		}
+		if !errors.Is(err, repository.ErrUserNotFound) {
+			return err
+		}
+
+		if err := l.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		if err := l.audit(ctx, user.ID, domain.ActionUserRegister, nil, map[string]string{"username": user.Username, "issuer": issuer}); err != nil {
+			return err
+		}
+
+		identity := domain.NewUserIdentity(user.ID, issuer, subject)
+		if err := l.identityRepo.Create(ctx, identity); err != nil {
+			return err
+		}
+		return l.audit(ctx, user.ID, domain.ActionLinkIdentity, nil, map[string]string{"issuer": identity.Issuer, "subject": identity.Subject})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (l *identityLinker) syncRole(ctx context.Context, user *domain.User, role domain.UserRole) error {
+	oldRole := user.Role
+	user.Role = role
+	// Update and audit log in one transaction
+	err := l.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := l.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+		return l.audit(ctx, user.ID, domain.ActionSyncUserRole, map[string]string{"role": string(oldRole)}, map[string]string{"role": string(role)})
+	})
+	if err != nil {
+		user.Role = oldRole
+		return err
+	}
+	return nil
+}
+
+// audit records a change of userID made by its own login, in the
+// transaction of the change.
+func (l *identityLinker) audit(ctx context.Context, userID int64, action domain.ActionType, oldValue, newValue interface{}) error {
+	auditLog, err := domain.NewAuditLog(userID, action, userID, oldValue, newValue)
+	if err != nil {
+		return err
+	}
+	if err := l.auditRepo.Create(ctx, auditLog); err != nil {
+		return fmt.Errorf("failed to create audit log for %s: %w", action, err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"internal-dns/pkg/totp"
+)
+
+type mfaService struct {
+	mfaRepo   repository.MFARepository
+	userRepo  repository.UserRepository
+	auditRepo repository.AuditLogRepository
+	tx        repository.Transactor
+	issuer    string
+}
+
+// NewMFAService creates a new two-factor authentication service. issuer
+// names the service in authenticator apps.
+func NewMFAService(mfaRepo repository.MFARepository, userRepo repository.UserRepository, auditRepo repository.AuditLogRepository, tx repository.Transactor, issuer string) usecase.MFAUseCase {
+	return &mfaService{
+		mfaRepo:   mfaRepo,
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
+		tx:        tx,
+		issuer:    issuer,
+	}
+}
+
+func (s *mfaService) Status(ctx context.Context, userID int64) (*domain.MFAStatus, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	enrollment, err := s.mfaRepo.Find(ctx, userID)
+	if err != nil && !errors.Is(err, domain.ErrMFANotEnrolled) {
+		return nil, err
+	}
+
+	status := &domain.MFAStatus{Enabled: enrollment.Enabled(), Required: user.Role == domain.RoleAdmin}
+	if status.Enabled {
+		status.RecoveryCodesLeft = enrollment.RecoveryCodesLeft
+	}
+	return status, nil
+}
+
+func (s *mfaService) BeginEnrollment(ctx context.Context, userID int64) (*domain.TOTPProvisioning, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.SavePending(ctx, domain.NewTOTPEnrollment(userID, secret)); err != nil {
+		return nil, err
+	}
+	return &domain.TOTPProvisioning{
+		Secret: totp.EncodeSecret(secret),
+		URI:    totp.URI(s.issuer, user.Username, secret),
+	}, nil
+}
+
+func (s *mfaService) ConfirmEnrollment(ctx context.Context, userID int64, code string) ([]string, error) {
+	enrollment, err := s.mfaRepo.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Enabled() {
+		return nil, domain.ErrMFAAlreadyEnabled
+	}
+	// Recovery codes do not exist yet; only the app proves the secret
+	step, ok := totp.Validate(enrollment.Secret, normalizeMFACode(code), time.Now())
+	if !ok {
+		return nil, domain.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := domain.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.mfaRepo.Confirm(ctx, userID, step, hashes); err != nil {
+			return err
+		}
+		return s.audit(ctx, userID, domain.ActionEnableMFA, userID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mfaService) Verify(ctx context.Context, userID int64, code string) error {
+	enrollment, err := s.mfaRepo.Find(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enrollment.Enabled() {
+		return domain.ErrMFANotEnrolled
+	}
+
+	code = normalizeMFACode(code)
+	if step, ok := totp.Validate(enrollment.Secret, code, time.Now()); ok {
+		return s.mfaRepo.UseStep(ctx, userID, step)
+	}
+	if len(code) == totp.Digits {
+		return domain.ErrInvalidMFACode
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.mfaRepo.UseRecoveryCode(ctx, userID, domain.HashRecoveryCode(code)); err != nil {
+			return err
+		}
+		return s.audit(ctx, userID, domain.ActionUseRecoveryCode, userID, map[string]int{"recoveryCodesLeft": enrollment.RecoveryCodesLeft - 1})
+	})
+}
+
+func (s *mfaService) Disable(ctx context.Context, userID int64, code string) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Role == domain.RoleAdmin {
+		return domain.ErrMFARequired
+	}
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.mfaRepo.Delete(ctx, userID); err != nil {
+			return err
+		}
+		return s.audit(ctx, userID, domain.ActionDisableMFA, userID, nil)
+	})
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := domain.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+			return err
+		}
+		return s.audit(ctx, userID, domain.ActionRegenerateRecoveryCodes, userID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mfaService) Reset(ctx context.Context, actorID, userID int64) error {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := s.mfaRepo.Find(ctx, userID); err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.mfaRepo.Delete(ctx, userID); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, domain.ActionDisableMFA, userID, nil)
+	})
+}
+
+// audit records a change of the second factor of userID, in the
+// transaction of the change.
+func (s *mfaService) audit(ctx context.Context, actorID int64, action domain.ActionType, userID int64, newValue interface{}) error {
+	auditLog, err := domain.NewAuditLog(actorID, action, userID, nil, newValue)
+	if err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		return fmt.Errorf("failed to create audit log for %s: %w", action, err)
+	}
+	return nil
+}
+
+// normalizeMFACode removes the spaces that apps show in codes.
+func normalizeMFACode(code string) string {
+	return strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+}
@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/usecase"
+	"internal-dns/pkg/totp"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockMFARepository is a mock of repository.MFARepository
+type MockMFARepository struct {
+	mock.Mock
+}
+
+func (m *MockMFARepository) Find(ctx context.Context, userID int64) (*domain.TOTPEnrollment, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TOTPEnrollment), args.Error(1)
+}
+
+func (m *MockMFARepository) SavePending(ctx context.Context, enrollment *domain.TOTPEnrollment) error {
+	return m.Called(ctx, enrollment).Error(0)
+}
+
+func (m *MockMFARepository) Confirm(ctx context.Context, userID, step int64, recoveryCodeHashes []string) error {
+	return m.Called(ctx, userID, step, recoveryCodeHashes).Error(0)
+}
+
+func (m *MockMFARepository) UseStep(ctx context.Context, userID, step int64) error {
+	return m.Called(ctx, userID, step).Error(0)
+}
+
+func (m *MockMFARepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	return m.Called(ctx, userID, codeHash).Error(0)
+}
+
+func (m *MockMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	return m.Called(ctx, userID, codeHashes).Error(0)
+}
+
+func (m *MockMFARepository) Delete(ctx context.Context, userID int64) error {
+	return m.Called(ctx, userID).Error(0)
+}
+
+// MockMFAUseCase is a mock of usecase.MFAUseCase
+type MockMFAUseCase struct {
+	mock.Mock
+}
+
+func (m *MockMFAUseCase) Status(ctx context.Context, userID int64) (*domain.MFAStatus, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MFAStatus), args.Error(1)
+}
+
+func (m *MockMFAUseCase) BeginEnrollment(ctx context.Context, userID int64) (*domain.TOTPProvisioning, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TOTPProvisioning), args.Error(1)
+}
+
+func (m *MockMFAUseCase) ConfirmEnrollment(ctx context.Context, userID int64, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockMFAUseCase) Verify(ctx context.Context, userID int64, code string) error {
+	return m.Called(ctx, userID, code).Error(0)
+}
+
+func (m *MockMFAUseCase) Disable(ctx context.Context, userID int64, code string) error {
+	return m.Called(ctx, userID, code).Error(0)
+}
+
+func (m *MockMFAUseCase) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockMFAUseCase) Reset(ctx context.Context, actorID, userID int64) error {
+	return m.Called(ctx, actorID, userID).Error(0)
+}
+
+// enabledEnrollment returns a confirmed enrollment of a user with a new
+// secret.
+func enabledEnrollment(t *testing.T, userID int64) *domain.TOTPEnrollment {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	enrollment := domain.NewTOTPEnrollment(userID, secret)
+	confirmedAt := time.Now()
+	enrollment.ConfirmedAt = &confirmedAt
+	enrollment.RecoveryCodesLeft = domain.RecoveryCodeCount
+	return enrollment
+}
+
+func currentCode(enrollment *domain.TOTPEnrollment) string {
+	return totp.Code(enrollment.Secret, totp.Step(time.Now()))
+}
+
+// wrongCode returns a code of the secret that is not accepted now.
+func wrongCode(enrollment *domain.TOTPEnrollment) string {
+	return totp.Code(enrollment.Secret, totp.Step(time.Now())+5)
+}
+
+func TestMFAService(t *testing.T) {
+	ctx := context.Background()
+	alice := &domain.User{ID: 1, Username: "alice", Role: domain.RoleUser, IsEnabled: true}
+	admin := &domain.User{ID: 2, Username: "root", Role: domain.RoleAdmin, IsEnabled: true}
+
+	setup := func() (*MockMFARepository, *MockUserRepository, *MockAuditLogRepository, *mfaService) {
+		mfaRepo, users, audit := new(MockMFARepository), new(MockUserRepository), new(MockAuditLogRepository)
+		users.On("FindByID", ctx, alice.ID).Return(alice, nil)
+		users.On("FindByID", ctx, admin.ID).Return(admin, nil)
+		audit.On("Create", mock.Anything, mock.Anything).Return(nil)
+		return mfaRepo, users, audit, NewMFAService(mfaRepo, users, audit, noTx{}, "Internal DNS").(*mfaService)
+	}
+
+	t.Run("Enrollment", func(t *testing.T) {
+		mfaRepo, _, audit, svc := setup()
+		var pending *domain.TOTPEnrollment
+		mfaRepo.On("SavePending", ctx, mock.Anything).Run(func(args mock.Arguments) {
+			pending = args.Get(1).(*domain.TOTPEnrollment)
+		}).Return(nil).Once()
+
+		provisioning, err := svc.BeginEnrollment(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, totp.EncodeSecret(pending.Secret), provisioning.Secret)
+		assert.Equal(t, totp.URI("Internal DNS", "alice", pending.Secret), provisioning.URI)
+		assert.False(t, pending.Enabled())
+
+		mfaRepo.On("Find", ctx, alice.ID).Return(pending, nil)
+		_, err = svc.ConfirmEnrollment(ctx, alice.ID, "000000")
+		if currentCode(pending) != "000000" {
+			assert.ErrorIs(t, err, domain.ErrInvalidMFACode)
+		}
+
+		var hashes []string
+		step := totp.Step(time.Now())
+		mfaRepo.On("Confirm", ctx, alice.ID, step, mock.Anything).Run(func(args mock.Arguments) {
+			hashes = args.Get(3).([]string)
+		}).Return(nil).Once()
+		code := totp.Code(pending.Secret, step)
+		codes, err := svc.ConfirmEnrollment(ctx, alice.ID, code[:3]+" "+code[3:])
+		require.NoError(t, err)
+		require.Len(t, codes, domain.RecoveryCodeCount)
+		for i, c := range codes {
+			assert.Equal(t, domain.HashRecoveryCode(c), hashes[i])
+		}
+		last := audit.Calls[len(audit.Calls)-1].Arguments.Get(1).(*domain.AuditLog)
+		assert.Equal(t, domain.ActionEnableMFA, last.Action)
+	})
+
+	t.Run("Enrolling twice", func(t *testing.T) {
+		mfaRepo, _, _, svc := setup()
+		mfaRepo.On("SavePending", ctx, mock.Anything).Return(domain.ErrMFAAlreadyEnabled)
+		mfaRepo.On("Find", ctx, alice.ID).Return(enabledEnrollment(t, alice.ID), nil)
+
+		_, err := svc.BeginEnrollment(ctx, alice.ID)
+		assert.ErrorIs(t, err, domain.ErrMFAAlreadyEnabled)
+		_, err = svc.ConfirmEnrollment(ctx, alice.ID, "123456")
+		assert.ErrorIs(t, err, domain.ErrMFAAlreadyEnabled)
+	})
+
+	t.Run("TOTP codes", func(t *testing.T) {
+		mfaRepo, _, _, svc := setup()
+		enrollment := enabledEnrollment(t, alice.ID)
+		mfaRepo.On("Find", ctx, alice.ID).Return(enrollment, nil)
+		step := totp.Step(time.Now())
+		mfaRepo.On("UseStep", ctx, alice.ID, step).Return(nil).Once()
+
+		require.NoError(t, svc.Verify(ctx, alice.ID, totp.Code(enrollment.Secret, step)))
+
+		// The repository refuses steps that were used
+		mfaRepo.On("UseStep", ctx, alice.ID, step).Return(domain.ErrInvalidMFACode).Once()
+		assert.ErrorIs(t, svc.Verify(ctx, alice.ID, totp.Code(enrollment.Secret, step)), domain.ErrInvalidMFACode)
+
+		assert.ErrorIs(t, svc.Verify(ctx, alice.ID, wrongCode(enrollment)), domain.ErrInvalidMFACode)
+		mfaRepo.AssertNotCalled(t, "UseRecoveryCode", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Recovery codes", func(t *testing.T) {
+		mfaRepo, _, audit, svc := setup()
+		mfaRepo.On("Find", ctx, alice.ID).Return(enabledEnrollment(t, alice.ID), nil)
+		mfaRepo.On("UseRecoveryCode", ctx, alice.ID, domain.HashRecoveryCode("abcde-fghij")).Return(nil).Once()
+		mfaRepo.On("UseRecoveryCode", ctx, alice.ID, mock.Anything).Return(domain.ErrInvalidMFACode)
+
+		require.NoError(t, svc.Verify(ctx, alice.ID, "ABCDE-FGHIJ"))
+		last := audit.Calls[len(audit.Calls)-1].Arguments.Get(1).(*domain.AuditLog)
+		assert.Equal(t, domain.ActionUseRecoveryCode, last.Action)
+		assert.JSONEq(t, `{"recoveryCodesLeft": 9}`, string(last.NewValue))
+
+		assert.ErrorIs(t, svc.Verify(ctx, alice.ID, "abcde-fghij"), domain.ErrInvalidMFACode)
+	})
+
+	t.Run("Not enabled", func(t *testing.T) {
+		mfaRepo, _, _, svc := setup()
+		pending := enabledEnrollment(t, alice.ID)
+		pending.ConfirmedAt = nil
+		mfaRepo.On("Find", ctx, alice.ID).Return(pending, nil)
+
+		assert.ErrorIs(t, svc.Verify(ctx, alice.ID, currentCode(pending)), domain.ErrMFANotEnrolled)
+		status, err := svc.Status(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &domain.MFAStatus{}, status)
+	})
+
+	t.Run("Admins cannot disable it", func(t *testing.T) {
+		mfaRepo, _, _, svc := setup()
+		enrollment := enabledEnrollment(t, admin.ID)
+		mfaRepo.On("Find", ctx, admin.ID).Return(enrollment, nil)
+
+		assert.ErrorIs(t, svc.Disable(ctx, admin.ID, currentCode(enrollment)), domain.ErrMFARequired)
+		mfaRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+
+		status, err := svc.Status(ctx, admin.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &domain.MFAStatus{Enabled: true, Required: true, RecoveryCodesLeft: 10}, status)
+
+		// Other admins reset it instead
+		mfaRepo.On("Delete", ctx, admin.ID).Return(nil).Once()
+		require.NoError(t, svc.Reset(ctx, 99, admin.ID))
+		mfaRepo.AssertExpectations(t)
+	})
+
+	t.Run("Users disable it with a code", func(t *testing.T) {
+		mfaRepo, _, audit, svc := setup()
+		enrollment := enabledEnrollment(t, alice.ID)
+		mfaRepo.On("Find", ctx, alice.ID).Return(enrollment, nil)
+		mfaRepo.On("UseStep", ctx, alice.ID, mock.Anything).Return(nil)
+		mfaRepo.On("Delete", ctx, alice.ID).Return(nil).Once()
+
+		assert.ErrorIs(t, svc.Disable(ctx, alice.ID, wrongCode(enrollment)), domain.ErrInvalidMFACode)
+		require.NoError(t, svc.Disable(ctx, alice.ID, currentCode(enrollment)))
+		mfaRepo.AssertExpectations(t)
+		last := audit.Calls[len(audit.Calls)-1].Arguments.Get(1).(*domain.AuditLog)
+		assert.Equal(t, domain.ActionDisableMFA, last.Action)
+	})
+}
+
+func TestAuthService_MFALogin(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	challenges := cache.NewMFAChallengeStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	type mocks struct {
+		users *MockUserRepository
+		mfa   *MockMFARepository
+		audit *MockAuditLogRepository
+	}
+	// setup creates an auth service with local users and a real MFA
+	// service, and the user user with password "password123"
+	setup := func(role domain.UserRole) (*mocks, *domain.User, *authService) {
+		m := &mocks{new(MockUserRepository), new(MockMFARepository), new(MockAuditLogRepository)}
+		user, err := domain.NewUser("alice", "password123", role)
+		require.NoError(t, err)
+		user.ID = 1
+		m.users.On("FindByUsername", ctx, "alice").Return(user, nil)
+		m.users.On("FindByID", ctx, user.ID).Return(user, nil)
+		m.audit.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+		refresh := new(MockRefreshTokenRepository)
+		refresh.On("Create", ctx, mock.Anything).Return(nil)
+		tokens := new(MockTokenGenerator)
+		tokens.On("GenerateAccessToken", mock.Anything, mock.Anything).Return("access_token", nil)
+		tokens.On("GenerateRefreshToken", mock.Anything, mock.Anything).Return("refresh_token", nil)
+
+		mfa := NewMFAService(m.mfa, m.users, m.audit, noTx{}, "Internal DNS")
+		svc := NewAuthService(m.users, refresh, new(MockSessionDenylist), tokens, m.audit, []usecase.Authenticator{NewLocalAuthenticator(m.users)}, mfa, challenges, nil, domain.LoginThrottling{}, noTx{})
+		return m, user, svc.(*authService)
+	}
+	lastAudit := func(m *mocks) *domain.AuditLog {
+		return m.audit.Calls[len(m.audit.Calls)-1].Arguments.Get(1).(*domain.AuditLog)
+	}
+
+	t.Run("Users without MFA get tokens", func(t *testing.T) {
+		m, _, svc := setup(domain.RoleUser)
+		m.mfa.On("Find", ctx, int64(1)).Return(nil, domain.ErrMFANotEnrolled)
+
+		result, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		require.NoError(t, err)
+		assert.Equal(t, "access_token", result.AccessToken)
+		assert.Empty(t, result.MFAToken)
+	})
+
+	t.Run("Users with MFA answer a challenge", func(t *testing.T) {
+		m, user, svc := setup(domain.RoleUser)
+		enrollment := enabledEnrollment(t, user.ID)
+		m.mfa.On("Find", ctx, user.ID).Return(enrollment, nil)
+		step := totp.Step(time.Now())
+		m.mfa.On("UseStep", ctx, user.ID, step).Return(nil).Once()
+
+		result, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		require.NoError(t, err)
+		assert.Empty(t, result.AccessToken)
+		assert.Empty(t, result.RefreshToken)
+		require.NotEmpty(t, result.MFAToken)
+		assert.False(t, result.MFAEnrollmentRequired)
+
+		// A wrong code can be corrected
+		_, err = svc.VerifyMFA(ctx, result.MFAToken, wrongCode(enrollment), "192.0.2.1")
+		assert.ErrorIs(t, err, domain.ErrInvalidMFACode)
+		assert.Equal(t, domain.ActionUserLoginFailure, lastAudit(m).Action)
+
+		tokens, err := svc.VerifyMFA(ctx, result.MFAToken, totp.Code(enrollment.Secret, step), "192.0.2.1")
+		require.NoError(t, err)
+		assert.Equal(t, "access_token", tokens.AccessToken)
+		assert.Equal(t, "refresh_token", tokens.RefreshToken)
+		assert.Equal(t, domain.ActionUserLoginSuccess, lastAudit(m).Action)
+		assert.JSONEq(t, `{"authenticator": "local", "mfa": "totp"}`, string(lastAudit(m).NewValue))
+
+		// The challenge is answered once
+		_, err = svc.VerifyMFA(ctx, result.MFAToken, currentCode(enrollment), "192.0.2.1")
+		assert.ErrorIs(t, err, domain.ErrInvalidMFAChallenge)
+		_, err = svc.VerifyMFA(ctx, "unknown", currentCode(enrollment), "192.0.2.1")
+		assert.ErrorIs(t, err, domain.ErrInvalidMFAChallenge)
+	})
+
+	t.Run("Too many wrong codes end the challenge", func(t *testing.T) {
+		m, user, svc := setup(domain.RoleUser)
+		enrollment := enabledEnrollment(t, user.ID)
+		m.mfa.On("Find", ctx, user.ID).Return(enrollment, nil)
+
+		result, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		require.NoError(t, err)
+		for i := 0; i < domain.MFAChallengeMaxAttempts; i++ {
+			_, err = svc.VerifyMFA(ctx, result.MFAToken, wrongCode(enrollment), "192.0.2.1")
+			assert.ErrorIs(t, err, domain.ErrInvalidMFACode)
+		}
+		_, err = svc.VerifyMFA(ctx, result.MFAToken, currentCode(enrollment), "192.0.2.1")
+		assert.ErrorIs(t, err, domain.ErrInvalidMFAChallenge)
+		m.mfa.AssertNotCalled(t, "UseStep", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Admins enroll before they get tokens", func(t *testing.T) {
+		m, user, svc := setup(domain.RoleAdmin)
+		m.mfa.On("Find", ctx, user.ID).Return(nil, domain.ErrMFANotEnrolled).Twice()
+
+		result, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		require.NoError(t, err)
+		assert.Empty(t, result.AccessToken)
+		require.NotEmpty(t, result.MFAToken)
+		assert.True(t, result.MFAEnrollmentRequired)
+
+		// There is no secret to answer with yet
+		_, err = svc.VerifyMFA(ctx, result.MFAToken, "123456", "192.0.2.1")
+		assert.ErrorIs(t, err, domain.ErrMFANotEnrolled)
+
+		var pending *domain.TOTPEnrollment
+		m.mfa.On("SavePending", ctx, mock.Anything).Run(func(args mock.Arguments) {
+			pending = args.Get(1).(*domain.TOTPEnrollment)
+		}).Return(nil).Once()
+		provisioning, err := svc.BeginMFAEnrollment(ctx, result.MFAToken)
+		require.NoError(t, err)
+		assert.Contains(t, provisioning.URI, "otpauth://totp/")
+
+		m.mfa.On("Find", ctx, user.ID).Return(pending, nil)
+		step := totp.Step(time.Now())
+		m.mfa.On("Confirm", ctx, user.ID, step, mock.Anything).Return(nil).Once()
+		tokens, err := svc.VerifyMFA(ctx, result.MFAToken, totp.Code(pending.Secret, step), "192.0.2.1")
+		require.NoError(t, err)
+		assert.Equal(t, "access_token", tokens.AccessToken)
+		assert.Len(t, tokens.RecoveryCodes, domain.RecoveryCodeCount)
+		m.mfa.AssertExpectations(t)
+	})
+
+	t.Run("Enrollment is only offered to logins that require it", func(t *testing.T) {
+		m, user, svc := setup(domain.RoleUser)
+		m.mfa.On("Find", ctx, user.ID).Return(enabledEnrollment(t, user.ID), nil)
+
+		result, err := svc.Login(ctx, "alice", "password123", "192.0.2.1")
+		require.NoError(t, err)
+		_, err = svc.BeginMFAEnrollment(ctx, result.MFAToken)
+		assert.ErrorIs(t, err, domain.ErrMFAAlreadyEnabled)
+		m.mfa.AssertNotCalled(t, "SavePending", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+type policyRuleService struct {
+	ruleRepo  repository.PolicyRuleRepository
+	auditRepo repository.AuditLogRepository
+	tx        repository.Transactor
+}
+
+// NewPolicyRuleService creates a new PolicyRuleUseCase implementation.
+func NewPolicyRuleService(ruleRepo repository.PolicyRuleRepository, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.PolicyRuleUseCase {
+	return &policyRuleService{
+		ruleRepo:  ruleRepo,
+		auditRepo: auditRepo,
+		tx:        tx,
+	}
+}
+
+func (s *policyRuleService) CreateRule(ctx context.Context, actorID int64, pattern string, match domain.PolicyMatch, action domain.PolicyAction, redirectIP, comment string) (*domain.PolicyRule, error) {
+	rule, err := domain.NewPolicyRule(actorID, pattern, match, action, redirectIP, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.ruleRepo.Create(ctx, rule); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, domain.ActionCreatePolicyRule, rule.ID, nil, rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *policyRuleService) GetRule(ctx context.Context, id int64) (*domain.PolicyRule, error) {
+	return s.ruleRepo.FindByID(ctx, id)
+}
+
+func (s *policyRuleService) ListRules(ctx context.Context) ([]*domain.PolicyRule, error) {
+	return s.ruleRepo.FindAll(ctx)
+}
+
+func (s *policyRuleService) UpdateRule(ctx context.Context, actorID, id int64, pattern string, match domain.PolicyMatch, action domain.PolicyAction, redirectIP, comment string) (*domain.PolicyRule, error) {
+	oldRule, err := s.ruleRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := domain.NewPolicyRule(oldRule.CreatedBy, pattern, match, action, redirectIP, comment)
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = id
+	rule.Source = oldRule.Source
+	rule.CreatedAt = oldRule.CreatedAt
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.ruleRepo.Update(ctx, rule); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, domain.ActionUpdatePolicyRule, id, oldRule, rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *policyRuleService) DeleteRule(ctx context.Context, actorID, id int64) error {
+	rule, err := s.ruleRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.ruleRepo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, domain.ActionDeletePolicyRule, id, rule, nil)
+	})
+}
+
+// audit records a policy change, in the transaction of the change.
+func (s *policyRuleService) audit(ctx context.Context, actorID int64, action domain.ActionType, ruleID int64, oldValue, newValue interface{}) error {
+	auditLog, err := domain.NewAuditLog(actorID, action, ruleID, oldValue, newValue)
+	if err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		return fmt.Errorf("failed to create audit log for policy rule change: %w", err)
+	}
+	return nil
+}
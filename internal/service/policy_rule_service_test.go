@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockPolicyRuleRepository is a mock of PolicyRuleRepository
+type MockPolicyRuleRepository struct {
+	mock.Mock
+}
+
+func (m *MockPolicyRuleRepository) Create(ctx context.Context, rule *domain.PolicyRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *MockPolicyRuleRepository) FindByID(ctx context.Context, id int64) (*domain.PolicyRule, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PolicyRule), args.Error(1)
+}
+
+func (m *MockPolicyRuleRepository) FindAll(ctx context.Context) ([]*domain.PolicyRule, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.PolicyRule), args.Error(1)
+}
+
+func (m *MockPolicyRuleRepository) Update(ctx context.Context, rule *domain.PolicyRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *MockPolicyRuleRepository) Delete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func waitForAudit(t *testing.T, wg *sync.WaitGroup) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("timeout waiting for audit log creation goroutine")
+	}
+}
+
+func TestPolicyRuleService_CreateRule(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPolicyRuleRepository)
+	mockAuditRepo := new(MockAuditLogRepository)
+	service := NewPolicyRuleService(mockRepo, mockAuditRepo, noTx{})
+
+	t.Run("Success", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		mockRepo.On("Create", ctx, mock.AnythingOfType("*domain.PolicyRule")).Return(nil).Once()
+		mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+			return l.Action == domain.ActionCreatePolicyRule
+		})).Run(func(args mock.Arguments) { wg.Done() }).Return(nil).Once()
+
+		rule, err := service.CreateRule(ctx, 1, "Ads.Example.COM.", domain.PolicyMatchSuffix, domain.PolicyActionNXDomain, "", "ads")
+		require.NoError(t, err)
+		waitForAudit(t, &wg)
+
+		assert.Equal(t, "ads.example.com", rule.Pattern)
+		mockRepo.AssertExpectations(t)
+		mockAuditRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid rule is not stored", func(t *testing.T) {
+		_, err := service.CreateRule(ctx, 1, "ads.example.com", domain.PolicyMatchExact, domain.PolicyActionRedirect, "not-an-ip", "")
+		assert.ErrorIs(t, err, domain.ErrInvalidPolicyRedirect)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		mockRepo.On("Create", ctx, mock.AnythingOfType("*domain.PolicyRule")).Return(repository.ErrDuplicatePolicyRule).Once()
+
+		_, err := service.CreateRule(ctx, 1, "dup.example.com", domain.PolicyMatchExact, domain.PolicyActionNXDomain, "", "")
+		assert.ErrorIs(t, err, repository.ErrDuplicatePolicyRule)
+	})
+}
+
+func TestPolicyRuleService_UpdateRule(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPolicyRuleRepository)
+	mockAuditRepo := new(MockAuditLogRepository)
+	service := NewPolicyRuleService(mockRepo, mockAuditRepo, noTx{})
+
+	created := time.Now().Add(-time.Hour)
+	existing := &domain.PolicyRule{ID: 7, Pattern: "old.example.com", Match: domain.PolicyMatchExact, Action: domain.PolicyActionNXDomain, Source: "database", CreatedBy: 3, CreatedAt: created}
+
+	t.Run("Success", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		mockRepo.On("FindByID", ctx, int64(7)).Return(existing, nil).Once()
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(r *domain.PolicyRule) bool {
+			return r.ID == 7 && r.CreatedBy == 3 && r.CreatedAt.Equal(created)
+		})).Return(nil).Once()
+		mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+			return l.Action == domain.ActionUpdatePolicyRule
+		})).Run(func(args mock.Arguments) { wg.Done() }).Return(nil).Once()
+
+		rule, err := service.UpdateRule(ctx, 1, 7, "new.example.com", domain.PolicyMatchExact, domain.PolicyActionRedirect, "10.0.0.1", "")
+		require.NoError(t, err)
+		waitForAudit(t, &wg)
+
+		assert.Equal(t, "10.0.0.1", rule.RedirectIP)
+		mockRepo.AssertExpectations(t)
+		mockAuditRepo.AssertExpectations(t)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mockRepo.On("FindByID", ctx, int64(8)).Return(nil, repository.ErrPolicyRuleNotFound).Once()
+
+		_, err := service.UpdateRule(ctx, 1, 8, "x.example.com", domain.PolicyMatchExact, domain.PolicyActionNXDomain, "", "")
+		assert.ErrorIs(t, err, repository.ErrPolicyRuleNotFound)
+		mockRepo.AssertNotCalled(t, "Update")
+	})
+}
+
+func TestPolicyRuleService_DeleteRule(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPolicyRuleRepository)
+	mockAuditRepo := new(MockAuditLogRepository)
+	service := NewPolicyRuleService(mockRepo, mockAuditRepo, noTx{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	rule := &domain.PolicyRule{ID: 5, Pattern: "bad.example.com", Match: domain.PolicyMatchExact, Action: domain.PolicyActionNXDomain}
+	mockRepo.On("FindByID", ctx, int64(5)).Return(rule, nil).Once()
+	mockRepo.On("Delete", ctx, int64(5)).Return(nil).Once()
+	mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+		return l.Action == domain.ActionDeletePolicyRule
+	})).Run(func(args mock.Arguments) { wg.Done() }).Return(nil).Once()
+
+	require.NoError(t, service.DeleteRule(ctx, 1, 5))
+	waitForAudit(t, &wg)
+
+	mockRepo.AssertExpectations(t)
+	mockAuditRepo.AssertExpectations(t)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+type queryStatsService struct {
+	store   cache.QueryStatsStore
+	dnsRepo repository.DNSRecordRepository
+
+	mu      sync.Mutex
+	pending map[string]*domain.QueryStat
+}
+
+// NewQueryStatsService creates a new QueryStatsUseCase implementation.
+// Queries are aggregated in memory, flushed to the shared store and from
+// there to the database, so the query path never waits on either backend.
+func NewQueryStatsService(store cache.QueryStatsStore, dnsRepo repository.DNSRecordRepository) usecase.QueryStatsUseCase {
+	return &queryStatsService{
+		store:   store,
+		dnsRepo: dnsRepo,
+		pending: make(map[string]*domain.QueryStat),
+	}
+}
+
+func (s *queryStatsService) Record(domainName string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stat, ok := s.pending[domainName]
+	if !ok {
+		stat = &domain.QueryStat{DomainName: domainName}
+		s.pending[domainName] = stat
+	}
+	stat.Count++
+	if at.After(stat.LastQueriedAt) {
+		stat.LastQueriedAt = at
+	}
+}
+
+func (s *queryStatsService) FlushToStore(ctx context.Context) error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string]*domain.QueryStat)
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	stats := make([]domain.QueryStat, 0, len(pending))
+	for _, stat := range pending {
+		stats = append(stats, *stat)
+	}
+	if err := s.store.Add(ctx, stats); err != nil {
+		// Keep the counts for the next attempt
+		s.merge(stats)
+		return err
+	}
+	return nil
+}
+
+func (s *queryStatsService) FlushToDatabase(ctx context.Context) error {
+	stats, err := s.store.Drain(ctx)
+	if err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+
+	if err := s.dnsRepo.AddQueryStats(ctx, stats); err != nil {
+		// Put the drained counts back so that they are not lost
+		if storeErr := s.store.Add(ctx, stats); storeErr != nil {
+			log.Printf("Failed to return query stats to store: %v", storeErr)
+			s.merge(stats)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *queryStatsService) merge(stats []domain.QueryStat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stat := range stats {
+		existing, ok := s.pending[stat.DomainName]
+		if !ok {
+			existing = &domain.QueryStat{DomainName: stat.DomainName}
+			s.pending[stat.DomainName] = existing
+		}
+		existing.Count += stat.Count
+		if stat.LastQueriedAt.After(existing.LastQueriedAt) {
+			existing.LastQueriedAt = stat.LastQueriedAt
+		}
+	}
+}
+
+// RunQueryStatsFlusher flushes uc to its store every storeInterval and to the
+// database every dbInterval until ctx is cancelled, then flushes once more.
+func RunQueryStatsFlusher(ctx context.Context, uc usecase.QueryStatsUseCase, storeInterval, dbInterval time.Duration) {
+	storeTicker := time.NewTicker(storeInterval)
+	defer storeTicker.Stop()
+	dbTicker := time.NewTicker(dbInterval)
+	defer dbTicker.Stop()
+
+	for {
+		select {
+		case <-storeTicker.C:
+			if err := uc.FlushToStore(ctx); err != nil {
+				log.Printf("Failed to flush query stats to store: %v", err)
+			}
+		case <-dbTicker.C:
+			if err := uc.FlushToDatabase(ctx); err != nil {
+				log.Printf("Failed to flush query stats to database: %v", err)
+			}
+		case <-ctx.Done():
+			// Use a fresh context so the final flush is not cancelled with ctx
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := uc.FlushToStore(flushCtx); err != nil {
+				log.Printf("Failed to flush query stats to store: %v", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"internal-dns/internal/domain"
+)
+
+// MockQueryStatsStore is a mock implementation of cache.QueryStatsStore
+type MockQueryStatsStore struct {
+	mock.Mock
+}
+
+func (m *MockQueryStatsStore) Add(ctx context.Context, stats []domain.QueryStat) error {
+	args := m.Called(ctx, stats)
+	return args.Error(0)
+}
+func (m *MockQueryStatsStore) Drain(ctx context.Context) ([]domain.QueryStat, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.QueryStat), args.Error(1)
+}
+
+func TestQueryStatsService_FlushToStore(t *testing.T) {
+	ctx := context.Background()
+	t1 := time.Unix(1700000000, 0)
+	t2 := t1.Add(time.Second)
+
+	t.Run("Aggregates queries per name", func(t *testing.T) {
+		store := new(MockQueryStatsStore)
+		svc := NewQueryStatsService(store, new(MockDNSRecordRepository))
+
+		svc.Record("a.local", t2)
+		svc.Record("a.local", t1)
+		store.On("Add", ctx, []domain.QueryStat{{DomainName: "a.local", Count: 2, LastQueriedAt: t2}}).Return(nil).Once()
+
+		require.NoError(t, svc.FlushToStore(ctx))
+		// Nothing is pending after a successful flush
+		require.NoError(t, svc.FlushToStore(ctx))
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Keeps counts when the store fails", func(t *testing.T) {
+		store := new(MockQueryStatsStore)
+		svc := NewQueryStatsService(store, new(MockDNSRecordRepository))
+
+		svc.Record("a.local", t1)
+		store.On("Add", ctx, []domain.QueryStat{{DomainName: "a.local", Count: 1, LastQueriedAt: t1}}).Return(errors.New("redis down")).Once()
+		assert.Error(t, svc.FlushToStore(ctx))
+
+		svc.Record("a.local", t2)
+		store.On("Add", ctx, []domain.QueryStat{{DomainName: "a.local", Count: 2, LastQueriedAt: t2}}).Return(nil).Once()
+		require.NoError(t, svc.FlushToStore(ctx))
+		store.AssertExpectations(t)
+	})
+}
+
+func TestQueryStatsService_FlushToDatabase(t *testing.T) {
+	ctx := context.Background()
+	stats := []domain.QueryStat{{DomainName: "a.local", Count: 3, LastQueriedAt: time.Unix(1700000000, 0)}}
+
+	t.Run("Writes drained stats", func(t *testing.T) {
+		store := new(MockQueryStatsStore)
+		repo := new(MockDNSRecordRepository)
+		svc := NewQueryStatsService(store, repo)
+
+		store.On("Drain", ctx).Return(stats, nil).Once()
+		repo.On("AddQueryStats", ctx, stats).Return(nil).Once()
+
+		require.NoError(t, svc.FlushToDatabase(ctx))
+		store.AssertExpectations(t)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Returns stats to the store when the database fails", func(t *testing.T) {
+		store := new(MockQueryStatsStore)
+		repo := new(MockDNSRecordRepository)
+		svc := NewQueryStatsService(store, repo)
+
+		store.On("Drain", ctx).Return(stats, nil).Once()
+		repo.On("AddQueryStats", ctx, stats).Return(errors.New("db down")).Once()
+		store.On("Add", ctx, stats).Return(nil).Once()
+
+		assert.Error(t, svc.FlushToDatabase(ctx))
+		store.AssertExpectations(t)
+		repo.AssertExpectations(t)
+	})
+}
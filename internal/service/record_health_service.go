@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+type recordHealthService struct {
+	dnsRepo    repository.DNSRecordRepository
+	healthRepo repository.RecordHealthRepository
+	cache      cache.DNSRecordCache
+	auditRepo  repository.AuditLogRepository
+	tx         repository.Transactor
+}
+
+// NewRecordHealthService creates a new RecordHealthUseCase implementation.
+func NewRecordHealthService(dnsRepo repository.DNSRecordRepository, healthRepo repository.RecordHealthRepository, cache cache.DNSRecordCache, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.RecordHealthUseCase {
+	return &recordHealthService{
+		dnsRepo:    dnsRepo,
+		healthRepo: healthRepo,
+		cache:      cache,
+		auditRepo:  auditRepo,
+		tx:         tx,
+	}
+}
+
+func (s *recordHealthService) SetHealthCheck(ctx context.Context, userID, recordID int64, additionalValues []string, check *domain.HealthCheck) (*domain.DNSRecord, error) {
+	oldRecord, err := findOwnedRecord(ctx, s.dnsRepo, userID, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.CheckNameScope(ctx, oldRecord.DomainName); err != nil {
+		return nil, err
+	}
+
+	updatedRecord := *oldRecord
+	if err := updatedRecord.SetHealthCheck(additionalValues, check); err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.dnsRepo.Update(ctx, &updatedRecord); err != nil {
+			return err
+		}
+		auditLog, err := domain.NewAuditLog(userID, domain.ActionUpdateHealthCheck, recordID, oldRecord, &updatedRecord)
+		if err != nil {
+			return err
+		}
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for health check update: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The DNS servers rebuild the state of the new targets
+	if err := s.healthRepo.DeleteStatusByRecordID(ctx, recordID); err != nil {
+		log.Printf("Failed to reset health status of record %d: %v", recordID, err)
+	}
+	if err := s.cache.Delete(ctx, updatedRecord.DomainName); err != nil {
+		log.Printf("Failed to delete domain from cache: %v", err)
+	}
+
+	return &updatedRecord, nil
+}
+
+func (s *recordHealthService) GetRecordHealth(ctx context.Context, userID, recordID int64, historyLimit int) (*domain.RecordHealth, error) {
+	record, err := findOwnedRecord(ctx, s.dnsRepo, userID, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if historyLimit < 1 || historyLimit > 500 {
+		historyLimit = 50
+	}
+
+	targets, err := s.healthRepo.FindStatusByRecordID(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.healthRepo.FindEventsByRecordID(ctx, recordID, historyLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.RecordHealth{Record: record, Targets: targets, History: history}, nil
+}
+
+// findOwnedRecord returns a record if it belongs to userID.
+func findOwnedRecord(ctx context.Context, dnsRepo repository.DNSRecordRepository, userID, recordID int64) (*domain.DNSRecord, error) {
+	record, err := dnsRepo.FindByID(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if record.UserID != userID {
+		return nil, repository.ErrDNSRecordNotFound // Hide existence from other users
+	}
+	return record, nil
+}
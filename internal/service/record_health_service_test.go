@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockRecordHealthRepository is a mock of RecordHealthRepository
+type MockRecordHealthRepository struct {
+	mock.Mock
+}
+
+func (m *MockRecordHealthRepository) SaveStatus(ctx context.Context, status *domain.TargetHealth) error {
+	args := m.Called(ctx, status)
+	return args.Error(0)
+}
+
+func (m *MockRecordHealthRepository) AddEvent(ctx context.Context, event *domain.HealthEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockRecordHealthRepository) FindStatusByRecordID(ctx context.Context, recordID int64) ([]*domain.TargetHealth, error) {
+	args := m.Called(ctx, recordID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.TargetHealth), args.Error(1)
+}
+
+func (m *MockRecordHealthRepository) FindEventsByRecordID(ctx context.Context, recordID int64, limit int) ([]*domain.HealthEvent, error) {
+	args := m.Called(ctx, recordID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.HealthEvent), args.Error(1)
+}
+
+func (m *MockRecordHealthRepository) DeleteStatusByRecordID(ctx context.Context, recordID int64) error {
+	args := m.Called(ctx, recordID)
+	return args.Error(0)
+}
+
+func TestRecordHealthService_SetHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockDNSRecordRepository)
+	mockHealthRepo := new(MockRecordHealthRepository)
+	mockCache := new(MockDNSRecordCache)
+	mockAuditRepo := new(MockAuditLogRepository)
+	service := NewRecordHealthService(mockRepo, mockHealthRepo, mockCache, mockAuditRepo, noTx{})
+
+	check, err := domain.NewHealthCheck(domain.HealthCheckHTTP, 8080, "/healthz", 0, 0, 0, 0, 0)
+	require.NoError(t, err)
+
+	t.Run("Success", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		record := &domain.DNSRecord{ID: 1, UserID: 1, DomainName: "svc.local", Type: domain.A, Value: "10.0.0.1"}
+		mockRepo.On("FindByID", ctx, int64(1)).Return(record, nil).Once()
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(r *domain.DNSRecord) bool {
+			return assert.ObjectsAreEqual([]string{"10.0.0.2"}, r.AdditionalValues) && r.HealthCheck == check
+		})).Return(nil).Once()
+		mockHealthRepo.On("DeleteStatusByRecordID", ctx, int64(1)).Return(nil).Once()
+		mockCache.On("Delete", ctx, "svc.local").Return(nil).Once()
+		mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+			return l.Action == domain.ActionUpdateHealthCheck
+		})).Run(func(args mock.Arguments) { wg.Done() }).Return(nil).Once()
+
+		updated, err := service.SetHealthCheck(ctx, 1, 1, []string{"10.0.0.2", "10.0.0.1"}, check)
+		require.NoError(t, err)
+		waitForAudit(t, &wg)
+
+		assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, updated.Addresses())
+		assert.Nil(t, record.HealthCheck, "the stored record must not be modified")
+		mockRepo.AssertExpectations(t)
+		mockHealthRepo.AssertExpectations(t)
+		mockCache.AssertExpectations(t)
+		mockAuditRepo.AssertExpectations(t)
+	})
+
+	t.Run("CNAME records cannot be checked", func(t *testing.T) {
+		record := &domain.DNSRecord{ID: 2, UserID: 1, DomainName: "alias.local", Type: domain.CNAME, Value: "svc.local"}
+		mockRepo.On("FindByID", ctx, int64(2)).Return(record, nil).Once()
+
+		_, err := service.SetHealthCheck(ctx, 1, 2, nil, check)
+		assert.ErrorIs(t, err, domain.ErrHealthCheckRequiresA)
+	})
+
+	t.Run("Invalid additional value", func(t *testing.T) {
+		record := &domain.DNSRecord{ID: 3, UserID: 1, DomainName: "svc.local", Type: domain.A, Value: "10.0.0.1"}
+		mockRepo.On("FindByID", ctx, int64(3)).Return(record, nil).Once()
+
+		_, err := service.SetHealthCheck(ctx, 1, 3, []string{"not-an-ip"}, nil)
+		assert.ErrorIs(t, err, domain.ErrInvalidRecordValue)
+	})
+
+	t.Run("Record of another user", func(t *testing.T) {
+		record := &domain.DNSRecord{ID: 4, UserID: 2, DomainName: "other.local", Type: domain.A, Value: "10.0.0.1"}
+		mockRepo.On("FindByID", ctx, int64(4)).Return(record, nil).Once()
+
+		_, err := service.SetHealthCheck(ctx, 1, 4, nil, check)
+		assert.ErrorIs(t, err, repository.ErrDNSRecordNotFound)
+	})
+}
+
+func TestRecordHealthService_GetRecordHealth(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockDNSRecordRepository)
+	mockHealthRepo := new(MockRecordHealthRepository)
+	service := NewRecordHealthService(mockRepo, mockHealthRepo, new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+
+	record := &domain.DNSRecord{ID: 1, UserID: 1, DomainName: "svc.local", Type: domain.A, Value: "10.0.0.1"}
+	targets := []*domain.TargetHealth{{RecordID: 1, Target: "10.0.0.1", Healthy: false}}
+	events := []*domain.HealthEvent{{RecordID: 1, Target: "10.0.0.1", Healthy: false, Error: "connection refused"}}
+
+	mockRepo.On("FindByID", ctx, int64(1)).Return(record, nil).Once()
+	mockHealthRepo.On("FindStatusByRecordID", ctx, int64(1)).Return(targets, nil).Once()
+	// Out of range limits fall back to the default
+	mockHealthRepo.On("FindEventsByRecordID", ctx, int64(1), 50).Return(events, nil).Once()
+
+	health, err := service.GetRecordHealth(ctx, 1, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, record, health.Record)
+	assert.Equal(t, targets, health.Targets)
+	assert.Equal(t, events, health.History)
+	mockHealthRepo.AssertExpectations(t)
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"internal-dns/pkg/bloomfilter"
+)
+
+type recordHistoryService struct {
+	dnsRepo     repository.DNSRecordRepository
+	userRepo    repository.UserRepository
+	bloomFilter bloomfilter.Filter
+	cache       cache.DNSRecordCache
+	auditRepo   repository.AuditLogRepository
+	tx          repository.Transactor
+}
+
+// NewRecordHistoryService creates a new RecordHistoryUseCase implementation.
+func NewRecordHistoryService(dnsRepo repository.DNSRecordRepository, userRepo repository.UserRepository, bf bloomfilter.Filter, cache cache.DNSRecordCache, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.RecordHistoryUseCase {
+	return &recordHistoryService{
+		dnsRepo:     dnsRepo,
+		userRepo:    userRepo,
+		bloomFilter: bf,
+		cache:       cache,
+		auditRepo:   auditRepo,
+		tx:          tx,
+	}
+}
+
+// GetRecordHistory returns every version of a record of the user, oldest
+// first. Deleted records keep their history.
+func (s *recordHistoryService) GetRecordHistory(ctx context.Context, userID, recordID int64) ([]*domain.RecordVersion, error) {
+	versions, _, err := s.history(ctx, userID, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	usernames := make(map[int64]string)
+	for _, v := range versions {
+		name, ok := usernames[v.UserID]
+		if !ok {
+			if user, err := s.userRepo.FindByID(ctx, v.UserID); err == nil {
+				name = user.Username
+			}
+			usernames[v.UserID] = name
+		}
+		v.Username = name
+	}
+	return versions, nil
+}
+
+// RollbackRecord restores the state of a record at version, validated like
+// an update. A deleted record is taken out of the trash, or inserted again
+// under its ID if it was purged; the restored name must not have been taken
+// by another record since.
+func (s *recordHistoryService) RollbackRecord(ctx context.Context, userID, recordID int64, version int) (*domain.DNSRecord, error) {
+	versions, current, err := s.history(ctx, userID, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if version < 1 || version > len(versions) {
+		return nil, domain.ErrRecordVersionNotFound
+	}
+	target := versions[version-1].Record
+	if target == nil {
+		return nil, domain.ErrRollbackToDeleted
+	}
+
+	record, err := domain.NewDNSRecord(userID, target.DomainName, target.Value, target.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.CheckNameScope(ctx, record.DomainName); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		if err := domain.CheckNameScope(ctx, current.DomainName); err != nil {
+			return nil, err
+		}
+	}
+	if record.Type == domain.A {
+		if err := record.SetHealthCheck(target.AdditionalValues, target.HealthCheck); err != nil {
+			return nil, err
+		}
+		if err := record.SetTrafficPolicy(target.TrafficPolicy); err != nil {
+			return nil, err
+		}
+	}
+	record.ID = recordID
+
+	var oldValue interface{}
+	if current != nil {
+		oldValue = current
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if current != nil {
+			record.CreatedAt = current.CreatedAt
+			if err := s.dnsRepo.Update(ctx, record); err != nil {
+				return err
+			}
+		} else {
+			record.CreatedAt = target.CreatedAt
+			if err := s.dnsRepo.Restore(ctx, record); err != nil {
+				return err
+			}
+		}
+		auditLog, err := domain.NewAuditLog(userID, domain.ActionRollbackDNSRecord, recordID, oldValue, record)
+		if err != nil {
+			return err
+		}
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for DNS record rollback: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if current != nil {
+		if err := s.cache.Delete(ctx, current.DomainName); err != nil {
+			log.Printf("Failed to delete old domain from cache: %v", err)
+		}
+	} else if err := s.bloomFilter.Add(ctx, record.DomainName); err != nil {
+		log.Printf("Failed to add domain to Bloom filter: %v", err)
+	}
+	if current == nil || current.DomainName != record.DomainName {
+		if err := s.cache.Delete(ctx, record.DomainName); err != nil {
+			log.Printf("Failed to delete new domain from cache: %v", err)
+		}
+	}
+
+	return record, nil
+}
+
+// history reconstructs the versions of a record from the audit log and
+// checks that it belongs to the user. current is nil if the record is
+// deleted.
+func (s *recordHistoryService) history(ctx context.Context, userID, recordID int64) (versions []*domain.RecordVersion, current *domain.DNSRecord, err error) {
+	current, err = s.dnsRepo.FindByID(ctx, recordID)
+	if err != nil && !errors.Is(err, repository.ErrDNSRecordNotFound) {
+		return nil, nil, err
+	}
+
+	entries, err := s.auditRepo.FindByTarget(ctx, recordID, domain.RecordHistoryActions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var state *domain.DNSRecord
+	var owner int64
+	for _, entry := range entries {
+		switch entry.Action {
+		case domain.ActionUpdateTrafficPolicy:
+			// These entries only hold the policy
+			if state == nil {
+				continue
+			}
+			var policy *domain.TrafficPolicy
+			if len(entry.NewValue) > 0 {
+				if err := json.Unmarshal(entry.NewValue, &policy); err != nil {
+					log.Printf("Skipping unreadable audit log %d: %v", entry.ID, err)
+					continue
+				}
+			}
+			next := *state
+			next.TrafficPolicy = policy
+			state = &next
+		case domain.ActionDeleteDNSRecord:
+			var deleted domain.DNSRecord
+			if err := json.Unmarshal(entry.OldValue, &deleted); err == nil {
+				owner = deleted.UserID
+			}
+			state = nil
+		default:
+			var next domain.DNSRecord
+			if err := json.Unmarshal(entry.NewValue, &next); err != nil {
+				log.Printf("Skipping unreadable audit log %d: %v", entry.ID, err)
+				continue
+			}
+			// Query statistics are not part of a version
+			next.QueryCount, next.LastQueriedAt = 0, nil
+			state, owner = &next, next.UserID
+		}
+		versions = append(versions, &domain.RecordVersion{
+			Version:   len(versions) + 1,
+			Action:    entry.Action,
+			UserID:    entry.UserID,
+			Timestamp: entry.Timestamp,
+			ChangeID:  entry.ChangeID,
+			Record:    state,
+		})
+	}
+
+	if current != nil {
+		owner = current.UserID
+	} else if len(versions) == 0 {
+		return nil, nil, repository.ErrDNSRecordNotFound
+	}
+	if owner != userID {
+		return nil, nil, repository.ErrDNSRecordNotFound // Hide existence from other users
+	}
+	return versions, current, nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordHistoryService(t *testing.T) {
+	ctx := context.Background()
+
+	v1 := &domain.DNSRecord{ID: 7, UserID: 1, DomainName: "app.local", Type: domain.A, Value: "10.0.0.1"}
+	v2 := &domain.DNSRecord{ID: 7, UserID: 1, DomainName: "app.local", Type: domain.A, Value: "10.0.0.2"}
+	policy := &domain.TrafficPolicy{Targets: []domain.TrafficTarget{{Value: "10.0.0.3", Weight: 1}}}
+	auditEntries := func(deleted bool) []*domain.AuditLog {
+		logs := []*domain.AuditLog{
+			mustAuditLog(t, 1, domain.ActionCreateDNSRecord, nil, v1),
+			mustAuditLog(t, 2, domain.ActionUpdateDNSRecord, v1, v2),
+			mustAuditLog(t, 1, domain.ActionUpdateTrafficPolicy, nil, policy),
+		}
+		if deleted {
+			logs = append(logs, mustAuditLog(t, 1, domain.ActionDeleteDNSRecord, v2, nil))
+		}
+		return logs
+	}
+
+	t.Run("Reconstructs every version of a deleted record", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		mockUserRepo := new(MockUserRepository)
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewRecordHistoryService(mockRepo, mockUserRepo, new(MockBloomFilter), new(MockDNSRecordCache), mockAuditRepo, noTx{})
+
+		mockRepo.On("FindByID", ctx, int64(7)).Return(nil, repository.ErrDNSRecordNotFound)
+		mockAuditRepo.On("FindByTarget", ctx, int64(7), domain.RecordHistoryActions).Return(auditEntries(true), nil)
+		mockUserRepo.On("FindByID", ctx, int64(1)).Return(&domain.User{ID: 1, Username: "alice"}, nil).Once()
+		mockUserRepo.On("FindByID", ctx, int64(2)).Return(&domain.User{ID: 2, Username: "admin"}, nil).Once()
+
+		versions, err := service.GetRecordHistory(ctx, 1, 7)
+		require.NoError(t, err)
+
+		require.Len(t, versions, 4)
+		assert.Equal(t, 1, versions[0].Version)
+		assert.Equal(t, "10.0.0.1", versions[0].Record.Value)
+		assert.Equal(t, "admin", versions[1].Username)
+		assert.Equal(t, "10.0.0.2", versions[2].Record.Value)
+		assert.Equal(t, policy, versions[2].Record.TrafficPolicy)
+		assert.Nil(t, versions[1].Record.TrafficPolicy)
+		assert.Nil(t, versions[3].Record)
+		assert.Equal(t, "alice", versions[3].Username)
+		mockUserRepo.AssertExpectations(t)
+	})
+
+	t.Run("Hides the history of other users' records", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewRecordHistoryService(mockRepo, new(MockUserRepository), new(MockBloomFilter), new(MockDNSRecordCache), mockAuditRepo, noTx{})
+
+		mockRepo.On("FindByID", ctx, int64(7)).Return(nil, repository.ErrDNSRecordNotFound)
+		mockAuditRepo.On("FindByTarget", ctx, int64(7), domain.RecordHistoryActions).Return(auditEntries(true), nil)
+
+		_, err := service.GetRecordHistory(ctx, 2, 7)
+		assert.ErrorIs(t, err, repository.ErrDNSRecordNotFound)
+	})
+
+	t.Run("Rolls back an existing record through an update", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		mockRepo := new(MockDNSRecordRepository)
+		mockCache := new(MockDNSRecordCache)
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewRecordHistoryService(mockRepo, new(MockUserRepository), new(MockBloomFilter), mockCache, mockAuditRepo, noTx{})
+
+		current := *v2
+		current.TrafficPolicy = policy
+		mockRepo.On("FindByID", ctx, int64(7)).Return(&current, nil)
+		mockAuditRepo.On("FindByTarget", ctx, int64(7), domain.RecordHistoryActions).Return(auditEntries(false), nil)
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(r *domain.DNSRecord) bool {
+			return r.ID == 7 && r.Value == "10.0.0.1" && r.TrafficPolicy == nil
+		})).Return(nil).Once()
+		mockCache.On("Delete", ctx, "app.local").Return(nil).Once()
+		mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+			return l.Action == domain.ActionRollbackDNSRecord && l.TargetID == 7 && l.OldValue != nil
+		})).Run(func(args mock.Arguments) { wg.Done() }).Return(nil).Once()
+
+		record, err := service.RollbackRecord(ctx, 1, 7, 1)
+		require.NoError(t, err)
+		waitForAudit(t, &wg)
+
+		assert.Equal(t, "10.0.0.1", record.Value)
+		mockRepo.AssertExpectations(t)
+		mockCache.AssertExpectations(t)
+		mockAuditRepo.AssertExpectations(t)
+	})
+
+	t.Run("Restores a deleted record under its ID", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		mockRepo := new(MockDNSRecordRepository)
+		mockBF := new(MockBloomFilter)
+		mockCache := new(MockDNSRecordCache)
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewRecordHistoryService(mockRepo, new(MockUserRepository), mockBF, mockCache, mockAuditRepo, noTx{})
+
+		mockRepo.On("FindByID", ctx, int64(7)).Return(nil, repository.ErrDNSRecordNotFound)
+		mockAuditRepo.On("FindByTarget", ctx, int64(7), domain.RecordHistoryActions).Return(auditEntries(true), nil)
+		mockRepo.On("Restore", ctx, mock.MatchedBy(func(r *domain.DNSRecord) bool {
+			return r.ID == 7 && r.Value == "10.0.0.2" && r.TrafficPolicy != nil
+		})).Return(nil).Once()
+		mockBF.On("Add", ctx, "app.local").Return(nil).Once()
+		mockCache.On("Delete", ctx, "app.local").Return(nil).Once()
+		mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+			return l.Action == domain.ActionRollbackDNSRecord && l.OldValue == nil
+		})).Run(func(args mock.Arguments) { wg.Done() }).Return(nil).Once()
+
+		_, err := service.RollbackRecord(ctx, 1, 7, 3)
+		require.NoError(t, err)
+		waitForAudit(t, &wg)
+
+		mockRepo.AssertExpectations(t)
+		mockBF.AssertExpectations(t)
+	})
+
+	t.Run("Refuses names taken since", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewRecordHistoryService(mockRepo, new(MockUserRepository), new(MockBloomFilter), new(MockDNSRecordCache), mockAuditRepo, noTx{})
+
+		mockRepo.On("FindByID", ctx, int64(7)).Return(nil, repository.ErrDNSRecordNotFound)
+		mockAuditRepo.On("FindByTarget", ctx, int64(7), domain.RecordHistoryActions).Return(auditEntries(true), nil)
+		mockRepo.On("Restore", ctx, mock.Anything).Return(repository.ErrDuplicateDomainName).Once()
+
+		_, err := service.RollbackRecord(ctx, 1, 7, 2)
+		assert.ErrorIs(t, err, repository.ErrDuplicateDomainName)
+		mockAuditRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Rejects unknown and deleted versions", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewRecordHistoryService(mockRepo, new(MockUserRepository), new(MockBloomFilter), new(MockDNSRecordCache), mockAuditRepo, noTx{})
+
+		mockRepo.On("FindByID", ctx, int64(7)).Return(nil, repository.ErrDNSRecordNotFound)
+		mockAuditRepo.On("FindByTarget", ctx, int64(7), domain.RecordHistoryActions).Return(auditEntries(true), nil)
+
+		_, err := service.RollbackRecord(ctx, 1, 7, 5)
+		assert.ErrorIs(t, err, domain.ErrRecordVersionNotFound)
+		_, err = service.RollbackRecord(ctx, 1, 7, 4)
+		assert.ErrorIs(t, err, domain.ErrRollbackToDeleted)
+	})
+}
+
+func mustAuditLog(t *testing.T, userID int64, action domain.ActionType, oldValue, newValue interface{}) *domain.AuditLog {
+	t.Helper()
+	auditLog, err := domain.NewAuditLog(userID, action, 7, oldValue, newValue)
+	require.NoError(t, err)
+	return auditLog
+}
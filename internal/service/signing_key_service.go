@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+// signingKeyLead is how long a new key is published before it signs tokens,
+// so that services that cache the public keys fetch it in time.
+const signingKeyLead = time.Hour
+
+type signingKeyService struct {
+	keyRepo          repository.SigningKeyRepository
+	algorithm        string
+	rotationInterval time.Duration
+	now              func() time.Time
+
+	mu   sync.RWMutex
+	keys []*domain.SigningKey // in the order they activate
+}
+
+// NewSigningKeyService creates a new SigningKeyUseCase implementation that
+// generates keys for algorithm and replaces the current key every
+// rotationInterval; 0 disables rotation. Keys are loaded by Rotate.
+func NewSigningKeyService(keyRepo repository.SigningKeyRepository, algorithm string, rotationInterval time.Duration) (usecase.SigningKeyUseCase, error) {
+	if err := domain.ValidateSigningAlgorithm(algorithm); err != nil {
+		return nil, err
+	}
+	return &signingKeyService{
+		keyRepo:          keyRepo,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		now:              time.Now,
+	}, nil
+}
+
+func (s *signingKeyService) Rotate(ctx context.Context) error {
+	keys, err := s.keyRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	if due, activatesAt := s.due(keys, now); due {
+		if keys, err = s.add(ctx, keys, activatesAt); err != nil {
+			return err
+		}
+	}
+
+	// A key signs until the next one activates; the tokens it signed are
+	// valid for at most the lifetime of a refresh token after that
+	var kept []*domain.SigningKey
+	for i, key := range keys {
+		if i+1 < len(keys) && !keys[i+1].ActivatesAt.Add(domain.RefreshTokenTTL).After(now) {
+			if err := s.keyRepo.Delete(ctx, key.ID); err != nil {
+				return fmt.Errorf("deleting signing key %s: %w", key.ID, err)
+			}
+			log.Printf("Deleted retired signing key %s", key.ID)
+			continue
+		}
+		kept = append(kept, key)
+	}
+
+	s.mu.Lock()
+	s.keys = kept
+	s.mu.Unlock()
+	return nil
+}
+
+// due reports whether a key has to be added, and when it activates: the
+// first key at once, later ones signingKeyLead after they are added, which
+// is that long before the latest key is due for rotation.
+func (s *signingKeyService) due(keys []*domain.SigningKey, now time.Time) (bool, time.Time) {
+	if len(keys) == 0 {
+		return true, now
+	}
+	if s.rotationInterval == 0 {
+		return false, time.Time{}
+	}
+	lead := min(signingKeyLead, s.rotationInterval/2)
+	rotateAt := keys[len(keys)-1].ActivatesAt.Add(s.rotationInterval)
+	if now.Before(rotateAt.Add(-lead)) {
+		return false, time.Time{}
+	}
+	return true, now.Add(lead)
+}
+
+// add generates a key that activates at activatesAt, and returns the keys
+// including it, or including the key another server added concurrently.
+func (s *signingKeyService) add(ctx context.Context, keys []*domain.SigningKey, activatesAt time.Time) ([]*domain.SigningKey, error) {
+	key, err := domain.NewSigningKey(s.algorithm, activatesAt)
+	if err != nil {
+		return nil, err
+	}
+	var latestID string
+	if len(keys) > 0 {
+		latestID = keys[len(keys)-1].ID
+	}
+
+	err = s.keyRepo.Add(ctx, key, latestID)
+	if errors.Is(err, domain.ErrSigningKeyConflict) {
+		return s.keyRepo.List(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Added %s signing key %s, signing from %s", key.Algorithm, key.ID, key.ActivatesAt.Format(time.RFC3339))
+	return append(keys, key), nil
+}
+
+func (s *signingKeyService) SigningKey() (*domain.SigningKey, error) {
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if !s.keys[i].ActivatesAt.After(now) {
+			return s.keys[i], nil
+		}
+	}
+	return nil, domain.ErrNoSigningKey
+}
+
+func (s *signingKeyService) VerificationKey(kid string) (*domain.SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.ID == kid {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (s *signingKeyService) PublicKeys() []*domain.SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*domain.SigningKey(nil), s.keys...)
+}
+
+// RunSigningKeyRotator rotates and reloads the signing keys every interval
+// until ctx is cancelled.
+func RunSigningKeyRotator(ctx context.Context, uc usecase.SigningKeyUseCase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := uc.Rotate(ctx); err != nil {
+				log.Printf("Failed to rotate signing keys: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
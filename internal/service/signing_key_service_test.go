@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockSigningKeyRepository is a mock of SigningKeyRepository
+type MockSigningKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockSigningKeyRepository) Add(ctx context.Context, key *domain.SigningKey, latestID string) error {
+	args := m.Called(ctx, key, latestID)
+	return args.Error(0)
+}
+
+func (m *MockSigningKeyRepository) List(ctx context.Context) ([]*domain.SigningKey, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.SigningKey), args.Error(1)
+}
+
+func (m *MockSigningKeyRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func TestSigningKeyService_Rotate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	interval := 30 * 24 * time.Hour
+	key := func(id string, activatesAt time.Time) *domain.SigningKey {
+		k, err := domain.NewSigningKey(domain.SigningAlgorithmEdDSA, activatesAt)
+		require.NoError(t, err)
+		k.ID = id
+		return k
+	}
+	newService := func(repo *MockSigningKeyRepository) *signingKeyService {
+		s, err := NewSigningKeyService(repo, domain.SigningAlgorithmEdDSA, interval)
+		require.NoError(t, err)
+		svc := s.(*signingKeyService)
+		svc.now = func() time.Time { return now }
+		return svc
+	}
+
+	t.Run("Creates the first key", func(t *testing.T) {
+		repo := new(MockSigningKeyRepository)
+		s := newService(repo)
+		_, err := s.SigningKey()
+		assert.ErrorIs(t, err, domain.ErrNoSigningKey)
+
+		repo.On("List", ctx).Return([]*domain.SigningKey{}, nil).Once()
+		repo.On("Add", ctx, mock.AnythingOfType("*domain.SigningKey"), "").Return(nil).Once()
+		require.NoError(t, s.Rotate(ctx))
+		repo.AssertExpectations(t)
+
+		signing, err := s.SigningKey()
+		require.NoError(t, err)
+		assert.Equal(t, now, signing.ActivatesAt)
+		assert.Equal(t, domain.SigningAlgorithmEdDSA, signing.Algorithm)
+	})
+
+	t.Run("Publishes the next key before it signs", func(t *testing.T) {
+		repo := new(MockSigningKeyRepository)
+		s := newService(repo)
+		current := key("current", now.Add(-interval+30*time.Minute))
+		repo.On("List", ctx).Return([]*domain.SigningKey{current}, nil).Once()
+		repo.On("Add", ctx, mock.AnythingOfType("*domain.SigningKey"), "current").Return(nil).Once()
+		require.NoError(t, s.Rotate(ctx))
+		repo.AssertExpectations(t)
+
+		keys := s.PublicKeys()
+		require.Len(t, keys, 2)
+		assert.Equal(t, now.Add(signingKeyLead), keys[1].ActivatesAt)
+
+		// The current key signs until the next one activates
+		signing, err := s.SigningKey()
+		require.NoError(t, err)
+		assert.Equal(t, "current", signing.ID)
+		s.now = func() time.Time { return now.Add(signingKeyLead) }
+		signing, err = s.SigningKey()
+		require.NoError(t, err)
+		assert.Equal(t, keys[1].ID, signing.ID)
+
+		// Both verify tokens
+		for _, k := range keys {
+			verifying, err := s.VerificationKey(k.ID)
+			require.NoError(t, err)
+			assert.Equal(t, k, verifying)
+		}
+		_, err = s.VerificationKey("unknown")
+		assert.Error(t, err)
+	})
+
+	t.Run("Keeps the current key until it is due", func(t *testing.T) {
+		repo := new(MockSigningKeyRepository)
+		s := newService(repo)
+		repo.On("List", ctx).Return([]*domain.SigningKey{key("current", now.Add(-interval/2))}, nil).Once()
+		require.NoError(t, s.Rotate(ctx))
+		repo.AssertNotCalled(t, "Add", mock.Anything, mock.Anything, mock.Anything)
+		assert.Len(t, s.PublicKeys(), 1)
+	})
+
+	t.Run("Uses the key of a server that rotated first", func(t *testing.T) {
+		repo := new(MockSigningKeyRepository)
+		s := newService(repo)
+		current := key("current", now.Add(-interval))
+		other := key("other", now.Add(signingKeyLead))
+		repo.On("List", ctx).Return([]*domain.SigningKey{current}, nil).Once()
+		repo.On("Add", ctx, mock.AnythingOfType("*domain.SigningKey"), "current").Return(domain.ErrSigningKeyConflict).Once()
+		repo.On("List", ctx).Return([]*domain.SigningKey{current, other}, nil).Once()
+		require.NoError(t, s.Rotate(ctx))
+		repo.AssertExpectations(t)
+		assert.Equal(t, []*domain.SigningKey{current, other}, s.PublicKeys())
+	})
+
+	t.Run("Deletes keys once their tokens have expired", func(t *testing.T) {
+		repo := new(MockSigningKeyRepository)
+		s := newService(repo)
+		retired := key("retired", now.Add(-2*interval))
+		previous := key("previous", now.Add(-interval))
+		current := key("current", now.Add(-24*time.Hour))
+		repo.On("List", ctx).Return([]*domain.SigningKey{retired, previous, current}, nil).Once()
+		repo.On("Delete", ctx, "retired").Return(nil).Once()
+		require.NoError(t, s.Rotate(ctx))
+		repo.AssertExpectations(t)
+
+		// Tokens that the previous key signed a day ago can still be valid
+		assert.Equal(t, []*domain.SigningKey{previous, current}, s.PublicKeys())
+	})
+
+	t.Run("Rejects unknown algorithms", func(t *testing.T) {
+		_, err := NewSigningKeyService(new(MockSigningKeyRepository), "HS256", interval)
+		assert.ErrorIs(t, err, domain.ErrInvalidSigningAlgorithm)
+	})
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"internal-dns/pkg/oidc"
+	"internal-dns/pkg/token"
+)
+
+// AuthenticatorOIDC names single sign-on logins in MFA challenges and audit
+// logs.
+const AuthenticatorOIDC = "oidc"
+
+type ssoService struct {
+	provider         oidc.Provider
+	roles            domain.RoleMapping
+	logins           cache.SSOLoginStore
+	users            *identityLinker
+	refreshTokenRepo repository.RefreshTokenRepository
+	tokenGenerator   token.Generator
+	auditRepo        repository.AuditLogRepository
+	mfa              usecase.MFAUseCase
+	challenges       cache.MFAChallengeStore
+	tx               repository.Transactor
+}
+
+// NewSSOService creates a new SSOUseCase implementation that logs users in
+// at provider and grants them roles by roles. Users with a second factor of
+// mfa, and admins, answer a challenge saved in challenges as with password
+// logins.
+func NewSSOService(provider oidc.Provider, roles domain.RoleMapping, logins cache.SSOLoginStore, userRepo repository.UserRepository, identityRepo repository.UserIdentityRepository, refreshTokenRepo repository.RefreshTokenRepository, tokenGenerator token.Generator, auditRepo repository.AuditLogRepository, mfa usecase.MFAUseCase, challenges cache.MFAChallengeStore, tx repository.Transactor) usecase.SSOUseCase {
+	return &ssoService{
+		provider:         provider,
+		roles:            roles,
+		logins:           logins,
+		users:            &identityLinker{userRepo: userRepo, identityRepo: identityRepo, auditRepo: auditRepo, tx: tx},
+		refreshTokenRepo: refreshTokenRepo,
+		tokenGenerator:   tokenGenerator,
+		auditRepo:        auditRepo,
+		mfa:              mfa,
+		challenges:       challenges,
+		tx:               tx,
+	}
+}
+
+func (s *ssoService) BeginLogin(ctx context.Context) (string, error) {
+	login, err := domain.NewSSOLogin()
+	if err != nil {
+		return "", err
+	}
+	authURL, err := s.provider.AuthCodeURL(ctx, login.State, login.Nonce, login.CodeVerifier)
+	if err != nil {
+		return "", err
+	}
+	if err := s.logins.Save(ctx, login); err != nil {
+		return "", err
+	}
+	return authURL, nil
+}
+
+func (s *ssoService) CompleteLogin(ctx context.Context, state, code string) (*domain.LoginResult, error) {
+	login, err := s.logins.Take(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := s.provider.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if errors.Is(err, oidc.ErrCodeRejected) || errors.Is(err, oidc.ErrInvalidIDToken) {
+		log.Printf("SSO login failed: %v", err)
+		return nil, domain.ErrSSOLoginFailed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := s.roles.Role(idToken.Groups)
+	if err != nil {
+		if auditErr := s.auditFailure(ctx, 0, idToken); auditErr != nil {
+			return nil, auditErr
+		}
+		return nil, err
+	}
+	user, err := s.users.resolve(ctx, idToken.Issuer, idToken.Subject, idToken.Username, role)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsEnabled {
+		if err := s.auditFailure(ctx, user.ID, idToken); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrUserDisabled
+	}
+
+	// The provider's login counts as the password: users with a second
+	// factor, and admins, still have to answer a challenge
+	result, err := challengeMFA(ctx, s.mfa, s.challenges, user, AuthenticatorOIDC)
+	if err != nil || result != nil {
+		return result, err
+	}
+
+	// Session and audit log in one transaction
+	result = &domain.LoginResult{}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		result.AccessToken, result.RefreshToken, err = startSession(ctx, s.refreshTokenRepo, s.tokenGenerator, user)
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, user.ID, domain.ActionUserLoginSuccess, map[string]string{"authenticator": AuthenticatorOIDC, "issuer": idToken.Issuer})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// auditFailure records a refused login of userID, or of an account without
+// a user if it is 0, even if the client has gone.
+func (s *ssoService) auditFailure(ctx context.Context, userID int64, idToken *oidc.IDToken) error {
+	return s.audit(context.WithoutCancel(ctx), userID, domain.ActionUserLoginFailure, map[string]string{"username": idToken.Username, "issuer": idToken.Issuer})
+}
+
+// audit records a login of userID, in the transaction of the change it
+// describes.
+func (s *ssoService) audit(ctx context.Context, userID int64, action domain.ActionType, newValue interface{}) error {
+	auditLog, err := domain.NewAuditLog(userID, action, userID, nil, newValue)
+	if err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		return fmt.Errorf("failed to create audit log for %s: %w", action, err)
+	}
+	return nil
+}
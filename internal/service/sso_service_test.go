@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/repository"
+	"internal-dns/pkg/oidc"
+	"internal-dns/pkg/oidc/oidctest"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockUserIdentityRepository is a mock of UserIdentityRepository
+type MockUserIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserIdentityRepository) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (m *MockUserIdentityRepository) FindBySubject(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error) {
+	args := m.Called(ctx, issuer, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.UserIdentity), args.Error(1)
+}
+
+func TestSSOService(t *testing.T) {
+	ctx := context.Background()
+	idp := oidctest.NewServer("internal-dns", "secret")
+	defer idp.Close()
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	provider := oidc.NewProvider(oidc.Config{
+		IssuerURL:     idp.URL,
+		ClientID:      "internal-dns",
+		ClientSecret:  "secret",
+		RedirectURL:   "https://dns.example.com/api/v1/auth/oidc/callback",
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+	}, idp.Client())
+	roles := domain.RoleMapping{AdminGroups: []string{"dns-admins"}, UserGroups: []string{"engineering"}}
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	logins, challenges := cache.NewSSOLoginStore(client), cache.NewMFAChallengeStore(client)
+
+	type mocks struct {
+		users      *MockUserRepository
+		identities *MockUserIdentityRepository
+		refresh    *MockRefreshTokenRepository
+		tokens     *MockTokenGenerator
+		audit      *MockAuditLogRepository
+		mfa        *MockMFAUseCase
+		mfaStatus  *domain.MFAStatus // of every user
+	}
+	setup := func() (*mocks, *ssoService) {
+		m := &mocks{new(MockUserRepository), new(MockUserIdentityRepository), new(MockRefreshTokenRepository), new(MockTokenGenerator), new(MockAuditLogRepository), new(MockMFAUseCase), &domain.MFAStatus{}}
+		m.refresh.On("Create", ctx, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+		m.tokens.On("GenerateAccessToken", mock.Anything, mock.Anything).Return("access_token", nil)
+		m.tokens.On("GenerateRefreshToken", mock.Anything, mock.Anything).Return("refresh_token", nil)
+		m.audit.On("Create", mock.Anything, mock.Anything).Return(nil)
+		m.mfa.On("Status", ctx, mock.Anything).Return(m.mfaStatus, nil)
+		svc := NewSSOService(provider, roles, logins, m.users, m.identities, m.refresh, m.tokens, m.audit, m.mfa, challenges, noTx{}).(*ssoService)
+		return m, svc
+	}
+	// login logs alice in at the provider and returns the state and code
+	// that she is redirected back with
+	login := func(t *testing.T, svc *ssoService, groups ...string) (string, string) {
+		authURL, err := svc.BeginLogin(ctx)
+		require.NoError(t, err)
+		redirect, err := idp.Authorize(authURL, "alice-sub", map[string]interface{}{"preferred_username": "alice", "groups": groups})
+		require.NoError(t, err)
+		return redirect.Query().Get("state"), redirect.Query().Get("code")
+	}
+	audited := func(m *mocks, action domain.ActionType) bool {
+		for _, call := range m.audit.Calls {
+			if call.Arguments.Get(1).(*domain.AuditLog).Action == action {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("First login provisions the user", func(t *testing.T) {
+		m, svc := setup()
+		m.identities.On("FindBySubject", ctx, idp.URL, "alice-sub").Return(nil, domain.ErrIdentityNotFound)
+		m.users.On("FindByUsername", ctx, "alice").Return(nil, repository.ErrUserNotFound)
+		m.users.On("Create", ctx, mock.MatchedBy(func(u *domain.User) bool {
+			return u.Username == "alice" && u.Role == domain.RoleAdmin && u.PasswordHash == ""
+		})).Run(func(args mock.Arguments) { args.Get(1).(*domain.User).ID = 7 }).Return(nil).Once()
+		m.identities.On("Create", ctx, mock.MatchedBy(func(i *domain.UserIdentity) bool {
+			return i.UserID == 7 && i.Issuer == idp.URL && i.Subject == "alice-sub"
+		})).Return(nil).Once()
+
+		state, code := login(t, svc, "engineering", "dns-admins")
+		result, err := svc.CompleteLogin(ctx, state, code)
+		require.NoError(t, err)
+		assert.Equal(t, "access_token", result.AccessToken)
+		assert.Equal(t, "refresh_token", result.RefreshToken)
+		m.users.AssertExpectations(t)
+		m.identities.AssertExpectations(t)
+		assert.True(t, audited(m, domain.ActionUserRegister))
+		assert.True(t, audited(m, domain.ActionLinkIdentity))
+		assert.True(t, audited(m, domain.ActionUserLoginSuccess))
+	})
+
+	t.Run("Local users of the same name are not linked", func(t *testing.T) {
+		m, svc := setup()
+		local, _ := domain.NewUser("alice", "password123", domain.RoleAdmin)
+		local.ID = 3
+		m.identities.On("FindBySubject", ctx, idp.URL, "alice-sub").Return(nil, domain.ErrIdentityNotFound)
+		m.users.On("FindByUsername", ctx, "alice").Return(local, nil)
+
+		// Whoever is called alice at the provider does not become the local admin
+		state, code := login(t, svc, "engineering", "dns-admins")
+		result, err := svc.CompleteLogin(ctx, state, code)
+		assert.ErrorIs(t, err, domain.ErrUsernameUnlinkable)
+		assert.Nil(t, result)
+		m.identities.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		m.users.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		m.users.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		m.tokens.AssertNotCalled(t, "GenerateAccessToken", mock.Anything, mock.Anything)
+		m.refresh.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("A failed link leaves no user behind", func(t *testing.T) {
+		m, _ := setup()
+		tx := &recordingTx{}
+		svc := NewSSOService(provider, roles, logins, m.users, m.identities, m.refresh, m.tokens, m.audit, m.mfa, challenges, tx).(*ssoService)
+		linkErr := errors.New("database error")
+		m.identities.On("FindBySubject", ctx, idp.URL, "alice-sub").Return(nil, domain.ErrIdentityNotFound)
+		m.users.On("FindByUsername", mock.MatchedBy(inTx), "alice").Return(nil, repository.ErrUserNotFound)
+		m.users.On("Create", mock.MatchedBy(inTx), mock.AnythingOfType("*domain.User")).Return(nil).Once()
+		m.identities.On("Create", mock.MatchedBy(inTx), mock.AnythingOfType("*domain.UserIdentity")).Return(linkErr).Once()
+
+		// The user is created in the transaction of the link, which is
+		// rolled back with it
+		state, code := login(t, svc, "engineering")
+		_, err := svc.CompleteLogin(ctx, state, code)
+		assert.ErrorIs(t, err, linkErr)
+		m.users.AssertExpectations(t)
+		assert.Equal(t, 1, tx.rolledBack)
+		assert.Zero(t, tx.committed)
+		m.refresh.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Later logins sync the role with the groups", func(t *testing.T) {
+		m, svc := setup()
+		user := &domain.User{ID: 7, Username: "alice", Role: domain.RoleAdmin, IsEnabled: true}
+		m.identities.On("FindBySubject", ctx, idp.URL, "alice-sub").Return(&domain.UserIdentity{UserID: 7}, nil)
+		m.users.On("FindByID", ctx, int64(7)).Return(user, nil)
+		m.users.On("Update", ctx, user).Return(nil).Once()
+
+		state, code := login(t, svc, "engineering")
+		_, err := svc.CompleteLogin(ctx, state, code)
+		require.NoError(t, err)
+		assert.Equal(t, domain.RoleUser, user.Role)
+		m.users.AssertExpectations(t)
+		m.tokens.AssertCalled(t, "GenerateAccessToken", user, mock.Anything)
+		assert.True(t, audited(m, domain.ActionSyncUserRole))
+		m.identities.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Admins answer an MFA challenge before they get tokens", func(t *testing.T) {
+		m, svc := setup()
+		m.mfaStatus.Required = true
+		m.identities.On("FindBySubject", ctx, idp.URL, "alice-sub").Return(&domain.UserIdentity{UserID: 7}, nil)
+		m.users.On("FindByID", ctx, int64(7)).Return(&domain.User{ID: 7, Username: "alice", Role: domain.RoleAdmin, IsEnabled: true}, nil)
+
+		state, code := login(t, svc, "engineering", "dns-admins")
+		result, err := svc.CompleteLogin(ctx, state, code)
+		require.NoError(t, err)
+		assert.Empty(t, result.AccessToken)
+		assert.Empty(t, result.RefreshToken)
+		assert.NotEmpty(t, result.MFAToken)
+		assert.True(t, result.MFAEnrollmentRequired)
+		m.tokens.AssertNotCalled(t, "GenerateAccessToken", mock.Anything, mock.Anything)
+		m.refresh.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		assert.False(t, audited(m, domain.ActionUserLoginSuccess))
+
+		// The challenge is answered at VerifyMFA like that of a password login
+		challenge, err := challenges.Take(ctx, result.MFAToken)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), challenge.UserID)
+		assert.Equal(t, AuthenticatorOIDC, challenge.Authenticator)
+	})
+
+	t.Run("Users without a mapped group are refused", func(t *testing.T) {
+		m, svc := setup()
+
+		state, code := login(t, svc, "sales")
+		_, err := svc.CompleteLogin(ctx, state, code)
+		assert.ErrorIs(t, err, domain.ErrNoRoleForGroups)
+		m.identities.AssertNotCalled(t, "FindBySubject", mock.Anything, mock.Anything, mock.Anything)
+		m.refresh.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		assert.True(t, audited(m, domain.ActionUserLoginFailure))
+	})
+
+	t.Run("Disabled users are refused", func(t *testing.T) {
+		m, svc := setup()
+		m.identities.On("FindBySubject", ctx, idp.URL, "alice-sub").Return(&domain.UserIdentity{UserID: 7}, nil)
+		m.users.On("FindByID", ctx, int64(7)).Return(&domain.User{ID: 7, Username: "alice", Role: domain.RoleUser}, nil)
+
+		state, code := login(t, svc, "engineering")
+		_, err := svc.CompleteLogin(ctx, state, code)
+		assert.ErrorIs(t, err, domain.ErrUserDisabled)
+		m.refresh.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Service accounts are not linked", func(t *testing.T) {
+		m, svc := setup()
+		account, _ := domain.NewServiceAccount("alice")
+		m.identities.On("FindBySubject", ctx, idp.URL, "alice-sub").Return(nil, domain.ErrIdentityNotFound)
+		m.users.On("FindByUsername", ctx, "alice").Return(account, nil)
+
+		state, code := login(t, svc, "engineering")
+		_, err := svc.CompleteLogin(ctx, state, code)
+		assert.ErrorIs(t, err, domain.ErrUsernameUnlinkable)
+		m.identities.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Logins complete once", func(t *testing.T) {
+		m, svc := setup()
+		m.identities.On("FindBySubject", ctx, idp.URL, "alice-sub").Return(&domain.UserIdentity{UserID: 7}, nil)
+		m.users.On("FindByID", ctx, int64(7)).Return(&domain.User{ID: 7, Username: "alice", Role: domain.RoleUser, IsEnabled: true}, nil)
+
+		state, code := login(t, svc, "engineering")
+		_, err := svc.CompleteLogin(ctx, state, code)
+		require.NoError(t, err)
+		_, err = svc.CompleteLogin(ctx, state, code)
+		assert.ErrorIs(t, err, domain.ErrInvalidSSOLogin)
+
+		_, err = svc.CompleteLogin(ctx, "forged", code)
+		assert.ErrorIs(t, err, domain.ErrInvalidSSOLogin)
+	})
+
+	t.Run("Codes of other logins are rejected", func(t *testing.T) {
+		_, svc := setup()
+
+		// The code was issued for another login, whose verifier differs
+		state, _ := login(t, svc, "engineering")
+		_, code := login(t, svc, "engineering")
+		_, err := svc.CompleteLogin(ctx, state, code)
+		assert.ErrorIs(t, err, domain.ErrSSOLoginFailed)
+	})
+}
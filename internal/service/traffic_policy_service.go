@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+)
+
+type trafficPolicyService struct {
+	dnsRepo   repository.DNSRecordRepository
+	cache     cache.DNSRecordCache
+	auditRepo repository.AuditLogRepository
+	tx        repository.Transactor
+}
+
+// NewTrafficPolicyService creates a new TrafficPolicyUseCase implementation.
+func NewTrafficPolicyService(dnsRepo repository.DNSRecordRepository, cache cache.DNSRecordCache, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.TrafficPolicyUseCase {
+	return &trafficPolicyService{
+		dnsRepo:   dnsRepo,
+		cache:     cache,
+		auditRepo: auditRepo,
+		tx:        tx,
+	}
+}
+
+// SetTrafficPolicy attaches policy to a record, or detaches the current one
+// if policy is nil.
+func (s *trafficPolicyService) SetTrafficPolicy(ctx context.Context, userID, recordID int64, policy *domain.TrafficPolicy) (*domain.DNSRecord, error) {
+	oldRecord, err := findOwnedRecord(ctx, s.dnsRepo, userID, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.CheckNameScope(ctx, oldRecord.DomainName); err != nil {
+		return nil, err
+	}
+
+	updatedRecord := *oldRecord
+	if err := updatedRecord.SetTrafficPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.dnsRepo.Update(ctx, &updatedRecord); err != nil {
+			return err
+		}
+		auditLog, err := domain.NewAuditLog(userID, domain.ActionUpdateTrafficPolicy, recordID, oldRecord.TrafficPolicy, updatedRecord.TrafficPolicy)
+		if err != nil {
+			return err
+		}
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for traffic policy update: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cache.Delete(ctx, updatedRecord.DomainName); err != nil {
+		log.Printf("Failed to delete domain from cache: %v", err)
+	}
+
+	return &updatedRecord, nil
+}
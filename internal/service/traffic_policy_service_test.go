@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrafficPolicyService_SetTrafficPolicy(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockDNSRecordRepository)
+	mockCache := new(MockDNSRecordCache)
+	mockAuditRepo := new(MockAuditLogRepository)
+	service := NewTrafficPolicyService(mockRepo, mockCache, mockAuditRepo, noTx{})
+
+	policy, err := domain.NewTrafficPolicy([]domain.TrafficTarget{{Value: "10.0.0.1", Weight: 2}, {Value: "10.0.0.2"}}, nil, 1)
+	require.NoError(t, err)
+
+	t.Run("Success", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		record := &domain.DNSRecord{ID: 1, UserID: 1, DomainName: "svc.local", Type: domain.A, Value: "10.0.0.1"}
+		mockRepo.On("FindByID", ctx, int64(1)).Return(record, nil).Once()
+		mockRepo.On("Update", ctx, mock.MatchedBy(func(r *domain.DNSRecord) bool {
+			return r.TrafficPolicy == policy
+		})).Return(nil).Once()
+		mockCache.On("Delete", ctx, "svc.local").Return(nil).Once()
+		mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+			return l.Action == domain.ActionUpdateTrafficPolicy
+		})).Run(func(args mock.Arguments) { wg.Done() }).Return(nil).Once()
+
+		updated, err := service.SetTrafficPolicy(ctx, 1, 1, policy)
+		require.NoError(t, err)
+		waitForAudit(t, &wg)
+
+		assert.Equal(t, policy, updated.TrafficPolicy)
+		assert.Nil(t, record.TrafficPolicy, "the stored record must not be modified")
+		mockRepo.AssertExpectations(t)
+		mockCache.AssertExpectations(t)
+		mockAuditRepo.AssertExpectations(t)
+	})
+
+	t.Run("CNAME records cannot have a policy", func(t *testing.T) {
+		record := &domain.DNSRecord{ID: 2, UserID: 1, DomainName: "alias.local", Type: domain.CNAME, Value: "svc.local"}
+		mockRepo.On("FindByID", ctx, int64(2)).Return(record, nil).Once()
+
+		_, err := service.SetTrafficPolicy(ctx, 1, 2, policy)
+		assert.ErrorIs(t, err, domain.ErrTrafficPolicyRequiresA)
+	})
+
+	t.Run("Record of another user", func(t *testing.T) {
+		record := &domain.DNSRecord{ID: 3, UserID: 2, DomainName: "other.local", Type: domain.A, Value: "10.0.0.1"}
+		mockRepo.On("FindByID", ctx, int64(3)).Return(record, nil).Once()
+
+		_, err := service.SetTrafficPolicy(ctx, 1, 3, nil)
+		assert.ErrorIs(t, err, repository.ErrDNSRecordNotFound)
+	})
+}
@@ -2,7 +2,7 @@ package service
 
 import (
 	"context"
-	"log" // Added log import
+	"fmt"
 	"internal-dns/internal/domain"
 	"internal-dns/internal/repository"
 	"internal-dns/internal/usecase" // Keep usecase import for interface
@@ -11,12 +11,14 @@ import (
 type userService struct {
 	userRepo  repository.UserRepository
 	auditRepo repository.AuditLogRepository // Added auditRepo
+	tx        repository.Transactor
 }
 
-func NewUserService(userRepo repository.UserRepository, auditRepo repository.AuditLogRepository) usecase.UserUseCase { // Changed signature, kept usecase interface
+func NewUserService(userRepo repository.UserRepository, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.UserUseCase { // Changed signature, kept usecase interface
 	return &userService{
 		userRepo:  userRepo,
 		auditRepo: auditRepo,
+		tx:        tx,
 	}
 }
 
@@ -37,19 +39,23 @@ func (s *userService) UpdateUserStatus(ctx context.Context, actorID, targetUserI
 	oldUser := *user // Make a copy for the audit log
 
 	user.IsEnabled = isEnabled
-	if err := s.userRepo.Update(ctx, user); err != nil {
-		return nil, err
-	}
-
-	// Fire-and-forget audit log
-	go func() {
+	// Update and audit log in one transaction
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
 		auditLog, err := domain.NewAuditLog(actorID, domain.ActionUpdateUserStatus, targetUserID, oldUser, user)
-		if err == nil {
-			if err := s.auditRepo.Create(context.Background(), auditLog); err != nil {
-				log.Printf("failed to create audit log for user status update: %v", err)
-			}
+		if err != nil {
+			return err
 		}
-	}()
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for user status update: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return user, nil
 }
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/infrastructure/cache"
+	"internal-dns/internal/infrastructure/zonefile"
+	"internal-dns/internal/repository"
+	"internal-dns/internal/usecase"
+	"internal-dns/pkg/bloomfilter"
+)
+
+// exportPageSize is the page size used to read all records of a user.
+const exportPageSize = 100
+
+type zoneService struct {
+	dnsRepo     repository.DNSRecordRepository
+	bloomFilter bloomfilter.Filter
+	cache       cache.DNSRecordCache
+	auditRepo   repository.AuditLogRepository
+	tx          repository.Transactor
+}
+
+// NewZoneService creates a new ZoneUseCase implementation.
+func NewZoneService(dnsRepo repository.DNSRecordRepository, bf bloomfilter.Filter, cache cache.DNSRecordCache, auditRepo repository.AuditLogRepository, tx repository.Transactor) usecase.ZoneUseCase {
+	return &zoneService{
+		dnsRepo:     dnsRepo,
+		bloomFilter: bf,
+		cache:       cache,
+		auditRepo:   auditRepo,
+		tx:          tx,
+	}
+}
+
+// zoneName collects the zone file entries of one owner name.
+type zoneName struct {
+	line   int
+	name   string
+	typ    string
+	values []string
+}
+
+// ImportZone parses a zone file and diffs it against the stored records of
+// the zone. Records are created or updated, never deleted. Changes are only
+// stored if there are no errors and dryRun is not set, all in one
+// transaction.
+func (s *zoneService) ImportZone(ctx context.Context, userID int64, zone string, content io.Reader, dryRun bool) (*domain.ZoneImport, error) {
+	zone, err := domain.NormalizeZone(zone)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := zonefile.Parse(content, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.ZoneImport{
+		Zone:     zone,
+		DryRun:   dryRun,
+		Errors:   parsed.Errors,
+		Warnings: parsed.Warnings,
+	}
+	names := groupZoneEntries(zone, parsed.Entries, result)
+
+	existing, err := s.dnsRepo.FindByZone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]*domain.DNSRecord, len(existing))
+	for _, record := range existing {
+		stored[record.DomainName] = record
+	}
+
+	var creates, updates []*domain.DNSRecord
+	for _, n := range names {
+		record, err := domain.NewDNSRecord(userID, n.name, n.values[0], domain.RecordType(n.typ))
+		if err == nil {
+			err = domain.CheckNameScope(ctx, record.DomainName)
+		}
+		if err == nil && len(n.values) > 1 {
+			err = record.SetHealthCheck(n.values[1:], nil)
+		}
+		if err != nil {
+			addZoneIssue(&result.Errors, n.line, "%s: %v", n.name, err)
+			continue
+		}
+
+		old, ok := stored[record.DomainName]
+		switch {
+		case !ok:
+			creates = append(creates, record)
+			result.Changes = append(result.Changes, domain.ZoneChange{Line: n.line, Action: domain.ZoneChangeCreate, Record: record})
+		case old.UserID != userID:
+			addZoneIssue(&result.Errors, n.line, "%s belongs to another user", record.DomainName)
+		case sameRecordData(old, record):
+			result.Changes = append(result.Changes, domain.ZoneChange{Line: n.line, Action: domain.ZoneChangeUnchanged, Record: old, Previous: old})
+		default:
+			record.ID = old.ID
+			record.CreatedAt = old.CreatedAt
+			if record.Type == domain.A && old.Type == domain.A {
+				// Zone files cannot express these; keep them
+				record.HealthCheck = old.HealthCheck
+				record.TrafficPolicy = old.TrafficPolicy
+			}
+			updates = append(updates, record)
+			result.Changes = append(result.Changes, domain.ZoneChange{Line: n.line, Action: domain.ZoneChangeUpdate, Record: record, Previous: old})
+		}
+	}
+
+	// Deleted records are not part of the zone but keep their names reserved
+	if len(creates) > 0 {
+		lines := make(map[string]int, len(creates))
+		for _, change := range result.Changes {
+			if change.Action == domain.ZoneChangeCreate {
+				lines[change.Record.DomainName] = change.Line
+			}
+		}
+		names := make([]string, 0, len(creates))
+		for _, record := range creates {
+			names = append(names, record.DomainName)
+		}
+		reserved, err := s.dnsRepo.FindByDomainNames(ctx, names)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range reserved {
+			addZoneIssue(&result.Errors, lines[record.DomainName], "%s: %v", record.DomainName, repository.ErrDomainNameInTrash)
+		}
+	}
+
+	sortZoneIssues(result.Errors)
+	sortZoneIssues(result.Warnings)
+	if len(result.Errors) > 0 || dryRun {
+		return result, nil
+	}
+
+	var changes []domain.ChangeSetItem
+	for _, change := range result.Changes {
+		switch change.Action {
+		case domain.ZoneChangeCreate:
+			changes = append(changes, domain.ChangeSetItem{Op: domain.ChangeCreate, Record: change.Record})
+		case domain.ZoneChangeUpdate:
+			changes = append(changes, domain.ChangeSetItem{Op: domain.ChangeUpdate, Record: change.Record, Previous: change.Previous})
+		}
+	}
+	summary := map[string]interface{}{"zone": zone, "created": len(creates), "updated": len(updates)}
+	changeID := domain.NewChangeID()
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if len(creates)+len(updates) > 0 {
+			if err := s.dnsRepo.ApplyBatch(ctx, creates, updates, nil); err != nil {
+				return err
+			}
+		}
+		if err := auditRecordChanges(ctx, s.auditRepo, userID, changeID, changes); err != nil {
+			return err
+		}
+		auditLog, err := domain.NewAuditLog(userID, domain.ActionImportZone, 0, nil, summary)
+		if err != nil {
+			return err
+		}
+		auditLog.ChangeID = changeID
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log for zone import: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Applied = true
+
+	for _, record := range creates {
+		if err := s.bloomFilter.Add(ctx, record.DomainName); err != nil {
+			log.Printf("Failed to add domain to Bloom filter: %v", err)
+		}
+	}
+	for _, record := range updates {
+		if err := s.cache.Delete(ctx, record.DomainName); err != nil {
+			log.Printf("Failed to delete domain from cache: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// groupZoneEntries merges the entries of each owner name into one record
+// candidate, in order of first appearance. Problems are added to result.
+func groupZoneEntries(zone string, entries []zonefile.Entry, result *domain.ZoneImport) []*zoneName {
+	var names []*zoneName
+	byName := make(map[string]*zoneName)
+	for _, e := range entries {
+		if !domain.InZone(e.Name, zone) {
+			addZoneIssue(&result.Errors, e.Line, "%s is outside the zone %s", e.Name, zone)
+			continue
+		}
+
+		switch e.Type {
+		case string(domain.A), string(domain.CNAME):
+		case "SOA", "NS":
+			addZoneIssue(&result.Warnings, e.Line, "%s record skipped, zone data is not managed here", e.Type)
+			continue
+		default:
+			addZoneIssue(&result.Warnings, e.Line, "unsupported record type %s skipped", e.Type)
+			continue
+		}
+
+		n, ok := byName[e.Name]
+		if !ok {
+			n = &zoneName{line: e.Line, name: e.Name, typ: e.Type}
+			byName[e.Name] = n
+			names = append(names, n)
+		}
+		switch {
+		case n.typ != e.Type:
+			addZoneIssue(&result.Errors, e.Line, "%s cannot have both A and CNAME records", e.Name)
+		case e.Type == string(domain.CNAME) && len(n.values) > 0:
+			addZoneIssue(&result.Errors, e.Line, "%s has more than one CNAME record", e.Name)
+		default:
+			n.values = append(n.values, e.Value)
+		}
+	}
+	return names
+}
+
+func addZoneIssue(issues *[]domain.ZoneIssue, line int, format string, args ...interface{}) {
+	*issues = append(*issues, domain.ZoneIssue{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func sortZoneIssues(issues []domain.ZoneIssue) {
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+}
+
+func sameRecordData(a, b *domain.DNSRecord) bool {
+	return a.Type == b.Type && a.Value == b.Value && slices.Equal(a.AdditionalValues, b.AdditionalValues)
+}
+
+// ExportZone renders the records of a zone as a zone file.
+func (s *zoneService) ExportZone(ctx context.Context, userID int64, zone string, allOwners bool) ([]byte, error) {
+	zone, err := domain.NormalizeZone(zone)
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.dnsRepo.FindByZone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	if !allOwners {
+		owned := records[:0]
+		for _, record := range records {
+			if record.UserID == userID {
+				owned = append(owned, record)
+			}
+		}
+		records = owned
+	}
+
+	var buf bytes.Buffer
+	if err := zonefile.Write(&buf, zone, records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportUserRecords renders all records of a user, fully qualified.
+func (s *zoneService) ExportUserRecords(ctx context.Context, userID int64) ([]byte, error) {
+	records, err := findAllUserRecords(ctx, s.dnsRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := zonefile.Write(&buf, "", records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// findAllUserRecords reads all records of a user, page by page.
+func findAllUserRecords(ctx context.Context, dnsRepo repository.DNSRecordRepository, userID int64) ([]*domain.DNSRecord, error) {
+	var records []*domain.DNSRecord
+	for page := 1; ; page++ {
+		batch, err := dnsRepo.FindByUserID(ctx, userID, page, exportPageSize)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, batch...)
+		if len(batch) < exportPageSize {
+			return records, nil
+		}
+	}
+}
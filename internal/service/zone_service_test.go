@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"internal-dns/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testZoneFile = `$ORIGIN corp.example.com.
+$TTL 300
+@      IN SOA ns1 hostmaster 1 3600 900 604800 300
+www    IN A     10.0.0.1
+       IN A     10.0.0.2
+api    IN CNAME www
+static IN A     10.0.0.9
+`
+
+func TestZoneService_ImportZone(t *testing.T) {
+	ctx := context.Background()
+
+	existing := func() []*domain.DNSRecord {
+		return []*domain.DNSRecord{
+			{ID: 7, UserID: 1, DomainName: "www.corp.example.com", Type: domain.A, Value: "10.0.0.1",
+				HealthCheck: &domain.HealthCheck{Type: domain.HealthCheckTCP, Port: 443}},
+			{ID: 8, UserID: 1, DomainName: "static.corp.example.com", Type: domain.A, Value: "10.0.0.9"},
+		}
+	}
+
+	t.Run("Dry run returns the diff without changing anything", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		service := NewZoneService(mockRepo, new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+		mockRepo.On("FindByZone", ctx, "corp.example.com").Return(existing(), nil).Once()
+		mockRepo.On("FindByDomainNames", ctx, []string{"api.corp.example.com"}).Return(nil, nil).Once()
+
+		result, err := service.ImportZone(ctx, 1, "Corp.Example.com.", strings.NewReader(testZoneFile), true)
+		require.NoError(t, err)
+
+		assert.False(t, result.Applied)
+		assert.Empty(t, result.Errors)
+		require.Len(t, result.Warnings, 1)
+		assert.Equal(t, 3, result.Warnings[0].Line)
+
+		require.Len(t, result.Changes, 3)
+		assert.Equal(t, domain.ZoneChangeUpdate, result.Changes[0].Action)
+		assert.Equal(t, []string{"10.0.0.2"}, result.Changes[0].Record.AdditionalValues)
+		assert.Equal(t, domain.ZoneChangeCreate, result.Changes[1].Action)
+		assert.Equal(t, "www.corp.example.com", result.Changes[1].Record.Value)
+		assert.Equal(t, domain.ZoneChangeUnchanged, result.Changes[2].Action)
+		mockRepo.AssertNotCalled(t, "ApplyBatch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Applies all changes in one batch", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(3)
+
+		mockRepo := new(MockDNSRecordRepository)
+		mockBF := new(MockBloomFilter)
+		mockCache := new(MockDNSRecordCache)
+		mockAuditRepo := new(MockAuditLogRepository)
+		service := NewZoneService(mockRepo, mockBF, mockCache, mockAuditRepo, noTx{})
+
+		mockRepo.On("FindByZone", ctx, "corp.example.com").Return(existing(), nil).Once()
+		mockRepo.On("FindByDomainNames", ctx, []string{"api.corp.example.com"}).Return(nil, nil).Once()
+		mockRepo.On("ApplyBatch", ctx,
+			mock.MatchedBy(func(creates []*domain.DNSRecord) bool {
+				return len(creates) == 1 && creates[0].DomainName == "api.corp.example.com"
+			}),
+			mock.MatchedBy(func(updates []*domain.DNSRecord) bool {
+				return len(updates) == 1 && updates[0].ID == 7 && updates[0].HealthCheck != nil
+			}),
+			[]int64(nil),
+		).Return(nil).Once()
+		mockBF.On("Add", ctx, "api.corp.example.com").Return(nil).Once()
+		mockCache.On("Delete", ctx, "www.corp.example.com").Return(nil).Once()
+		// One entry per changed record and a summary, all sharing the change ID
+		changeIDs := make(map[domain.ActionType]string)
+		var mu sync.Mutex
+		for _, action := range []domain.ActionType{domain.ActionCreateDNSRecord, domain.ActionUpdateDNSRecord, domain.ActionImportZone} {
+			action := action
+			mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *domain.AuditLog) bool {
+				return l.Action == action
+			})).Run(func(args mock.Arguments) {
+				mu.Lock()
+				changeIDs[action] = args.Get(1).(*domain.AuditLog).ChangeID
+				mu.Unlock()
+				wg.Done()
+			}).Return(nil).Once()
+		}
+
+		result, err := service.ImportZone(ctx, 1, "corp.example.com", strings.NewReader(testZoneFile), false)
+		require.NoError(t, err)
+		waitForAudit(t, &wg)
+
+		assert.True(t, result.Applied)
+		mockRepo.AssertExpectations(t)
+		mockBF.AssertExpectations(t)
+		mockCache.AssertExpectations(t)
+		mockAuditRepo.AssertExpectations(t)
+		assert.NotEmpty(t, changeIDs[domain.ActionImportZone])
+		assert.Equal(t, changeIDs[domain.ActionImportZone], changeIDs[domain.ActionCreateDNSRecord])
+		assert.Equal(t, changeIDs[domain.ActionImportZone], changeIDs[domain.ActionUpdateDNSRecord])
+	})
+
+	t.Run("Errors block the import", func(t *testing.T) {
+		mockRepo := new(MockDNSRecordRepository)
+		service := NewZoneService(mockRepo, new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+		taken := []*domain.DNSRecord{{ID: 9, UserID: 2, DomainName: "taken.corp.example.com", Type: domain.A, Value: "10.0.0.5"}}
+		mockRepo.On("FindByZone", ctx, "corp.example.com").Return(taken, nil).Once()
+		deletedAt := time.Now()
+		trashed := []*domain.DNSRecord{{ID: 10, UserID: 1, DomainName: "trashed.corp.example.com", Type: domain.A, Value: "10.0.0.6", DeletedAt: &deletedAt}}
+		mockRepo.On("FindByDomainNames", ctx, mock.Anything).Return(trashed, nil).Once()
+
+		content := "$ORIGIN corp.example.com.\n" +
+			"ok      IN A 10.0.0.1\n" +
+			"taken   IN A 10.0.0.2\n" +
+			"outside.example.org. IN A 10.0.0.3\n" +
+			"ok      IN CNAME www\n" +
+			"broken  IN A not-an-ip\n" +
+			"trashed IN A 10.0.0.6\n"
+		result, err := service.ImportZone(ctx, 1, "corp.example.com", strings.NewReader(content), false)
+		require.NoError(t, err)
+
+		assert.False(t, result.Applied)
+		var lines []int
+		for _, issue := range result.Errors {
+			lines = append(lines, issue.Line)
+		}
+		assert.Equal(t, []int{3, 4, 5, 6, 7}, lines)
+		mockRepo.AssertNotCalled(t, "ApplyBatch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid zone", func(t *testing.T) {
+		service := NewZoneService(new(MockDNSRecordRepository), new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+		_, err := service.ImportZone(ctx, 1, "bad zone", strings.NewReader(""), true)
+		assert.ErrorIs(t, err, domain.ErrInvalidZone)
+	})
+}
+
+func TestZoneService_ExportZone(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockDNSRecordRepository)
+	service := NewZoneService(mockRepo, new(MockBloomFilter), new(MockDNSRecordCache), new(MockAuditLogRepository), noTx{})
+
+	records := func() []*domain.DNSRecord {
+		return []*domain.DNSRecord{
+			{ID: 1, UserID: 1, DomainName: "a.corp.example.com", Type: domain.A, Value: "10.0.0.1"},
+			{ID: 2, UserID: 2, DomainName: "b.corp.example.com", Type: domain.A, Value: "10.0.0.2"},
+		}
+	}
+
+	mockRepo.On("FindByZone", ctx, "corp.example.com").Return(records(), nil).Once()
+	data, err := service.ExportZone(ctx, 1, "corp.example.com", false)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "10.0.0.1")
+	assert.NotContains(t, string(data), "10.0.0.2")
+
+	mockRepo.On("FindByZone", ctx, "corp.example.com").Return(records(), nil).Once()
+	data, err = service.ExportZone(ctx, 1, "corp.example.com", true)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "10.0.0.2")
+}
@@ -0,0 +1,26 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"internal-dns/internal/domain"
+)
+
+// APIKeyUseCase manages service accounts and their API keys, on behalf of
+// an admin, the actor.
+type APIKeyUseCase interface {
+	CreateServiceAccount(ctx context.Context, actorID int64, username string) (*domain.User, error)
+	// CreateKey creates a key of a service account and returns it with the
+	// key to hand out, which cannot be retrieved later.
+	CreateKey(ctx context.Context, actorID, userID int64, name string, scopes []domain.APIKeyScope, suffixes []string, expiresAt *time.Time) (*domain.APIKey, string, error)
+	ListKeys(ctx context.Context, userID int64) ([]*domain.APIKey, error)
+	// RotateKey replaces the secret of a key and returns the new key. The
+	// old one stops working at once.
+	RotateKey(ctx context.Context, actorID, userID, keyID int64) (*domain.APIKey, string, error)
+	RevokeKey(ctx context.Context, actorID, userID, keyID int64) error
+	// Authenticate returns the service account and the key of key, and
+	// records that the key was used. It returns domain.ErrInvalidAPIKey if
+	// the key is unknown, expired or revoked, or its account is disabled.
+	Authenticate(ctx context.Context, key string) (*domain.User, *domain.APIKey, error)
+}
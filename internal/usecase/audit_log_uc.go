@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"context"
+
+	"internal-dns/internal/domain"
+	"internal-dns/internal/repository"
+)
+
+// AuditLogUseCase defines reading the audit log.
+type AuditLogUseCase interface {
+	ListAuditLogs(ctx context.Context, filter domain.AuditLogFilter) (*domain.AuditLogPage, error)
+	ListUserActivity(ctx context.Context, userID int64, filter domain.AuditLogFilter) (*domain.AuditLogPage, error)
+	// ExportAuditLogs calls fn with every entry matching filter, newest
+	// first, ignoring its cursor and limit.
+	ExportAuditLogs(ctx context.Context, filter domain.AuditLogFilter, fn func(*domain.AuditLog) error) error
+}
+
+// AuditOutboxUseCase is an audit log whose writes are queued in the
+// transaction of the caller and relayed to the audit log, so that entries
+// are committed with the changes they describe. Reads go to the audit log
+// directly.
+type AuditOutboxUseCase interface {
+	repository.AuditLogRepository
+	// Relay stores one batch of queued entries and returns its size.
+	Relay(ctx context.Context) (int, error)
+	// Flush relays until the outbox is empty.
+	Flush(ctx context.Context) error
+}
+
+// AuditChainUseCase defines verifying that the audit log has not been
+// tampered with.
+type AuditChainUseCase interface {
+	// VerifyChain walks the audit log hash chain and its checkpoints and
+	// reports the first broken link.
+	VerifyChain(ctx context.Context) (*domain.AuditChainReport, error)
+	// CreateCheckpoint signs the hash of the newest entry. Without new
+	// entries it returns the latest checkpoint.
+	CreateCheckpoint(ctx context.Context) (*domain.AuditCheckpoint, error)
+}
+
+// AuditArchiveUseCase defines the retention of the audit log.
+type AuditArchiveUseCase interface {
+	// Run archives and removes expired entries once.
+	Run(ctx context.Context) (*domain.AuditArchiveRun, error)
+	Status(ctx context.Context) (*domain.AuditArchiveStatus, error)
+}
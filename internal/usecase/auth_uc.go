@@ -2,11 +2,43 @@ package usecase
 
 import (
 	"context"
+	"internal-dns/internal/domain"
 )
 
 // AuthUseCase defines the interface for authentication-related operations.
 type AuthUseCase interface {
 	Register(ctx context.Context, username, password string) error
-	Login(ctx context.Context, username, password string) (accessToken, refreshToken string, err error)
+	// Login checks the password of a user. Users with two-factor
+	// authentication enabled, and admins, get an MFA challenge to answer
+	// with VerifyMFA instead of tokens. After failed logins of the username
+	// or from clientIP, logins are refused for a while with a
+	// *domain.LoginThrottledError.
+	Login(ctx context.Context, username, password, clientIP string) (*domain.LoginResult, error)
+	// BeginMFAEnrollment creates a TOTP secret for an admin whose login
+	// requires enrolling first. The challenge is then answered with a code
+	// of the new secret.
+	BeginMFAEnrollment(ctx context.Context, mfaToken string) (*domain.TOTPProvisioning, error)
+	// VerifyMFA answers the MFA challenge of a login with a TOTP or
+	// recovery code and returns the tokens. After
+	// domain.MFAChallengeMaxAttempts wrong codes the user has to log in
+	// again. Wrong codes count as failed logins.
+	VerifyMFA(ctx context.Context, mfaToken, code, clientIP string) (*domain.LoginResult, error)
+	// Refresh exchanges a refresh token for new access and refresh tokens.
+	// Reusing a refresh token revokes every token of its login.
+	Refresh(ctx context.Context, refreshToken string) (accessToken, newRefreshToken string, err error)
+	// Logout revokes the session sessionID of a user.
+	Logout(ctx context.Context, userID int64, sessionID string) error
+	ListSessions(ctx context.Context, userID int64) ([]*domain.Session, error)
+	// RevokeSession revokes a session of a user. It returns
+	// domain.ErrSessionNotFound if the user has no such active session.
+	RevokeSession(ctx context.Context, userID int64, sessionID string) error
+	// RevokeAllSessions revokes every session of a user, on behalf of actor.
+	RevokeAllSessions(ctx context.Context, actorID, userID int64) error
+	// UnlockLogin forgets the failed logins of a user, on behalf of actor,
+	// so that a locked out user can log in again.
+	UnlockLogin(ctx context.Context, actorID, userID int64) error
+	// PurgeExpiredRefreshTokens deletes the records of expired refresh
+	// tokens and returns their number.
+	PurgeExpiredRefreshTokens(ctx context.Context) (int64, error)
 }
 
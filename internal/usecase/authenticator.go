@@ -0,0 +1,19 @@
+package usecase
+
+import (
+	"context"
+
+	"internal-dns/internal/domain"
+)
+
+// Authenticator checks passwords against one source of users, such as the
+// local users or an LDAP directory.
+type Authenticator interface {
+	// Name identifies the authenticator in the configuration and the audit
+	// log.
+	Name() string
+	// Authenticate returns the user with username and password. It returns
+	// domain.ErrInvalidCredentials if the source does not know the user or
+	// the password is wrong.
+	Authenticate(ctx context.Context, username, password string) (*domain.User, error)
+}
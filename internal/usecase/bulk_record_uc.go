@@ -0,0 +1,12 @@
+package usecase
+
+import (
+	"context"
+	"internal-dns/internal/domain"
+)
+
+// BulkRecordUseCase defines the interface for importing and exporting many records at once.
+type BulkRecordUseCase interface {
+	ImportRecords(ctx context.Context, userID int64, rows []domain.RecordImportRow, mode domain.ImportMode) (*domain.RecordImport, error)
+	ExportRecords(ctx context.Context, userID int64) ([]*domain.DNSRecord, error)
+}
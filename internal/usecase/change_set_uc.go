@@ -0,0 +1,14 @@
+package usecase
+
+import (
+	"context"
+	"internal-dns/internal/domain"
+)
+
+// ChangeSetUseCase defines the interface for applying several record changes atomically.
+type ChangeSetUseCase interface {
+	PreviewChangeSet(ctx context.Context, userID int64, changes []domain.RecordChange) (*domain.ChangeSet, error)
+	// ApplyChangeSet validates and applies the changes in one transaction.
+	// A non-empty fingerprint from a preview must still match the records.
+	ApplyChangeSet(ctx context.Context, userID int64, changes []domain.RecordChange, fingerprint string) (*domain.ChangeSet, error)
+}
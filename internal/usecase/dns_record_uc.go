@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"internal-dns/internal/domain"
+	"time"
 )
 
 // DNSRecordUseCase defines the interface for DNS record management business logic.
@@ -13,4 +14,8 @@ type DNSRecordUseCase interface {
 	UpdateRecord(ctx context.Context, userID int64, recordID int64, domainName, value string, recordType domain.RecordType) (*domain.DNSRecord, error)
 	DeleteRecord(ctx context.Context, userID int64, recordID int64) error
 	ResolveDomain(ctx context.Context, domainName string) (*domain.DNSRecord, error)
+	ListUnusedRecords(ctx context.Context, unusedFor time.Duration) ([]*domain.DNSRecord, error)
+	ListDeletedRecords(ctx context.Context, userID int64) ([]*domain.DNSRecord, error)
+	RestoreRecord(ctx context.Context, userID int64, recordID int64) (*domain.DNSRecord, error)
+	PurgeDeletedRecords(ctx context.Context, deletedFor time.Duration) (int64, error)
 }
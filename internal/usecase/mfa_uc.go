@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"context"
+
+	"internal-dns/internal/domain"
+)
+
+// MFAUseCase manages the TOTP two-factor authentication of users.
+type MFAUseCase interface {
+	Status(ctx context.Context, userID int64) (*domain.MFAStatus, error)
+	// BeginEnrollment creates a new TOTP secret for a user, replacing one
+	// that has not been confirmed. It returns domain.ErrMFAAlreadyEnabled
+	// if the user has enabled two-factor authentication.
+	BeginEnrollment(ctx context.Context, userID int64) (*domain.TOTPProvisioning, error)
+	// ConfirmEnrollment enables two-factor authentication with the first
+	// code of the new secret and returns the user's recovery codes.
+	ConfirmEnrollment(ctx context.Context, userID int64, code string) (recoveryCodes []string, err error)
+	// Verify checks a TOTP or recovery code of a user. Every code is
+	// accepted once; it returns domain.ErrInvalidMFACode otherwise.
+	Verify(ctx context.Context, userID int64, code string) error
+	// Disable disables the two-factor authentication of a user after
+	// checking a code. Admins cannot disable it (domain.ErrMFARequired).
+	Disable(ctx context.Context, userID int64, code string) error
+	// RegenerateRecoveryCodes replaces the recovery codes of a user after
+	// checking a code.
+	RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error)
+	// Reset disables the two-factor authentication of a user who lost
+	// their device, on behalf of actor.
+	Reset(ctx context.Context, actorID, userID int64) error
+}
@@ -0,0 +1,15 @@
+package usecase
+
+import (
+	"context"
+	"internal-dns/internal/domain"
+)
+
+// PolicyRuleUseCase defines the business logic for managing response policy rules.
+type PolicyRuleUseCase interface {
+	CreateRule(ctx context.Context, actorID int64, pattern string, match domain.PolicyMatch, action domain.PolicyAction, redirectIP, comment string) (*domain.PolicyRule, error)
+	GetRule(ctx context.Context, id int64) (*domain.PolicyRule, error)
+	ListRules(ctx context.Context) ([]*domain.PolicyRule, error)
+	UpdateRule(ctx context.Context, actorID, id int64, pattern string, match domain.PolicyMatch, action domain.PolicyAction, redirectIP, comment string) (*domain.PolicyRule, error)
+	DeleteRule(ctx context.Context, actorID, id int64) error
+}
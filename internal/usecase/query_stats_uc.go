@@ -0,0 +1,16 @@
+package usecase
+
+import (
+	"context"
+	"time"
+)
+
+// QueryStatsUseCase defines the interface for collecting per-name query statistics.
+type QueryStatsUseCase interface {
+	// Record counts one answered query for domainName. It must not block.
+	Record(domainName string, at time.Time)
+	// FlushToStore moves the locally aggregated statistics to the shared store.
+	FlushToStore(ctx context.Context) error
+	// FlushToDatabase moves the statistics in the shared store to the database.
+	FlushToDatabase(ctx context.Context) error
+}
@@ -0,0 +1,12 @@
+package usecase
+
+import (
+	"context"
+	"internal-dns/internal/domain"
+)
+
+// RecordHealthUseCase defines the interface for managing health-checked records.
+type RecordHealthUseCase interface {
+	SetHealthCheck(ctx context.Context, userID, recordID int64, additionalValues []string, check *domain.HealthCheck) (*domain.DNSRecord, error)
+	GetRecordHealth(ctx context.Context, userID, recordID int64, historyLimit int) (*domain.RecordHealth, error)
+}
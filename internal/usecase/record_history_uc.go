@@ -0,0 +1,13 @@
+package usecase
+
+import (
+	"context"
+
+	"internal-dns/internal/domain"
+)
+
+// RecordHistoryUseCase defines the version history of DNS records.
+type RecordHistoryUseCase interface {
+	GetRecordHistory(ctx context.Context, userID, recordID int64) ([]*domain.RecordVersion, error)
+	RollbackRecord(ctx context.Context, userID, recordID int64, version int) (*domain.DNSRecord, error)
+}
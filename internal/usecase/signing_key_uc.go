@@ -0,0 +1,22 @@
+package usecase
+
+import (
+	"context"
+	"internal-dns/internal/domain"
+)
+
+// SigningKeyUseCase manages the keys that tokens are signed with. It serves
+// as the key set of the token generator.
+type SigningKeyUseCase interface {
+	// Rotate adds the next key when the current one is due for rotation,
+	// deletes keys that can no longer have signed a valid token, and
+	// reloads the keys.
+	Rotate(ctx context.Context) error
+	// SigningKey returns the key that new tokens are signed with.
+	SigningKey() (*domain.SigningKey, error)
+	// VerificationKey returns the key with the ID kid.
+	VerificationKey(kid string) (*domain.SigningKey, error)
+	// PublicKeys returns the keys that tokens are verified with, including
+	// the next key before it activates.
+	PublicKeys() []*domain.SigningKey
+}
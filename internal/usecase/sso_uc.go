@@ -0,0 +1,22 @@
+package usecase
+
+import (
+	"context"
+
+	"internal-dns/internal/domain"
+)
+
+// SSOUseCase defines the interface for single sign-on at an OpenID Connect
+// identity provider.
+type SSOUseCase interface {
+	// BeginLogin starts a login and returns the URL of the identity provider
+	// that the user is sent to.
+	BeginLogin(ctx context.Context) (string, error)
+	// CompleteLogin completes the login state with the authorization code
+	// that the provider redirected the user back with. Users are provisioned
+	// on their first login and get the role that their groups are granted;
+	// existing users of the same name are not linked. Like password logins,
+	// it returns an MFA challenge instead of tokens for users with a second
+	// factor and for admins.
+	CompleteLogin(ctx context.Context, state, code string) (*domain.LoginResult, error)
+}
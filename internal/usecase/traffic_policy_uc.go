@@ -0,0 +1,11 @@
+package usecase
+
+import (
+	"context"
+	"internal-dns/internal/domain"
+)
+
+// TrafficPolicyUseCase defines the interface for managing traffic policies of records.
+type TrafficPolicyUseCase interface {
+	SetTrafficPolicy(ctx context.Context, userID, recordID int64, policy *domain.TrafficPolicy) (*domain.DNSRecord, error)
+}
@@ -0,0 +1,16 @@
+package usecase
+
+import (
+	"context"
+	"internal-dns/internal/domain"
+	"io"
+)
+
+// ZoneUseCase defines the interface for importing and exporting zone files.
+type ZoneUseCase interface {
+	ImportZone(ctx context.Context, userID int64, zone string, content io.Reader, dryRun bool) (*domain.ZoneImport, error)
+	// ExportZone renders the records of a zone. Without allOwners only the
+	// records of userID are included.
+	ExportZone(ctx context.Context, userID int64, zone string, allOwners bool) ([]byte, error)
+	ExportUserRecords(ctx context.Context, userID int64) ([]byte, error)
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseIP parses a bare IPv4 or IPv6 address, surrounding spaces ignored.
+func ParseIP(value string) (net.IP, error) {
+	ip := net.ParseIP(strings.TrimSpace(value))
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", value)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4, nil
+	}
+	return ip, nil
+}
+
+// ParseNetwork parses a CIDR or a bare address, which is taken as a network
+// of that single address.
+func ParseNetwork(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		ip, err := ParseIP(value)
+		if err != nil {
+			return nil, err
+		}
+		bits := 8 * len(ip)
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network %q: %w", value, err)
+	}
+	return network, nil
+}
+
+// ParseNetworks parses a list of CIDRs or bare addresses. Any invalid entry,
+// an empty one included, fails the whole list rather than being skipped.
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		network, err := ParseNetwork(v)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks([]string{"10.0.0.0/8", " ::1 ", "192.0.2.7", "2001:db8::/32"})
+	require.NoError(t, err)
+	require.Len(t, networks, 4)
+	assert.Equal(t, "10.0.0.0/8", networks[0].String())
+	assert.Equal(t, "::1/128", networks[1].String())
+	assert.Equal(t, "192.0.2.7/32", networks[2].String())
+	assert.Equal(t, "2001:db8::/32", networks[3].String())
+
+	// Invalid entries fail the whole list instead of being skipped
+	for _, invalid := range []string{"not-an-ip", "", "10.0.0.0/33", "192.0.2.300"} {
+		_, err = ParseNetworks([]string{"10.0.0.0/8", invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseIP(t *testing.T) {
+	ip, err := ParseIP(" 192.0.2.1 ")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", ip.String())
+	assert.Len(t, ip, 4)
+
+	_, err = ParseIP("192.0.2.0/24")
+	assert.Error(t, err)
+}
@@ -0,0 +1,10 @@
+-- Per-record query statistics, flushed periodically by the DNS server.
+-- Kept out of dns_records so that flushing does not touch updated_at.
+CREATE TABLE IF NOT EXISTS dns_record_stats (
+    record_id BIGINT PRIMARY KEY REFERENCES dns_records(id) ON DELETE CASCADE,
+    query_count BIGINT NOT NULL DEFAULT 0,
+    last_queried_at TIMESTAMPTZ
+);
+
+-- Index for the unused records report
+CREATE INDEX IF NOT EXISTS idx_dns_record_stats_last_queried_at ON dns_record_stats(last_queried_at);
@@ -0,0 +1,18 @@
+-- Response policy rules managed by admins, evaluated by the DNS server
+CREATE TABLE IF NOT EXISTS policy_rules (
+    id BIGSERIAL PRIMARY KEY,
+    pattern VARCHAR(255) NOT NULL,
+    match VARCHAR(32) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    redirect_ip VARCHAR(64) NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (pattern, match)
+);
+
+CREATE TRIGGER trg_policy_rules_updated_at
+BEFORE UPDATE ON policy_rules
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
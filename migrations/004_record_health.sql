@@ -0,0 +1,29 @@
+-- Additional addresses and health check definitions of A records
+ALTER TABLE dns_records
+    ADD COLUMN IF NOT EXISTS additional_values TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS health_check JSONB;
+
+-- Current health of each checked address, written by the DNS servers
+CREATE TABLE IF NOT EXISTS dns_record_health (
+    record_id BIGINT NOT NULL REFERENCES dns_records(id) ON DELETE CASCADE,
+    target VARCHAR(255) NOT NULL,
+    healthy BOOLEAN NOT NULL,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    consecutive_successes INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    last_checked_at TIMESTAMPTZ NOT NULL,
+    last_changed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (record_id, target)
+);
+
+-- Health state changes
+CREATE TABLE IF NOT EXISTS dns_record_health_events (
+    id BIGSERIAL PRIMARY KEY,
+    record_id BIGINT NOT NULL REFERENCES dns_records(id) ON DELETE CASCADE,
+    target VARCHAR(255) NOT NULL,
+    healthy BOOLEAN NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dns_record_health_events_record ON dns_record_health_events(record_id, created_at DESC);
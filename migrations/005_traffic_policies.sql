@@ -0,0 +1,3 @@
+-- Traffic policies steering the answers of A records between several targets
+ALTER TABLE dns_records
+    ADD COLUMN IF NOT EXISTS traffic_policy JSONB;
@@ -0,0 +1,12 @@
+-- Change sets apply several record changes in one transaction. The unique
+-- name constraint is checked at commit within them, so that records can swap
+-- names.
+ALTER TABLE dns_records DROP CONSTRAINT IF EXISTS dns_records_domain_name_key;
+ALTER TABLE dns_records
+    ADD CONSTRAINT dns_records_domain_name_key UNIQUE (domain_name) DEFERRABLE INITIALLY IMMEDIATE;
+
+-- Audit entries written by one change set share its ID
+ALTER TABLE audit_logs
+    ADD COLUMN IF NOT EXISTS change_id VARCHAR(36);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_change_id ON audit_logs(change_id) WHERE change_id IS NOT NULL;
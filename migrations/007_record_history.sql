@@ -0,0 +1,2 @@
+-- Version history of DNS records is read from the audit log by target
+CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs(target_id, id);
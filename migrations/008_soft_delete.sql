@@ -0,0 +1,6 @@
+-- Deleted records stay in the trash, keeping their name reserved, until
+-- they are purged
+ALTER TABLE dns_records
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_dns_records_deleted_at ON dns_records(deleted_at) WHERE deleted_at IS NOT NULL;
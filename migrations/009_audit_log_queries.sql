@@ -0,0 +1,6 @@
+-- Audit log queries filter by actor, action, target and time, and page by
+-- descending ID
+DROP INDEX IF EXISTS idx_audit_logs_user_id;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action, id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
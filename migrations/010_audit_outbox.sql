@@ -0,0 +1,10 @@
+-- Audit entries are queued and delivered in batches, possibly more than once;
+-- the event ID makes delivery idempotent
+ALTER TABLE audit_logs
+    ADD COLUMN IF NOT EXISTS event_id VARCHAR(36);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_event_id ON audit_logs(event_id);
+
+-- Failed logins for unknown users have no actor
+ALTER TABLE audit_logs
+    ALTER COLUMN user_id DROP NOT NULL;
@@ -0,0 +1,19 @@
+-- Each audit entry carries a hash over its content and the hash of the entry
+-- before it, so that edited or deleted entries are detected
+ALTER TABLE audit_logs
+    ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS hash VARCHAR(64);
+
+-- Entries outlive the users they are about; deleting them would break the chain
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_user_id_fkey;
+
+-- Signed hashes of the chain at points in time
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id BIGSERIAL PRIMARY KEY,
+    log_id BIGINT NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    signature VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_checkpoints_log_id ON audit_checkpoints(log_id);
@@ -0,0 +1,71 @@
+-- audit_logs is partitioned by month, so that expired months can be archived
+-- and dropped. Instead of being copied, the existing table becomes the
+-- partition of everything before next month; the archiver creates the
+-- partitions of later months ahead of time.
+ALTER TABLE audit_logs RENAME TO audit_logs_legacy;
+ALTER TABLE audit_logs_legacy DROP CONSTRAINT IF EXISTS audit_logs_pkey;
+
+-- Unique indexes of a partitioned table must include the partition key;
+-- event IDs are checked for duplicates while entries are appended
+DROP INDEX IF EXISTS idx_audit_logs_event_id;
+ALTER INDEX IF EXISTS idx_audit_logs_user_id RENAME TO idx_audit_logs_legacy_user_id;
+ALTER INDEX IF EXISTS idx_audit_logs_action RENAME TO idx_audit_logs_legacy_action;
+ALTER INDEX IF EXISTS idx_audit_logs_created_at RENAME TO idx_audit_logs_legacy_created_at;
+ALTER INDEX IF EXISTS idx_audit_logs_target RENAME TO idx_audit_logs_legacy_target;
+ALTER INDEX IF EXISTS idx_audit_logs_change_id RENAME TO idx_audit_logs_legacy_change_id;
+
+CREATE TABLE audit_logs (
+    id BIGINT NOT NULL DEFAULT nextval('audit_logs_id_seq'),
+    user_id BIGINT,
+    action VARCHAR(255) NOT NULL,
+    target_id BIGINT,
+    old_value TEXT,
+    new_value TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    change_id VARCHAR(36),
+    event_id VARCHAR(36),
+    prev_hash VARCHAR(64),
+    hash VARCHAR(64),
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+-- The sequence must survive dropping the legacy partition
+ALTER SEQUENCE audit_logs_id_seq OWNED BY audit_logs.id;
+
+DO $$
+BEGIN
+    EXECUTE format('ALTER TABLE audit_logs ATTACH PARTITION audit_logs_legacy FOR VALUES FROM (MINVALUE) TO (%L)',
+        (date_trunc('month', NOW() AT TIME ZONE 'UTC') + INTERVAL '1 month') AT TIME ZONE 'UTC');
+END $$;
+
+-- Catches entries of months without a partition until the archiver creates it
+CREATE TABLE IF NOT EXISTS audit_logs_default PARTITION OF audit_logs DEFAULT;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action, id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs(target_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_change_id ON audit_logs(change_id) WHERE change_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_event_id ON audit_logs(event_id);
+
+-- Files that removed entries were archived to
+CREATE TABLE IF NOT EXISTS audit_archives (
+    id BIGSERIAL PRIMARY KEY,
+    partition_name VARCHAR(63) NOT NULL,
+    file TEXT NOT NULL,
+    entries BIGINT NOT NULL,
+    first_id BIGINT NOT NULL,
+    last_id BIGINT NOT NULL,
+    dropped BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Runs of archived entries that verification of the hash chain skips
+CREATE TABLE IF NOT EXISTS audit_chain_gaps (
+    id BIGSERIAL PRIMARY KEY,
+    archive_id BIGINT NOT NULL REFERENCES audit_archives(id),
+    from_hash VARCHAR(64) NOT NULL,
+    to_hash VARCHAR(64) NOT NULL,
+    entries BIGINT NOT NULL,
+    signature VARCHAR(64) NOT NULL DEFAULT ''
+);
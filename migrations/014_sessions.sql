@@ -0,0 +1,10 @@
+-- Refresh token families are listed as the user's sessions. Every token
+-- carries the start of its session, since the first token of a family is
+-- purged once it has expired.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_created_at TIMESTAMPTZ;
+UPDATE refresh_tokens t
+SET session_created_at = COALESCE((SELECT f.created_at FROM refresh_tokens f WHERE f.id = t.family_id), t.created_at)
+WHERE session_created_at IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN session_created_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id) WHERE used_at IS NULL AND revoked_at IS NULL;
@@ -0,0 +1,11 @@
+-- Key pairs that tokens are signed with. Private keys are encrypted with
+-- JWT_KEY_ENCRYPTION_KEY.
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    activates_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_signing_keys_activates_at ON signing_keys(activates_at);
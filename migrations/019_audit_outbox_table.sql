@@ -0,0 +1,11 @@
+-- Audit entries wait here until the relay appends them to audit_logs. They
+-- are inserted in the transaction of the change they describe, so that a
+-- change is never stored without its entry. claimed_by is the relay that
+-- took an entry; entries it does not delete within a minute are taken over.
+CREATE TABLE IF NOT EXISTS audit_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    log JSONB NOT NULL,
+    claimed_by TEXT,
+    claimed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
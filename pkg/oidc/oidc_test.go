@@ -0,0 +1,108 @@
+package oidc_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"internal-dns/pkg/oidc"
+	"internal-dns/pkg/oidc/oidctest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	ctx := context.Background()
+	idp := oidctest.NewServer("internal-dns", "secret")
+	defer idp.Close()
+
+	cfg := oidc.Config{
+		IssuerURL:     idp.URL,
+		ClientID:      "internal-dns",
+		ClientSecret:  "secret",
+		RedirectURL:   "https://dns.example.com/api/v1/auth/oidc/callback",
+		Scopes:        []string{"profile", "groups"},
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+	}
+	p := oidc.NewProvider(cfg, idp.Client())
+	claims := map[string]interface{}{"preferred_username": "alice", "groups": []string{"dns-admins", "staff"}}
+
+	login := func(t *testing.T, p oidc.Provider, verifier string) url.Values {
+		authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+		require.NoError(t, err)
+		redirect, err := idp.Authorize(authURL, "subject-1", claims)
+		require.NoError(t, err)
+		return redirect.Query()
+	}
+
+	t.Run("Authorization request", func(t *testing.T) {
+		authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier")
+		require.NoError(t, err)
+		u, err := url.Parse(authURL)
+		require.NoError(t, err)
+		q := u.Query()
+		assert.Equal(t, idp.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+		assert.Equal(t, "openid profile groups", q.Get("scope"))
+		assert.Equal(t, cfg.RedirectURL, q.Get("redirect_uri"))
+		assert.Equal(t, oidc.CodeChallenge("verifier"), q.Get("code_challenge"))
+		assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	})
+
+	t.Run("Exchange verifies the ID token", func(t *testing.T) {
+		q := login(t, p, "verifier")
+		assert.Equal(t, "state-1", q.Get("state"))
+
+		token, err := p.Exchange(ctx, q.Get("code"), "verifier", "nonce-1")
+		require.NoError(t, err)
+		assert.Equal(t, idp.URL, token.Issuer)
+		assert.Equal(t, "subject-1", token.Subject)
+		assert.Equal(t, "alice", token.Username)
+		assert.Equal(t, []string{"dns-admins", "staff"}, token.Groups)
+	})
+
+	t.Run("Codes are redeemed once", func(t *testing.T) {
+		q := login(t, p, "verifier")
+		_, err := p.Exchange(ctx, q.Get("code"), "verifier", "nonce-1")
+		require.NoError(t, err)
+		_, err = p.Exchange(ctx, q.Get("code"), "verifier", "nonce-1")
+		assert.ErrorIs(t, err, oidc.ErrCodeRejected)
+	})
+
+	t.Run("Wrong code verifier", func(t *testing.T) {
+		q := login(t, p, "verifier")
+		_, err := p.Exchange(ctx, q.Get("code"), "other", "nonce-1")
+		assert.ErrorIs(t, err, oidc.ErrCodeRejected)
+	})
+
+	t.Run("Wrong nonce", func(t *testing.T) {
+		q := login(t, p, "verifier")
+		_, err := p.Exchange(ctx, q.Get("code"), "verifier", "nonce-2")
+		assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+	})
+
+	t.Run("Token issued to another client or expired", func(t *testing.T) {
+		for _, override := range []map[string]interface{}{
+			{"aud": "other"},
+			{"aud": []string{"internal-dns", "other"}, "azp": "other"},
+			{"exp": time.Now().Add(-time.Hour).Unix()},
+		} {
+			authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier")
+			require.NoError(t, err)
+			redirect, err := idp.Authorize(authURL, "subject-1", override)
+			require.NoError(t, err)
+
+			_, err = p.Exchange(ctx, redirect.Query().Get("code"), "verifier", "nonce-1")
+			assert.ErrorIs(t, err, oidc.ErrInvalidIDToken, override)
+		}
+	})
+
+	t.Run("Issuer mismatch", func(t *testing.T) {
+		wrong := cfg
+		wrong.IssuerURL = idp.URL + "/"
+		_, err := oidc.NewProvider(wrong, idp.Client()).AuthCodeURL(ctx, "s", "n", "v")
+		assert.ErrorContains(t, err, "reports issuer")
+	})
+}
@@ -0,0 +1,50 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"internal-dns/internal/domain"
+	"math/big"
+)
+
+// JWK is the public part of a signing key as a JSON Web Key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// Ed25519 keys (RFC 8037)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWKS returns the public keys of keys as a JSON Web Key Set.
+func NewJWKS(keys []*domain.SigningKey) (*JWKS, error) {
+	set := &JWKS{Keys: []JWK{}}
+	for _, key := range keys {
+		jwk := JWK{Kid: key.ID, Alg: key.Algorithm, Use: "sig"}
+		switch pub := key.PublicKey().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		default:
+			return nil, fmt.Errorf("signing key %s: unsupported public key type %T", key.ID, pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
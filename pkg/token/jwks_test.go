@@ -0,0 +1,48 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"internal-dns/internal/domain"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewJWKS(t *testing.T) {
+	edKey := newSigningKey(t, domain.SigningAlgorithmEdDSA)
+	rsaKey := newSigningKey(t, domain.SigningAlgorithmRS256)
+
+	set, err := NewJWKS([]*domain.SigningKey{edKey, rsaKey})
+	require.NoError(t, err)
+	require.Len(t, set.Keys, 2)
+
+	ed := set.Keys[0]
+	assert.Equal(t, JWK{Kty: "OKP", Kid: edKey.ID, Alg: "EdDSA", Use: "sig", Crv: "Ed25519", X: ed.X}, ed)
+	x, err := base64.RawURLEncoding.DecodeString(ed.X)
+	require.NoError(t, err)
+	assert.Equal(t, edKey.PublicKey(), ed25519.PublicKey(x))
+
+	rs := set.Keys[1]
+	assert.Equal(t, "RSA", rs.Kty)
+	assert.Equal(t, "RS256", rs.Alg)
+	assert.Equal(t, "AQAB", rs.E)
+	n, err := base64.RawURLEncoding.DecodeString(rs.N)
+	require.NoError(t, err)
+	assert.Zero(t, rsaKey.PublicKey().(*rsa.PublicKey).N.Cmp(new(big.Int).SetBytes(n)))
+
+	// The private parts are not published
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"d"`)
+
+	empty, err := NewJWKS(nil)
+	require.NoError(t, err)
+	data, err = json.Marshal(empty)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"keys":[]}`, string(data))
+}
@@ -1,6 +1,7 @@
 package token
 
 import (
+	"errors"
 	"fmt"
 	"internal-dns/internal/domain"
 	"time"
@@ -8,67 +9,125 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Token types, carried in the "typ" claim.
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
 // Generator defines the interface for JWT token generation and validation.
 type Generator interface {
-	GenerateAccessToken(user *domain.User) (string, error)
-	GenerateRefreshToken(user *domain.User) (string, error)
+	// GenerateAccessToken generates an access token of the session
+	// sessionID, which it carries as its "sid" claim.
+	GenerateAccessToken(user *domain.User, sessionID string) (string, error)
+	// GenerateRefreshToken generates a refresh token that carries id, the ID
+	// of its server-side record, as its "jti" claim.
+	GenerateRefreshToken(user *domain.User, id string) (string, error)
+	// ValidateToken validates an access token.
 	ValidateToken(tokenString string) (*CustomClaims, error)
+	ValidateRefreshToken(tokenString string) (*CustomClaims, error)
+}
+
+// KeySet provides the keys that tokens are signed and verified with.
+type KeySet interface {
+	// SigningKey returns the key that new tokens are signed with.
+	SigningKey() (*domain.SigningKey, error)
+	// VerificationKey returns the key with the ID kid.
+	VerificationKey(kid string) (*domain.SigningKey, error)
 }
 
 // jwtGenerator implements the Generator interface.
 type jwtGenerator struct {
-	secretKey string
+	keys KeySet
 }
 
 // CustomClaims contains custom JWT claims.
 type CustomClaims struct {
-	UserID int64           `json:"user_id"`
-	Role   domain.UserRole `json:"role"`
+	UserID    int64           `json:"user_id"`
+	Role      domain.UserRole `json:"role"`
+	Type      string          `json:"typ"`
+	SessionID string          `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// NewJWTGenerator creates a new JWT generator.
-func NewJWTGenerator(secretKey string) Generator {
-	return &jwtGenerator{secretKey: secretKey}
+// NewJWTGenerator creates a new JWT generator that signs tokens with the
+// current key of keys, and accepts tokens signed with any of its keys.
+func NewJWTGenerator(keys KeySet) Generator {
+	return &jwtGenerator{keys: keys}
 }
 
 // GenerateAccessToken generates a new access token for a user.
-func (g *jwtGenerator) GenerateAccessToken(user *domain.User) (string, error) {
+func (g *jwtGenerator) GenerateAccessToken(user *domain.User, sessionID string) (string, error) {
 	claims := &CustomClaims{
-		UserID: user.ID,
-		Role:   user.Role,
+		UserID:    user.ID,
+		Role:      user.Role,
+		Type:      TypeAccess,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        domain.NewChangeID(),
 			Subject:   user.Username,
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 1)), // 1 hour
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(domain.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(g.secretKey))
+	return g.sign(claims)
 }
 
 // GenerateRefreshToken generates a new refresh token for a user.
-func (g *jwtGenerator) GenerateRefreshToken(user *domain.User) (string, error) {
+func (g *jwtGenerator) GenerateRefreshToken(user *domain.User, id string) (string, error) {
 	claims := &CustomClaims{
 		UserID: user.ID,
 		Role:   user.Role,
+		Type:   TypeRefresh,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
 			Subject:   user.Username,
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 7)), // 7 days
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(domain.RefreshTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(g.secretKey))
+	return g.sign(claims)
 }
 
-// ValidateToken validates a JWT token string.
+// sign signs claims with the current key, and names the key in the "kid"
+// header.
+func (g *jwtGenerator) sign(claims *CustomClaims) (string, error) {
+	key, err := g.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// ValidateToken validates an access token string.
 func (g *jwtGenerator) ValidateToken(tokenString string) (*CustomClaims, error) {
+	return g.validate(tokenString, TypeAccess)
+}
+
+// ValidateRefreshToken validates a refresh token string.
+func (g *jwtGenerator) ValidateRefreshToken(tokenString string) (*CustomClaims, error) {
+	return g.validate(tokenString, TypeRefresh)
+}
+
+// validate validates a JWT token string of the given type, so that refresh
+// tokens cannot be used as access tokens and vice versa.
+func (g *jwtGenerator) validate(tokenString, tokenType string) (*CustomClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("missing key ID")
+		}
+		key, err := g.keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		// The algorithm of the key, not the one the token names, decides
+		if token.Method.Alg() != key.Algorithm {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(g.secretKey), nil
+		return key.PublicKey(), nil
 	})
 
 	if err != nil {
@@ -76,6 +135,9 @@ func (g *jwtGenerator) ValidateToken(tokenString string) (*CustomClaims, error)
 	}
 
 	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
+		if claims.Type != tokenType {
+			return nil, fmt.Errorf("unexpected token type: %q", claims.Type)
+		}
 		return claims, nil
 	}
 
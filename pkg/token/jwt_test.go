@@ -1,17 +1,44 @@
 package token
 
 import (
+	"errors"
 	"internal-dns/internal/domain"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// staticKeySet signs with its last key.
+type staticKeySet []*domain.SigningKey
+
+func (s staticKeySet) SigningKey() (*domain.SigningKey, error) {
+	if len(s) == 0 {
+		return nil, domain.ErrNoSigningKey
+	}
+	return s[len(s)-1], nil
+}
+
+func (s staticKeySet) VerificationKey(kid string) (*domain.SigningKey, error) {
+	for _, key := range s {
+		if key.ID == kid {
+			return key, nil
+		}
+	}
+	return nil, errors.New("unknown key")
+}
+
+func newSigningKey(t testing.TB, algorithm string) *domain.SigningKey {
+	key, err := domain.NewSigningKey(algorithm, time.Now())
+	require.NoError(t, err)
+	return key
+}
+
 func TestJWTGenerator(t *testing.T) {
-	secretKey := "test-secret-key"
-	generator := NewJWTGenerator(secretKey)
+	key := newSigningKey(t, domain.SigningAlgorithmEdDSA)
+	generator := NewJWTGenerator(staticKeySet{key})
 	user := &domain.User{
 		ID:       1,
 		Username: "testuser",
@@ -19,12 +46,14 @@ func TestJWTGenerator(t *testing.T) {
 	}
 
 	t.Run("GenerateAccessToken", func(t *testing.T) {
-		tokenString, err := generator.GenerateAccessToken(user)
+		tokenString, err := generator.GenerateAccessToken(user, "session-id")
 		require.NoError(t, err)
 		assert.NotEmpty(t, tokenString)
 
 		claims, err := generator.ValidateToken(tokenString)
 		require.NoError(t, err)
+		assert.Equal(t, "session-id", claims.SessionID)
+		assert.NotEmpty(t, claims.ID)
 		assert.Equal(t, user.ID, claims.UserID)
 		assert.Equal(t, user.Role, claims.Role)
 		assert.Equal(t, user.Username, claims.Subject)
@@ -32,12 +61,14 @@ func TestJWTGenerator(t *testing.T) {
 	})
 
 	t.Run("GenerateRefreshToken", func(t *testing.T) {
-		tokenString, err := generator.GenerateRefreshToken(user)
+		tokenString, err := generator.GenerateRefreshToken(user, "token-id")
 		require.NoError(t, err)
 		assert.NotEmpty(t, tokenString)
 
-		claims, err := generator.ValidateToken(tokenString)
+		claims, err := generator.ValidateRefreshToken(tokenString)
 		require.NoError(t, err)
+		assert.Equal(t, "token-id", claims.ID)
+		assert.Equal(t, TypeRefresh, claims.Type)
 		assert.Equal(t, user.ID, claims.UserID)
 		assert.Equal(t, user.Role, claims.Role)
 		assert.Equal(t, user.Username, claims.Subject)
@@ -46,14 +77,22 @@ func TestJWTGenerator(t *testing.T) {
 
 	t.Run("ValidateToken", func(t *testing.T) {
 		// Valid token
-		validToken, err := generator.GenerateAccessToken(user)
+		validToken, err := generator.GenerateAccessToken(user, "session-id")
 		require.NoError(t, err)
 		_, err = generator.ValidateToken(validToken)
 		assert.NoError(t, err)
 
+		// Unknown key
+		otherGenerator := NewJWTGenerator(staticKeySet{newSigningKey(t, domain.SigningAlgorithmEdDSA)})
+		invalidToken, err := otherGenerator.GenerateAccessToken(user, "session-id")
+		require.NoError(t, err)
+		_, err = generator.ValidateToken(invalidToken)
+		assert.Error(t, err)
+
 		// Invalid signature
-		otherGenerator := NewJWTGenerator("different-secret")
-		invalidToken, err := otherGenerator.GenerateAccessToken(user)
+		forged := newSigningKey(t, domain.SigningAlgorithmEdDSA)
+		forged.ID = key.ID
+		invalidToken, err = NewJWTGenerator(staticKeySet{forged}).GenerateAccessToken(user, "session-id")
 		require.NoError(t, err)
 		_, err = generator.ValidateToken(invalidToken)
 		assert.Error(t, err)
@@ -62,10 +101,85 @@ func TestJWTGenerator(t *testing.T) {
 		_, err = generator.ValidateToken("not.a.real.token")
 		assert.Error(t, err)
 	})
+
+	t.Run("Token types are not interchangeable", func(t *testing.T) {
+		accessToken, err := generator.GenerateAccessToken(user, "session-id")
+		require.NoError(t, err)
+		refreshToken, err := generator.GenerateRefreshToken(user, "token-id")
+		require.NoError(t, err)
+
+		_, err = generator.ValidateToken(refreshToken)
+		assert.Error(t, err)
+		_, err = generator.ValidateRefreshToken(accessToken)
+		assert.Error(t, err)
+	})
+}
+
+func TestJWTGenerator_KeyRotation(t *testing.T) {
+	user := &domain.User{ID: 1, Username: "testuser", Role: domain.RoleUser}
+	oldKey := newSigningKey(t, domain.SigningAlgorithmRS256)
+	newKey := newSigningKey(t, domain.SigningAlgorithmEdDSA)
+
+	oldToken, err := NewJWTGenerator(staticKeySet{oldKey}).GenerateAccessToken(user, "session-id")
+	require.NoError(t, err)
+
+	// Tokens signed with the previous key stay valid after the rotation
+	generator := NewJWTGenerator(staticKeySet{oldKey, newKey})
+	newToken, err := generator.GenerateAccessToken(user, "session-id")
+	require.NoError(t, err)
+	for kid, tokenString := range map[string]string{oldKey.ID: oldToken, newKey.ID: newToken} {
+		parsed, _, err := jwt.NewParser().ParseUnverified(tokenString, &CustomClaims{})
+		require.NoError(t, err)
+		assert.Equal(t, kid, parsed.Header["kid"])
+
+		claims, err := generator.ValidateToken(tokenString)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, claims.UserID)
+	}
+
+	// And are rejected once it is removed
+	_, err = NewJWTGenerator(staticKeySet{newKey}).ValidateToken(oldToken)
+	assert.Error(t, err)
+}
+
+func TestJWTGenerator_RejectsOtherAlgorithms(t *testing.T) {
+	user := &domain.User{ID: 1, Username: "testuser", Role: domain.RoleUser}
+	key := newSigningKey(t, domain.SigningAlgorithmEdDSA)
+	generator := NewJWTGenerator(staticKeySet{key})
+	claims := &CustomClaims{
+		UserID: user.ID,
+		Role:   domain.RoleAdmin,
+		Type:   TypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+
+	// A token that names a key but is signed with another algorithm
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodHS256, jwt.SigningMethodNone} {
+		forged := jwt.NewWithClaims(method, claims)
+		forged.Header["kid"] = key.ID
+		var signingKey interface{} = []byte("secret")
+		if method == jwt.SigningMethodNone {
+			signingKey = jwt.UnsafeAllowNoneSignatureType
+		}
+		tokenString, err := forged.SignedString(signingKey)
+		require.NoError(t, err)
+
+		_, err = generator.ValidateToken(tokenString)
+		assert.Error(t, err, method.Alg())
+	}
+
+	// A token without a key ID
+	rsaKey := newSigningKey(t, domain.SigningAlgorithmRS256)
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(rsaKey.PrivateKey)
+	require.NoError(t, err)
+	_, err = NewJWTGenerator(staticKeySet{rsaKey}).ValidateToken(tokenString)
+	assert.Error(t, err)
 }
 
 func BenchmarkGenerateAccessToken(b *testing.B) {
-	generator := NewJWTGenerator("benchmark-secret")
+	generator := NewJWTGenerator(staticKeySet{newSigningKey(b, domain.SigningAlgorithmEdDSA)})
 	user := &domain.User{
 		ID:       1,
 		Username: "benchuser",
@@ -73,6 +187,6 @@ func BenchmarkGenerateAccessToken(b *testing.B) {
 	}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = generator.GenerateAccessToken(user)
+		_, _ = generator.GenerateAccessToken(user, "session-id")
 	}
 }
@@ -0,0 +1,68 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA-1 secret of the test vectors of RFC 6238.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	// The last 6 digits of the 8-digit codes of RFC 6238, appendix B
+	for unix, code := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		assert.Equal(t, code, Code(rfcSecret, Step(time.Unix(unix, 0))), unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	t.Run("Current and neighbouring steps", func(t *testing.T) {
+		for _, s := range []int64{step - 1, step, step + 1} {
+			got, ok := Validate(rfcSecret, Code(rfcSecret, s), now)
+			assert.True(t, ok)
+			assert.Equal(t, s, got)
+		}
+	})
+
+	t.Run("Other codes", func(t *testing.T) {
+		for _, code := range []string{Code(rfcSecret, step-2), Code(rfcSecret, step+2), "", "12345", "1234567", "abcdef"} {
+			_, ok := Validate(rfcSecret, code, now)
+			assert.False(t, ok, code)
+		}
+		other, err := GenerateSecret()
+		require.NoError(t, err)
+		_, ok := Validate(other, Code(rfcSecret, step), now)
+		assert.False(t, ok)
+	})
+}
+
+func TestURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, SecretSize)
+
+	u, err := url.Parse(URI("Internal DNS", "alice", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Internal DNS:alice", u.Path)
+	assert.Equal(t, EncodeSecret(secret), u.Query().Get("secret"))
+	assert.Equal(t, "Internal DNS", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+	assert.Equal(t, "30", u.Query().Get("period"))
+	assert.NotContains(t, u.String(), "+")
+}